@@ -3,6 +3,7 @@ package adkernelAdn
 import (
 	"fmt"
 	"net/http"
+	"strconv"
 	"text/template"
 
 	"github.com/golang/glog"
@@ -200,8 +201,8 @@ func (adapter *adkernelAdnAdapter) buildEndpointURL(params *openrtb_ext.ExtImpAd
 	if params.Host != "" {
 		reqHost = params.Host
 	}
-	endpointParams := macros.EndpointTemplateParams{Host: reqHost, PublisherID: params.PublisherID}
-	return macros.ResolveMacros(adapter.EndpointTemplate, endpointParams)
+	endpointParams := macros.EndpointTemplateParams{Host: reqHost, PublisherID: strconv.Itoa(params.PublisherID)}
+	return macros.ResolveEndpoint(adapter.EndpointTemplate, endpointParams)
 }
 
 //MakeBids translates adkernel bid response to prebid-server specific format
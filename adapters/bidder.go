@@ -39,6 +39,19 @@ type Bidder interface {
 	MakeBids(internalRequest *openrtb.BidRequest, externalRequest *RequestData, response *ResponseData) (*BidderResponse, []error)
 }
 
+// TimeoutBidder is a Bidder which wants to hear about the requests to its server which timed out.
+//
+// If the host enables timeout notifications, Prebid Server sends a sample of them asynchronously,
+// after the auction has stopped waiting for the Bidder. Their responses are ignored.
+type TimeoutBidder interface {
+	Bidder
+
+	// MakeTimeoutNotification makes the HTTP request which tells the Bidder's server that the given request timed out.
+	//
+	// A nil request means that there's nothing to send.
+	MakeTimeoutNotification(req *RequestData) (*RequestData, []error)
+}
+
 func BadInput(msg string) *errortypes.BadInput {
 	return &errortypes.BadInput{
 		Message: msg,
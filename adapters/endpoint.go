@@ -0,0 +1,70 @@
+package adapters
+
+import (
+	"strconv"
+
+	"github.com/buger/jsonparser"
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/macros"
+	"github.com/prebid/prebid-server/openrtb_ext"
+)
+
+// NewEndpointTemplateParams fills in the endpoint macros which come from the request, so that Bidders can resolve
+// their endpoint templates per request with macros.ResolveEndpoint. The region is the host's region, from its config.
+//
+// The media type, zone ID and placement ID come from the first imp only, since the endpoint is resolved once for
+// the whole request. Bidders which send each imp to its own endpoint should resolve it for each imp's request.
+// The zone and placement IDs are taken from the zoneId (or zone) and placementId (or placement) params
+// in imp.ext.bidder, if the Bidder has them. Bidders whose params have other names should set them on the result.
+func NewEndpointTemplateParams(request *openrtb.BidRequest, region string) macros.EndpointTemplateParams {
+	params := macros.EndpointTemplateParams{
+		Region: region,
+	}
+	if request.Site != nil && request.Site.Publisher != nil {
+		params.AccountID = request.Site.Publisher.ID
+	} else if request.App != nil && request.App.Publisher != nil {
+		params.AccountID = request.App.Publisher.ID
+	}
+	if request.Regs != nil {
+		if gdpr, err := jsonparser.GetInt(request.Regs.Ext, "gdpr"); err == nil {
+			params.GDPR = strconv.FormatInt(gdpr, 10)
+		}
+		params.USPrivacy, _ = jsonparser.GetString(request.Regs.Ext, "us_privacy")
+	}
+	if request.User != nil {
+		params.GDPRConsent, _ = jsonparser.GetString(request.User.Ext, "consent")
+	}
+	if len(request.Imp) > 0 {
+		imp := &request.Imp[0]
+		params.MediaType = string(impMediaType(imp))
+		params.ZoneID = bidderParam(imp, "zoneId", "zone")
+		params.PlacementID = bidderParam(imp, "placementId", "placement")
+	}
+	return params
+}
+
+// impMediaType returns the first of banner, video, audio or native which the imp has.
+func impMediaType(imp *openrtb.Imp) openrtb_ext.BidType {
+	switch {
+	case imp.Banner != nil:
+		return openrtb_ext.BidTypeBanner
+	case imp.Video != nil:
+		return openrtb_ext.BidTypeVideo
+	case imp.Audio != nil:
+		return openrtb_ext.BidTypeAudio
+	case imp.Native != nil:
+		return openrtb_ext.BidTypeNative
+	}
+	return ""
+}
+
+// bidderParam returns the first of the keys in imp.ext.bidder which is a string or number, or "" if there aren't any.
+func bidderParam(imp *openrtb.Imp, keys ...string) string {
+	for _, key := range keys {
+		value, dataType, _, err := jsonparser.Get(imp.Ext, "bidder", key)
+		if err == nil && (dataType == jsonparser.String || dataType == jsonparser.Number) {
+			return string(value)
+		}
+	}
+	return ""
+}
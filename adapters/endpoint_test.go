@@ -0,0 +1,46 @@
+package adapters
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/macros"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewEndpointTemplateParams(t *testing.T) {
+	request := &openrtb.BidRequest{
+		Site: &openrtb.Site{Publisher: &openrtb.Publisher{ID: "some-account"}},
+		Regs: &openrtb.Regs{Ext: json.RawMessage(`{"gdpr":1,"us_privacy":"1YNN"}`)},
+		User: &openrtb.User{Ext: json.RawMessage(`{"consent":"some-consent"}`)},
+		Imp: []openrtb.Imp{{
+			Video: &openrtb.Video{},
+			Ext:   json.RawMessage(`{"bidder":{"zoneId":"some-zone","placement":123}}`),
+		}, {
+			Banner: &openrtb.Banner{},
+			Ext:    json.RawMessage(`{"bidder":{"zoneId":"other-zone"}}`),
+		}},
+	}
+	assert.Equal(t, macros.EndpointTemplateParams{
+		AccountID:   "some-account",
+		ZoneID:      "some-zone",
+		PlacementID: "123",
+		GDPR:        "1",
+		GDPRConsent: "some-consent",
+		USPrivacy:   "1YNN",
+		MediaType:   "video",
+		Region:      "us-east",
+	}, NewEndpointTemplateParams(request, "us-east"))
+}
+
+func TestNewEndpointTemplateParamsApp(t *testing.T) {
+	request := &openrtb.BidRequest{
+		App: &openrtb.App{Publisher: &openrtb.Publisher{ID: "some-account"}},
+		Imp: []openrtb.Imp{{Native: &openrtb.Native{}, Ext: json.RawMessage(`{"bidder":{"zone":{"id":1}}}`)}},
+	}
+	assert.Equal(t, macros.EndpointTemplateParams{
+		AccountID: "some-account",
+		MediaType: "native",
+	}, NewEndpointTemplateParams(request, ""), "Params which aren't strings or numbers should be ignored")
+}
@@ -0,0 +1,304 @@
+package generic
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"text/template"
+
+	"github.com/buger/jsonparser"
+	"github.com/golang/glog"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/adapters"
+	"github.com/prebid/prebid-server/errortypes"
+	"github.com/prebid/prebid-server/macros"
+	"github.com/prebid/prebid-server/openrtb_ext"
+)
+
+// GenericAdapter is a Bidder for bidders which speak plain OpenRTB 2.5, and which the host configures
+// in the adapters.{bidder}.generic section of its config instead of writing an adapter.
+type GenericAdapter struct {
+	name             string
+	endpointTemplate template.Template
+	headers          http.Header
+	paramsPlacement  string
+	bidTypeExtPath   []string
+	defaultBidType   openrtb_ext.BidType
+	currency         string
+	region           string
+	timeoutEndpoint  string
+}
+
+// Options hold the host's settings for a generic bidder. See config.GenericAdapter for what each of them means.
+type Options struct {
+	Headers         map[string]string
+	ParamsPlacement string
+	BidTypeExtField string
+	DefaultBidType  openrtb_ext.BidType
+	Currency        string
+	TimeoutEndpoint string
+}
+
+// genericParams are the params which generic bidders' endpoint templates can use.
+// Bidders may expect any other params too. Those get passed along as-is.
+type genericParams struct {
+	Host        string      `json:"host"`
+	PublisherID publisherID `json:"publisherId"`
+}
+
+// publisherID is the publisherId param. Bidders may use either strings or numbers for it.
+type publisherID string
+
+func (id *publisherID) UnmarshalJSON(data []byte) error {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	switch v := value.(type) {
+	case string:
+		*id = publisherID(v)
+	case float64:
+		*id = publisherID(string(bytes.TrimSpace(data)))
+	case nil:
+		*id = ""
+	default:
+		return errors.New("publisherId must be a string or a number")
+	}
+	return nil
+}
+
+func (a *GenericAdapter) MakeRequests(request *openrtb.BidRequest) ([]*adapters.RequestData, []error) {
+	var errs []error
+	var params *genericParams
+	imps := make([]openrtb.Imp, 0, len(request.Imp))
+	for _, imp := range request.Imp {
+		impParams, err := a.placeParams(&imp)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if params == nil {
+			params = impParams
+		}
+		imps = append(imps, imp)
+	}
+	if len(imps) == 0 {
+		return nil, errs
+	}
+
+	endpointParams := adapters.NewEndpointTemplateParams(request, a.region)
+	endpointParams.Host = params.Host
+	endpointParams.PublisherID = string(params.PublisherID)
+	endpoint, err := macros.ResolveEndpoint(a.endpointTemplate, endpointParams)
+	if err != nil {
+		return nil, append(errs, err)
+	}
+
+	bidRequest := *request
+	bidRequest.Imp = imps
+	reqJSON, err := jsoniter.Marshal(bidRequest)
+	if err != nil {
+		return nil, append(errs, err)
+	}
+
+	headers := http.Header{}
+	headers.Add("Content-Type", "application/json;charset=utf-8")
+	headers.Add("Accept", "application/json")
+	headers.Add("x-openrtb-version", "2.5")
+	for key, values := range a.headers {
+		headers[key] = values
+	}
+	return []*adapters.RequestData{{
+		Method:  "POST",
+		Uri:     endpoint,
+		Body:    reqJSON,
+		Headers: headers,
+	}}, errs
+}
+
+// MakeTimeoutNotification sends the request which timed out to the bidder's timeout endpoint,
+// so that it can tell which one it was. There's nothing to send if the host didn't configure one.
+func (a *GenericAdapter) MakeTimeoutNotification(req *adapters.RequestData) (*adapters.RequestData, []error) {
+	if a.timeoutEndpoint == "" {
+		return nil, nil
+	}
+	headers := http.Header{}
+	headers.Add("Content-Type", "application/json;charset=utf-8")
+	for key, values := range a.headers {
+		headers[key] = values
+	}
+	return &adapters.RequestData{
+		Method:  "POST",
+		Uri:     a.timeoutEndpoint,
+		Body:    req.Body,
+		Headers: headers,
+	}, nil
+}
+
+// placeParams moves the publisher's params from imp.ext.bidder to wherever this bidder wants them,
+// and returns the ones which the endpoint template can use.
+func (a *GenericAdapter) placeParams(imp *openrtb.Imp) (*genericParams, error) {
+	var impExt map[string]json.RawMessage
+	if err := jsoniter.Unmarshal(imp.Ext, &impExt); err != nil {
+		return nil, &errortypes.BadInput{
+			Message: fmt.Sprintf("imp %s has an invalid ext: %v", imp.ID, err),
+		}
+	}
+	bidderParams, ok := impExt["bidder"]
+	if !ok {
+		return nil, &errortypes.BadInput{
+			Message: fmt.Sprintf("imp %s is missing ext.bidder", imp.ID),
+		}
+	}
+	var params genericParams
+	if err := jsoniter.Unmarshal(bidderParams, &params); err != nil {
+		return nil, &errortypes.BadInput{
+			Message: fmt.Sprintf("imp %s has invalid params. host must be a string, and publisherId must be a string or a number", imp.ID),
+		}
+	}
+
+	switch a.paramsPlacement {
+	case "ext":
+		imp.Ext = bidderParams
+	case "name":
+		delete(impExt, "bidder")
+		impExt[a.name] = bidderParams
+		impExtJSON, err := json.Marshal(impExt)
+		if err != nil {
+			return nil, err
+		}
+		imp.Ext = impExtJSON
+	}
+	return &params, nil
+}
+
+func (a *GenericAdapter) MakeBids(internalRequest *openrtb.BidRequest, externalRequest *adapters.RequestData, response *adapters.ResponseData) (*adapters.BidderResponse, []error) {
+	if response.StatusCode == http.StatusNoContent {
+		return nil, nil
+	}
+
+	if response.StatusCode == http.StatusBadRequest {
+		return nil, []error{&errortypes.BadInput{
+			Message: fmt.Sprintf("Unexpected status code: %d. Run with request.debug = 1 for more info", response.StatusCode),
+		}}
+	}
+
+	if response.StatusCode != http.StatusOK {
+		return nil, []error{&errortypes.BadServerResponse{
+			Message: fmt.Sprintf("Unexpected status code: %d. Run with request.debug = 1 for more info", response.StatusCode),
+		}}
+	}
+
+	var bidResp openrtb.BidResponse
+	if err := jsoniter.Unmarshal(response.Body, &bidResp); err != nil {
+		return nil, []error{&errortypes.BadServerResponse{
+			Message: fmt.Sprintf("Bad server response: %v", err),
+		}}
+	}
+
+	imps := make(map[string]*openrtb.Imp, len(internalRequest.Imp))
+	for i := range internalRequest.Imp {
+		imps[internalRequest.Imp[i].ID] = &internalRequest.Imp[i]
+	}
+
+	var errs []error
+	bidResponse := adapters.NewBidderResponseWithBidsCapacity(len(internalRequest.Imp))
+	bidResponse.Currency = bidResp.Cur
+	if bidResponse.Currency == "" {
+		bidResponse.Currency = a.currency
+	}
+	for _, seatBid := range bidResp.SeatBid {
+		for i := range seatBid.Bid {
+			bid := &seatBid.Bid[i]
+			bidType, err := a.bidType(bid, imps[bid.ImpID])
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			bidResponse.Bids = append(bidResponse.Bids, &adapters.TypedBid{
+				Bid:     bid,
+				BidType: bidType,
+			})
+		}
+	}
+	return bidResponse, errs
+}
+
+// bidType returns the bid's media type from bid.ext, if the bidder is configured to put it there.
+// Otherwise, it's the imp's media type. Bids on multiformat imps get the default bid type if the imp has it,
+// or else the first of banner, video, audio or native which the imp has.
+func (a *GenericAdapter) bidType(bid *openrtb.Bid, imp *openrtb.Imp) (openrtb_ext.BidType, error) {
+	if imp == nil {
+		return "", &errortypes.BadServerResponse{
+			Message: fmt.Sprintf("bid %s is for imp %s, which wasn't in the request", bid.ID, bid.ImpID),
+		}
+	}
+	if len(a.bidTypeExtPath) > 0 {
+		if value, err := jsonparser.GetString(bid.Ext, a.bidTypeExtPath...); err == nil {
+			if bidType, err := openrtb_ext.ParseBidType(value); err == nil {
+				return bidType, nil
+			}
+		}
+	}
+
+	var impTypes []openrtb_ext.BidType
+	if imp.Banner != nil {
+		impTypes = append(impTypes, openrtb_ext.BidTypeBanner)
+	}
+	if imp.Video != nil {
+		impTypes = append(impTypes, openrtb_ext.BidTypeVideo)
+	}
+	if imp.Audio != nil {
+		impTypes = append(impTypes, openrtb_ext.BidTypeAudio)
+	}
+	if imp.Native != nil {
+		impTypes = append(impTypes, openrtb_ext.BidTypeNative)
+	}
+	if len(impTypes) == 0 {
+		return "", &errortypes.BadServerResponse{
+			Message: fmt.Sprintf("bid %s is for imp %s, which has no media types", bid.ID, bid.ImpID),
+		}
+	}
+	for _, impType := range impTypes {
+		if impType == a.defaultBidType {
+			return impType, nil
+		}
+	}
+	return impTypes[0], nil
+}
+
+// NewGenericBidder makes a Bidder for the bidder with the given name, which the host configured in its
+// adapters.{bidder} config. The endpoint is a template which can use any of the macros.EndpointTemplateParams.
+// Host and PublisherID get their values from the "host" and "publisherId" params of the first imp, and Region
+// is the host's region.
+func NewGenericBidder(name string, endpoint string, region string, opts Options) adapters.Bidder {
+	endpointTemplate, err := template.New("endpointTemplate").Parse(endpoint)
+	if err != nil {
+		glog.Fatalf("Unable to parse endpoint url template for generic bidder %s: %v", name, err)
+		return nil
+	}
+
+	headers := http.Header{}
+	for key, value := range opts.Headers {
+		headers.Set(key, value)
+	}
+	var bidTypeExtPath []string
+	if opts.BidTypeExtField != "" {
+		bidTypeExtPath = strings.Split(opts.BidTypeExtField, ".")
+	}
+	return &GenericAdapter{
+		name:             name,
+		endpointTemplate: *endpointTemplate,
+		headers:          headers,
+		paramsPlacement:  opts.ParamsPlacement,
+		bidTypeExtPath:   bidTypeExtPath,
+		defaultBidType:   opts.DefaultBidType,
+		currency:         opts.Currency,
+		region:           region,
+		timeoutEndpoint:  opts.TimeoutEndpoint,
+	}
+}
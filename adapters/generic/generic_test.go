@@ -0,0 +1,91 @@
+package generic
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/adapters"
+	"github.com/prebid/prebid-server/adapters/adapterstest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJsonSamples(t *testing.T) {
+	adapterstest.RunJSONBidderTest(t, "generictest", NewGenericBidder("somessp", "http://{{.Host}}.some-ssp.com/bid?pub={{.PublisherID}}&placement={{.PlacementID}}&region={{.Region}}", "eu", Options{
+		Headers:         map[string]string{"x-api-key": "some-key"},
+		BidTypeExtField: "prebid.type",
+		DefaultBidType:  "video",
+		Currency:        "EUR",
+	}))
+}
+
+func TestHeaders(t *testing.T) {
+	bidder := NewGenericBidder("somessp", "http://some-ssp.com/bid", "", Options{
+		Headers: map[string]string{"x-api-key": "some-key"},
+	})
+	reqs, errs := bidder.MakeRequests(&openrtb.BidRequest{
+		Imp: []openrtb.Imp{{ID: "some-imp", Ext: json.RawMessage(`{"bidder":{}}`)}},
+	})
+	assert.Empty(t, errs)
+	if assert.Len(t, reqs, 1) {
+		assert.Equal(t, "some-key", reqs[0].Headers.Get("X-Api-Key"))
+		assert.Equal(t, "2.5", reqs[0].Headers.Get("X-Openrtb-Version"))
+		assert.Equal(t, "application/json;charset=utf-8", reqs[0].Headers.Get("Content-Type"))
+	}
+}
+
+func TestParamsPlacement(t *testing.T) {
+	testCases := []struct {
+		placement   string
+		expectedExt string
+	}{
+		{placement: "", expectedExt: `{"bidder":{"publisherId":1},"prebid":{"is_rewarded_inventory":1}}`},
+		{placement: "bidder", expectedExt: `{"bidder":{"publisherId":1},"prebid":{"is_rewarded_inventory":1}}`},
+		{placement: "ext", expectedExt: `{"publisherId":1}`},
+		{placement: "name", expectedExt: `{"somessp":{"publisherId":1},"prebid":{"is_rewarded_inventory":1}}`},
+	}
+	for _, test := range testCases {
+		bidder := NewGenericBidder("somessp", "http://some-ssp.com/bid", "", Options{ParamsPlacement: test.placement})
+		request := &openrtb.BidRequest{
+			Imp: []openrtb.Imp{{
+				ID:  "some-imp",
+				Ext: json.RawMessage(`{"bidder":{"publisherId":1},"prebid":{"is_rewarded_inventory":1}}`),
+			}},
+		}
+		reqs, errs := bidder.MakeRequests(request)
+		assert.Empty(t, errs, "placement %s", test.placement)
+		if assert.Len(t, reqs, 1, "placement %s", test.placement) {
+			var sent openrtb.BidRequest
+			assert.NoError(t, json.Unmarshal(reqs[0].Body, &sent))
+			assert.JSONEq(t, test.expectedExt, string(sent.Imp[0].Ext), "placement %s", test.placement)
+		}
+	}
+}
+
+func TestMakeTimeoutNotification(t *testing.T) {
+	bidder := NewGenericBidder("somessp", "http://some-ssp.com/bid", "", Options{
+		Headers:         map[string]string{"x-api-key": "some-key"},
+		TimeoutEndpoint: "http://some-ssp.com/timeout",
+	})
+	timeoutBidder, ok := bidder.(adapters.TimeoutBidder)
+	if !assert.True(t, ok, "Generic bidders should make timeout notifications") {
+		return
+	}
+	notification, errs := timeoutBidder.MakeTimeoutNotification(&adapters.RequestData{
+		Method: "POST",
+		Uri:    "http://some-ssp.com/bid",
+		Body:   []byte(`{"id":"some-request"}`),
+	})
+	assert.Empty(t, errs)
+	if assert.NotNil(t, notification) {
+		assert.Equal(t, "POST", notification.Method)
+		assert.Equal(t, "http://some-ssp.com/timeout", notification.Uri)
+		assert.JSONEq(t, `{"id":"some-request"}`, string(notification.Body), "The notification should say which request timed out")
+		assert.Equal(t, "some-key", notification.Headers.Get("X-Api-Key"))
+	}
+
+	bidder = NewGenericBidder("somessp", "http://some-ssp.com/bid", "", Options{})
+	notification, errs = bidder.(adapters.TimeoutBidder).MakeTimeoutNotification(&adapters.RequestData{})
+	assert.Nil(t, notification, "There's nothing to send without a timeout endpoint")
+	assert.Empty(t, errs)
+}
@@ -3,6 +3,7 @@ package adapters
 import (
 	"fmt"
 	"io/ioutil"
+	"strings"
 
 	"github.com/golang/glog"
 	"github.com/mxmCherry/openrtb"
@@ -20,6 +21,8 @@ import (
 //      to nil before the request is forwarded to the delegate.
 //   3. Any Imps which have no MediaTypes left will be removed.
 //   4. If there are no valid Imps left, the delegate won't be called at all.
+//   5. If the bidder declares OpenRTB 2.6, the fields which Prebid Server keeps in their OpenRTB 2.5 ext locations
+//      are moved to their OpenRTB 2.6 locations in the bodies of the delegate's requests.
 func EnforceBidderInfo(bidder Bidder, info BidderInfo) Bidder {
 	return &InfoAwareBidder{
 		Bidder: bidder,
@@ -59,7 +62,28 @@ func (i *InfoAwareBidder) MakeRequests(request *openrtb.BidRequest) ([]*RequestD
 		errs = append(errs, newErrs...)
 	}
 	reqs, delegateErrs := i.Bidder.MakeRequests(request)
-	return reqs, append(errs, delegateErrs...)
+	errs = append(errs, delegateErrs...)
+	if i.info.openrtbVersion == openrtb_ext.OpenRTBVersion26 {
+		errs = append(errs, convertToORTB26(reqs)...)
+	}
+	return reqs, errs
+}
+
+// convertToORTB26 moves the OpenRTB 2.6 fields in the JSON bodies of the requests out of their ext locations.
+// Requests whose bodies can't be converted are sent as they are.
+func convertToORTB26(reqs []*RequestData) []error {
+	var errs []error
+	for _, req := range reqs {
+		if req == nil || len(req.Body) == 0 {
+			continue
+		}
+		if body, err := openrtb_ext.MoveExtFieldsToORTB26(req.Body); err != nil {
+			errs = append(errs, BadInput(fmt.Sprintf("the request could not be converted to OpenRTB 2.6: %v", err)))
+		} else {
+			req.Body = body
+		}
+	}
+	return errs
 }
 
 // pruneImps trims invalid media types from each imp, and returns true if any of the
@@ -127,12 +151,18 @@ func (i *InfoAwareBidder) filterImps(imps []openrtb.Imp, numToFilter int) ([]ope
 type BidderInfos map[string]BidderInfo
 
 // ParseBidderInfos reads all the static/bidder-info/{bidder}.yaml files from the filesystem.
-// The map it returns will have a key for every element of the bidders array.
+// The map it returns will have a key for every element of the bidders array. Generic bidders don't have files,
+// so they mustn't be in the array. Their info comes from the host's config instead. Host aliases get the info
+// of the bidder they're an alias of, which the host's config can override.
 // If a {bidder}.yaml file does not exist for some bidder, it will panic.
 func ParseBidderInfos(infoDir string, bidders []openrtb_ext.BidderName) BidderInfos {
 	bidderInfos := make(map[string]BidderInfo, len(bidders))
 	for _, bidderName := range bidders {
 		bidderString := string(bidderName)
+		aliasOf, isAlias := openrtb_ext.HostAliasOf(bidderName)
+		if isAlias {
+			bidderString = string(aliasOf)
+		}
 		fileData, err := ioutil.ReadFile(infoDir + "/" + bidderString + ".yaml")
 		if err != nil {
 			glog.Fatalf("error reading from file %s: %v", infoDir+"/"+bidderString+".yaml", err)
@@ -142,7 +172,16 @@ func ParseBidderInfos(infoDir string, bidders []openrtb_ext.BidderName) BidderIn
 		if err := yaml.Unmarshal(fileData, &parsedInfo); err != nil {
 			glog.Fatalf("error parsing yaml in file %s: %v", infoDir+"/"+bidderString+".yaml", err)
 		}
-		bidderInfos[bidderString] = parsedInfo
+		if err := parsedInfo.validateOpenRTBVersion(); err != nil {
+			glog.Fatalf("error in file %s: %v", infoDir+"/"+bidderString+".yaml", err)
+		}
+		if parsedInfo.MaxImpsPerRequest < 0 {
+			glog.Fatalf("error in file %s: max_imps_per_request must not be negative. Got %d", infoDir+"/"+bidderString+".yaml", parsedInfo.MaxImpsPerRequest)
+		}
+		if isAlias {
+			parsedInfo.AliasOf = string(aliasOf)
+		}
+		bidderInfos[string(bidderName)] = parsedInfo
 	}
 	return bidderInfos
 }
@@ -167,6 +206,36 @@ type BidderInfo struct {
 	Maintainer   *MaintainerInfo   `yaml:"maintainer" json:"maintainer"`
 	Capabilities *CapabilitiesInfo `yaml:"capabilities" json:"capabilities"`
 	AliasOf      string            `json:"aliasOf,omitempty"`
+	// OpenRTBVersion is the version of OpenRTB which the bidder's server expects. It's 2.5 if undefined.
+	OpenRTBVersion string `yaml:"openrtb_version" json:"openrtbVersion,omitempty"`
+	// MaxImpsPerRequest is the most imps which the bidder can handle in one request. It's unlimited if undefined.
+	// Requests with more imps are split into chunks, and the bidder's MakeRequests is called for them concurrently.
+	// The chunks have their own imps, but share everything else, like the Site, App, User, Device and Ext.
+	// MakeRequests can replace those fields on its request, but must not change the objects which they point to.
+	MaxImpsPerRequest int `yaml:"max_imps_per_request" json:"maxImpsPerRequest,omitempty"`
+	// RegionMacro maps the host's regions to the values which the bidder's endpoints get for the {{.Region}} macro.
+	// Regions which it doesn't list use their own names. It's left out of the /info/bidders responses,
+	// since it only matters to the host.
+	RegionMacro map[string]string `yaml:"region_macro" json:"-"`
+}
+
+// RegionMacroValue returns the value of the {{.Region}} macro in the bidder's endpoints, in the given host region.
+func (info BidderInfo) RegionMacroValue(region string) string {
+	for hostRegion, value := range info.RegionMacro {
+		if strings.EqualFold(hostRegion, region) {
+			return value
+		}
+	}
+	return region
+}
+
+func (info BidderInfo) validateOpenRTBVersion() error {
+	switch info.OpenRTBVersion {
+	case "", openrtb_ext.OpenRTBVersion25, openrtb_ext.OpenRTBVersion26:
+		return nil
+	default:
+		return fmt.Errorf("openrtb_version must be %s or %s. Got %s", openrtb_ext.OpenRTBVersion25, openrtb_ext.OpenRTBVersion26, info.OpenRTBVersion)
+	}
 }
 
 type MaintainerInfo struct {
@@ -176,6 +245,14 @@ type MaintainerInfo struct {
 type CapabilitiesInfo struct {
 	App  *PlatformInfo `yaml:"app" json:"app"`
 	Site *PlatformInfo `yaml:"site" json:"site"`
+	// MultiFormat is false if the bidder can't handle imps with more than one media type.
+	// It's true if undefined.
+	MultiFormat *bool `yaml:"multiformat" json:"multiformat,omitempty"`
+}
+
+// SupportsMultiFormat returns true if the bidder can handle imps with more than one media type.
+func (info *CapabilitiesInfo) SupportsMultiFormat() bool {
+	return info == nil || info.MultiFormat == nil || *info.MultiFormat
 }
 
 type PlatformInfo struct {
@@ -193,8 +270,9 @@ func containsMediaType(haystack []openrtb_ext.BidType, needle openrtb_ext.BidTyp
 
 // Structs to handle parsed bidder info, so we aren't reparsing every request
 type parsedBidderInfo struct {
-	app  parsedSupports
-	site parsedSupports
+	app            parsedSupports
+	site           parsedSupports
+	openrtbVersion string
 }
 
 type parsedSupports struct {
@@ -206,7 +284,7 @@ type parsedSupports struct {
 }
 
 func parseBidderInfo(info BidderInfo) parsedBidderInfo {
-	var parsedInfo parsedBidderInfo
+	parsedInfo := parsedBidderInfo{openrtbVersion: info.OpenRTBVersion}
 	if info.Capabilities.App != nil {
 		parsedInfo.app.enabled = true
 		parsedInfo.app.banner, parsedInfo.app.video, parsedInfo.app.audio, parsedInfo.app.native = parseAllowedTypes(info.Capabilities.App.MediaTypes)
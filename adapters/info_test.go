@@ -111,6 +111,44 @@ func TestImpFiltering(t *testing.T) {
 	assert.Nil(t, req.Imp[1].Native)
 }
 
+func TestOpenRTB26Conversion(t *testing.T) {
+	body := []byte(`{"id":"some-id","regs":{"ext":{"gdpr":1}},"user":{"ext":{"consent":"some-consent"}}}`)
+	info := adapters.BidderInfo{
+		Capabilities: &adapters.CapabilitiesInfo{
+			Site: &adapters.PlatformInfo{
+				MediaTypes: []openrtb_ext.BidType{openrtb_ext.BidTypeBanner},
+			},
+		},
+	}
+
+	info.OpenRTBVersion = "2.6"
+	reqs, errs := adapters.EnforceBidderInfo(&bodyBidder{body: body}, info).MakeRequests(&openrtb.BidRequest{Site: &openrtb.Site{}})
+	assert.Empty(t, errs)
+	if assert.Len(t, reqs, 1) {
+		assert.JSONEq(t, `{"id":"some-id","regs":{"gdpr":1},"user":{"consent":"some-consent"}}`, string(reqs[0].Body))
+	}
+
+	info.OpenRTBVersion = "2.5"
+	reqs, errs = adapters.EnforceBidderInfo(&bodyBidder{body: body}, info).MakeRequests(&openrtb.BidRequest{Site: &openrtb.Site{}})
+	assert.Empty(t, errs)
+	if assert.Len(t, reqs, 1) {
+		assert.Equal(t, string(body), string(reqs[0].Body), "OpenRTB 2.5 bidders should get the ext locations")
+	}
+}
+
+// bodyBidder makes a single request with the given body.
+type bodyBidder struct {
+	body []byte
+}
+
+func (b *bodyBidder) MakeRequests(request *openrtb.BidRequest) ([]*adapters.RequestData, []error) {
+	return []*adapters.RequestData{{Method: "POST", Uri: "http://bidder.com", Body: b.body}}, nil
+}
+
+func (b *bodyBidder) MakeBids(internalRequest *openrtb.BidRequest, externalRequest *adapters.RequestData, response *adapters.ResponseData) (*adapters.BidderResponse, []error) {
+	return nil, nil
+}
+
 type mockBidder struct {
 	gotRequest *openrtb.BidRequest
 }
@@ -143,3 +181,13 @@ func TestParsing(t *testing.T) {
 	assert.Equal(t, false, infos.SupportsWebMediaType(mockBidderName, openrtb_ext.BidTypeAudio))
 	assert.Equal(t, true, infos.SupportsWebMediaType(mockBidderName, openrtb_ext.BidTypeNative))
 }
+
+func TestRegionMacroValue(t *testing.T) {
+	info := adapters.BidderInfo{
+		RegionMacro: map[string]string{"us-east": "use", "EU-West": "euw"},
+	}
+	assert.Equal(t, "use", info.RegionMacroValue("us-east"))
+	assert.Equal(t, "euw", info.RegionMacroValue("eu-west"), "Regions should match without regard to case")
+	assert.Equal(t, "apac", info.RegionMacroValue("apac"), "Unlisted regions should use their own names")
+	assert.Equal(t, "apac", adapters.BidderInfo{}.RegionMacroValue("apac"))
+}
@@ -1,6 +1,7 @@
 package adapters
 
 import (
+	"fmt"
 	"text/template"
 
 	"github.com/prebid/prebid-server/macros"
@@ -21,28 +22,50 @@ func GDPRAwareSyncerIDs(syncers map[openrtb_ext.BidderName]usersync.Usersyncer)
 type Syncer struct {
 	familyName   string
 	gdprVendorID uint16
-	urlTemplate  *template.Template
-	syncType     SyncType
+	// syncTypes are the supported types of syncs, with the preferred one first.
+	syncTypes    []SyncType
+	urlTemplates map[SyncType]*template.Template
 }
 
 func NewSyncer(familyName string, vendorID uint16, urlTemplate *template.Template, syncType SyncType) *Syncer {
 	return &Syncer{
 		familyName:   familyName,
 		gdprVendorID: vendorID,
-		urlTemplate:  urlTemplate,
-		syncType:     syncType,
+		syncTypes:    []SyncType{syncType},
+		urlTemplates: map[SyncType]*template.Template{syncType: urlTemplate},
 	}
 }
 
-type SyncType string
+type SyncType = usersync.SyncType
 
 const (
-	SyncTypeRedirect SyncType = "redirect"
-	SyncTypeIframe   SyncType = "iframe"
+	SyncTypeRedirect = usersync.SyncTypeRedirect
+	SyncTypeIframe   = usersync.SyncTypeIframe
 )
 
+// AddSyncType lets the Syncer do another type of sync, for pages which don't allow the preferred one.
+// If the Syncer already supports the type, its URL is replaced.
+func (s *Syncer) AddSyncType(syncType SyncType, urlTemplate *template.Template) {
+	if _, ok := s.urlTemplates[syncType]; !ok {
+		s.syncTypes = append(s.syncTypes, syncType)
+	}
+	s.urlTemplates[syncType] = urlTemplate
+}
+
+func (s *Syncer) SyncTypes() []SyncType {
+	return s.syncTypes
+}
+
 func (s *Syncer) GetUsersyncInfo(gdpr string, consent string) (*usersync.UsersyncInfo, error) {
-	userSyncURL, err := macros.ResolveMacros(*s.urlTemplate, macros.UserSyncTemplateParams{
+	return s.GetUsersyncInfoForType(s.syncTypes[0], gdpr, consent)
+}
+
+func (s *Syncer) GetUsersyncInfoForType(syncType SyncType, gdpr string, consent string) (*usersync.UsersyncInfo, error) {
+	urlTemplate, ok := s.urlTemplates[syncType]
+	if !ok {
+		return nil, fmt.Errorf("%s doesn't support %s syncs", s.familyName, syncType)
+	}
+	userSyncURL, err := macros.ResolveMacros(*urlTemplate, macros.UserSyncTemplateParams{
 		GDPR:        gdpr,
 		GDPRConsent: consent,
 	})
@@ -52,7 +75,7 @@ func (s *Syncer) GetUsersyncInfo(gdpr string, consent string) (*usersync.Usersyn
 
 	return &usersync.UsersyncInfo{
 		URL:         userSyncURL,
-		Type:        string(s.syncType),
+		Type:        string(syncType),
 		SupportCORS: false,
 	}, err
 }
@@ -0,0 +1,29 @@
+package adapters
+
+import (
+	"testing"
+	"text/template"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSyncerTypes(t *testing.T) {
+	syncer := NewSyncer("bidder", 0, template.Must(template.New("sync").Parse("https://redirect.com?gdpr={{.GDPR}}")), SyncTypeRedirect)
+	assert.Equal(t, []SyncType{SyncTypeRedirect}, syncer.SyncTypes())
+
+	_, err := syncer.GetUsersyncInfoForType(SyncTypeIframe, "1", "")
+	assert.Error(t, err, "iframe syncs shouldn't be supported yet")
+
+	syncer.AddSyncType(SyncTypeIframe, template.Must(template.New("sync").Parse("https://iframe.com?gdpr={{.GDPR}}")))
+	assert.Equal(t, []SyncType{SyncTypeRedirect, SyncTypeIframe}, syncer.SyncTypes())
+
+	syncInfo, err := syncer.GetUsersyncInfo("1", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://redirect.com?gdpr=1", syncInfo.URL)
+	assert.Equal(t, "redirect", syncInfo.Type)
+
+	syncInfo, err = syncer.GetUsersyncInfoForType(SyncTypeIframe, "1", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://iframe.com?gdpr=1", syncInfo.URL)
+	assert.Equal(t, "iframe", syncInfo.Type)
+}
@@ -5,14 +5,15 @@ import (
 	"github.com/prebid/prebid-server/analytics"
 	"github.com/prebid/prebid-server/analytics/filesystem"
 	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/privacy"
 )
 
 //Modules that need to be logged to need to be initialized here
 func NewPBSAnalytics(analytics *config.Analytics) analytics.PBSAnalyticsModule {
-	modules := make(enabledAnalytics, 0)
+	modules := make(enabledAnalytics)
 	if len(analytics.File.Filename) > 0 {
 		if mod, err := filesystem.NewFileLogger(analytics.File.Filename); err == nil {
-			modules = append(modules, mod)
+			modules["filelogger"] = mod
 		} else {
 			glog.Fatalf("Could not initialize FileLogger for file %v :%v", analytics.File.Filename, err)
 		}
@@ -20,12 +21,17 @@ func NewPBSAnalytics(analytics *config.Analytics) analytics.PBSAnalyticsModule {
 	return modules
 }
 
-//Collection of all the correctly configured analytics modules - implements the PBSAnalyticsModule interface
-type enabledAnalytics []analytics.PBSAnalyticsModule
+//Collection of all the correctly configured analytics modules, keyed by name - implements the PBSAnalyticsModule interface
+//
+//The names are used as the component name for the reportAnalytics privacy activity.
+type enabledAnalytics map[string]analytics.PBSAnalyticsModule
 
 func (ea enabledAnalytics) LogAuctionObject(ao *analytics.AuctionObject) {
-	for _, module := range ea {
-		module.LogAuctionObject(ao)
+	activityRequest := privacy.NewActivityRequest(ao.Request)
+	for name, module := range ea {
+		if allowReporting(ao.ActivityControl, name, activityRequest) {
+			module.LogAuctionObject(ao)
+		}
 	}
 }
 
@@ -42,7 +48,24 @@ func (ea enabledAnalytics) LogSetUIDObject(so *analytics.SetUIDObject) {
 }
 
 func (ea enabledAnalytics) LogAmpObject(ao *analytics.AmpObject) {
+	activityRequest := privacy.NewActivityRequest(ao.Request)
+	for name, module := range ea {
+		if allowReporting(ao.ActivityControl, name, activityRequest) {
+			module.LogAmpObject(ao)
+		}
+	}
+}
+
+// LogShadowObject sends the object to the modules which log shadow traffic
+func (ea enabledAnalytics) LogShadowObject(so *analytics.ShadowObject) {
 	for _, module := range ea {
-		module.LogAmpObject(ao)
+		if shadowModule, ok := module.(analytics.ShadowModule); ok {
+			shadowModule.LogShadowObject(so)
+		}
 	}
 }
+
+func allowReporting(activityControl privacy.ActivityControl, module string, activityRequest privacy.ActivityRequest) bool {
+	component := privacy.Component{Type: privacy.ComponentTypeAnalytics, Name: module}
+	return activityControl.Allow(privacy.ActivityReportAnalytics, component, activityRequest)
+}
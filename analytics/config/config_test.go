@@ -8,6 +8,7 @@ import (
 	"github.com/mxmCherry/openrtb"
 	"github.com/prebid/prebid-server/analytics"
 	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/privacy"
 )
 
 const TEST_DIR string = "testFiles"
@@ -47,6 +48,30 @@ func TestSampleModule(t *testing.T) {
 	}
 }
 
+func TestReportAnalyticsActivity(t *testing.T) {
+	var count int
+	am := initAnalytics(&count)
+	deny := false
+	activityControl := privacy.NewActivityControl(&config.Privacy{
+		Activities: config.Activities{
+			ReportAnalytics: config.Activity{
+				Default: &deny,
+			},
+		},
+	}, "")
+
+	am.LogAuctionObject(&analytics.AuctionObject{
+		Request:         &openrtb.BidRequest{},
+		ActivityControl: activityControl,
+	})
+	am.LogAmpObject(&analytics.AmpObject{
+		ActivityControl: activityControl,
+	})
+	if count != 0 {
+		t.Errorf("The reportAnalytics activity should have stopped the module from logging. Got %d calls", count)
+	}
+}
+
 type sampleModule struct {
 	count *int
 }
@@ -60,11 +85,34 @@ func (m *sampleModule) LogSetUIDObject(so *analytics.SetUIDObject) { *m.count++
 func (m *sampleModule) LogAmpObject(ao *analytics.AmpObject) { *m.count++ }
 
 func initAnalytics(count *int) analytics.PBSAnalyticsModule {
-	modules := make(enabledAnalytics, 0)
-	modules = append(modules, &sampleModule{count})
+	modules := enabledAnalytics{"sample": &sampleModule{count}}
 	return &modules
 }
 
+func TestLogShadowObject(t *testing.T) {
+	var count, shadowCount int
+	modules := enabledAnalytics{
+		"sample": &sampleModule{&count},
+		"shadow": &sampleShadowModule{sampleModule{&count}, &shadowCount},
+	}
+	var am analytics.PBSAnalyticsModule = modules
+	shadowModule, ok := am.(analytics.ShadowModule)
+	if !ok {
+		t.Fatalf("The enabled modules should log shadow objects")
+	}
+	shadowModule.LogShadowObject(&analytics.ShadowObject{Bidder: "appnexus"})
+	if shadowCount != 1 || count != 0 {
+		t.Errorf("Only the modules which log shadow objects should get them. Got %d shadow calls and %d others", shadowCount, count)
+	}
+}
+
+type sampleShadowModule struct {
+	sampleModule
+	shadowCount *int
+}
+
+func (m *sampleShadowModule) LogShadowObject(so *analytics.ShadowObject) { *m.shadowCount++ }
+
 func TestNewPBSAnalytics(t *testing.T) {
 	if _, err := os.Stat(TEST_DIR); os.IsNotExist(err) {
 		if err = os.MkdirAll(TEST_DIR, 0755); err != nil {
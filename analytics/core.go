@@ -2,6 +2,7 @@ package analytics
 
 import (
 	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/privacy"
 	"github.com/prebid/prebid-server/usersync"
 )
 
@@ -20,12 +21,20 @@ type PBSAnalyticsModule interface {
 	LogAmpObject(*AmpObject)
 }
 
+// ShadowModule is implemented by analytics modules which log the requests that were mirrored to bidders'
+// shadow endpoints. It's optional, so that modules which don't care about shadow traffic don't have to change.
+type ShadowModule interface {
+	LogShadowObject(*ShadowObject)
+}
+
 //Loggable object of a transaction at /openrtb2/auction endpoint
 type AuctionObject struct {
 	Status   int
 	Errors   []error
 	Request  *openrtb.BidRequest
 	Response *openrtb.BidResponse
+	// ActivityControl decides which modules may report on the Request. The zero value allows all of them.
+	ActivityControl privacy.ActivityControl
 }
 
 //Loggable object of a transaction at /openrtb2/amp endpoint
@@ -36,6 +45,28 @@ type AmpObject struct {
 	AuctionResponse    *openrtb.BidResponse
 	AmpTargetingValues map[string]string
 	Origin             string
+	// ActivityControl decides which modules may report on the Request. The zero value allows all of them.
+	ActivityControl privacy.ActivityControl
+}
+
+// Loggable object of a bidder request which was mirrored to the bidder's shadow endpoint. It only has
+// the results of the two calls, so that it carries none of the user's data.
+type ShadowObject struct {
+	Bidder     string
+	Production ShadowCall
+	Shadow     ShadowCall
+}
+
+// ShadowCall describes the call to one of a bidder's endpoints for a ShadowObject
+type ShadowCall struct {
+	Uri string
+	// Status is the HTTP status of the response, or 0 if there wasn't one
+	Status    int
+	LatencyMS int64
+	Bids      int
+	// Price is the total CPM of the bids, before any currency conversions or bid adjustments
+	Price float64
+	Error string
 }
 
 //Loggable object of a transaction at /setuid
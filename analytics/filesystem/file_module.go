@@ -16,6 +16,7 @@ const (
 	AUCTION     RequestType = "/openrtb2/auction"
 	SETUID      RequestType = "/set_uid"
 	AMP         RequestType = "/openrtb2/amp"
+	SHADOW      RequestType = "shadow"
 )
 
 //Module that can perform transactional logging
@@ -62,6 +63,17 @@ func (f *FileLogger) LogAmpObject(ao *analytics.AmpObject) {
 	f.Logger.Flush()
 }
 
+//Logs ShadowObject to file
+func (f *FileLogger) LogShadowObject(so *analytics.ShadowObject) {
+	if so == nil {
+		return
+	}
+	var b bytes.Buffer
+	b.WriteString(jsonifyShadowObject(so))
+	f.Logger.Debug(b.String())
+	f.Logger.Flush()
+}
+
 //Method to initialize the analytic module
 func NewFileLogger(filename string) (analytics.PBSAnalyticsModule, error) {
 	options := glog.LogOptions{
@@ -149,3 +161,21 @@ func jsonifyAmpObject(ao *analytics.AmpObject) string {
 		return fmt.Sprintf("Transactional Logs Error: Amp object badly formed %v", err)
 	}
 }
+
+func jsonifyShadowObject(so *analytics.ShadowObject) string {
+	type alias analytics.ShadowObject
+
+	b, err := jsoniter.Marshal(&struct {
+		Type RequestType `json:"type"`
+		*alias
+	}{
+		Type:  SHADOW,
+		alias: (*alias)(so),
+	})
+
+	if err == nil {
+		return string(b)
+	} else {
+		return fmt.Sprintf("Transactional Logs Error: Shadow object badly formed %v", err)
+	}
+}
@@ -55,6 +55,21 @@ func TestCookieSyncObject_ToJson(t *testing.T) {
 	}
 }
 
+func TestShadowObject_ToJson(t *testing.T) {
+	so := &analytics.ShadowObject{
+		Bidder:     "appnexus",
+		Production: analytics.ShadowCall{Uri: "http://ib.adnxs.com/openrtb2", Status: http.StatusOK, LatencyMS: 80, Bids: 1, Price: 1.5},
+		Shadow:     analytics.ShadowCall{Uri: "http://beta.adnxs.com/openrtb2", Error: "timeout"},
+	}
+	soJson := jsonifyShadowObject(so)
+	if strings.Contains(soJson, "Transactional Logs Error") {
+		t.Fatalf("ShadowObject failed to convert to json")
+	}
+	if !strings.Contains(soJson, `"type":"shadow"`) {
+		t.Errorf("ShadowObject json should have its type. Got %s", soJson)
+	}
+}
+
 func TestFileLogger_LogObjects(t *testing.T) {
 	if _, err := os.Stat(TEST_DIR); os.IsNotExist(err) {
 		if err = os.MkdirAll(TEST_DIR, 0755); err != nil {
@@ -67,6 +82,7 @@ func TestFileLogger_LogObjects(t *testing.T) {
 		fl.LogAmpObject(&analytics.AmpObject{})
 		fl.LogSetUIDObject(&analytics.SetUIDObject{})
 		fl.LogCookieSyncObject(&analytics.CookieSyncObject{})
+		fl.(analytics.ShadowModule).LogShadowObject(&analytics.ShadowObject{})
 	} else {
 		t.Fatalf("Couldn't initialize file logger: %v", err)
 	}
@@ -0,0 +1,99 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// Privacy configures the privacy activity controls.
+//
+// Each activity has an ordered list of rules. The first rule whose condition matches decides whether
+// the activity is allowed. If no rules match, the activity's default is used.
+type Privacy struct {
+	Activities Activities `mapstructure:"activities"`
+	// Accounts overrides the host Activities for individual publisher IDs.
+	// An activity which isn't defined for the account falls back to the host's rules.
+	Accounts map[string]Activities `mapstructure:"accounts"`
+}
+
+func (cfg *Privacy) validate(errs configErrors) configErrors {
+	errs = cfg.Activities.validate("privacy.activities", errs)
+	for account, activities := range cfg.Accounts {
+		errs = activities.validate(fmt.Sprintf("privacy.accounts.%s", account), errs)
+	}
+	return errs
+}
+
+// Activities holds the configuration for every activity that PBS can control.
+type Activities struct {
+	SyncUser           Activity `mapstructure:"sync_user"`
+	FetchBids          Activity `mapstructure:"fetch_bids"`
+	TransmitUserFPD    Activity `mapstructure:"transmit_ufpd"`
+	TransmitPreciseGeo Activity `mapstructure:"transmit_precise_geo"`
+	TransmitEIDs       Activity `mapstructure:"transmit_eids"`
+	ReportAnalytics    Activity `mapstructure:"report_analytics"`
+}
+
+func (cfg *Activities) validate(prefix string, errs configErrors) configErrors {
+	errs = cfg.SyncUser.validate(prefix+".sync_user", errs)
+	errs = cfg.FetchBids.validate(prefix+".fetch_bids", errs)
+	errs = cfg.TransmitUserFPD.validate(prefix+".transmit_ufpd", errs)
+	errs = cfg.TransmitPreciseGeo.validate(prefix+".transmit_precise_geo", errs)
+	errs = cfg.TransmitEIDs.validate(prefix+".transmit_eids", errs)
+	errs = cfg.ReportAnalytics.validate(prefix+".report_analytics", errs)
+	return errs
+}
+
+// Activity is the configuration for a single activity.
+type Activity struct {
+	// Default decides the activity if none of the rules match. If undefined, the activity is allowed.
+	Default *bool          `mapstructure:"default"`
+	Rules   []ActivityRule `mapstructure:"rules"`
+}
+
+// IsDefined is true if the activity has been configured at all.
+func (cfg *Activity) IsDefined() bool {
+	return cfg.Default != nil || len(cfg.Rules) > 0
+}
+
+func (cfg *Activity) validate(prefix string, errs configErrors) configErrors {
+	for i, rule := range cfg.Rules {
+		errs = rule.Condition.validate(fmt.Sprintf("%s.rules[%d].condition", prefix, i), errs)
+	}
+	return errs
+}
+
+// ActivityRule allows or denies an activity if its condition matches.
+type ActivityRule struct {
+	Allow     bool              `mapstructure:"allow"`
+	Condition ActivityCondition `mapstructure:"condition"`
+}
+
+// ActivityCondition matches if every field which is defined matches the request.
+// A condition with no fields defined matches every request.
+type ActivityCondition struct {
+	// ComponentName lists the bidder or analytics module names which this condition applies to.
+	ComponentName []string `mapstructure:"component_name"`
+	// ComponentType must be "bidder", "analytics" or "general".
+	ComponentType []string `mapstructure:"component_type"`
+	// GPPSID matches if any of these GPP section IDs apply to the request.
+	GPPSID []int `mapstructure:"gpp_sid"`
+	// Geo matches the request's country, or country and region, in the form "USA" or "USA.CA".
+	Geo []string `mapstructure:"geo"`
+}
+
+var activityGeoFormat = regexp.MustCompile(`^[A-Za-z]{3}(\.[A-Za-z0-9]+)?$`)
+
+func (cfg *ActivityCondition) validate(prefix string, errs configErrors) configErrors {
+	for _, componentType := range cfg.ComponentType {
+		if componentType != "bidder" && componentType != "analytics" && componentType != "general" {
+			errs = append(errs, fmt.Errorf("%s.component_type must be one of bidder, analytics or general. Got %s", prefix, componentType))
+		}
+	}
+	for _, geo := range cfg.Geo {
+		if !activityGeoFormat.MatchString(geo) {
+			errs = append(errs, fmt.Errorf("%s.geo must look like \"USA\" or \"USA.CA\". Got %s", prefix, geo))
+		}
+	}
+	return errs
+}
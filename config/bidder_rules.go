@@ -0,0 +1,77 @@
+package config
+
+import (
+	"fmt"
+)
+
+// BidderRules are static rules which stop the auction from calling some bidders, or only call them on a sample of the requests.
+//
+// The rules are evaluated in order for each bidder in a request. The first rule which matches the bidder and the request
+// decides whether the bidder gets called. If no rules match, the bidder is called.
+type BidderRules struct {
+	Rules []BidderRule `mapstructure:"rules"`
+	// Accounts holds extra rules for individual publisher IDs. They're evaluated before the host Rules.
+	Accounts map[string][]BidderRule `mapstructure:"accounts"`
+}
+
+func (cfg *BidderRules) validate(errs configErrors) configErrors {
+	for i, rule := range cfg.Rules {
+		errs = rule.validate(fmt.Sprintf("bidder_rules.rules[%d]", i), errs)
+	}
+	for account, rules := range cfg.Accounts {
+		for i, rule := range rules {
+			errs = rule.validate(fmt.Sprintf("bidder_rules.accounts.%s[%d]", account, i), errs)
+		}
+	}
+	return errs
+}
+
+// BidderRule decides how often the bidders get called on the requests which match its condition.
+type BidderRule struct {
+	// Name identifies the rule in the debug output. If undefined, the rule's position in its list is used.
+	Name string `mapstructure:"name"`
+	// Bidders lists the bidders, or bidder aliases, which this rule applies to. If empty, it applies to every bidder.
+	Bidders   []string            `mapstructure:"bidders"`
+	Condition BidderRuleCondition `mapstructure:"condition"`
+	// CallPercent is the percentage of matching requests which the bidders get called on. 0 means they're never called.
+	CallPercent float64 `mapstructure:"call_percent"`
+}
+
+func (cfg *BidderRule) validate(prefix string, errs configErrors) configErrors {
+	if cfg.CallPercent < 0 || cfg.CallPercent > 100 {
+		errs = append(errs, fmt.Errorf("%s.call_percent must be >= 0 and <= 100. Got %g", prefix, cfg.CallPercent))
+	}
+	return cfg.Condition.validate(prefix+".condition", errs)
+}
+
+// BidderRuleCondition matches if every field which is defined matches the request.
+// A condition with no fields defined matches every request.
+type BidderRuleCondition struct {
+	// Channel must be "web", "app", "amp" or "video". The video channel is the /openrtb2/video endpoint.
+	Channel []string `mapstructure:"channel"`
+	// DeviceType matches the request's device.devicetype, using the OpenRTB 2.5 values.
+	DeviceType []int `mapstructure:"device_type"`
+	// Geo matches the request's country, or country and region, in the form "USA" or "USA.CA".
+	Geo []string `mapstructure:"geo"`
+	// MediaType must be "banner", "video", "audio" or "native". It matches if any of the bidder's imps have that media type.
+	MediaType []string `mapstructure:"media_type"`
+}
+
+func (cfg *BidderRuleCondition) validate(prefix string, errs configErrors) configErrors {
+	for _, channel := range cfg.Channel {
+		if channel != "web" && channel != "app" && channel != "amp" && channel != "video" {
+			errs = append(errs, fmt.Errorf("%s.channel must be one of web, app, amp or video. Got %s", prefix, channel))
+		}
+	}
+	for _, geo := range cfg.Geo {
+		if !activityGeoFormat.MatchString(geo) {
+			errs = append(errs, fmt.Errorf("%s.geo must look like \"USA\" or \"USA.CA\". Got %s", prefix, geo))
+		}
+	}
+	for _, mediaType := range cfg.MediaType {
+		if mediaType != "banner" && mediaType != "video" && mediaType != "audio" && mediaType != "native" {
+			errs = append(errs, fmt.Errorf("%s.media_type must be one of banner, video, audio or native. Got %s", prefix, mediaType))
+		}
+	}
+	return errs
+}
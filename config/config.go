@@ -2,9 +2,11 @@ package config
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"net/url"
 	"reflect"
+	"regexp"
 	"strings"
 	"text/template"
 	"time"
@@ -15,6 +17,7 @@ import (
 	"github.com/spf13/viper"
 
 	validator "github.com/asaskevich/govalidator"
+	"golang.org/x/text/currency"
 )
 
 // Configuration
@@ -25,6 +28,12 @@ type Configuration struct {
 	Client      HTTPClient `mapstructure:"http_client"`
 	AdminPort   int        `mapstructure:"admin_port"`
 	EnableGzip  bool       `mapstructure:"enable_gzip"`
+	// Region is the region which this instance of Prebid Server runs in. Bidders' endpoint templates can use it
+	// with the {{.Region}} macro, and it picks their regional_endpoints.
+	Region string `mapstructure:"region"`
+	// RegionByCountry picks the region of the bidders' endpoints for each request, from its device.geo.country.
+	// The keys are ISO-3166-1-alpha-3 country codes. Requests from the other countries use the Region.
+	RegionByCountry map[string]string `mapstructure:"region_by_country"`
 	// StatusResponse is the string which will be returned by the /status endpoint when things are OK.
 	// If empty, it will return a 204 with no content.
 	StatusResponse  string             `mapstructure:"status_response"`
@@ -32,6 +41,10 @@ type Configuration struct {
 	CacheURL        Cache              `mapstructure:"cache"`
 	RecaptchaSecret string             `mapstructure:"recaptcha_secret"`
 	HostCookie      HostCookie         `mapstructure:"host_cookie"`
+	UserSync        UserSync           `mapstructure:"user_sync"`
+	UserIDStore     UserIDStore        `mapstructure:"user_id_store"`
+	SharedID        SharedID           `mapstructure:"shared_id"`
+	SChain          SChain             `mapstructure:"schain"`
 	Metrics         Metrics            `mapstructure:"metrics"`
 	DataCache       DataCache          `mapstructure:"datacache"`
 	StoredRequests  StoredRequests     `mapstructure:"stored_requests"`
@@ -48,6 +61,12 @@ type Configuration struct {
 	GDPR                 GDPR               `mapstructure:"gdpr"`
 	CurrencyConverter    CurrencyConverter  `mapstructure:"currency_converter"`
 	DefReqConfig         DefReqConfig       `mapstructure:"default_request"`
+	Privacy              Privacy            `mapstructure:"privacy"`
+	// CircuitBreaker is the circuit breaker for every bidder which doesn't have one in its adapters config.
+	CircuitBreaker CircuitBreaker `mapstructure:"circuit_breaker"`
+	TrafficShaping TrafficShaping `mapstructure:"traffic_shaping"`
+	BidderRules    BidderRules    `mapstructure:"bidder_rules"`
+	BidderTimeouts BidderTimeouts `mapstructure:"bidder_timeouts"`
 
 	VideoStoredRequestRequired bool `mapstructure:"video_stored_request_required"`
 }
@@ -85,6 +104,17 @@ func (cfg *Configuration) validate() configErrors {
 	}
 	errs = cfg.GDPR.validate(errs)
 	errs = cfg.CurrencyConverter.validate(errs)
+	errs = cfg.Privacy.validate(errs)
+	errs = cfg.HostCookie.validate(errs)
+	errs = cfg.UserSync.validate(errs)
+	errs = cfg.UserIDStore.validate(errs, &cfg.HostCookie.Security)
+	errs = cfg.SharedID.validate(errs)
+	errs = cfg.SChain.validate(errs)
+	errs = cfg.CircuitBreaker.validate("circuit_breaker", errs)
+	errs = cfg.TrafficShaping.validate(errs)
+	errs = cfg.BidderRules.validate(errs)
+	errs = cfg.BidderTimeouts.validate(errs)
+	errs = validateRegionByCountry(cfg.RegionByCountry, errs)
 	errs = validateAdapters(cfg.Adapters, errs)
 	return errs
 }
@@ -122,12 +152,40 @@ type GDPR struct {
 	HostVendorID        int          `mapstructure:"host_vendor_id"`
 	UsersyncIfAmbiguous bool         `mapstructure:"usersync_if_ambiguous"`
 	Timeouts            GDPRTimeouts `mapstructure:"timeouts_ms"`
+	GeoLocation         GeoLocation  `mapstructure:"geolocation"`
 }
 
 func (cfg *GDPR) validate(errs configErrors) configErrors {
 	if cfg.HostVendorID < 0 || cfg.HostVendorID > 0xffff {
 		errs = append(errs, fmt.Errorf("gdpr.host_vendor_id must be in the range [0, %d]. Got %d", 0xffff, cfg.HostVendorID))
 	}
+	errs = cfg.GeoLocation.validate(errs)
+	return errs
+}
+
+// GeoLocation configures the IP geolocation lookups which decide whether GDPR applies to requests
+// which don't define regs.ext.gdpr.
+type GeoLocation struct {
+	Enabled bool `mapstructure:"enabled"`
+	// Database is the path to a MaxMind DB (.mmdb) file with country data, like GeoLite2-Country.
+	Database        string `mapstructure:"database"`
+	CacheSizeBytes  int    `mapstructure:"cache_size_bytes"`
+	CacheTTLSeconds int    `mapstructure:"cache_ttl_seconds"`
+	// FailClosed treats requests whose country can't be found as if GDPR applies.
+	// Otherwise they fall back to usersync_if_ambiguous.
+	FailClosed bool `mapstructure:"fail_closed"`
+}
+
+func (cfg *GeoLocation) validate(errs configErrors) configErrors {
+	if !cfg.Enabled {
+		return errs
+	}
+	if cfg.Database == "" {
+		errs = append(errs, errors.New("gdpr.geolocation.database must be defined if gdpr.geolocation.enabled is true"))
+	}
+	if cfg.CacheTTLSeconds > 0 && cfg.CacheSizeBytes <= 0 {
+		errs = append(errs, fmt.Errorf("gdpr.geolocation.cache_size_bytes must be positive if gdpr.geolocation.cache_ttl_seconds is defined. Got %d", cfg.CacheSizeBytes))
+	}
 	return errs
 }
 
@@ -174,17 +232,221 @@ type HostCookie struct {
 	OptOutCookie Cookie `mapstructure:"optout_cookie"`
 	// Cookie timeout in days
 	TTL int64 `mapstructure:"ttl_days"`
+	// MaxCookieSizeBytes caps the size of the uids cookie. If it would be any bigger, UIDs are evicted until it fits.
+	// Browsers usually drop cookies over 4KB. 0 means no cap.
+	MaxCookieSizeBytes int `mapstructure:"max_cookie_size_bytes"`
+	// EvictionPriority lists the cookie families whose UIDs should be kept longest when the cookie is too big,
+	// most important first. Families which aren't listed are evicted before the ones which are.
+	EvictionPriority []string `mapstructure:"eviction_priority"`
+	// Security protects the uids cookie from being forged or read by the browser.
+	Security CookieSecurity `mapstructure:"security"`
+}
+
+func (cfg *HostCookie) validate(errs configErrors) configErrors {
+	if cfg.MaxCookieSizeBytes < 0 {
+		errs = append(errs, fmt.Errorf("host_cookie.max_cookie_size_bytes must be >= 0. Got %d", cfg.MaxCookieSizeBytes))
+	}
+	return cfg.Security.validate(errs)
+}
+
+// minCookieKeyLength is the shortest key which may be used to sign the uids cookie.
+// HMAC-SHA256 keys shorter than the hash output weaken the signature.
+const minCookieKeyLength = 32
+
+// CookieSecurity configures how the uids cookie is signed and encrypted.
+//
+// If there are no Keys, the cookie is written and read as plain base64 JSON, like older versions of Prebid Server did.
+type CookieSecurity struct {
+	// Keys sign the uids cookie with HMAC-SHA256. New cookies use the first key. The others are only used to
+	// read cookies, so that hosts can rotate keys without resetting everyone's cookie.
+	Keys []string `mapstructure:"keys"`
+	// Encrypt makes the cookie unreadable to the browser by encrypting it with AES-GCM. This requires Keys.
+	Encrypt bool `mapstructure:"encrypt"`
+	// AcceptLegacyUntil is an RFC 3339 timestamp. Unsigned cookies are still accepted until then,
+	// so that hosts can turn on signing without resetting everyone's cookie. Empty means they're never accepted.
+	AcceptLegacyUntil string `mapstructure:"accept_legacy_until"`
+}
+
+func (cfg *CookieSecurity) validate(errs configErrors) configErrors {
+	for i, key := range cfg.Keys {
+		if len(key) < minCookieKeyLength {
+			errs = append(errs, fmt.Errorf("host_cookie.security.keys[%d] must be at least %d characters long", i, minCookieKeyLength))
+		}
+	}
+	if cfg.Encrypt && len(cfg.Keys) == 0 {
+		errs = append(errs, errors.New("host_cookie.security.encrypt requires host_cookie.security.keys"))
+	}
+	if cfg.AcceptLegacyUntil != "" {
+		if _, err := time.Parse(time.RFC3339, cfg.AcceptLegacyUntil); err != nil {
+			errs = append(errs, fmt.Errorf("host_cookie.security.accept_legacy_until must be an RFC 3339 timestamp. Got %s", cfg.AcceptLegacyUntil))
+		}
+	}
+	return errs
+}
+
+// LegacyCookiesAllowed is true if unsigned uids cookies should still be accepted at the given time.
+func (cfg *CookieSecurity) LegacyCookiesAllowed(now time.Time) bool {
+	if len(cfg.Keys) == 0 {
+		return true
+	}
+	if cfg.AcceptLegacyUntil == "" {
+		return false
+	}
+	until, err := time.Parse(time.RFC3339, cfg.AcceptLegacyUntil)
+	return err == nil && now.Before(until)
 }
 
 func (cfg *HostCookie) TTLDuration() time.Duration {
 	return time.Duration(cfg.TTL) * time.Hour * 24
 }
 
+// UserSync configures which bidders the /cookie_sync endpoint returns.
+type UserSync struct {
+	// DefaultLimit is used for requests which don't define a limit. 0 means no limit.
+	DefaultLimit int `mapstructure:"default_limit"`
+	// MaxLimit caps the limit which requests can ask for. 0 means no cap.
+	MaxLimit int `mapstructure:"max_limit"`
+	// PriorityGroups are synced before all other bidders, in order. Bidders are shuffled within each group.
+	PriorityGroups [][]string `mapstructure:"priority_groups"`
+	// CoopSyncDefault is used for requests which don't define coopSync.
+	// Cooperative syncs also return bidders which the page didn't ask for.
+	CoopSyncDefault bool `mapstructure:"coop_sync_default"`
+}
+
+func (cfg *UserSync) validate(errs configErrors) configErrors {
+	if cfg.DefaultLimit < 0 {
+		errs = append(errs, fmt.Errorf("user_sync.default_limit must be >= 0. Got %d", cfg.DefaultLimit))
+	}
+	if cfg.MaxLimit < 0 {
+		errs = append(errs, fmt.Errorf("user_sync.max_limit must be >= 0. Got %d", cfg.MaxLimit))
+	}
+	if cfg.MaxLimit > 0 && cfg.DefaultLimit > cfg.MaxLimit {
+		errs = append(errs, fmt.Errorf("user_sync.default_limit must not be greater than user_sync.max_limit. Got %d and %d", cfg.DefaultLimit, cfg.MaxLimit))
+	}
+	groups := make(map[string]int)
+	for i, group := range cfg.PriorityGroups {
+		for _, bidder := range group {
+			if previous, ok := groups[bidder]; ok {
+				errs = append(errs, fmt.Errorf("user_sync.priority_groups has the bidder %s in groups %d and %d", bidder, previous, i))
+			}
+			groups[bidder] = i
+		}
+	}
+	return errs
+}
+
+// SharedID configures the random first-party ID which Prebid Server gives to users who don't have one yet.
+type SharedID struct {
+	Enabled bool `mapstructure:"enabled"`
+	// CookieName is the name of the cookie which holds the ID. It's set on the host_cookie.domain.
+	CookieName string `mapstructure:"cookie_name"`
+	// Source is the request.user.ext.eids[].source which the ID is sent to bidders with.
+	Source string `mapstructure:"source"`
+	// TTLDays is how long the cookie lasts. Each auction pushes this back.
+	TTLDays int `mapstructure:"ttl_days"`
+}
+
+func (cfg *SharedID) validate(errs configErrors) configErrors {
+	if !cfg.Enabled {
+		return errs
+	}
+	if cfg.CookieName == "" {
+		errs = append(errs, errors.New("shared_id.cookie_name is required when shared_id.enabled is true"))
+	}
+	if cfg.Source == "" {
+		errs = append(errs, errors.New("shared_id.source is required when shared_id.enabled is true"))
+	}
+	if cfg.TTLDays <= 0 {
+		errs = append(errs, fmt.Errorf("shared_id.ttl_days must be > 0. Got %d", cfg.TTLDays))
+	}
+	return errs
+}
+
+// TTL returns how long the shared ID cookie lasts.
+func (cfg *SharedID) TTL() time.Duration {
+	return time.Duration(cfg.TTLDays) * 24 * time.Hour
+}
+
+// SChain configures the node which Prebid Server adds to the supply chain which each bidder gets.
+type SChain struct {
+	// ASI is the host's advertising system domain, as it appears in the host's sellers.json.
+	// If it's empty, Prebid Server doesn't add a node for itself.
+	ASI string `mapstructure:"asi"`
+}
+
+func (cfg *SChain) validate(errs configErrors) configErrors {
+	if strings.ContainsAny(cfg.ASI, "/:") {
+		errs = append(errs, fmt.Errorf("schain.asi must be a domain, not a URL. Got %s", cfg.ASI))
+	}
+	return errs
+}
+
+// UserIDStore configures a server-side store for UIDs. This helps with browsers which drop or quickly expire
+// the uids cookie on the Prebid Server domain. The UIDs are keyed by an ID which PBS issues to each user,
+// and signs with the host_cookie.security.keys so that nobody can read or overwrite someone else's UIDs.
+type UserIDStore struct {
+	Enabled bool `mapstructure:"enabled"`
+	// Backend is either "memory" or "disk".
+	Backend string `mapstructure:"backend"`
+	// CookieName is the name of the cookie which /setuid saves the user's ID in. It's read by /setuid and
+	// /cookie_sync, and by auctions which don't send an ID in request.user.ext.
+	CookieName string `mapstructure:"cookie_name"`
+	// ExtField is the field of request.user.ext which can hold the user's ID in auction requests.
+	ExtField string `mapstructure:"ext_field"`
+	// TrustPublisherIDs accepts IDs which PBS didn't sign, so that publishers can key the store with their own
+	// first-party IDs. Only enable it if the host trusts every caller, since anyone who knows a user's ID
+	// can then read or overwrite their UIDs.
+	TrustPublisherIDs bool              `mapstructure:"trust_publisher_ids"`
+	Memory            UserIDStoreMemory `mapstructure:"memory"`
+	Disk              UserIDStoreDisk   `mapstructure:"disk"`
+}
+
+// UserIDStoreMemory configures the in-memory user ID store. The oldest users are evicted once it's full.
+type UserIDStoreMemory struct {
+	SizeBytes int `mapstructure:"size_bytes"`
+}
+
+// UserIDStoreDisk configures the user ID store which is saved in a file on the local disk.
+type UserIDStoreDisk struct {
+	Path string `mapstructure:"path"`
+}
+
+func (cfg *UserIDStore) validate(errs configErrors, security *CookieSecurity) configErrors {
+	if !cfg.Enabled {
+		return errs
+	}
+	if len(security.Keys) == 0 {
+		errs = append(errs, errors.New("user_id_store needs host_cookie.security.keys to sign the users' IDs"))
+	}
+	switch cfg.Backend {
+	case "memory":
+		if cfg.Memory.SizeBytes <= 0 {
+			errs = append(errs, fmt.Errorf("user_id_store.memory.size_bytes must be > 0. Got %d", cfg.Memory.SizeBytes))
+		}
+	case "disk":
+		if cfg.Disk.Path == "" {
+			errs = append(errs, errors.New("user_id_store.disk.path is required for the disk backend"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("user_id_store.backend must be one of: memory, disk. Got %s", cfg.Backend))
+	}
+	if cfg.CookieName == "" {
+		errs = append(errs, errors.New("user_id_store.cookie_name is required"))
+	}
+	return errs
+}
+
 const (
 	dummyHost        string = "dummyhost.com"
-	dummyPublisherID int    = 12
+	dummyPublisherID string = "12"
 	dummyGDPR        string = "0"
 	dummyGDPRConsent string = "someGDPRConsentString"
+	dummyAccountID   string = "someAccountID"
+	dummyZoneID      string = "someZoneID"
+	dummyPlacementID string = "somePlacementID"
+	dummyUSPrivacy   string = "1YNN"
+	dummyMediaType   string = "banner"
+	dummyRegion      string = "us-east"
 )
 
 type Adapter struct {
@@ -204,13 +466,390 @@ type Adapter struct {
 	//
 	// For more info on templates, see: https://golang.org/pkg/text/template/
 	UserSyncURL string `mapstructure:"usersync_url"`
-	PlatformID  string `mapstructure:"platform_id"` // needed for Facebook
-	XAPI        struct {
+	// UserSyncURLIframe and UserSyncURLRedirect let the Bidder sync with another type of sync than its default,
+	// for pages whose /cookie_sync filterSettings don't allow that. These are templates, like UserSyncURL.
+	UserSyncURLIframe   string `mapstructure:"usersync_url_iframe"`
+	UserSyncURLRedirect string `mapstructure:"usersync_url_redirect"`
+	PlatformID          string `mapstructure:"platform_id"` // needed for Facebook
+	XAPI                struct {
 		Username string `mapstructure:"username"`
 		Password string `mapstructure:"password"`
 		Tracker  string `mapstructure:"tracker"`
 	} `mapstructure:"xapi"` // needed for Rubicon
 	Disabled bool `mapstructure:"disabled"`
+	// HTTP configures the requests which Prebid Server sends to the bidder, on top of whatever its adapter does.
+	// It only applies to bidders whose adapters implement adapters.Bidder.
+	HTTP BidderHTTP `mapstructure:"http"`
+	// Generic makes this a bidder which Prebid Server talks to in plain OpenRTB 2.5, without any code of its own.
+	// Hosts can use it to add bidders which Prebid Server doesn't have an adapter for. The endpoint is a template,
+	// like the other adapters' endpoints.
+	Generic *GenericAdapter `mapstructure:"generic"`
+	// AliasOf makes this bidder a host-defined alias of a bidder which has an adapter. Aliases use that bidder's adapter and
+	// params, but they're first-class bidders otherwise: they have their own endpoint, usersync URLs and cookie family.
+	// The endpoint is the other bidder's endpoint if undefined.
+	AliasOf string `mapstructure:"alias_of"`
+	// GDPRVendorID is an alias's ID in the IAB Global Vendor List. It's the other bidder's ID if undefined.
+	GDPRVendorID uint16 `mapstructure:"gdpr_vendor_id"`
+	// BidderInfo overrides parts of the other bidder's static/bidder-info/{bidder}.yaml file for an alias.
+	BidderInfo *BidderInfoOverrides `mapstructure:"bidder_info"`
+	// CircuitBreaker replaces the top-level circuit_breaker config for this bidder.
+	CircuitBreaker *CircuitBreaker `mapstructure:"circuit_breaker"`
+	// TmaxMS is the most time which the bidder gets in an auction, over all of its HTTP requests.
+	// If undefined, its time comes from bidder_timeouts.percentile, or it gets the auction's whole timeout.
+	TmaxMS int `mapstructure:"tmax_ms"`
+	// RegionalEndpoints replace the Endpoint in the regions which they have keys for. The keys are regions,
+	// like the host's region config, and the values are endpoints like the Endpoint.
+	RegionalEndpoints map[string]string `mapstructure:"regional_endpoints"`
+	// Shadow mirrors a sample of the bidder's requests to another endpoint. It's off if undefined.
+	Shadow *BidderShadow `mapstructure:"shadow"`
+}
+
+// EndpointFor returns the bidder's endpoint in the region.
+func (cfg Adapter) EndpointFor(region string) string {
+	// Viper lower-cases all map keys, so the region lookup has to be case-insensitive.
+	if endpoint, ok := cfg.RegionalEndpoints[strings.ToLower(region)]; ok {
+		return endpoint
+	}
+	return cfg.Endpoint
+}
+
+// CircuitBreaker configures the circuit breaker in front of a bidder. The breaker opens when too many of the recent
+// requests to the bidder fail or time out. While it's open, auctions skip the bidder. After a while, it goes half-open
+// and sends a fraction of the requests to the bidder. It closes if one of those succeeds, and opens again if one fails.
+type CircuitBreaker struct {
+	Enabled bool `mapstructure:"enabled"`
+	// ErrorRate is the fraction of the requests in the window which must fail or time out for the breaker to open.
+	ErrorRate float64 `mapstructure:"error_rate"`
+	// MinRequests is how many requests the bidder must get in the window before the breaker can open.
+	MinRequests int `mapstructure:"min_requests"`
+	// WindowSeconds is how far back the breaker looks when it works out the error rate.
+	WindowSeconds int `mapstructure:"window_seconds"`
+	// OpenSeconds is how long the breaker stays open before it goes half-open.
+	OpenSeconds int `mapstructure:"open_seconds"`
+	// ProbeRate is the fraction of the requests which go to the bidder while the breaker is half-open.
+	ProbeRate float64 `mapstructure:"probe_rate"`
+}
+
+func (cfg *CircuitBreaker) validate(field string, errs configErrors) configErrors {
+	if !cfg.Enabled {
+		return errs
+	}
+	if cfg.ErrorRate <= 0 || cfg.ErrorRate > 1 {
+		errs = append(errs, fmt.Errorf("%s.error_rate must be > 0 and <= 1. Got %g", field, cfg.ErrorRate))
+	}
+	if cfg.MinRequests < 1 {
+		errs = append(errs, fmt.Errorf("%s.min_requests must be >= 1. Got %d", field, cfg.MinRequests))
+	}
+	if cfg.WindowSeconds < 1 {
+		errs = append(errs, fmt.Errorf("%s.window_seconds must be >= 1. Got %d", field, cfg.WindowSeconds))
+	}
+	if cfg.OpenSeconds < 1 {
+		errs = append(errs, fmt.Errorf("%s.open_seconds must be >= 1. Got %d", field, cfg.OpenSeconds))
+	}
+	if cfg.ProbeRate <= 0 || cfg.ProbeRate > 1 {
+		errs = append(errs, fmt.Errorf("%s.probe_rate must be > 0 and <= 1. Got %g", field, cfg.ProbeRate))
+	}
+	return errs
+}
+
+// BidderShadow mirrors a sample of a bidder's requests to another endpoint, like a new version of the bidder's server,
+// so that the two can be compared before the bidder moves over to it. The shadow endpoint's responses are only compared
+// with production's. They never make it into the auction.
+type BidderShadow struct {
+	// Endpoint replaces the scheme, host and path of the bidder's request URIs. Their query strings are kept.
+	Endpoint string `mapstructure:"endpoint"`
+	// SamplingRate is the fraction of the bidder's requests which get mirrored.
+	SamplingRate float64 `mapstructure:"sampling_rate"`
+	// TimeoutMS is how long Prebid Server waits for the shadow endpoint's response.
+	TimeoutMS int `mapstructure:"timeout_ms"`
+}
+
+func (cfg *BidderShadow) validate(adapterName string, errs configErrors) configErrors {
+	if !validator.IsURL(cfg.Endpoint) || !validator.IsRequestURL(cfg.Endpoint) {
+		errs = append(errs, fmt.Errorf("adapters.%s.shadow.endpoint must be a valid URL. Got %s", adapterName, cfg.Endpoint))
+	}
+	if cfg.SamplingRate < 0 || cfg.SamplingRate > 1 {
+		errs = append(errs, fmt.Errorf("adapters.%s.shadow.sampling_rate must be >= 0 and <= 1. Got %g", adapterName, cfg.SamplingRate))
+	}
+	if cfg.TimeoutMS < 1 {
+		errs = append(errs, fmt.Errorf("adapters.%s.shadow.timeout_ms must be >= 1. Got %d", adapterName, cfg.TimeoutMS))
+	}
+	return errs
+}
+
+// TrafficShaping skips the calls to bidders which are unlikely to bid. Prebid Server learns each bidder's bid rate
+// for each combination of domain (or app bundle), media type, size and country. A bidder is skipped when its bid rate
+// is too low for every imp in the request.
+type TrafficShaping struct {
+	Enabled bool `mapstructure:"enabled"`
+	// MinBidRate is the lowest bid rate for which the bidder still gets called.
+	MinBidRate float64 `mapstructure:"min_bid_rate"`
+	// MinRequests is how many imps the bidder must have seen for the inventory before it can be skipped.
+	MinRequests int `mapstructure:"min_requests"`
+	// ExplorationRate is the fraction of the calls which would be skipped that go to the bidder anyway,
+	// so that Prebid Server notices when its bid rate goes up.
+	ExplorationRate float64 `mapstructure:"exploration_rate"`
+	// HalfLifeSeconds is how long it takes for an imp to count half as much towards the bid rate.
+	HalfLifeSeconds int `mapstructure:"half_life_seconds"`
+	// MaxEntries is the most bid rates which Prebid Server keeps in memory.
+	MaxEntries int `mapstructure:"max_entries"`
+	// Accounts holds the settings of individual publisher IDs.
+	Accounts map[string]AccountTrafficShaping `mapstructure:"accounts"`
+}
+
+// AccountTrafficShaping holds a publisher's traffic shaping settings.
+type AccountTrafficShaping struct {
+	// Disabled turns traffic shaping off for the publisher, so that its auctions call every bidder.
+	Disabled bool `mapstructure:"disabled"`
+}
+
+func (cfg *TrafficShaping) validate(errs configErrors) configErrors {
+	if !cfg.Enabled {
+		return errs
+	}
+	if cfg.MinBidRate <= 0 || cfg.MinBidRate > 1 {
+		errs = append(errs, fmt.Errorf("traffic_shaping.min_bid_rate must be > 0 and <= 1. Got %g", cfg.MinBidRate))
+	}
+	if cfg.MinRequests < 1 {
+		errs = append(errs, fmt.Errorf("traffic_shaping.min_requests must be >= 1. Got %d", cfg.MinRequests))
+	}
+	if cfg.ExplorationRate < 0 || cfg.ExplorationRate > 1 {
+		errs = append(errs, fmt.Errorf("traffic_shaping.exploration_rate must be >= 0 and <= 1. Got %g", cfg.ExplorationRate))
+	}
+	if cfg.HalfLifeSeconds < 1 {
+		errs = append(errs, fmt.Errorf("traffic_shaping.half_life_seconds must be >= 1. Got %d", cfg.HalfLifeSeconds))
+	}
+	if cfg.MaxEntries < 1 {
+		errs = append(errs, fmt.Errorf("traffic_shaping.max_entries must be >= 1. Got %d", cfg.MaxEntries))
+	}
+	return errs
+}
+
+// BidderTimeouts gives the bidders deadlines of their own, within the auction's timeout.
+// Static deadlines come from adapters.{bidder}.tmax_ms.
+type BidderTimeouts struct {
+	// NetworkLatencyBufferMS is taken off the time left before a bidder's deadline to get the tmax in its request,
+	// to allow for the time which the request and response spend on the network.
+	NetworkLatencyBufferMS int `mapstructure:"network_latency_buffer_ms"`
+	// Percentile gives the bidders without a tmax_ms a deadline of this percentile of their recent response times.
+	// It's disabled if 0.
+	Percentile float64 `mapstructure:"percentile"`
+	// Samples is how many of each bidder's recent response times the percentile is taken over.
+	// Bidders don't get a deadline from the percentile until they have this many.
+	Samples int `mapstructure:"samples"`
+	// MinMS is the shortest deadline which the percentile can give a bidder.
+	MinMS         int                  `mapstructure:"min_ms"`
+	Notifications TimeoutNotifications `mapstructure:"notifications"`
+}
+
+// TimeoutNotifications tell bidders about the requests which timed out. They're only sent to bidders
+// whose adapters implement adapters.TimeoutBidder.
+type TimeoutNotifications struct {
+	Enabled bool `mapstructure:"enabled"`
+	// SamplingRate is the fraction of timeouts which get a notification.
+	SamplingRate float64 `mapstructure:"sampling_rate"`
+	// MaxPerSecond is the most notifications which each bidder gets in a second.
+	MaxPerSecond int `mapstructure:"max_per_second"`
+	// TimeoutMS is how long Prebid Server waits for the response to a notification.
+	TimeoutMS int `mapstructure:"timeout_ms"`
+}
+
+func (cfg *BidderTimeouts) validate(errs configErrors) configErrors {
+	if cfg.NetworkLatencyBufferMS < 0 {
+		errs = append(errs, fmt.Errorf("bidder_timeouts.network_latency_buffer_ms must be >= 0. Got %d", cfg.NetworkLatencyBufferMS))
+	}
+	if cfg.Percentile < 0 || cfg.Percentile > 100 {
+		errs = append(errs, fmt.Errorf("bidder_timeouts.percentile must be >= 0 and <= 100. Got %g", cfg.Percentile))
+	}
+	if cfg.Percentile > 0 && cfg.Samples < 1 {
+		errs = append(errs, fmt.Errorf("bidder_timeouts.samples must be >= 1 if bidder_timeouts.percentile is defined. Got %d", cfg.Samples))
+	}
+	if cfg.MinMS < 0 {
+		errs = append(errs, fmt.Errorf("bidder_timeouts.min_ms must be >= 0. Got %d", cfg.MinMS))
+	}
+	if !cfg.Notifications.Enabled {
+		return errs
+	}
+	if cfg.Notifications.SamplingRate <= 0 || cfg.Notifications.SamplingRate > 1 {
+		errs = append(errs, fmt.Errorf("bidder_timeouts.notifications.sampling_rate must be > 0 and <= 1. Got %g", cfg.Notifications.SamplingRate))
+	}
+	if cfg.Notifications.MaxPerSecond < 1 {
+		errs = append(errs, fmt.Errorf("bidder_timeouts.notifications.max_per_second must be >= 1. Got %d", cfg.Notifications.MaxPerSecond))
+	}
+	if cfg.Notifications.TimeoutMS < 1 {
+		errs = append(errs, fmt.Errorf("bidder_timeouts.notifications.timeout_ms must be >= 1. Got %d", cfg.Notifications.TimeoutMS))
+	}
+	return errs
+}
+
+// BidderCircuitBreaker returns the circuit breaker config for a bidder. That's the one in its adapters config if it has one,
+// and the top-level one otherwise.
+func (cfg *Configuration) BidderCircuitBreaker(bidder string) CircuitBreaker {
+	if adapter, ok := cfg.Adapters[strings.ToLower(bidder)]; ok && adapter.CircuitBreaker != nil {
+		return *adapter.CircuitBreaker
+	}
+	return cfg.CircuitBreaker
+}
+
+// BidderHTTP configures the HTTP requests to a bidder.
+type BidderHTTP struct {
+	// Headers are added to every request. They replace any headers with the same names which the adapter sets.
+	Headers map[string]string `mapstructure:"headers"`
+	// Auth adds an Authorization header to every request.
+	Auth BidderAuth `mapstructure:"auth"`
+	// Gzip compresses the request bodies, and sends them with a Content-Encoding: gzip header.
+	Gzip bool `mapstructure:"gzip"`
+	// MaxIdleConns is the most idle connections which Prebid Server keeps open to the bidder.
+	// HTTP2 makes Prebid Server use HTTP/2 with the bidder if its server supports it.
+	// If either is set, the bidder gets an HTTP client of its own. Otherwise, it shares the http_client with the others.
+	MaxIdleConns int  `mapstructure:"max_idle_connections"`
+	HTTP2        bool `mapstructure:"http2"`
+}
+
+// BidderAuth is the authorization for requests to a bidder.
+type BidderAuth struct {
+	// Type is "basic" for basic auth with the Username and Password, or "bearer" for the Token.
+	// There's no Authorization header if undefined.
+	Type     string `mapstructure:"type"`
+	Username string `mapstructure:"username"`
+	Password string `mapstructure:"password"`
+	Token    string `mapstructure:"token"`
+}
+
+func (cfg *BidderHTTP) validate(adapterName string, errs configErrors) configErrors {
+	switch cfg.Auth.Type {
+	case "":
+	case "basic":
+		if cfg.Auth.Username == "" {
+			errs = append(errs, fmt.Errorf("adapters.%s.http.auth.username is required for basic auth", adapterName))
+		}
+	case "bearer":
+		if cfg.Auth.Token == "" {
+			errs = append(errs, fmt.Errorf("adapters.%s.http.auth.token is required for bearer auth", adapterName))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("adapters.%s.http.auth.type must be basic or bearer. Got %s", adapterName, cfg.Auth.Type))
+	}
+	if cfg.MaxIdleConns < 0 {
+		errs = append(errs, fmt.Errorf("adapters.%s.http.max_idle_connections must be >= 0. Got %d", adapterName, cfg.MaxIdleConns))
+	}
+	return errs
+}
+
+// BidderInfoOverrides replace the parts of a bidder's static/bidder-info/{bidder}.yaml file which they define.
+type BidderInfoOverrides struct {
+	MaintainerEmail string   `mapstructure:"maintainer_email"`
+	AppMediaTypes   []string `mapstructure:"app_media_types"`
+	SiteMediaTypes  []string `mapstructure:"site_media_types"`
+}
+
+// AliasedBidder returns the name of the bidder which an alias is an alias of, as it's written in the BidderMap.
+// It returns false if the adapter isn't an alias, or if it's an alias of a bidder which doesn't exist.
+func (cfg Adapter) AliasedBidder() (openrtb_ext.BidderName, bool) {
+	if cfg.AliasOf == "" {
+		return "", false
+	}
+	for _, bidder := range openrtb_ext.BidderMap {
+		if strings.EqualFold(string(bidder), cfg.AliasOf) && !openrtb_ext.IsHostAlias(bidder) {
+			return bidder, true
+		}
+	}
+	return "", false
+}
+
+func (cfg Adapter) validateAlias(adapterName string, errs configErrors) configErrors {
+	if cfg.AliasOf == "" {
+		if cfg.GDPRVendorID != 0 || cfg.BidderInfo != nil {
+			errs = append(errs, fmt.Errorf("adapters.%s.gdpr_vendor_id and adapters.%s.bidder_info can only be used by aliases", adapterName, adapterName))
+		}
+		return errs
+	}
+	for _, bidder := range openrtb_ext.BidderMap {
+		if strings.ToLower(string(bidder)) == adapterName && !openrtb_ext.IsHostAlias(bidder) {
+			return append(errs, fmt.Errorf("adapters.%s.alias_of can't be used, because Prebid Server already has a bidder named %s", adapterName, bidder))
+		}
+	}
+	if cfg.Generic != nil {
+		errs = append(errs, fmt.Errorf("adapters.%s can't have both alias_of and generic", adapterName))
+	}
+	if _, ok := cfg.AliasedBidder(); !ok {
+		errs = append(errs, fmt.Errorf("adapters.%s.alias_of must be the name of a bidder which isn't an alias. Got %s", adapterName, cfg.AliasOf))
+	}
+	if cfg.BidderInfo != nil {
+		errs = validateMediaTypes(cfg.BidderInfo.AppMediaTypes, "adapters."+adapterName+".bidder_info.app_media_types", errs)
+		errs = validateMediaTypes(cfg.BidderInfo.SiteMediaTypes, "adapters."+adapterName+".bidder_info.site_media_types", errs)
+	}
+	return errs
+}
+
+// GenericAdapter configures a bidder which doesn't have an adapter of its own.
+type GenericAdapter struct {
+	// MaintainerEmail, AppMediaTypes and SiteMediaTypes take the place of the static/bidder-info/{bidder}.yaml file.
+	MaintainerEmail string   `mapstructure:"maintainer_email"`
+	AppMediaTypes   []string `mapstructure:"app_media_types"`
+	SiteMediaTypes  []string `mapstructure:"site_media_types"`
+	// Headers are added to every request to the bidder.
+	Headers map[string]string `mapstructure:"headers"`
+	// ParamsPlacement is where the bidder wants the publisher's params in each imp.ext:
+	//
+	//   "bidder" (the default) -- in imp.ext.bidder, like the other adapters get them
+	//   "ext" -- in imp.ext itself
+	//   "name" -- in imp.ext.{bidder}
+	ParamsPlacement string `mapstructure:"params_placement"`
+	// BidTypeExtField is the path in seatbid[i].bid[j].ext, separated by dots, where the bidder puts the bid's
+	// media type. If it's undefined, or a bid doesn't have one there, the media type is taken from the imp.
+	BidTypeExtField string `mapstructure:"bid_type_ext_field"`
+	// DefaultBidType is the media type of bids on multiformat imps which don't say what they are.
+	// If it's undefined or the imp doesn't have it, they're the first of banner, video, audio or native which the imp has.
+	DefaultBidType string `mapstructure:"default_bid_type"`
+	// Currency is the currency of the bidder's bids if its responses don't define one. It's USD if undefined.
+	Currency string `mapstructure:"currency"`
+	// TimeoutEndpoint gets a copy of each request which timed out, if bidder_timeouts.notifications are enabled.
+	// The bidder doesn't get timeout notifications if it's undefined.
+	TimeoutEndpoint string `mapstructure:"timeout_endpoint"`
+}
+
+func (cfg *GenericAdapter) validate(adapterName string, errs configErrors) configErrors {
+	for _, bidder := range openrtb_ext.BidderMap {
+		if strings.ToLower(string(bidder)) == adapterName {
+			return append(errs, fmt.Errorf("adapters.%s.generic can't be used, because Prebid Server already has an adapter named %s", adapterName, bidder))
+		}
+	}
+	if len(cfg.AppMediaTypes) == 0 && len(cfg.SiteMediaTypes) == 0 {
+		errs = append(errs, fmt.Errorf("adapters.%s.generic must define app_media_types or site_media_types", adapterName))
+	}
+	errs = validateMediaTypes(cfg.AppMediaTypes, "adapters."+adapterName+".generic.app_media_types", errs)
+	errs = validateMediaTypes(cfg.SiteMediaTypes, "adapters."+adapterName+".generic.site_media_types", errs)
+	switch cfg.ParamsPlacement {
+	case "", "bidder", "ext", "name":
+	default:
+		errs = append(errs, fmt.Errorf("adapters.%s.generic.params_placement must be bidder, ext or name. Got %s", adapterName, cfg.ParamsPlacement))
+	}
+	if cfg.DefaultBidType != "" {
+		if _, err := openrtb_ext.ParseBidType(cfg.DefaultBidType); err != nil {
+			errs = append(errs, fmt.Errorf("adapters.%s.generic.default_bid_type must be banner, video, audio or native. Got %s", adapterName, cfg.DefaultBidType))
+		}
+	}
+	if cfg.Currency != "" {
+		if _, err := currency.ParseISO(cfg.Currency); err != nil {
+			errs = append(errs, fmt.Errorf("adapters.%s.generic.currency must be an ISO-4217 currency code. Got %s", adapterName, cfg.Currency))
+		}
+	}
+	if cfg.TimeoutEndpoint != "" {
+		if _, err := url.ParseRequestURI(cfg.TimeoutEndpoint); err != nil {
+			errs = append(errs, fmt.Errorf("adapters.%s.generic.timeout_endpoint must be a URL. Got %s", adapterName, cfg.TimeoutEndpoint))
+		}
+	}
+	return errs
+}
+
+func validateMediaTypes(mediaTypes []string, field string, errs configErrors) configErrors {
+	for _, mediaType := range mediaTypes {
+		if _, err := openrtb_ext.ParseBidType(mediaType); err != nil {
+			errs = append(errs, fmt.Errorf("%s must only contain banner, video, audio or native. Got %s", field, mediaType))
+		}
+	}
+	return errs
 }
 
 // validateAdapterEndpoint makes sure that an adapter has a valid endpoint
@@ -227,7 +866,18 @@ func validateAdapterEndpoint(endpoint string, adapterName string, errs configErr
 		return append(errs, fmt.Errorf("Invalid endpoint template: %s for adapter: %s. %v", endpoint, adapterName, err))
 	}
 	// Resolve macros (if any) in the endpoint URL
-	resolvedEndpoint, err := macros.ResolveMacros(*endpointTemplate, macros.EndpointTemplateParams{Host: dummyHost, PublisherID: dummyPublisherID})
+	resolvedEndpoint, err := macros.ResolveEndpoint(*endpointTemplate, macros.EndpointTemplateParams{
+		Host:        dummyHost,
+		PublisherID: dummyPublisherID,
+		AccountID:   dummyAccountID,
+		ZoneID:      dummyZoneID,
+		PlacementID: dummyPlacementID,
+		GDPR:        dummyGDPR,
+		GDPRConsent: dummyGDPRConsent,
+		USPrivacy:   dummyUSPrivacy,
+		MediaType:   dummyMediaType,
+		Region:      dummyRegion,
+	})
 	if err != nil {
 		return append(errs, fmt.Errorf("Unable to resolve endpoint: %s for adapter: %s. %v", endpoint, adapterName, err))
 	}
@@ -273,15 +923,49 @@ func validateAdapterUserSyncURL(userSyncURL string, adapterName string, errs con
 	return errs
 }
 
+var countryCodeFormat = regexp.MustCompile(`^[A-Za-z]{3}$`)
+
+func validateRegionByCountry(regionByCountry map[string]string, errs configErrors) configErrors {
+	for country, region := range regionByCountry {
+		if !countryCodeFormat.MatchString(country) {
+			errs = append(errs, fmt.Errorf("region_by_country keys must be ISO-3166-1-alpha-3 country codes. Got %s", country))
+		}
+		if region == "" {
+			errs = append(errs, fmt.Errorf("region_by_country.%s must not be empty", country))
+		}
+	}
+	return errs
+}
+
 // validateAdapters validates adapter's endpoint and user sync URL
 func validateAdapters(adapterMap map[string]Adapter, errs configErrors) configErrors {
 	for adapterName, adapter := range adapterMap {
 		if !adapter.Disabled {
 			// Verify that every adapter has a valid endpoint associated with it
 			errs = validateAdapterEndpoint(adapter.Endpoint, adapterName, errs)
+			for _, endpoint := range adapter.RegionalEndpoints {
+				errs = validateAdapterEndpoint(endpoint, adapterName, errs)
+			}
 
 			// Verify that valid user_sync URLs are specified in the config
 			errs = validateAdapterUserSyncURL(adapter.UserSyncURL, adapterName, errs)
+			errs = validateAdapterUserSyncURL(adapter.UserSyncURLIframe, adapterName, errs)
+			errs = validateAdapterUserSyncURL(adapter.UserSyncURLRedirect, adapterName, errs)
+
+			if adapter.Generic != nil {
+				errs = adapter.Generic.validate(adapterName, errs)
+			}
+			errs = adapter.validateAlias(adapterName, errs)
+			errs = adapter.HTTP.validate(adapterName, errs)
+			if adapter.CircuitBreaker != nil {
+				errs = adapter.CircuitBreaker.validate("adapters."+adapterName+".circuit_breaker", errs)
+			}
+			if adapter.TmaxMS < 0 {
+				errs = append(errs, fmt.Errorf("adapters.%s.tmax_ms must be >= 0. Got %d", adapterName, adapter.TmaxMS))
+			}
+			if adapter.Shadow != nil {
+				errs = adapter.Shadow.validate(adapterName, errs)
+			}
 		}
 	}
 	return errs
@@ -436,6 +1120,21 @@ func (cfg *Configuration) setDerivedDefaults() {
 	setDefaultUsersync(cfg.Adapters, openrtb_ext.BidderYieldmo, "https://ads.yieldmo.com/pbsync?gdpr={{.GDPR}}&gdpr_consent={{.GDPRConsent}}&redirectUri="+url.QueryEscape(externalURL)+"%2Fsetuid%3Fbidder%3Dyieldmo%26gdpr%3D{{.GDPR}}%26gdpr_consent%3D{{.GDPRConsent}}%26uid%3D%24UID")
 	setDefaultUsersync(cfg.Adapters, openrtb_ext.BidderGamoshi, "https://rtb.gamoshi.io/pix/0000/scm?gdpr={{.GDPR}}&consent={{.GDPRConsent}}&rurl="+url.QueryEscape(externalURL)+"%2Fsetuid%3Fbidder%3Dgamoshi%26gdpr%3D{{.GDPR}}%26gdpr_consent%3D{{.GDPRConsent}}%26uid%3D%5Bgusr%5D")
 
+	setAliasEndpoints(cfg.Adapters)
+}
+
+// setAliasEndpoints gives aliases without an endpoint of their own the endpoints of the bidder they're an alias of,
+// including its regional ones. They don't get its usersync URLs, because those would store the user's ID in the
+// other bidder's cookie family.
+func setAliasEndpoints(m map[string]Adapter) {
+	for name, adapter := range m {
+		if adapter.AliasOf != "" && adapter.Endpoint == "" && len(adapter.RegionalEndpoints) == 0 {
+			aliased := m[strings.ToLower(adapter.AliasOf)]
+			adapter.Endpoint = aliased.Endpoint
+			adapter.RegionalEndpoints = aliased.RegionalEndpoints
+			m[name] = adapter
+		}
+	}
 }
 
 func setDefaultUsersync(m map[string]Adapter, bidder openrtb_ext.BidderName, defaultValue string) {
@@ -460,6 +1159,7 @@ func SetupViper(v *viper.Viper, filename string) {
 	v.SetDefault("external_url", "http://localhost:8000")
 	v.SetDefault("host", "")
 	v.SetDefault("port", 8000)
+	v.SetDefault("region", "")
 	v.SetDefault("admin_port", 6060)
 	v.SetDefault("enable_gzip", false)
 	v.SetDefault("status_response", "")
@@ -482,6 +1182,47 @@ func SetupViper(v *viper.Viper, filename string) {
 	v.SetDefault("host_cookie.optout_cookie.name", "")
 	v.SetDefault("host_cookie.value", "")
 	v.SetDefault("host_cookie.ttl_days", 90)
+	v.SetDefault("host_cookie.max_cookie_size_bytes", 0)
+	v.SetDefault("host_cookie.eviction_priority", []string{})
+	v.SetDefault("host_cookie.security.keys", []string{})
+	v.SetDefault("host_cookie.security.encrypt", false)
+	v.SetDefault("host_cookie.security.accept_legacy_until", "")
+	v.SetDefault("user_sync.default_limit", 0)
+	v.SetDefault("user_sync.max_limit", 0)
+	v.SetDefault("user_sync.priority_groups", [][]string{})
+	v.SetDefault("user_sync.coop_sync_default", false)
+	v.SetDefault("user_id_store.enabled", false)
+	v.SetDefault("user_id_store.backend", "memory")
+	v.SetDefault("user_id_store.cookie_name", "fpid")
+	v.SetDefault("user_id_store.ext_field", "fpid")
+	v.SetDefault("user_id_store.trust_publisher_ids", false)
+	v.SetDefault("user_id_store.memory.size_bytes", 100*1024*1024)
+	v.SetDefault("user_id_store.disk.path", "")
+	v.SetDefault("shared_id.enabled", false)
+	v.SetDefault("shared_id.cookie_name", "_pubcid")
+	v.SetDefault("shared_id.source", "pubcid.org")
+	v.SetDefault("shared_id.ttl_days", 365)
+	v.SetDefault("schain.asi", "")
+	v.SetDefault("circuit_breaker.enabled", false)
+	v.SetDefault("circuit_breaker.error_rate", 0.5)
+	v.SetDefault("circuit_breaker.min_requests", 20)
+	v.SetDefault("circuit_breaker.window_seconds", 60)
+	v.SetDefault("circuit_breaker.open_seconds", 30)
+	v.SetDefault("circuit_breaker.probe_rate", 0.1)
+	v.SetDefault("traffic_shaping.enabled", false)
+	v.SetDefault("traffic_shaping.min_bid_rate", 0.01)
+	v.SetDefault("traffic_shaping.min_requests", 200)
+	v.SetDefault("traffic_shaping.exploration_rate", 0.05)
+	v.SetDefault("traffic_shaping.half_life_seconds", 3600)
+	v.SetDefault("traffic_shaping.max_entries", 100000)
+	v.SetDefault("bidder_timeouts.network_latency_buffer_ms", 0)
+	v.SetDefault("bidder_timeouts.percentile", 0)
+	v.SetDefault("bidder_timeouts.samples", 1000)
+	v.SetDefault("bidder_timeouts.min_ms", 100)
+	v.SetDefault("bidder_timeouts.notifications.enabled", false)
+	v.SetDefault("bidder_timeouts.notifications.sampling_rate", 1.0)
+	v.SetDefault("bidder_timeouts.notifications.max_per_second", 10)
+	v.SetDefault("bidder_timeouts.notifications.timeout_ms", 200)
 	v.SetDefault("http_client.max_idle_connections", 400)
 	v.SetDefault("http_client.max_idle_connections_per_host", 10)
 	v.SetDefault("http_client.idle_connection_timeout_seconds", 60)
@@ -598,6 +1339,11 @@ func SetupViper(v *viper.Viper, filename string) {
 	v.SetDefault("gdpr.usersync_if_ambiguous", false)
 	v.SetDefault("gdpr.timeouts_ms.init_vendorlist_fetches", 0)
 	v.SetDefault("gdpr.timeouts_ms.active_vendorlist_fetch", 0)
+	v.SetDefault("gdpr.geolocation.enabled", false)
+	v.SetDefault("gdpr.geolocation.database", "")
+	v.SetDefault("gdpr.geolocation.cache_size_bytes", 0)
+	v.SetDefault("gdpr.geolocation.cache_ttl_seconds", 0)
+	v.SetDefault("gdpr.geolocation.fail_closed", false)
 	v.SetDefault("currency_converter.fetch_url", "https://cdn.jsdelivr.net/gh/prebid/currency-file@1/latest.json")
 	v.SetDefault("currency_converter.fetch_interval_seconds", 0) // #280 Not activated for the time being
 	v.SetDefault("default_request.type", "")
@@ -614,6 +1360,8 @@ func SetupViper(v *viper.Viper, filename string) {
 func setBidderDefaults(v *viper.Viper, bidder string) {
 	v.SetDefault("adapters."+bidder+".endpoint", "")
 	v.SetDefault("adapters."+bidder+".usersync_url", "")
+	v.SetDefault("adapters."+bidder+".usersync_url_iframe", "")
+	v.SetDefault("adapters."+bidder+".usersync_url_redirect", "")
 	v.SetDefault("adapters."+bidder+".platform_id", "")
 	v.SetDefault("adapters."+bidder+".xapi.username", "")
 	v.SetDefault("adapters."+bidder+".xapi.password", "")
@@ -2,6 +2,7 @@ package config
 
 import (
 	"bytes"
+	"errors"
 	"strings"
 	"testing"
 	"time"
@@ -30,12 +31,26 @@ var fullConfig = []byte(`
 gdpr:
   host_vendor_id: 15
   usersync_if_ambiguous: true
+  geolocation:
+    enabled: true
+    database: /var/lib/GeoLite2-Country.mmdb
+    cache_size_bytes: 1048576
+    cache_ttl_seconds: 3600
+    fail_closed: true
+user_sync:
+  default_limit: 5
+  max_limit: 10
+  coop_sync_default: true
+  priority_groups:
+    - ["appnexus", "rubicon"]
+    - ["pubmatic"]
 host_cookie:
   cookie_name: userid
   family: prebid
   domain: cookies.prebid.org
   opt_out_url: http://prebid.org/optout
   opt_in_url: http://prebid.org/optin
+  eviction_priority: ["adnxs", "rubicon"]
 external_url: http://prebid-server.prebid.org/
 host: prebid-server.prebid.org
 port: 1234
@@ -66,6 +81,21 @@ datacache:
   filename: /usr/db/db.db
   cache_size: 10000000
   ttl_seconds: 3600
+privacy:
+  activities:
+    sync_user:
+      default: false
+      rules:
+        - allow: true
+          condition:
+            component_type: ["bidder"]
+            component_name: ["adnxs"]
+            geo: ["USA.CA"]
+            gpp_sid: [7, 8]
+  accounts:
+    some-account:
+      fetch_bids:
+        default: false
 adapters:
   appnexus:
     endpoint: http://ib.adnxs.com/some/endpoint
@@ -143,6 +173,7 @@ func TestFullConfig(t *testing.T) {
 	cmpStrings(t, "cookie family", cfg.HostCookie.Family, "prebid")
 	cmpStrings(t, "opt out", cfg.HostCookie.OptOutURL, "http://prebid.org/optout")
 	cmpStrings(t, "opt in", cfg.HostCookie.OptInURL, "http://prebid.org/optin")
+	assert.Equal(t, []string{"adnxs", "rubicon"}, cfg.HostCookie.EvictionPriority)
 	cmpStrings(t, "external url", cfg.ExternalURL, "http://prebid-server.prebid.org/")
 	cmpStrings(t, "host", cfg.Host, "prebid-server.prebid.org")
 	cmpInts(t, "port", cfg.Port, 1234)
@@ -157,7 +188,31 @@ func TestFullConfig(t *testing.T) {
 	cmpInts(t, "http_client.idle_connection_timeout_seconds", cfg.Client.IdleConnTimeout, 30)
 	cmpInts(t, "gdpr.host_vendor_id", cfg.GDPR.HostVendorID, 15)
 	cmpBools(t, "gdpr.usersync_if_ambiguous", cfg.GDPR.UsersyncIfAmbiguous, true)
+	cmpBools(t, "gdpr.geolocation.enabled", cfg.GDPR.GeoLocation.Enabled, true)
+	cmpStrings(t, "gdpr.geolocation.database", cfg.GDPR.GeoLocation.Database, "/var/lib/GeoLite2-Country.mmdb")
+	cmpInts(t, "gdpr.geolocation.cache_size_bytes", cfg.GDPR.GeoLocation.CacheSizeBytes, 1048576)
+	cmpInts(t, "gdpr.geolocation.cache_ttl_seconds", cfg.GDPR.GeoLocation.CacheTTLSeconds, 3600)
+	cmpBools(t, "gdpr.geolocation.fail_closed", cfg.GDPR.GeoLocation.FailClosed, true)
+	cmpInts(t, "user_sync.default_limit", cfg.UserSync.DefaultLimit, 5)
+	cmpInts(t, "user_sync.max_limit", cfg.UserSync.MaxLimit, 10)
+	cmpBools(t, "user_sync.coop_sync_default", cfg.UserSync.CoopSyncDefault, true)
+	assert.Equal(t, [][]string{{"appnexus", "rubicon"}, {"pubmatic"}}, cfg.UserSync.PriorityGroups, "user_sync.priority_groups")
 	cmpStrings(t, "currency_converter.fetch_url", cfg.CurrencyConverter.FetchURL, "https://currency.prebid.org")
+	if assert.NotNil(t, cfg.Privacy.Activities.SyncUser.Default) {
+		cmpBools(t, "privacy.activities.sync_user.default", *cfg.Privacy.Activities.SyncUser.Default, false)
+	}
+	if assert.Len(t, cfg.Privacy.Activities.SyncUser.Rules, 1) {
+		rule := cfg.Privacy.Activities.SyncUser.Rules[0]
+		cmpBools(t, "privacy.activities.sync_user.rules[0].allow", rule.Allow, true)
+		assert.Equal(t, []string{"bidder"}, rule.Condition.ComponentType)
+		assert.Equal(t, []string{"adnxs"}, rule.Condition.ComponentName)
+		assert.Equal(t, []string{"USA.CA"}, rule.Condition.Geo)
+		assert.Equal(t, []int{7, 8}, rule.Condition.GPPSID)
+	}
+	assert.False(t, cfg.Privacy.Activities.FetchBids.IsDefined())
+	if accountFetchBids := cfg.Privacy.Accounts["some-account"].FetchBids; assert.NotNil(t, accountFetchBids.Default) {
+		cmpBools(t, "privacy.accounts.some-account.fetch_bids.default", *accountFetchBids.Default, false)
+	}
 	cmpInts(t, "currency_converter.fetch_interval_seconds", cfg.CurrencyConverter.FetchIntervalSeconds, 1800)
 	cmpStrings(t, "recaptcha_secret", cfg.RecaptchaSecret, "asdfasdfasdfasdf")
 	cmpStrings(t, "metrics.influxdb.host", cfg.Metrics.Influxdb.Host, "upstream:8232")
@@ -209,6 +264,20 @@ func TestInvalidAdapterEndpointConfig(t *testing.T) {
 	assert.Error(t, err, "invalid endpoint in config should return an error")
 }
 
+func TestAdapterEndpointMacros(t *testing.T) {
+	cfg := newDefaultConfig(t)
+	cfg.Adapters["appnexus"] = Adapter{
+		Endpoint: "http://{{.Region}}.{{.Host}}/bid?account={{.AccountID}}&zone={{.ZoneID}}&placement={{.PlacementID}}&gdpr={{.GDPR}}&consent={{.GDPRConsent}}&us_privacy={{.USPrivacy}}&type={{.MediaType}}",
+	}
+	assert.Empty(t, validateAdapters(cfg.Adapters, nil), "Endpoints should be able to use every macro")
+
+	cfg.Adapters["appnexus"] = Adapter{Endpoint: "http://some-host.com/bid?zone={{.Zone}}"}
+	errs := validateAdapters(cfg.Adapters, nil)
+	if assert.Len(t, errs, 1) {
+		assert.Contains(t, errs[0].Error(), "Unable to resolve endpoint: http://some-host.com/bid?zone={{.Zone}} for adapter: appnexus")
+	}
+}
+
 func TestInvalidAdapterUserSyncURLConfig(t *testing.T) {
 	v := viper.New()
 	SetupViper(v, "")
@@ -224,6 +293,588 @@ func TestNegativeRequestSize(t *testing.T) {
 	assertOneError(t, cfg.validate(), "cfg.max_request_size must be >= 0. Got -1")
 }
 
+func TestNegativeCookieSize(t *testing.T) {
+	cfg := newDefaultConfig(t)
+	cfg.HostCookie.MaxCookieSizeBytes = -1
+	assertOneError(t, cfg.validate(), "host_cookie.max_cookie_size_bytes must be >= 0. Got -1")
+}
+
+func TestShortCookieKey(t *testing.T) {
+	cfg := newDefaultConfig(t)
+	cfg.HostCookie.Security.Keys = []string{"too-short"}
+	assertOneError(t, cfg.validate(), "host_cookie.security.keys[0] must be at least 32 characters long")
+}
+
+func TestCookieEncryptionWithoutKeys(t *testing.T) {
+	cfg := newDefaultConfig(t)
+	cfg.HostCookie.Security.Encrypt = true
+	assertOneError(t, cfg.validate(), "host_cookie.security.encrypt requires host_cookie.security.keys")
+}
+
+func TestInvalidLegacyCookieDeadline(t *testing.T) {
+	cfg := newDefaultConfig(t)
+	cfg.HostCookie.Security.AcceptLegacyUntil = "next week"
+	assertOneError(t, cfg.validate(), "host_cookie.security.accept_legacy_until must be an RFC 3339 timestamp. Got next week")
+}
+
+func TestLegacyCookiesAllowed(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	security := CookieSecurity{}
+	assert.True(t, security.LegacyCookiesAllowed(now), "legacy cookies should be allowed if there are no keys")
+
+	security.Keys = []string{"0123456789abcdef0123456789abcdef"}
+	assert.False(t, security.LegacyCookiesAllowed(now), "legacy cookies should be rejected once there are keys")
+
+	security.AcceptLegacyUntil = "2026-02-01T00:00:00Z"
+	assert.True(t, security.LegacyCookiesAllowed(now), "legacy cookies should be allowed before the deadline")
+	assert.False(t, security.LegacyCookiesAllowed(now.AddDate(0, 2, 0)), "legacy cookies should be rejected after the deadline")
+}
+
+func TestUserIDStoreValidation(t *testing.T) {
+	cfg := newDefaultConfig(t)
+	cfg.UserIDStore.Enabled = true
+	assertOneError(t, cfg.validate(), "user_id_store needs host_cookie.security.keys to sign the users' IDs")
+
+	cfg.HostCookie.Security.Keys = []string{"0123456789abcdef0123456789abcdef"}
+	assert.Empty(t, cfg.validate(), "the default user ID store config should be valid")
+
+	cfg.UserIDStore.Backend = "redis"
+	assertOneError(t, cfg.validate(), "user_id_store.backend must be one of: memory, disk. Got redis")
+
+	cfg.UserIDStore.Backend = "disk"
+	assertOneError(t, cfg.validate(), "user_id_store.disk.path is required for the disk backend")
+
+	cfg.UserIDStore.Disk.Path = "/var/lib/prebid/uids.db"
+	cfg.UserIDStore.CookieName = ""
+	assertOneError(t, cfg.validate(), "user_id_store.cookie_name is required")
+}
+
+func TestSharedIDValidation(t *testing.T) {
+	cfg := newDefaultConfig(t)
+	cfg.SharedID.Enabled = true
+	assert.Empty(t, cfg.validate(), "the default shared ID config should be valid")
+
+	cfg.SharedID.Source = ""
+	assertOneError(t, cfg.validate(), "shared_id.source is required when shared_id.enabled is true")
+
+	cfg.SharedID.Source = "pubcid.org"
+	cfg.SharedID.TTLDays = 0
+	assertOneError(t, cfg.validate(), "shared_id.ttl_days must be > 0. Got 0")
+}
+
+func TestSChainValidation(t *testing.T) {
+	cfg := newDefaultConfig(t)
+	cfg.SChain.ASI = "prebid-host.com"
+	assert.Empty(t, cfg.validate())
+
+	cfg.SChain.ASI = "https://prebid-host.com"
+	assertOneError(t, cfg.validate(), "schain.asi must be a domain, not a URL. Got https://prebid-host.com")
+}
+
+var genericAdapterConfig = []byte(`
+adapters:
+  somessp:
+    endpoint: http://{{.Host}}.some-ssp.com/bid
+    generic:
+      maintainer_email: someone@some-ssp.com
+      site_media_types: ["banner", "video"]
+      headers:
+        X-Api-Key: some-key
+      params_placement: name
+      bid_type_ext_field: prebid.type
+      default_bid_type: video
+      currency: EUR
+`)
+
+func TestGenericAdapterConfig(t *testing.T) {
+	v := viper.New()
+	SetupViper(v, "")
+	v.SetConfigType("yaml")
+	v.ReadConfig(bytes.NewBuffer(genericAdapterConfig))
+	cfg, err := New(v)
+	assert.NoError(t, err)
+
+	generic := cfg.Adapters["somessp"].Generic
+	if assert.NotNil(t, generic) {
+		assert.Equal(t, "someone@some-ssp.com", generic.MaintainerEmail)
+		assert.Equal(t, []string{"banner", "video"}, generic.SiteMediaTypes)
+		assert.Equal(t, map[string]string{"x-api-key": "some-key"}, generic.Headers)
+		assert.Equal(t, "name", generic.ParamsPlacement)
+		assert.Equal(t, "prebid.type", generic.BidTypeExtField)
+		assert.Equal(t, "video", generic.DefaultBidType)
+		assert.Equal(t, "EUR", generic.Currency)
+	}
+	assert.Nil(t, cfg.Adapters["appnexus"].Generic, "Other adapters should not be generic")
+}
+
+func TestGenericAdapterValidation(t *testing.T) {
+	cfg := newDefaultConfig(t)
+	cfg.Adapters["somessp"] = Adapter{
+		Endpoint: "http://some-ssp.com/bid",
+		Generic:  &GenericAdapter{SiteMediaTypes: []string{"banner"}},
+	}
+	assert.Empty(t, cfg.validate())
+
+	cfg.Adapters["somessp"] = Adapter{
+		Endpoint: "http://some-ssp.com/bid",
+		Generic: &GenericAdapter{
+			AppMediaTypes:   []string{"popup"},
+			ParamsPlacement: "imp",
+			DefaultBidType:  "audio-video",
+			Currency:        "dollars",
+			TimeoutEndpoint: "some-ssp.com/timeout",
+		},
+	}
+	errs := cfg.validate()
+	assert.Len(t, errs, 5)
+	assert.Contains(t, errs, errors.New("adapters.somessp.generic.app_media_types must only contain banner, video, audio or native. Got popup"))
+	assert.Contains(t, errs, errors.New("adapters.somessp.generic.params_placement must be bidder, ext or name. Got imp"))
+	assert.Contains(t, errs, errors.New("adapters.somessp.generic.default_bid_type must be banner, video, audio or native. Got audio-video"))
+	assert.Contains(t, errs, errors.New("adapters.somessp.generic.currency must be an ISO-4217 currency code. Got dollars"))
+	assert.Contains(t, errs, errors.New("adapters.somessp.generic.timeout_endpoint must be a URL. Got some-ssp.com/timeout"))
+
+	delete(cfg.Adapters, "somessp")
+	cfg.Adapters["appnexus"] = Adapter{
+		Endpoint: "http://ib.adnxs.com/openrtb2",
+		Generic:  &GenericAdapter{SiteMediaTypes: []string{"banner"}},
+	}
+	assertOneError(t, cfg.validate(), "adapters.appnexus.generic can't be used, because Prebid Server already has an adapter named appnexus")
+}
+
+func TestAliasValidation(t *testing.T) {
+	cfg := newDefaultConfig(t)
+	cfg.Adapters["somealias"] = Adapter{
+		AliasOf:      "appnexus",
+		Endpoint:     "http://some-alias.com/bid",
+		GDPRVendorID: 12,
+		BidderInfo:   &BidderInfoOverrides{SiteMediaTypes: []string{"banner"}},
+	}
+	assert.Empty(t, cfg.validate())
+
+	cfg.Adapters["somealias"] = Adapter{
+		AliasOf:    "unknown",
+		Endpoint:   "http://some-alias.com/bid",
+		Generic:    &GenericAdapter{SiteMediaTypes: []string{"banner"}},
+		BidderInfo: &BidderInfoOverrides{AppMediaTypes: []string{"popup"}},
+	}
+	errs := cfg.validate()
+	assert.Len(t, errs, 3)
+	assert.Contains(t, errs, errors.New("adapters.somealias can't have both alias_of and generic"))
+	assert.Contains(t, errs, errors.New("adapters.somealias.alias_of must be the name of a bidder which isn't an alias. Got unknown"))
+	assert.Contains(t, errs, errors.New("adapters.somealias.bidder_info.app_media_types must only contain banner, video, audio or native. Got popup"))
+
+	delete(cfg.Adapters, "somealias")
+	cfg.Adapters["rubicon"] = Adapter{Endpoint: "http://some-alias.com/bid", AliasOf: "appnexus"}
+	assertOneError(t, cfg.validate(), "adapters.rubicon.alias_of can't be used, because Prebid Server already has a bidder named rubicon")
+
+	cfg.Adapters["rubicon"] = Adapter{Endpoint: "http://some-alias.com/bid", GDPRVendorID: 52}
+	assertOneError(t, cfg.validate(), "adapters.rubicon.gdpr_vendor_id and adapters.rubicon.bidder_info can only be used by aliases")
+}
+
+func TestAliasEndpoints(t *testing.T) {
+	cfg := &Configuration{
+		Adapters: map[string]Adapter{
+			"adkerneladn": {Endpoint: "http://adkernel.com/bid"},
+			"somealias":   {AliasOf: "adkernelAdn"},
+			"otheralias":  {AliasOf: "adkernelAdn", Endpoint: "http://other-alias.com/bid"},
+		},
+	}
+	cfg.setDerivedDefaults()
+	assert.Equal(t, "http://adkernel.com/bid", cfg.Adapters["somealias"].Endpoint, "Aliases should get the aliased bidder's endpoint by default")
+	assert.Equal(t, "http://other-alias.com/bid", cfg.Adapters["otheralias"].Endpoint)
+	assert.Empty(t, cfg.Adapters["somealias"].UserSyncURL, "Aliases should not get the aliased bidder's usersync URL")
+
+	aliasOf, ok := cfg.Adapters["somealias"].AliasedBidder()
+	assert.True(t, ok)
+	assert.Equal(t, openrtb_ext.BidderAdkernelAdn, aliasOf)
+}
+
+var regionalEndpointsConfig = []byte(`
+region: us-east
+region_by_country:
+  DEU: eu-west
+adapters:
+  appnexus:
+    endpoint: http://us.adnxs.com/bid
+    regional_endpoints:
+      eu-west: http://eu.adnxs.com/bid
+  somealias:
+    alias_of: appnexus
+`)
+
+func TestRegionalEndpointsConfig(t *testing.T) {
+	v := viper.New()
+	SetupViper(v, "")
+	v.SetConfigType("yaml")
+	v.ReadConfig(bytes.NewBuffer(regionalEndpointsConfig))
+	cfg, err := New(v)
+	assert.NoError(t, err)
+
+	assert.Equal(t, map[string]string{"deu": "eu-west"}, cfg.RegionByCountry)
+	assert.Equal(t, "http://us.adnxs.com/bid", cfg.Adapters["appnexus"].EndpointFor("us-east"))
+	assert.Equal(t, "http://eu.adnxs.com/bid", cfg.Adapters["appnexus"].EndpointFor("EU-West"))
+	assert.Equal(t, "http://eu.adnxs.com/bid", cfg.Adapters["somealias"].EndpointFor("eu-west"), "Aliases should get the aliased bidder's regional endpoints")
+}
+
+func TestRegionalEndpointsValidation(t *testing.T) {
+	cfg := newDefaultConfig(t)
+	cfg.RegionByCountry = map[string]string{"de": "eu-west", "fra": ""}
+	adapter := cfg.Adapters["appnexus"]
+	adapter.RegionalEndpoints = map[string]string{"eu-west": "eu.adnxs.com/bid"}
+	cfg.Adapters["appnexus"] = adapter
+
+	errs := cfg.validate()
+	assert.Len(t, errs, 3)
+	assert.Contains(t, errs, errors.New("region_by_country keys must be ISO-3166-1-alpha-3 country codes. Got de"))
+	assert.Contains(t, errs, errors.New("region_by_country.fra must not be empty"))
+	assert.Contains(t, errs, errors.New("The endpoint: eu.adnxs.com/bid for appnexus is not a valid URL"))
+}
+
+var bidderHTTPConfig = []byte(`
+adapters:
+  appnexus:
+    http:
+      headers:
+        X-Api-Key: some-key
+      auth:
+        type: basic
+        username: user
+        password: pass
+      gzip: true
+      max_idle_connections: 20
+      http2: true
+`)
+
+func TestBidderHTTPConfig(t *testing.T) {
+	v := viper.New()
+	SetupViper(v, "")
+	v.SetConfigType("yaml")
+	v.ReadConfig(bytes.NewBuffer(bidderHTTPConfig))
+	cfg, err := New(v)
+	assert.NoError(t, err)
+
+	assert.Equal(t, BidderHTTP{
+		Headers:      map[string]string{"x-api-key": "some-key"},
+		Auth:         BidderAuth{Type: "basic", Username: "user", Password: "pass"},
+		Gzip:         true,
+		MaxIdleConns: 20,
+		HTTP2:        true,
+	}, cfg.Adapters["appnexus"].HTTP)
+	assert.NotEmpty(t, cfg.Adapters["appnexus"].Endpoint, "Other appnexus settings should keep their defaults")
+}
+
+func TestBidderHTTPValidation(t *testing.T) {
+	cfg := newDefaultConfig(t)
+	cfg.Adapters["appnexus"] = Adapter{
+		Endpoint: "http://ib.adnxs.com/openrtb2",
+		HTTP: BidderHTTP{
+			Auth:         BidderAuth{Type: "bearer", Token: "some-token"},
+			MaxIdleConns: 10,
+		},
+	}
+	assert.Empty(t, cfg.validate())
+
+	cfg.Adapters["appnexus"] = Adapter{
+		Endpoint: "http://ib.adnxs.com/openrtb2",
+		HTTP: BidderHTTP{
+			Auth:         BidderAuth{Type: "digest"},
+			MaxIdleConns: -1,
+		},
+	}
+	errs := cfg.validate()
+	assert.Len(t, errs, 2)
+	assert.Contains(t, errs, errors.New("adapters.appnexus.http.auth.type must be basic or bearer. Got digest"))
+	assert.Contains(t, errs, errors.New("adapters.appnexus.http.max_idle_connections must be >= 0. Got -1"))
+
+	cfg.Adapters["appnexus"] = Adapter{
+		Endpoint: "http://ib.adnxs.com/openrtb2",
+		HTTP:     BidderHTTP{Auth: BidderAuth{Type: "basic", Password: "pass"}},
+	}
+	assertOneError(t, cfg.validate(), "adapters.appnexus.http.auth.username is required for basic auth")
+}
+
+var circuitBreakerConfig = []byte(`
+circuit_breaker:
+  enabled: true
+  error_rate: 0.8
+adapters:
+  appnexus:
+    circuit_breaker:
+      enabled: false
+`)
+
+func TestCircuitBreakerConfig(t *testing.T) {
+	v := viper.New()
+	SetupViper(v, "")
+	v.SetConfigType("yaml")
+	v.ReadConfig(bytes.NewBuffer(circuitBreakerConfig))
+	cfg, err := New(v)
+	assert.NoError(t, err)
+
+	assert.Equal(t, CircuitBreaker{
+		Enabled:       true,
+		ErrorRate:     0.8,
+		MinRequests:   20,
+		WindowSeconds: 60,
+		OpenSeconds:   30,
+		ProbeRate:     0.1,
+	}, cfg.BidderCircuitBreaker("rubicon"), "Bidders without their own circuit breaker should use the top-level one")
+	assert.Equal(t, CircuitBreaker{}, cfg.BidderCircuitBreaker("appnexus"))
+}
+
+func TestCircuitBreakerValidation(t *testing.T) {
+	cfg := newDefaultConfig(t)
+	cfg.CircuitBreaker = CircuitBreaker{
+		Enabled:       true,
+		ErrorRate:     1.5,
+		MinRequests:   0,
+		WindowSeconds: 0,
+		OpenSeconds:   0,
+		ProbeRate:     0,
+	}
+	errs := cfg.validate()
+	assert.Len(t, errs, 5)
+	assert.Contains(t, errs, errors.New("circuit_breaker.error_rate must be > 0 and <= 1. Got 1.5"))
+	assert.Contains(t, errs, errors.New("circuit_breaker.min_requests must be >= 1. Got 0"))
+	assert.Contains(t, errs, errors.New("circuit_breaker.window_seconds must be >= 1. Got 0"))
+	assert.Contains(t, errs, errors.New("circuit_breaker.open_seconds must be >= 1. Got 0"))
+	assert.Contains(t, errs, errors.New("circuit_breaker.probe_rate must be > 0 and <= 1. Got 0"))
+
+	cfg.CircuitBreaker = CircuitBreaker{}
+	cfg.Adapters["appnexus"] = Adapter{
+		Endpoint:       "http://ib.adnxs.com/openrtb2",
+		CircuitBreaker: &CircuitBreaker{Enabled: true, ErrorRate: 0.5, MinRequests: 10, WindowSeconds: 60, OpenSeconds: 30, ProbeRate: 2},
+	}
+	assertOneError(t, cfg.validate(), "adapters.appnexus.circuit_breaker.probe_rate must be > 0 and <= 1. Got 2")
+}
+
+var bidderShadowConfig = []byte(`
+adapters:
+  appnexus:
+    endpoint: http://ib.adnxs.com/openrtb2
+    shadow:
+      endpoint: http://beta.adnxs.com/openrtb2
+      sampling_rate: 0.05
+      timeout_ms: 500
+`)
+
+func TestBidderShadowConfig(t *testing.T) {
+	v := viper.New()
+	SetupViper(v, "")
+	v.SetConfigType("yaml")
+	v.ReadConfig(bytes.NewBuffer(bidderShadowConfig))
+	cfg, err := New(v)
+	assert.NoError(t, err)
+
+	assert.Equal(t, &BidderShadow{
+		Endpoint:     "http://beta.adnxs.com/openrtb2",
+		SamplingRate: 0.05,
+		TimeoutMS:    500,
+	}, cfg.Adapters["appnexus"].Shadow)
+	assert.Nil(t, cfg.Adapters["rubicon"].Shadow, "Bidders shouldn't be mirrored unless their config says so")
+}
+
+func TestBidderShadowValidation(t *testing.T) {
+	cfg := newDefaultConfig(t)
+	cfg.Adapters["appnexus"] = Adapter{
+		Endpoint: "http://ib.adnxs.com/openrtb2",
+		Shadow:   &BidderShadow{Endpoint: "beta.adnxs.com", SamplingRate: 1.5},
+	}
+	errs := cfg.validate()
+	assert.Len(t, errs, 3)
+	assert.Contains(t, errs, errors.New("adapters.appnexus.shadow.endpoint must be a valid URL. Got beta.adnxs.com"))
+	assert.Contains(t, errs, errors.New("adapters.appnexus.shadow.sampling_rate must be >= 0 and <= 1. Got 1.5"))
+	assert.Contains(t, errs, errors.New("adapters.appnexus.shadow.timeout_ms must be >= 1. Got 0"))
+}
+
+var trafficShapingConfig = []byte(`
+traffic_shaping:
+  enabled: true
+  min_bid_rate: 0.02
+  accounts:
+    some-account:
+      disabled: true
+`)
+
+func TestTrafficShapingConfig(t *testing.T) {
+	v := viper.New()
+	SetupViper(v, "")
+	v.SetConfigType("yaml")
+	v.ReadConfig(bytes.NewBuffer(trafficShapingConfig))
+	cfg, err := New(v)
+	assert.NoError(t, err)
+
+	assert.Equal(t, TrafficShaping{
+		Enabled:         true,
+		MinBidRate:      0.02,
+		MinRequests:     200,
+		ExplorationRate: 0.05,
+		HalfLifeSeconds: 3600,
+		MaxEntries:      100000,
+		Accounts:        map[string]AccountTrafficShaping{"some-account": {Disabled: true}},
+	}, cfg.TrafficShaping)
+}
+
+func TestTrafficShapingValidation(t *testing.T) {
+	cfg := newDefaultConfig(t)
+	cfg.TrafficShaping = TrafficShaping{
+		Enabled:         true,
+		MinBidRate:      0,
+		MinRequests:     0,
+		ExplorationRate: -0.1,
+		HalfLifeSeconds: 0,
+		MaxEntries:      0,
+	}
+	errs := cfg.validate()
+	assert.Len(t, errs, 5)
+	assert.Contains(t, errs, errors.New("traffic_shaping.min_bid_rate must be > 0 and <= 1. Got 0"))
+	assert.Contains(t, errs, errors.New("traffic_shaping.min_requests must be >= 1. Got 0"))
+	assert.Contains(t, errs, errors.New("traffic_shaping.exploration_rate must be >= 0 and <= 1. Got -0.1"))
+	assert.Contains(t, errs, errors.New("traffic_shaping.half_life_seconds must be >= 1. Got 0"))
+	assert.Contains(t, errs, errors.New("traffic_shaping.max_entries must be >= 1. Got 0"))
+
+	cfg.TrafficShaping.Enabled = false
+	assert.Empty(t, cfg.validate(), "Disabled traffic shaping shouldn't be validated")
+}
+
+var bidderRulesConfig = []byte(`
+bidder_rules:
+  rules:
+    - name: no-app-in-canada
+      bidders: ["appnexus"]
+      condition:
+        channel: ["app"]
+        geo: ["CAN"]
+    - bidders: ["rubicon"]
+      condition:
+        device_type: [4, 5]
+        media_type: ["video"]
+      call_percent: 20
+  accounts:
+    some-account:
+      - call_percent: 100
+`)
+
+func TestBidderRulesConfig(t *testing.T) {
+	v := viper.New()
+	SetupViper(v, "")
+	v.SetConfigType("yaml")
+	v.ReadConfig(bytes.NewBuffer(bidderRulesConfig))
+	cfg, err := New(v)
+	assert.NoError(t, err)
+
+	assert.Equal(t, BidderRules{
+		Rules: []BidderRule{
+			{
+				Name:      "no-app-in-canada",
+				Bidders:   []string{"appnexus"},
+				Condition: BidderRuleCondition{Channel: []string{"app"}, Geo: []string{"CAN"}},
+			},
+			{
+				Bidders:     []string{"rubicon"},
+				Condition:   BidderRuleCondition{DeviceType: []int{4, 5}, MediaType: []string{"video"}},
+				CallPercent: 20,
+			},
+		},
+		Accounts: map[string][]BidderRule{
+			"some-account": {{CallPercent: 100}},
+		},
+	}, cfg.BidderRules)
+}
+
+func TestBidderRulesValidation(t *testing.T) {
+	cfg := newDefaultConfig(t)
+	cfg.BidderRules.Rules = []BidderRule{{
+		Condition: BidderRuleCondition{
+			Channel:   []string{"mobile"},
+			Geo:       []string{"US-CA"},
+			MediaType: []string{"display"},
+		},
+		CallPercent: 120,
+	}}
+	cfg.BidderRules.Accounts = map[string][]BidderRule{
+		"some-account": {{CallPercent: -1}},
+	}
+	errs := cfg.validate()
+	assert.Len(t, errs, 5)
+	assert.Contains(t, errs, errors.New("bidder_rules.rules[0].call_percent must be >= 0 and <= 100. Got 120"))
+	assert.Contains(t, errs, errors.New("bidder_rules.rules[0].condition.channel must be one of web, app, amp or video. Got mobile"))
+	assert.Contains(t, errs, errors.New(`bidder_rules.rules[0].condition.geo must look like "USA" or "USA.CA". Got US-CA`))
+	assert.Contains(t, errs, errors.New("bidder_rules.rules[0].condition.media_type must be one of banner, video, audio or native. Got display"))
+	assert.Contains(t, errs, errors.New("bidder_rules.accounts.some-account[0].call_percent must be >= 0 and <= 100. Got -1"))
+}
+
+var bidderTimeoutsConfig = []byte(`
+bidder_timeouts:
+  network_latency_buffer_ms: 20
+  percentile: 95
+  notifications:
+    enabled: true
+    sampling_rate: 0.5
+adapters:
+  appnexus:
+    tmax_ms: 300
+`)
+
+func TestBidderTimeoutsConfig(t *testing.T) {
+	v := viper.New()
+	SetupViper(v, "")
+	v.SetConfigType("yaml")
+	v.ReadConfig(bytes.NewBuffer(bidderTimeoutsConfig))
+	cfg, err := New(v)
+	assert.NoError(t, err)
+
+	assert.Equal(t, BidderTimeouts{
+		NetworkLatencyBufferMS: 20,
+		Percentile:             95,
+		Samples:                1000,
+		MinMS:                  100,
+		Notifications: TimeoutNotifications{
+			Enabled:      true,
+			SamplingRate: 0.5,
+			MaxPerSecond: 10,
+			TimeoutMS:    200,
+		},
+	}, cfg.BidderTimeouts)
+	assert.Equal(t, 300, cfg.Adapters["appnexus"].TmaxMS)
+	assert.Equal(t, 0, cfg.Adapters["rubicon"].TmaxMS)
+}
+
+func TestBidderTimeoutsValidation(t *testing.T) {
+	cfg := newDefaultConfig(t)
+	cfg.BidderTimeouts = BidderTimeouts{
+		NetworkLatencyBufferMS: -1,
+		Percentile:             101,
+		Samples:                0,
+		MinMS:                  -1,
+		Notifications: TimeoutNotifications{
+			Enabled:      true,
+			SamplingRate: 0,
+			MaxPerSecond: 0,
+			TimeoutMS:    0,
+		},
+	}
+	adapter := cfg.Adapters["appnexus"]
+	adapter.TmaxMS = -1
+	cfg.Adapters["appnexus"] = adapter
+
+	errs := cfg.validate()
+	assert.Len(t, errs, 8)
+	assert.Contains(t, errs, errors.New("bidder_timeouts.network_latency_buffer_ms must be >= 0. Got -1"))
+	assert.Contains(t, errs, errors.New("bidder_timeouts.percentile must be >= 0 and <= 100. Got 101"))
+	assert.Contains(t, errs, errors.New("bidder_timeouts.samples must be >= 1 if bidder_timeouts.percentile is defined. Got 0"))
+	assert.Contains(t, errs, errors.New("bidder_timeouts.min_ms must be >= 0. Got -1"))
+	assert.Contains(t, errs, errors.New("bidder_timeouts.notifications.sampling_rate must be > 0 and <= 1. Got 0"))
+	assert.Contains(t, errs, errors.New("bidder_timeouts.notifications.max_per_second must be >= 1. Got 0"))
+	assert.Contains(t, errs, errors.New("bidder_timeouts.notifications.timeout_ms must be >= 1. Got 0"))
+	assert.Contains(t, errs, errors.New("adapters.appnexus.tmax_ms must be >= 0. Got -1"))
+
+	cfg.BidderTimeouts = BidderTimeouts{Notifications: TimeoutNotifications{SamplingRate: 2}}
+	adapter.TmaxMS = 0
+	cfg.Adapters["appnexus"] = adapter
+	assert.Empty(t, cfg.validate(), "Disabled notifications shouldn't be validated")
+}
+
 func TestNegativeVendorID(t *testing.T) {
 	cfg := newDefaultConfig(t)
 	cfg.GDPR.HostVendorID = -1
@@ -243,6 +894,50 @@ func TestOverflowedVendorID(t *testing.T) {
 	assertOneError(t, cfg.validate(), "gdpr.host_vendor_id must be in the range [0, 65535]. Got 65536")
 }
 
+func TestGeoLocationWithoutDatabase(t *testing.T) {
+	cfg := newDefaultConfig(t)
+	cfg.GDPR.GeoLocation.Enabled = true
+	assertOneError(t, cfg.validate(), "gdpr.geolocation.database must be defined if gdpr.geolocation.enabled is true")
+}
+
+func TestGeoLocationCacheTTLWithoutSize(t *testing.T) {
+	cfg := newDefaultConfig(t)
+	cfg.GDPR.GeoLocation.Enabled = true
+	cfg.GDPR.GeoLocation.Database = "GeoLite2-Country.mmdb"
+	cfg.GDPR.GeoLocation.CacheTTLSeconds = 60
+	assertOneError(t, cfg.validate(), "gdpr.geolocation.cache_size_bytes must be positive if gdpr.geolocation.cache_ttl_seconds is defined. Got 0")
+}
+
+func TestUserSyncDefaultLimitOverMax(t *testing.T) {
+	cfg := newDefaultConfig(t)
+	cfg.UserSync.DefaultLimit = 5
+	cfg.UserSync.MaxLimit = 2
+	assertOneError(t, cfg.validate(), "user_sync.default_limit must not be greater than user_sync.max_limit. Got 5 and 2")
+}
+
+func TestUserSyncNegativeLimit(t *testing.T) {
+	cfg := newDefaultConfig(t)
+	cfg.UserSync.MaxLimit = -1
+	assertOneError(t, cfg.validate(), "user_sync.max_limit must be >= 0. Got -1")
+}
+
+func TestUserSyncRepeatedPriorityBidder(t *testing.T) {
+	cfg := newDefaultConfig(t)
+	cfg.UserSync.PriorityGroups = [][]string{{"appnexus"}, {"rubicon", "appnexus"}}
+	assertOneError(t, cfg.validate(), "user_sync.priority_groups has the bidder appnexus in groups 0 and 1")
+}
+
+func TestInvalidActivityCondition(t *testing.T) {
+	cfg := newDefaultConfig(t)
+	cfg.Privacy.Activities.FetchBids.Rules = []ActivityRule{{
+		Condition: ActivityCondition{ComponentType: []string{"adapter"}},
+	}}
+	assertOneError(t, cfg.validate(), "privacy.activities.fetch_bids.rules[0].condition.component_type must be one of bidder, analytics or general. Got adapter")
+
+	cfg.Privacy.Activities.FetchBids.Rules[0].Condition = ActivityCondition{Geo: []string{"US-CA"}}
+	assertOneError(t, cfg.validate(), `privacy.activities.fetch_bids.rules[0].condition.geo must look like "USA" or "USA.CA". Got US-CA`)
+}
+
 func TestNegativeCurrencyConverterFetchInterval(t *testing.T) {
 	cfg := Configuration{
 		CurrencyConverter: CurrencyConverter{
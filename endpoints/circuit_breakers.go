@@ -0,0 +1,28 @@
+package endpoints
+
+import (
+	"net/http"
+
+	"github.com/golang/glog"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/prebid/prebid-server/exchange"
+)
+
+type circuitBreakers interface {
+	States() []exchange.CircuitBreakerStatus
+}
+
+// NewCircuitBreakersEndpoint returns the current state of each Bidder's circuit breaker.
+func NewCircuitBreakersEndpoint(breakers circuitBreakers) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		jsonOutput, err := jsoniter.Marshal(breakers.States())
+		if err != nil {
+			glog.Errorf("/bidders/circuit_breakers Critical error when trying to marshal the circuit breaker states: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(jsonOutput)
+	}
+}
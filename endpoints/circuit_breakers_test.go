@@ -0,0 +1,43 @@
+package endpoints
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prebid/prebid-server/exchange"
+	"github.com/prebid/prebid-server/pbsmetrics"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockCircuitBreakers []exchange.CircuitBreakerStatus
+
+func (m mockCircuitBreakers) States() []exchange.CircuitBreakerStatus {
+	return m
+}
+
+func TestCircuitBreakersEndpoint(t *testing.T) {
+	openedAt := time.Date(2019, 6, 1, 12, 0, 0, 0, time.UTC)
+	handler := NewCircuitBreakersEndpoint(mockCircuitBreakers{
+		{Bidder: "appnexus", State: pbsmetrics.CircuitBreakerOpen, Requests: 20, Failures: 15, OpenedAt: &openedAt},
+		{Bidder: "rubicon", State: pbsmetrics.CircuitBreakerClosed, Requests: 3},
+	})
+	w := httptest.NewRecorder()
+
+	handler(w, nil)
+
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.JSONEq(t, `[
+		{"bidder":"appnexus","state":"open","requests":20,"failures":15,"opened_at":"2019-06-01T12:00:00Z"},
+		{"bidder":"rubicon","state":"closed","requests":3,"failures":0}
+	]`, w.Body.String())
+}
+
+func TestCircuitBreakersEndpointWithoutBreakers(t *testing.T) {
+	handler := NewCircuitBreakersEndpoint(mockCircuitBreakers{})
+	w := httptest.NewRecorder()
+
+	handler(w, nil)
+
+	assert.JSONEq(t, `[]`, w.Body.String())
+}
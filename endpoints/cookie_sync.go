@@ -17,19 +17,24 @@ import (
 	"github.com/prebid/prebid-server/analytics"
 	"github.com/prebid/prebid-server/config"
 	"github.com/prebid/prebid-server/gdpr"
+	"github.com/prebid/prebid-server/gpp"
 	"github.com/prebid/prebid-server/openrtb_ext"
 	"github.com/prebid/prebid-server/pbsmetrics"
+	"github.com/prebid/prebid-server/privacy"
 	"github.com/prebid/prebid-server/usersync"
 )
 
-func NewCookieSyncEndpoint(syncers map[openrtb_ext.BidderName]usersync.Usersyncer, cfg *config.Configuration, syncPermissions gdpr.Permissions, metrics pbsmetrics.MetricsEngine, pbsAnalytics analytics.PBSAnalyticsModule) httprouter.Handle {
+func NewCookieSyncEndpoint(syncers map[openrtb_ext.BidderName]usersync.Usersyncer, cfg *config.Configuration, syncPermissions gdpr.Permissions, metrics pbsmetrics.MetricsEngine, pbsAnalytics analytics.PBSAnalyticsModule, uidStore *usersync.UIDStore) httprouter.Handle {
 	deps := &cookieSyncDeps{
 		syncers:         syncers,
 		hostCookie:      &cfg.HostCookie,
 		gDPR:            &cfg.GDPR,
+		userSync:        &cfg.UserSync,
+		privacy:         &cfg.Privacy,
 		syncPermissions: syncPermissions,
 		metrics:         metrics,
 		pbsAnalytics:    pbsAnalytics,
+		uidStore:        uidStore,
 	}
 	return deps.Endpoint
 }
@@ -38,9 +43,12 @@ type cookieSyncDeps struct {
 	syncers         map[openrtb_ext.BidderName]usersync.Usersyncer
 	hostCookie      *config.HostCookie
 	gDPR            *config.GDPR
+	userSync        *config.UserSync
+	privacy         *config.Privacy
 	syncPermissions gdpr.Permissions
 	metrics         pbsmetrics.MetricsEngine
 	pbsAnalytics    analytics.PBSAnalyticsModule
+	uidStore        *usersync.UIDStore
 }
 
 func (deps *cookieSyncDeps) Endpoint(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
@@ -54,7 +62,7 @@ func (deps *cookieSyncDeps) Endpoint(w http.ResponseWriter, r *http.Request, _ h
 	defer deps.pbsAnalytics.LogCookieSyncObject(&co)
 
 	deps.metrics.RecordCookieSync(pbsmetrics.Labels{})
-	userSyncCookie := usersync.ParsePBSCookieFromRequest(r, deps.hostCookie)
+	userSyncCookie, _ := deps.uidStore.ParsePBSCookieFromRequest(r.Context(), r, deps.hostCookie, "")
 	if !userSyncCookie.AllowSyncs() {
 		http.Error(w, "User has opted out", http.StatusUnauthorized)
 		co.Status = http.StatusUnauthorized
@@ -86,6 +94,21 @@ func (deps *cookieSyncDeps) Endpoint(w http.ResponseWriter, r *http.Request, _ h
 		return
 	}
 
+	if err := parsedReq.applyGPP(); err != nil {
+		co.Status = http.StatusBadRequest
+		co.Errors = append(co.Errors, err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	typeFilter, err := parsedReq.FilterSettings.parse()
+	if err != nil {
+		co.Status = http.StatusBadRequest
+		co.Errors = append(co.Errors, err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	if parsedReq.GDPR != nil && *parsedReq.GDPR == 1 && parsedReq.Consent == "" {
 		co.Status = http.StatusBadRequest
 		co.Errors = append(co.Errors, errors.New("gdpr_consent is required if gdpr is 1"))
@@ -107,22 +130,29 @@ func (deps *cookieSyncDeps) Endpoint(w http.ResponseWriter, r *http.Request, _ h
 			parsedReq.Bidders = append(parsedReq.Bidders, string(bidder))
 		}
 	}
+	coopSync := deps.userSync.CoopSyncDefault
+	if parsedReq.CoopSync != nil {
+		coopSync = *parsedReq.CoopSync
+	}
+	parsedReq.Bidders = chooseBidders(parsedReq.Bidders, deps.syncers, deps.userSync.PriorityGroups, coopSync)
 
 	parsedReq.filterExistingSyncs(deps.syncers, userSyncCookie)
-	adapterSyncs := make(map[openrtb_ext.BidderName]bool)
-	for _, b := range parsedReq.Bidders {
-		// assume all bidders will be GDPR blocked
-		adapterSyncs[openrtb_ext.BidderName(b)] = true
+	activityControl := privacy.NewActivityControl(deps.privacy, parsedReq.Account)
+	if gdprRule := parsedReq.gdprSyncRule(deps.syncers, deps.syncPermissions); gdprRule != nil {
+		activityControl = activityControl.WithDefault(privacy.ActivitySyncUser, gdprRule)
 	}
-	parsedReq.filterForGDPR(deps.syncPermissions)
-	for _, b := range parsedReq.Bidders {
-		// surviving bidders are not GDPR blocked
-		adapterSyncs[openrtb_ext.BidderName(b)] = false
+	candidates := append([]string(nil), parsedReq.Bidders...)
+	omittedBefore := len(parsedReq.omitted)
+	parsedReq.filterForActivities(deps.syncers, activityControl)
+	gdprBlocked := make(map[string]bool)
+	for _, omitted := range parsedReq.omitted[omittedBefore:] {
+		gdprBlocked[omitted.Bidder] = omitted.Error == omitReasonGDPR
 	}
-	for b, g := range adapterSyncs {
-		deps.metrics.RecordAdapterCookieSync(b, g)
+	for _, b := range candidates {
+		deps.metrics.RecordAdapterCookieSync(openrtb_ext.BidderName(b), gdprBlocked[b])
 	}
-	parsedReq.filterToLimit()
+	syncTypes := parsedReq.filterForSyncTypes(deps.syncers, typeFilter)
+	parsedReq.filterToLimit(deps.userSync)
 
 	csResp := cookieSyncResponse{
 		Status:       cookieSyncStatus(userSyncCookie.LiveSyncCount()),
@@ -130,7 +160,7 @@ func (deps *cookieSyncDeps) Endpoint(w http.ResponseWriter, r *http.Request, _ h
 	}
 	for i := 0; i < len(parsedReq.Bidders); i++ {
 		bidder := parsedReq.Bidders[i]
-		syncInfo, err := deps.syncers[openrtb_ext.BidderName(bidder)].GetUsersyncInfo(gdprToString(parsedReq.GDPR), parsedReq.Consent)
+		syncInfo, err := deps.syncers[openrtb_ext.BidderName(bidder)].GetUsersyncInfoForType(syncTypes[bidder], gdprToString(parsedReq.GDPR), parsedReq.Consent)
 		if err == nil {
 			newSync := &usersync.CookieSyncBidders{
 				BidderCode:   bidder,
@@ -143,6 +173,10 @@ func (deps *cookieSyncDeps) Endpoint(w http.ResponseWriter, r *http.Request, _ h
 		}
 	}
 
+	if parsedReq.Debug {
+		csResp.Debug = parsedReq.omitted
+	}
+
 	if len(csResp.BidderStatus) > 0 {
 		co.BidderStatus = append(co.BidderStatus, csResp.BidderStatus...)
 	}
@@ -178,63 +212,292 @@ func cookieSyncStatus(syncCount int) string {
 }
 
 type cookieSyncRequest struct {
-	Bidders []string `json:"bidders"`
-	GDPR    *int     `json:"gdpr"`
-	Consent string   `json:"gdpr_consent"`
-	Limit   int      `json:"limit"`
+	Bidders  []string `json:"bidders"`
+	GDPR     *int     `json:"gdpr"`
+	Consent  string   `json:"gdpr_consent"`
+	Limit    int      `json:"limit"`
+	GPP      string   `json:"gpp"`
+	GPPSID   string   `json:"gpp_sid"`
+	Account  string   `json:"account"`
+	CoopSync *bool    `json:"coopSync"`
+	Debug    bool     `json:"debug"`
+
+	FilterSettings *cookieSyncFilterSettings `json:"filterSettings"`
+
+	// omitted explains why each bidder which won't be synced was left out.
+	omitted []cookieSyncDebug
+}
+
+// Reasons for leaving a bidder out of the /cookie_sync response
+const (
+	omitReasonAlreadySynced = "Already in sync"
+	omitReasonNoSyncer      = "Unsupported bidder"
+	omitReasonGDPR          = "Rejected by GDPR"
+	omitReasonPrivacy       = "Rejected by privacy activity controls"
+	omitReasonLimit         = "Limit reached"
+	omitReasonSyncType      = "No sync type allowed by filterSettings"
+)
+
+// omit removes the bidder at index i, and records the reason why.
+func (req *cookieSyncRequest) omit(i int, reason string) {
+	req.omitted = append(req.omitted, cookieSyncDebug{Bidder: req.Bidders[i], Error: reason})
+	req.Bidders = append(req.Bidders[:i], req.Bidders[i+1:]...)
+}
+
+// chooseBidders orders the bidders which might be synced. The requested bidders come first, followed by every
+// other bidder with a syncer if this is a cooperative sync. Within each of those, the priority groups come first.
+// Bidders are shuffled within each group, so that limits don't always favor the same ones.
+func chooseBidders(requested []string, syncers map[openrtb_ext.BidderName]usersync.Usersyncer, priorityGroups [][]string, coopSync bool) []string {
+	chosen := make([]string, 0, len(requested))
+	seen := make(map[string]bool, len(requested))
+	addShuffled := func(bidders []string, include func(string) bool) {
+		group := make([]string, 0, len(bidders))
+		for _, bidder := range bidders {
+			if !seen[bidder] && include(bidder) {
+				seen[bidder] = true
+				group = append(group, bidder)
+			}
+		}
+		rand.Shuffle(len(group), func(i, j int) {
+			group[i], group[j] = group[j], group[i]
+		})
+		chosen = append(chosen, group...)
+	}
+
+	isRequested := make(map[string]bool, len(requested))
+	for _, bidder := range requested {
+		isRequested[bidder] = true
+	}
+	wasRequested := func(bidder string) bool {
+		return isRequested[bidder]
+	}
+	for _, group := range priorityGroups {
+		addShuffled(group, wasRequested)
+	}
+	addShuffled(requested, wasRequested)
+
+	if coopSync {
+		hasSyncer := func(bidder string) bool {
+			_, ok := syncers[openrtb_ext.BidderName(bidder)]
+			return ok
+		}
+		for _, group := range priorityGroups {
+			addShuffled(group, hasSyncer)
+		}
+		others := make([]string, 0, len(syncers))
+		for bidder := range syncers {
+			others = append(others, string(bidder))
+		}
+		addShuffled(others, hasSyncer)
+	}
+	return chosen
+}
+
+// applyGPP fills in the gdpr and gdpr_consent fields from the GPP fields, if the caller didn't send them.
+func (req *cookieSyncRequest) applyGPP() error {
+	gdprSignal, consent, err := gdprFromGPP(gdprToString(req.GDPR), req.Consent, req.GPP, req.GPPSID)
+	if err != nil {
+		return err
+	}
+	if gdprSignal != "" {
+		gdprInt, _ := strconv.Atoi(gdprSignal)
+		req.GDPR = &gdprInt
+	}
+	req.Consent = consent
+	return nil
 }
 
 func (req *cookieSyncRequest) filterExistingSyncs(valid map[openrtb_ext.BidderName]usersync.Usersyncer, cookie *usersync.PBSCookie) {
 	for i := 0; i < len(req.Bidders); i++ {
 		thisBidder := req.Bidders[i]
-		if syncer, isValid := valid[openrtb_ext.BidderName(thisBidder)]; !isValid || cookie.HasLiveSync(syncer.FamilyName()) {
-			req.Bidders = append(req.Bidders[:i], req.Bidders[i+1:]...)
+		if syncer, isValid := valid[openrtb_ext.BidderName(thisBidder)]; !isValid {
+			req.omit(i, omitReasonNoSyncer)
+			i--
+		} else if cookie.HasLiveSync(syncer.FamilyName()) {
+			req.omit(i, omitReasonAlreadySynced)
 			i--
 		}
 	}
 }
 
-func (req *cookieSyncRequest) filterForGDPR(permissions gdpr.Permissions) {
+// gdprSyncRule returns the syncUser rule which enforces GDPR for the requested bidders, or nil if GDPR doesn't apply.
+// Like the privacy activity rules, it's matched against the syncers' cookie family names.
+func (req *cookieSyncRequest) gdprSyncRule(syncers map[openrtb_ext.BidderName]usersync.Usersyncer, permissions gdpr.Permissions) privacy.DefaultRule {
 	if req.GDPR != nil && *req.GDPR == 0 {
-		return
+		return nil
 	}
 
 	if allowSync, err := permissions.HostCookiesAllowed(context.Background(), req.Consent); err != nil || !allowSync {
-		req.Bidders = nil
-		return
+		return func(privacy.Component) bool { return false }
+	}
+
+	allowedFamilies := make(map[string]bool, len(req.Bidders))
+	for _, bidder := range req.Bidders {
+		if allowSync, err := permissions.BidderSyncAllowed(context.Background(), openrtb_ext.BidderName(bidder), req.Consent); err == nil && allowSync {
+			allowedFamilies[syncers[openrtb_ext.BidderName(bidder)].FamilyName()] = true
+		}
+	}
+	return func(component privacy.Component) bool {
+		return allowedFamilies[component.Name]
 	}
+}
 
+// filterForActivities removes the bidders whose syncs aren't allowed by the syncUser privacy activity.
+// Rules are matched against the syncer's cookie family name, so that they work the same way here as in /setuid.
+func (req *cookieSyncRequest) filterForActivities(syncers map[openrtb_ext.BidderName]usersync.Usersyncer, activityControl privacy.ActivityControl) {
+	// An invalid gpp_sid has already been rejected by applyGPP
+	sids, _ := gpp.ParseSIDs(req.GPPSID)
+	activityRequest := privacy.ActivityRequest{GPPSIDs: sids}
 	for i := 0; i < len(req.Bidders); i++ {
-		if allowSync, err := permissions.BidderSyncAllowed(context.Background(), openrtb_ext.BidderName(req.Bidders[i]), req.Consent); err != nil || !allowSync {
-			req.Bidders = append(req.Bidders[:i], req.Bidders[i+1:]...)
+		component := privacy.Component{Type: privacy.ComponentTypeBidder, Name: syncers[openrtb_ext.BidderName(req.Bidders[i])].FamilyName()}
+		if allowed, byGDPR := activityControl.Decide(privacy.ActivitySyncUser, component, activityRequest); byGDPR && !allowed {
+			req.omit(i, omitReasonGDPR)
+			i--
+		} else if !allowed {
+			req.omit(i, omitReasonPrivacy)
 			i--
 		}
 	}
 }
 
-// filterToLimit will enforce a max limit on cookiesyncs supplied, picking a random subset of syncs to get to the limit if over.
-func (req *cookieSyncRequest) filterToLimit() {
-	if req.Limit <= 0 {
-		return
+// filterForSyncTypes removes the bidders which can't do any of the types of syncs which the filter allows.
+// It returns the type of sync to use for each of the other bidders, which is the first allowed one from the syncer's SyncTypes.
+func (req *cookieSyncRequest) filterForSyncTypes(syncers map[openrtb_ext.BidderName]usersync.Usersyncer, filter syncTypeFilter) map[string]usersync.SyncType {
+	chosen := make(map[string]usersync.SyncType, len(req.Bidders))
+	for i := 0; i < len(req.Bidders); i++ {
+		bidder := req.Bidders[i]
+		for _, syncType := range syncers[openrtb_ext.BidderName(bidder)].SyncTypes() {
+			if filter.allows(bidder, syncType) {
+				chosen[bidder] = syncType
+				break
+			}
+		}
+		if _, ok := chosen[bidder]; !ok {
+			req.omit(i, omitReasonSyncType)
+			i--
+		}
 	}
-	if req.Limit >= len(req.Bidders) {
+	return chosen
+}
+
+// filterToLimit drops the bidders past the limit. The request's limit is used if it has one, and the host's default otherwise.
+// Either way, it can't be more than the host's max. Since chooseBidders already put the bidders in order, the first ones are kept.
+func (req *cookieSyncRequest) filterToLimit(cfg *config.UserSync) {
+	limit := req.Limit
+	if limit <= 0 {
+		limit = cfg.DefaultLimit
+	}
+	if cfg.MaxLimit > 0 && (limit <= 0 || limit > cfg.MaxLimit) {
+		limit = cfg.MaxLimit
+	}
+	if limit <= 0 {
 		return
 	}
+	for len(req.Bidders) > limit {
+		req.omit(limit, omitReasonLimit)
+	}
+}
+
+// cookieSyncFilterSettings uses the same format as the Prebid.js userSync.filterSettings config.
+// Prebid.js calls redirect syncs "image" syncs.
+type cookieSyncFilterSettings struct {
+	Iframe *cookieSyncFilter `json:"iframe"`
+	Image  *cookieSyncFilter `json:"image"`
+}
+
+type cookieSyncFilter struct {
+	// Bidders is either "*" or a list of bidder names.
+	Bidders json.RawMessage `json:"bidders"`
+	// Filter is "include" or "exclude".
+	Filter string `json:"filter"`
+}
+
+// parse makes a syncTypeFilter. If the request has no filterSettings, every type of sync is allowed.
+// Otherwise, types which filterSettings leaves out follow the Prebid.js defaults: image syncs are allowed, and iframe syncs aren't.
+func (settings *cookieSyncFilterSettings) parse() (syncTypeFilter, error) {
+	if settings == nil {
+		return syncTypeFilter{iframe: bidderFilter{all: true, include: true}, redirect: bidderFilter{all: true, include: true}}, nil
+	}
+	iframe, err := settings.Iframe.parse("iframe", bidderFilter{all: true, include: false})
+	if err != nil {
+		return syncTypeFilter{}, err
+	}
+	redirect, err := settings.Image.parse("image", bidderFilter{all: true, include: true})
+	if err != nil {
+		return syncTypeFilter{}, err
+	}
+	return syncTypeFilter{iframe: iframe, redirect: redirect}, nil
+}
 
-	// Modified Fisher and Yates' shuffle. We don't need the bidder list shuffled, so we stop shuffling once the final values beyond limit have been set.
-	// We also don't bother saving the values that should go into the entries beyond limit, as they will be discarded.
-	for i := len(req.Bidders) - 1; i >= req.Limit; i-- {
-		j := rand.Intn(i + 1)
-		if i != j {
-			req.Bidders[j] = req.Bidders[i]
-			// Don't complete the swap as the new value for req.Bidders[i] will be discarded below, and will never again be accessed as part of the swapping.
+func (f *cookieSyncFilter) parse(name string, defaultFilter bidderFilter) (bidderFilter, error) {
+	if f == nil {
+		return defaultFilter, nil
+	}
+	var parsed bidderFilter
+	switch f.Filter {
+	case "", "include":
+		parsed.include = true
+	case "exclude":
+		parsed.include = false
+	default:
+		return bidderFilter{}, fmt.Errorf(`filterSettings.%s.filter must be "include" or "exclude". Got %s`, name, f.Filter)
+	}
+
+	var all string
+	var bidders []string
+	if len(f.Bidders) == 0 {
+		parsed.all = true
+	} else if err := json.Unmarshal(f.Bidders, &all); err == nil {
+		if all != "*" {
+			return bidderFilter{}, fmt.Errorf(`filterSettings.%s.bidders must be "*" or a list of bidders. Got %s`, name, all)
 		}
+		parsed.all = true
+	} else if err := json.Unmarshal(f.Bidders, &bidders); err == nil {
+		parsed.bidders = make(map[string]bool, len(bidders))
+		for _, bidder := range bidders {
+			parsed.bidders[bidder] = true
+		}
+	} else {
+		return bidderFilter{}, fmt.Errorf(`filterSettings.%s.bidders must be "*" or a list of bidders`, name)
 	}
-	req.Bidders = req.Bidders[:req.Limit]
-	return
+	return parsed, nil
+}
+
+// syncTypeFilter decides which types of syncs each bidder is allowed to do.
+type syncTypeFilter struct {
+	iframe   bidderFilter
+	redirect bidderFilter
+}
+
+func (f syncTypeFilter) allows(bidder string, syncType usersync.SyncType) bool {
+	switch syncType {
+	case usersync.SyncTypeIframe:
+		return f.iframe.allows(bidder)
+	case usersync.SyncTypeRedirect:
+		return f.redirect.allows(bidder)
+	}
+	return false
+}
+
+// bidderFilter includes or excludes either all bidders, or the ones in a list.
+type bidderFilter struct {
+	include bool
+	all     bool
+	bidders map[string]bool
+}
+
+func (f bidderFilter) allows(bidder string) bool {
+	matches := f.all || f.bidders[bidder]
+	return matches == f.include
 }
 
 type cookieSyncResponse struct {
 	Status       string                        `json:"status"`
 	BidderStatus []*usersync.CookieSyncBidders `json:"bidder_status"`
+	Debug        []cookieSyncDebug             `json:"debug,omitempty"`
+}
+
+type cookieSyncDebug struct {
+	Bidder string `json:"bidder"`
+	Error  string `json:"error"`
 }
@@ -2,6 +2,7 @@ package endpoints
 
 import (
 	"context"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -11,6 +12,7 @@ import (
 
 	"github.com/buger/jsonparser"
 	"github.com/julienschmidt/httprouter"
+	"github.com/prebid/prebid-server/adapters"
 	"github.com/prebid/prebid-server/adapters/appnexus"
 	"github.com/prebid/prebid-server/adapters/audienceNetwork"
 	"github.com/prebid/prebid-server/adapters/lifestreet"
@@ -20,6 +22,7 @@ import (
 	"github.com/prebid/prebid-server/gdpr"
 	"github.com/prebid/prebid-server/openrtb_ext"
 	metricsConf "github.com/prebid/prebid-server/pbsmetrics/config"
+	"github.com/prebid/prebid-server/privacy"
 	"github.com/prebid/prebid-server/usersync"
 	"github.com/stretchr/testify/assert"
 )
@@ -65,6 +68,79 @@ func TestGDPRConsentRequired(t *testing.T) {
 	assert.Equal(t, "gdpr_consent is required if gdpr=1\n", rr.Body.String())
 }
 
+func TestGPPIgnoresGDPRIfNotApplicable(t *testing.T) {
+	rr := doPost(`{"gpp":"DBABTA~1YNN","gpp_sid":"6","bidders":["appnexus", "pubmatic"]}`, nil, false, nil)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.ElementsMatch(t, []string{"appnexus", "pubmatic"}, parseSyncs(t, rr.Body.Bytes()))
+}
+
+func TestGPPConsentRequired(t *testing.T) {
+	rr := doPost(`{"gpp_sid":"2","bidders":["appnexus", "pubmatic"]}`, nil, false, nil)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Equal(t, "gdpr_consent is required if gdpr=1\n", rr.Body.String())
+}
+
+func TestCookieSyncApplyGPP(t *testing.T) {
+	req := &cookieSyncRequest{
+		GPP:    "DBABMA~CPXxRfAPXxRfAAfKABENB-CgAAAAAAAAAAYgAAAAAAAA",
+		GPPSID: "2",
+	}
+	assert.NoError(t, req.applyGPP())
+	assert.Equal(t, 1, *req.GDPR)
+	assert.Equal(t, "CPXxRfAPXxRfAAfKABENB-CgAAAAAAAAAAYgAAAAAAAA", req.Consent)
+
+	req = &cookieSyncRequest{GPP: "invalid"}
+	assert.Error(t, req.applyGPP())
+}
+
+func TestCookieSyncFilterForActivities(t *testing.T) {
+	deny := false
+	activityControl := privacy.NewActivityControl(&config.Privacy{
+		Accounts: map[string]config.Activities{
+			"some-account": {
+				SyncUser: config.Activity{
+					Default: &deny,
+					Rules: []config.ActivityRule{{
+						Allow:     true,
+						Condition: config.ActivityCondition{ComponentName: []string{"adnxs"}},
+					}},
+				},
+			},
+		},
+	}, "some-account")
+
+	req := &cookieSyncRequest{Bidders: []string{"appnexus", "audienceNetwork", "lifestreet"}}
+	req.filterForActivities(syncersForTest(), activityControl)
+	assert.Equal(t, []string{"appnexus"}, req.Bidders)
+}
+
+func TestCookieSyncGDPRRuleOverride(t *testing.T) {
+	activityControl := privacy.NewActivityControl(&config.Privacy{
+		Accounts: map[string]config.Activities{
+			"some-account": {
+				SyncUser: config.Activity{
+					Rules: []config.ActivityRule{{
+						Allow:     true,
+						Condition: config.ActivityCondition{ComponentName: []string{"audienceNetwork"}},
+					}},
+				},
+			},
+		},
+	}, "some-account")
+
+	syncers := syncersForTest()
+	req := &cookieSyncRequest{Bidders: []string{"appnexus", "audienceNetwork", "lifestreet"}, Consent: "some-consent"}
+	gdprRule := req.gdprSyncRule(syncers, mockPermissions(true, map[openrtb_ext.BidderName]usersync.Usersyncer{
+		openrtb_ext.BidderAppnexus: syncers[openrtb_ext.BidderAppnexus],
+	}))
+	req.filterForActivities(syncers, activityControl.WithDefault(privacy.ActivitySyncUser, gdprRule))
+	assert.ElementsMatch(t, []string{"appnexus", "audienceNetwork"}, req.Bidders, "The account's rule should override GDPR")
+	assert.Equal(t, []cookieSyncDebug{{Bidder: "lifestreet", Error: omitReasonGDPR}}, req.omitted)
+
+	req = &cookieSyncRequest{Bidders: []string{"appnexus"}, GDPR: new(int)}
+	assert.Nil(t, req.gdprSyncRule(syncers, mockPermissions(false, nil)), "GDPR shouldn't apply if gdpr is 0")
+}
+
 func TestCookieSyncHasCookies(t *testing.T) {
 	rr := doPost(`{"bidders":["appnexus", "audienceNetwork", "random"]}`, map[string]string{
 		"adnxs":           "1234",
@@ -95,7 +171,7 @@ func TestCookieSyncNoBidders(t *testing.T) {
 }
 
 func TestCookieSyncNoCookiesBrokenGDPR(t *testing.T) {
-	rr := doConfigurablePost(`{"bidders":["appnexus", "audienceNetwork", "random"],"gdpr_consent":"GLKHGKGKKGK"}`, nil, true, map[openrtb_ext.BidderName]usersync.Usersyncer{}, config.GDPR{UsersyncIfAmbiguous: true})
+	rr := doConfigurablePost(`{"bidders":["appnexus", "audienceNetwork", "random"],"gdpr_consent":"GLKHGKGKKGK"}`, nil, true, map[openrtb_ext.BidderName]usersync.Usersyncer{}, config.Configuration{GDPR: config.GDPR{UsersyncIfAmbiguous: true}})
 	assert.Equal(t, rr.Header().Get("Content-Type"), "application/json; charset=utf-8")
 	assert.Equal(t, http.StatusOK, rr.Code)
 	assert.ElementsMatch(t, []string{"appnexus", "audienceNetwork"}, parseSyncs(t, rr.Body.Bytes()))
@@ -117,12 +193,180 @@ func TestCookieSyncWithLargeLimit(t *testing.T) {
 	assert.Equal(t, "no_cookie", parseStatus(t, rr.Body.Bytes()))
 }
 
+func TestCookieSyncCoopSync(t *testing.T) {
+	rr := doPost(`{"bidders":["appnexus"],"coopSync":true}`, nil, true, syncersForTest())
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.ElementsMatch(t, []string{"appnexus", "audienceNetwork", "lifestreet", "pubmatic"}, parseSyncs(t, rr.Body.Bytes()))
+}
+
+func TestCookieSyncCoopSyncDefault(t *testing.T) {
+	cfg := config.Configuration{UserSync: config.UserSync{CoopSyncDefault: true}}
+	rr := doConfigurablePost(`{"bidders":["appnexus"]}`, nil, true, syncersForTest(), cfg)
+	assert.ElementsMatch(t, []string{"appnexus", "audienceNetwork", "lifestreet", "pubmatic"}, parseSyncs(t, rr.Body.Bytes()))
+
+	rr = doConfigurablePost(`{"bidders":["appnexus"],"coopSync":false}`, nil, true, syncersForTest(), cfg)
+	assert.ElementsMatch(t, []string{"appnexus"}, parseSyncs(t, rr.Body.Bytes()))
+}
+
+func TestCookieSyncPriorityGroups(t *testing.T) {
+	cfg := config.Configuration{UserSync: config.UserSync{
+		PriorityGroups: [][]string{{"pubmatic", "lifestreet"}},
+	}}
+	rr := doConfigurablePost(`{"bidders":["appnexus","lifestreet","pubmatic"],"limit":2}`, nil, true, syncersForTest(), cfg)
+	assert.ElementsMatch(t, []string{"lifestreet", "pubmatic"}, parseSyncs(t, rr.Body.Bytes()))
+}
+
+func TestCookieSyncHostLimits(t *testing.T) {
+	cfg := config.Configuration{UserSync: config.UserSync{DefaultLimit: 1, MaxLimit: 3}}
+	rr := doConfigurablePost(`{}`, nil, true, syncersForTest(), cfg)
+	assert.Len(t, parseSyncs(t, rr.Body.Bytes()), 1, "default limit")
+
+	rr = doConfigurablePost(`{"limit":10}`, nil, true, syncersForTest(), cfg)
+	assert.Len(t, parseSyncs(t, rr.Body.Bytes()), 3, "max limit")
+}
+
+func TestCookieSyncDebug(t *testing.T) {
+	rr := doPost(`{"bidders":["appnexus","random","pubmatic","lifestreet"],"gdpr":1,"gdpr_consent":"BOONs2HOONs2HABABBENAGgAAAAPrABACGA","limit":1,"debug":true}`, nil, true, map[openrtb_ext.BidderName]usersync.Usersyncer{
+		openrtb_ext.BidderAppnexus:   nil,
+		openrtb_ext.BidderLifestreet: nil,
+	})
+	assert.Equal(t, http.StatusOK, rr.Code)
+	syncs := parseSyncs(t, rr.Body.Bytes())
+	if !assert.Len(t, syncs, 1) {
+		return
+	}
+
+	reasons := make(map[string]string)
+	jsonparser.ArrayEach(rr.Body.Bytes(), func(value []byte, dataType jsonparser.ValueType, offset int, err error) {
+		bidder, _ := jsonparser.GetString(value, "bidder")
+		reasons[bidder], _ = jsonparser.GetString(value, "error")
+	}, "debug")
+	expected := map[string]string{
+		"random":   omitReasonNoSyncer,
+		"pubmatic": omitReasonGDPR,
+	}
+	if syncs[0] == "appnexus" {
+		expected["lifestreet"] = omitReasonLimit
+	} else {
+		expected["appnexus"] = omitReasonLimit
+	}
+	assert.Equal(t, expected, reasons)
+}
+
+func TestCookieSyncNoDebugByDefault(t *testing.T) {
+	rr := doPost(`{"bidders":["random"]}`, nil, true, syncersForTest())
+	_, dataType, _, _ := jsonparser.Get(rr.Body.Bytes(), "debug")
+	assert.Equal(t, jsonparser.NotExist, dataType)
+}
+
+func TestChooseBidders(t *testing.T) {
+	syncers := syncersForTest()
+	priorityGroups := [][]string{{"lifestreet"}, {"pubmatic", "audienceNetwork"}}
+
+	chosen := chooseBidders([]string{"appnexus", "pubmatic", "random"}, syncers, priorityGroups, false)
+	assert.Equal(t, "pubmatic", chosen[0], "priority groups go first")
+	assert.ElementsMatch(t, []string{"appnexus", "random"}, chosen[1:])
+
+	chosen = chooseBidders([]string{"appnexus", "pubmatic", "appnexus"}, syncers, priorityGroups, true)
+	assert.Len(t, chosen, 4, "bidders should not be repeated")
+	assert.Equal(t, "pubmatic", chosen[0], "requested priority bidders go first")
+	assert.Equal(t, "appnexus", chosen[1], "requested bidders go before cooperative ones")
+	assert.Equal(t, "lifestreet", chosen[2], "cooperative bidders follow the priority groups")
+	assert.Equal(t, "audienceNetwork", chosen[3])
+}
+
+func TestFilterToLimit(t *testing.T) {
+	testCases := []struct {
+		description   string
+		requestLimit  int
+		cfg           config.UserSync
+		expectedCount int
+	}{
+		{description: "No limits", expectedCount: 4},
+		{description: "Request limit", requestLimit: 2, expectedCount: 2},
+		{description: "Default limit", cfg: config.UserSync{DefaultLimit: 3}, expectedCount: 3},
+		{description: "Request limit beats the default", requestLimit: 1, cfg: config.UserSync{DefaultLimit: 3}, expectedCount: 1},
+		{description: "Max limit without a request limit", cfg: config.UserSync{MaxLimit: 2}, expectedCount: 2},
+		{description: "Max limit caps the request limit", requestLimit: 3, cfg: config.UserSync{MaxLimit: 2}, expectedCount: 2},
+	}
+
+	for _, test := range testCases {
+		req := &cookieSyncRequest{Bidders: []string{"a", "b", "c", "d"}, Limit: test.requestLimit}
+		req.filterToLimit(&test.cfg)
+		assert.Len(t, req.Bidders, test.expectedCount, test.description)
+		assert.Equal(t, []string{"a", "b", "c", "d"}[:test.expectedCount], req.Bidders, test.description)
+		assert.Len(t, req.omitted, 4-test.expectedCount, test.description)
+	}
+}
+
+func TestCookieSyncFilterSettings(t *testing.T) {
+	// pubmatic only does iframe syncs, and the others only do redirects.
+	rr := doPost(`{"bidders":["appnexus","pubmatic","lifestreet"],"filterSettings":{"image":{"bidders":["lifestreet"],"filter":"exclude"}},"debug":true}`, nil, true, syncersForTest())
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.ElementsMatch(t, []string{"appnexus"}, parseSyncs(t, rr.Body.Bytes()))
+	debug, _, _, _ := jsonparser.Get(rr.Body.Bytes(), "debug")
+	assert.Contains(t, string(debug), omitReasonSyncType)
+
+	rr = doPost(`{"bidders":["appnexus","pubmatic"],"filterSettings":{"iframe":{"bidders":"*","filter":"include"},"image":{"bidders":"*","filter":"exclude"}}}`, nil, true, syncersForTest())
+	assert.ElementsMatch(t, []string{"pubmatic"}, parseSyncs(t, rr.Body.Bytes()))
+}
+
+func TestCookieSyncInvalidFilterSettings(t *testing.T) {
+	rr := doPost(`{"filterSettings":{"iframe":{"bidders":"*","filter":"only"}}}`, nil, true, syncersForTest())
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	rr = doPost(`{"filterSettings":{"image":{"bidders":"appnexus"}}}`, nil, true, syncersForTest())
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestFilterForSyncTypes(t *testing.T) {
+	multiSyncer := adapters.NewSyncer("multi", 0, template.Must(template.New("sync").Parse("redirect.com")), adapters.SyncTypeRedirect)
+	multiSyncer.AddSyncType(adapters.SyncTypeIframe, template.Must(template.New("sync").Parse("iframe.com")))
+	syncers := map[openrtb_ext.BidderName]usersync.Usersyncer{
+		"multi":                    multiSyncer,
+		openrtb_ext.BidderPubmatic: pubmatic.NewPubmaticSyncer(template.Must(template.New("sync").Parse("thaturl.com"))),
+	}
+	testCases := []struct {
+		description string
+		settings    string
+		expected    map[string]usersync.SyncType
+	}{
+		{
+			description: "No filterSettings allows every type",
+			expected:    map[string]usersync.SyncType{"multi": usersync.SyncTypeRedirect, "pubmatic": usersync.SyncTypeIframe},
+		},
+		{
+			description: "Iframes are excluded by default",
+			settings:    `{"image":{"bidders":"*"}}`,
+			expected:    map[string]usersync.SyncType{"multi": usersync.SyncTypeRedirect},
+		},
+		{
+			description: "The syncer falls back to its other type",
+			settings:    `{"iframe":{"bidders":["multi","pubmatic"]},"image":{"bidders":["multi"],"filter":"exclude"}}`,
+			expected:    map[string]usersync.SyncType{"multi": usersync.SyncTypeIframe, "pubmatic": usersync.SyncTypeIframe},
+		},
+	}
+
+	for _, test := range testCases {
+		var settings *cookieSyncFilterSettings
+		if test.settings != "" {
+			settings = &cookieSyncFilterSettings{}
+			assert.NoError(t, json.Unmarshal([]byte(test.settings), settings), test.description)
+		}
+		filter, err := settings.parse()
+		assert.NoError(t, err, test.description)
+		req := &cookieSyncRequest{Bidders: []string{"multi", "pubmatic"}}
+		assert.Equal(t, test.expected, req.filterForSyncTypes(syncers, filter), test.description)
+		assert.Len(t, req.Bidders, len(test.expected), test.description)
+	}
+}
+
 func doPost(body string, existingSyncs map[string]string, gdprHostConsent bool, gdprBidders map[openrtb_ext.BidderName]usersync.Usersyncer) *httptest.ResponseRecorder {
-	return doConfigurablePost(body, existingSyncs, gdprHostConsent, gdprBidders, config.GDPR{})
+	return doConfigurablePost(body, existingSyncs, gdprHostConsent, gdprBidders, config.Configuration{})
 }
 
-func doConfigurablePost(body string, existingSyncs map[string]string, gdprHostConsent bool, gdprBidders map[openrtb_ext.BidderName]usersync.Usersyncer, cfgGDPR config.GDPR) *httptest.ResponseRecorder {
-	endpoint := testableEndpoint(mockPermissions(gdprHostConsent, gdprBidders), cfgGDPR)
+func doConfigurablePost(body string, existingSyncs map[string]string, gdprHostConsent bool, gdprBidders map[openrtb_ext.BidderName]usersync.Usersyncer, cfg config.Configuration) *httptest.ResponseRecorder {
+	endpoint := testableEndpoint(mockPermissions(gdprHostConsent, gdprBidders), cfg)
 	router := httprouter.New()
 	router.POST("/cookie_sync", endpoint)
 	req, _ := http.NewRequest("POST", "/cookie_sync", strings.NewReader(body))
@@ -139,8 +383,8 @@ func doConfigurablePost(body string, existingSyncs map[string]string, gdprHostCo
 	return rr
 }
 
-func testableEndpoint(perms gdpr.Permissions, cfgGDPR config.GDPR) httprouter.Handle {
-	return NewCookieSyncEndpoint(syncersForTest(), &config.Configuration{GDPR: cfgGDPR}, perms, &metricsConf.DummyMetricsEngine{}, analyticsConf.NewPBSAnalytics(&config.Analytics{}))
+func testableEndpoint(perms gdpr.Permissions, cfg config.Configuration) httprouter.Handle {
+	return NewCookieSyncEndpoint(syncersForTest(), &cfg, perms, &metricsConf.DummyMetricsEngine{}, analyticsConf.NewPBSAnalytics(&config.Analytics{}), nil)
 }
 
 func syncersForTest() map[openrtb_ext.BidderName]usersync.Usersyncer {
@@ -13,12 +13,16 @@ import (
 )
 
 // NewBiddersEndpoint implements /info/bidders
-func NewBiddersEndpoint(aliases map[string]string) httprouter.Handle {
-	bidderNames := make([]string, 0, len(openrtb_ext.BidderMap)+len(aliases))
+func NewBiddersEndpoint(aliases map[string]string, genericBidders []openrtb_ext.BidderName) httprouter.Handle {
+	bidderNames := make([]string, 0, len(openrtb_ext.BidderMap)+len(genericBidders)+len(aliases))
 	for bidderName := range openrtb_ext.BidderMap {
 		bidderNames = append(bidderNames, bidderName)
 	}
 
+	for _, bidderName := range genericBidders {
+		bidderNames = append(bidderNames, string(bidderName))
+	}
+
 	for aliasName := range aliases {
 		bidderNames = append(bidderNames, aliasName)
 	}
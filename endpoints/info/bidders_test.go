@@ -20,7 +20,7 @@ import (
 )
 
 func TestGetBiddersNoAliases(t *testing.T) {
-	testGetBidders(t, map[string]string{})
+	testGetBidders(t, map[string]string{}, nil)
 }
 
 func TestGetBiddersWithAliases(t *testing.T) {
@@ -29,11 +29,19 @@ func TestGetBiddersWithAliases(t *testing.T) {
 		"test2": "rubicon",
 		"test3": "openx",
 	}
-	testGetBidders(t, aliases)
+	testGetBidders(t, aliases, nil)
 }
 
-func testGetBidders(t *testing.T, aliases map[string]string) {
-	endpoint := info.NewBiddersEndpoint(aliases)
+func TestGetBiddersWithGenericBidders(t *testing.T) {
+	testGetBidders(t, map[string]string{"test1": "appnexus"}, []openrtb_ext.BidderName{"somegeneric"})
+}
+
+func testGetBidders(t *testing.T, aliases map[string]string, genericBidders []openrtb_ext.BidderName) {
+	endpoint := info.NewBiddersEndpoint(aliases, genericBidders)
+	generic := make(map[string]bool, len(genericBidders))
+	for _, bidderName := range genericBidders {
+		generic[string(bidderName)] = true
+	}
 
 	req, err := http.NewRequest("GET", "http://prebid-server.com/info/bidders", strings.NewReader(""))
 	if err != nil {
@@ -55,13 +63,13 @@ func testGetBidders(t *testing.T, aliases map[string]string) {
 	}
 	for _, bidderName := range bidderSlice {
 		if _, ok := openrtb_ext.BidderMap[bidderName]; !ok {
-			if _, aok := aliases[bidderName]; !aok {
+			if _, aok := aliases[bidderName]; !aok && !generic[bidderName] {
 				t.Errorf("Response from /info/bidders contained unexpected BidderName: %s", bidderName)
 			}
 		}
 	}
-	if len(bidderSlice) != len(openrtb_ext.BidderMap)+len(aliases) {
-		t.Errorf("Response from /info/bidders did not match BidderMap. Expected %d elements. Got %d", len(openrtb_ext.BidderMap), len(bidderSlice))
+	if len(bidderSlice) != len(openrtb_ext.BidderMap)+len(genericBidders)+len(aliases) {
+		t.Errorf("Response from /info/bidders did not match BidderMap. Expected %d elements. Got %d", len(openrtb_ext.BidderMap)+len(genericBidders)+len(aliases), len(bidderSlice))
 	}
 }
 
@@ -238,6 +246,10 @@ func validateInfo(info *adapters.BidderInfo) error {
 		return err
 	}
 
+	if info.OpenRTBVersion != "" && info.OpenRTBVersion != "2.5" && info.OpenRTBVersion != "2.6" {
+		return fmt.Errorf("openrtb_version must be 2.5, 2.6 or undefined. Got %q", info.OpenRTBVersion)
+	}
+
 	return nil
 }
 
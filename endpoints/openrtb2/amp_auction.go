@@ -22,6 +22,8 @@ import (
 	"github.com/prebid/prebid-server/exchange"
 	"github.com/prebid/prebid-server/openrtb_ext"
 	"github.com/prebid/prebid-server/pbsmetrics"
+	"github.com/prebid/prebid-server/privacy"
+	"github.com/prebid/prebid-server/sharedid"
 	"github.com/prebid/prebid-server/stored_requests"
 	"github.com/prebid/prebid-server/stored_requests/backends/empty_fetcher"
 	"github.com/prebid/prebid-server/usersync"
@@ -48,6 +50,8 @@ func NewAmpEndpoint(
 	disabledBidders map[string]string,
 	defReqJSON []byte,
 	bidderMap map[string]openrtb_ext.BidderName,
+	uidStore *usersync.UIDStore,
+	sharedID *sharedid.Generator,
 ) (httprouter.Handle, error) {
 
 	if ex == nil || validator == nil || requestsById == nil || cfg == nil || met == nil {
@@ -68,7 +72,9 @@ func NewAmpEndpoint(
 		disabledBidders,
 		defRequest,
 		defReqJSON,
-		bidderMap}).AmpAuction), nil
+		bidderMap,
+		uidStore,
+		sharedID}).AmpAuction), nil
 
 }
 
@@ -143,7 +149,11 @@ func (deps *endpointDeps) AmpAuction(w http.ResponseWriter, r *http.Request, _ h
 	}
 	defer cancel()
 
-	usersyncs := usersync.ParsePBSCookieFromRequest(r, &(deps.cfg.HostCookie))
+	if err := deps.sharedID.Apply(ctx, r, w, req); err != nil {
+		glog.Errorf("Failed to set the shared ID: %v", err)
+	}
+
+	usersyncs, _ := deps.uidStore.ParsePBSCookieFromRequest(ctx, r, &(deps.cfg.HostCookie), deps.uidStore.FirstPartyIDFromUser(req.User))
 	if req.App != nil {
 		labels.Source = pbsmetrics.DemandApp
 	} else {
@@ -156,6 +166,7 @@ func (deps *endpointDeps) AmpAuction(w http.ResponseWriter, r *http.Request, _ h
 	}
 	response, err := deps.ex.HoldAuction(ctx, req, usersyncs, labels, &deps.categories)
 	ao.AuctionResponse = response
+	ao.ActivityControl = privacy.NewActivityControl(&deps.cfg.Privacy, labels.PubID)
 
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
@@ -291,7 +302,11 @@ func (deps *endpointDeps) loadRequestJSONForAmp(httpRequest *http.Request) (req
 	}
 
 	// The fetched config becomes the entire OpenRTB request
-	requestJSON := storedRequests[ampID]
+	requestJSON, err := openrtb_ext.MoveORTB26FieldsToExt(storedRequests[ampID])
+	if err != nil {
+		errs = []error{err}
+		return
+	}
 	if err := jsoniter.Unmarshal(requestJSON, req); err != nil {
 		errs = []error{err}
 		return
@@ -25,9 +25,12 @@ import (
 	"github.com/prebid/prebid-server/config"
 	"github.com/prebid/prebid-server/errortypes"
 	"github.com/prebid/prebid-server/exchange"
+	"github.com/prebid/prebid-server/gpp"
 	"github.com/prebid/prebid-server/openrtb_ext"
 	"github.com/prebid/prebid-server/pbsmetrics"
 	"github.com/prebid/prebid-server/prebid"
+	"github.com/prebid/prebid-server/privacy"
+	"github.com/prebid/prebid-server/sharedid"
 	"github.com/prebid/prebid-server/stored_requests"
 	"github.com/prebid/prebid-server/stored_requests/backends/empty_fetcher"
 	"github.com/prebid/prebid-server/usersync"
@@ -36,7 +39,7 @@ import (
 
 const storedRequestTimeoutMillis = 50
 
-func NewEndpoint(ex exchange.Exchange, validator openrtb_ext.BidderParamValidator, requestsById stored_requests.Fetcher, categories stored_requests.CategoryFetcher, cfg *config.Configuration, met pbsmetrics.MetricsEngine, pbsAnalytics analytics.PBSAnalyticsModule, disabledBidders map[string]string, defReqJSON []byte, bidderMap map[string]openrtb_ext.BidderName) (httprouter.Handle, error) {
+func NewEndpoint(ex exchange.Exchange, validator openrtb_ext.BidderParamValidator, requestsById stored_requests.Fetcher, categories stored_requests.CategoryFetcher, cfg *config.Configuration, met pbsmetrics.MetricsEngine, pbsAnalytics analytics.PBSAnalyticsModule, disabledBidders map[string]string, defReqJSON []byte, bidderMap map[string]openrtb_ext.BidderName, uidStore *usersync.UIDStore, sharedID *sharedid.Generator) (httprouter.Handle, error) {
 
 	if ex == nil || validator == nil || requestsById == nil || cfg == nil || met == nil {
 		return nil, errors.New("NewEndpoint requires non-nil arguments.")
@@ -55,7 +58,9 @@ func NewEndpoint(ex exchange.Exchange, validator openrtb_ext.BidderParamValidato
 		disabledBidders,
 		defRequest,
 		defReqJSON,
-		bidderMap}).Auction), nil
+		bidderMap,
+		uidStore,
+		sharedID}).Auction), nil
 }
 
 type endpointDeps struct {
@@ -71,6 +76,8 @@ type endpointDeps struct {
 	defaultRequest   bool
 	defReqJSON       []byte
 	bidderMap        map[string]openrtb_ext.BidderName
+	uidStore         *usersync.UIDStore
+	sharedID         *sharedid.Generator
 }
 
 func (deps *endpointDeps) Auction(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
@@ -133,7 +140,11 @@ func (deps *endpointDeps) Auction(w http.ResponseWriter, r *http.Request, _ http
 	}
 	defer cancel()
 
-	usersyncs := usersync.ParsePBSCookieFromRequest(r, &(deps.cfg.HostCookie))
+	if err := deps.sharedID.Apply(ctx, r, w, req); err != nil {
+		glog.Errorf("Failed to set the shared ID: %v", err)
+	}
+
+	usersyncs, _ := deps.uidStore.ParsePBSCookieFromRequest(ctx, r, &(deps.cfg.HostCookie), deps.uidStore.FirstPartyIDFromUser(req.User))
 	if req.App != nil {
 		labels.Source = pbsmetrics.DemandApp
 	} else {
@@ -148,6 +159,7 @@ func (deps *endpointDeps) Auction(w http.ResponseWriter, r *http.Request, _ http
 	numImps = len(req.Imp)
 	response, err := deps.ex.HoldAuction(ctx, req, usersyncs, labels, &deps.categories)
 	ao.Request = req
+	ao.ActivityControl = privacy.NewActivityControl(&deps.cfg.Privacy, labels.PubID)
 	ao.Response = response
 	if err != nil {
 		labels.RequestStatus = pbsmetrics.RequestStatusErr
@@ -216,6 +228,12 @@ func (deps *endpointDeps) parseRequest(httpRequest *http.Request) (req *openrtb.
 		return
 	}
 
+	// Our OpenRTB 2.5 model has no place for the OpenRTB 2.6 fields, so they're moved into the ext locations which 2.5 callers use.
+	if requestJson, err = openrtb_ext.MoveORTB26FieldsToExt(requestJson); err != nil {
+		errs = []error{err}
+		return
+	}
+
 	if err := jsoniter.Unmarshal(requestJson, req); err != nil {
 		errs = []error{err}
 		return
@@ -276,7 +294,15 @@ func (deps *endpointDeps) validateRequest(req *openrtb.BidRequest) []error {
 			return []error{err}
 		}
 
-		if err := validateBidAdjustmentFactors(bidExt.Prebid.BidAdjustmentFactors, aliases); err != nil {
+		if err := deps.validateBidAdjustmentFactors(bidExt.Prebid.BidAdjustmentFactors, aliases); err != nil {
+			return []error{err}
+		}
+
+		if err := deps.validateEidPermissions(bidExt.Prebid.Data, aliases); err != nil {
+			return []error{err}
+		}
+
+		if err := deps.validateFirstPartyData(&bidExt.Prebid, req, aliases); err != nil {
 			return []error{err}
 		}
 	}
@@ -312,7 +338,7 @@ func (deps *endpointDeps) validateRequest(req *openrtb.BidRequest) []error {
 		return errL
 	}
 
-	if err := validateUser(req.User, aliases); err != nil {
+	if err := deps.validateUser(req.User, aliases); err != nil {
 		errL = append(errL, err)
 		return errL
 	}
@@ -325,12 +351,12 @@ func (deps *endpointDeps) validateRequest(req *openrtb.BidRequest) []error {
 	return errL
 }
 
-func validateBidAdjustmentFactors(adjustmentFactors map[string]float64, aliases map[string]string) error {
+func (deps *endpointDeps) validateBidAdjustmentFactors(adjustmentFactors map[string]float64, aliases map[string]string) error {
 	for bidderToAdjust, adjustmentFactor := range adjustmentFactors {
 		if adjustmentFactor <= 0 {
 			return fmt.Errorf("request.ext.prebid.bidadjustmentfactors.%s must be a positive number. Got %f", bidderToAdjust, adjustmentFactor)
 		}
-		if _, isBidder := openrtb_ext.BidderMap[bidderToAdjust]; !isBidder {
+		if !deps.isKnownBidder(bidderToAdjust) {
 			if _, isAlias := aliases[bidderToAdjust]; !isAlias {
 				return fmt.Errorf("request.ext.prebid.bidadjustmentfactors.%s is not a known bidder or alias", bidderToAdjust)
 			}
@@ -339,6 +365,95 @@ func validateBidAdjustmentFactors(adjustmentFactors map[string]float64, aliases
 	return nil
 }
 
+func (deps *endpointDeps) validateEidPermissions(prebidData *openrtb_ext.ExtRequestPrebidData, aliases map[string]string) error {
+	if prebidData == nil {
+		return nil
+	}
+	sources := make(map[string]int, len(prebidData.EidPermissions))
+	for index, permission := range prebidData.EidPermissions {
+		if permission.Source == "" {
+			return fmt.Errorf("request.ext.prebid.data.eidpermissions[%d] missing required field: \"source\"", index)
+		}
+		if firstIndex, ok := sources[permission.Source]; ok {
+			return fmt.Errorf(`request.ext.prebid.data.eidpermissions[%d] and request.ext.prebid.data.eidpermissions[%d] both have source "%s". Sources must be unique.`, firstIndex, index, permission.Source)
+		}
+		sources[permission.Source] = index
+		if len(permission.Bidders) == 0 {
+			return fmt.Errorf("request.ext.prebid.data.eidpermissions[%d] missing or empty required field: \"bidders\"", index)
+		}
+		for _, bidder := range permission.Bidders {
+			if !deps.isKnownBidderOrWildcard(bidder, aliases) {
+				return fmt.Errorf("request.ext.prebid.data.eidpermissions[%d] contains %s, which is not a known bidder or alias", index, bidder)
+			}
+		}
+	}
+	return nil
+}
+
+func (deps *endpointDeps) validateFirstPartyData(prebid *openrtb_ext.ExtRequestPrebid, req *openrtb.BidRequest, aliases map[string]string) error {
+	if prebid.Data != nil {
+		for index, bidder := range prebid.Data.Bidders {
+			if !deps.isKnownBidderOrWildcard(bidder, aliases) {
+				return fmt.Errorf("request.ext.prebid.data.bidders[%d] is %s, which is not a known bidder or alias", index, bidder)
+			}
+		}
+	}
+	for index, bidderConfig := range prebid.BidderConfigs {
+		if len(bidderConfig.Bidders) == 0 {
+			return fmt.Errorf("request.ext.prebid.bidderconfig[%d] missing or empty required field: \"bidders\"", index)
+		}
+		for _, bidder := range bidderConfig.Bidders {
+			if !deps.isKnownBidderOrWildcard(bidder, aliases) {
+				return fmt.Errorf("request.ext.prebid.bidderconfig[%d] contains %s, which is not a known bidder or alias", index, bidder)
+			}
+		}
+		if bidderConfig.Config == nil || bidderConfig.Config.ORTB2 == nil {
+			return fmt.Errorf("request.ext.prebid.bidderconfig[%d] missing required field: \"config.ortb2\"", index)
+		}
+		ortb2 := bidderConfig.Config.ORTB2
+		if len(ortb2.Site) > 0 {
+			if req.App != nil {
+				return fmt.Errorf("request.ext.prebid.bidderconfig[%d].config.ortb2.site can't be used on app requests", index)
+			}
+			if err := json.Unmarshal(ortb2.Site, &openrtb.Site{}); err != nil {
+				return fmt.Errorf("request.ext.prebid.bidderconfig[%d].config.ortb2.site is invalid: %v", index, err)
+			}
+		}
+		if len(ortb2.App) > 0 {
+			if req.Site != nil {
+				return fmt.Errorf("request.ext.prebid.bidderconfig[%d].config.ortb2.app can't be used on site requests", index)
+			}
+			if err := json.Unmarshal(ortb2.App, &openrtb.App{}); err != nil {
+				return fmt.Errorf("request.ext.prebid.bidderconfig[%d].config.ortb2.app is invalid: %v", index, err)
+			}
+		}
+		if len(ortb2.User) > 0 {
+			if err := json.Unmarshal(ortb2.User, &openrtb.User{}); err != nil {
+				return fmt.Errorf("request.ext.prebid.bidderconfig[%d].config.ortb2.user is invalid: %v", index, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (deps *endpointDeps) isKnownBidderOrWildcard(bidder string, aliases map[string]string) bool {
+	if bidder == "*" || deps.isKnownBidder(bidder) {
+		return true
+	}
+	_, isAlias := aliases[bidder]
+	return isAlias
+}
+
+// isKnownBidder returns true if the bidder is in the BidderMap, or is one of this instance's generic bidders.
+// Generic bidders aren't in the BidderMap, because they're defined by the host's config.
+func (deps *endpointDeps) isKnownBidder(bidder string) bool {
+	if _, isBidder := openrtb_ext.BidderMap[bidder]; isBidder {
+		return true
+	}
+	_, isBidder := deps.bidderMap[bidder]
+	return isBidder
+}
+
 func (deps *endpointDeps) validateImp(imp *openrtb.Imp, aliases map[string]string, index int) []error {
 	if imp.ID == "" {
 		return []error{fmt.Errorf("request.imp[%d] missing required field: \"id\"", index)}
@@ -706,6 +821,11 @@ func (deps *endpointDeps) validateImpExt(imp *openrtb.Imp, aliases map[string]st
 				bidderExts[bidder] = ext
 			}
 		}
+		for bidder, mediaType := range prebidExt.PreferredMediaType {
+			if _, err := openrtb_ext.ParseBidType(string(mediaType)); err != nil {
+				return []error{fmt.Errorf("request.imp[%d].ext.prebid.preferredmediatype.%s must be one of banner, video, audio or native. Got %s", impIndex, bidder, mediaType)}
+			}
+		}
 	}
 
 	/* Process all the bidder exts in the request */
@@ -808,7 +928,35 @@ func (deps *endpointDeps) validateApp(app *openrtb.App) error {
 	return nil
 }
 
-func validateUser(user *openrtb.User, aliases map[string]string) error {
+func validateEids(eids []openrtb_ext.ExtUserEid) error {
+	if eids == nil {
+		return nil
+	}
+	if len(eids) == 0 {
+		return errors.New("request.user.ext.eids must contain at least one element or be undefined")
+	}
+	sources := make(map[string]int, len(eids))
+	for eidIndex, eid := range eids {
+		if eid.Source == "" {
+			return fmt.Errorf("request.user.ext.eids[%d] missing required field: \"source\"", eidIndex)
+		}
+		if firstIndex, ok := sources[eid.Source]; ok {
+			return fmt.Errorf(`request.user.ext.eids[%d] and request.user.ext.eids[%d] both have source "%s". Sources must be unique.`, firstIndex, eidIndex, eid.Source)
+		}
+		sources[eid.Source] = eidIndex
+		if len(eid.UIDs) == 0 {
+			return fmt.Errorf("request.user.ext.eids[%d].uids must contain at least one element", eidIndex)
+		}
+		for uidIndex, uid := range eid.UIDs {
+			if uid.ID == "" {
+				return fmt.Errorf("request.user.ext.eids[%d].uids[%d] missing required field: \"id\"", eidIndex, uidIndex)
+			}
+		}
+	}
+	return nil
+}
+
+func (deps *endpointDeps) validateUser(user *openrtb.User, aliases map[string]string) error {
 	// DigiTrust support
 	if user != nil && user.Ext != nil {
 		// Creating ExtUser object to check if DigiTrust is valid
@@ -824,7 +972,7 @@ func validateUser(user *openrtb.User, aliases map[string]string) error {
 					return errors.New(`request.user.ext.prebid requires a "buyeruids" property with at least one ID defined. If none exist, then request.user.ext.prebid should not be defined.`)
 				}
 				for bidderName := range userExt.Prebid.BuyerUIDs {
-					if _, ok := openrtb_ext.BidderMap[bidderName]; !ok {
+					if !deps.isKnownBidder(bidderName) {
 						if _, ok := aliases[bidderName]; !ok {
 							return fmt.Errorf("request.user.ext.%s is neither a known bidder name nor an alias in request.ext.prebid.aliases.", bidderName)
 						}
@@ -845,6 +993,9 @@ func validateUser(user *openrtb.User, aliases map[string]string) error {
 					}
 				}
 			}
+			if err := validateEids(userExt.Eids); err != nil {
+				return err
+			}
 		} else {
 			// Return error.
 			return fmt.Errorf("request.user.ext object is not valid: %v", err)
@@ -863,10 +1014,42 @@ func validateRegs(regs *openrtb.Regs) error {
 		if regsExt.GDPR != nil && (*regsExt.GDPR < 0 || *regsExt.GDPR > 1) {
 			return errors.New("request.regs.ext.gdpr must be either 0 or 1.")
 		}
+		if regsExt.GPP != "" {
+			if _, err := gpp.Parse(regsExt.GPP); err != nil {
+				return fmt.Errorf("request.regs.ext.gpp is invalid: %v", err)
+			}
+		}
 	}
 	return nil
 }
 
+// setUSPrivacyFromGPP fills regs.ext.us_privacy from the GPP US Privacy section, if the caller didn't define it.
+// The GPP string itself is passed through to bidders untouched.
+func setUSPrivacyFromGPP(bidReq *openrtb.BidRequest) {
+	if bidReq.Regs == nil || len(bidReq.Regs.Ext) == 0 {
+		return
+	}
+	var regsExt openrtb_ext.ExtRegs
+	if err := jsoniter.Unmarshal(bidReq.Regs.Ext, &regsExt); err != nil || regsExt.GPP == "" || regsExt.USPrivacy != "" {
+		return
+	}
+	parsed, err := gpp.Parse(regsExt.GPP)
+	if err != nil {
+		return
+	}
+	if usp, ok := parsed.Section(gpp.SectionUSPV1); ok {
+		uspJson, err := json.Marshal(usp)
+		if err != nil {
+			return
+		}
+		if newExt, err := jsonparser.Set(bidReq.Regs.Ext, uspJson, "us_privacy"); err == nil {
+			regs := *bidReq.Regs
+			regs.Ext = newExt
+			bidReq.Regs = &regs
+		}
+	}
+}
+
 // setFieldsImplicitly uses _implicit_ information from the httpReq to set values on bidReq.
 // This function does not consume the request body, which was set explicitly, but infers certain
 // OpenRTB properties from the headers and other implicit info.
@@ -883,6 +1066,7 @@ func (deps *endpointDeps) setFieldsImplicitly(httpReq *http.Request, bidReq *ope
 
 	deps.setUserImplicitly(httpReq, bidReq)
 	setAuctionTypeImplicitly(bidReq)
+	setUSPrivacyFromGPP(bidReq)
 }
 
 // setDeviceImplicitly uses implicit info from httpReq to populate bidReq.Device
@@ -78,6 +78,9 @@ func BenchmarkOpenrtbEndpoint(b *testing.B) {
 			infos,
 			gdpr.AlwaysAllow{},
 			currencies.NewRateConverterDefault(),
+			nil,
+			nil,
+			nil,
 		),
 		paramValidator,
 		empty_fetcher.EmptyFetcher{},
@@ -88,6 +91,8 @@ func BenchmarkOpenrtbEndpoint(b *testing.B) {
 		map[string]string{},
 		[]byte{},
 		nil,
+		nil,
+		nil,
 	)
 
 	b.ResetTimer()
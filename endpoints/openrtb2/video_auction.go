@@ -24,20 +24,21 @@ import (
 	"github.com/prebid/prebid-server/exchange"
 	"github.com/prebid/prebid-server/openrtb_ext"
 	"github.com/prebid/prebid-server/pbsmetrics"
+	"github.com/prebid/prebid-server/privacy"
 	"github.com/prebid/prebid-server/stored_requests"
 	"github.com/prebid/prebid-server/usersync"
 )
 
 var defaultRequestTimeout int64 = 5000
 
-func NewVideoEndpoint(ex exchange.Exchange, validator openrtb_ext.BidderParamValidator, requestsById stored_requests.Fetcher, videoFetcher stored_requests.Fetcher, categories stored_requests.CategoryFetcher, cfg *config.Configuration, met pbsmetrics.MetricsEngine, pbsAnalytics analytics.PBSAnalyticsModule, disabledBidders map[string]string, defReqJSON []byte, bidderMap map[string]openrtb_ext.BidderName) (httprouter.Handle, error) {
+func NewVideoEndpoint(ex exchange.Exchange, validator openrtb_ext.BidderParamValidator, requestsById stored_requests.Fetcher, videoFetcher stored_requests.Fetcher, categories stored_requests.CategoryFetcher, cfg *config.Configuration, met pbsmetrics.MetricsEngine, pbsAnalytics analytics.PBSAnalyticsModule, disabledBidders map[string]string, defReqJSON []byte, bidderMap map[string]openrtb_ext.BidderName, uidStore *usersync.UIDStore) (httprouter.Handle, error) {
 
 	if ex == nil || validator == nil || requestsById == nil || cfg == nil || met == nil {
 		return nil, errors.New("NewVideoEndpoint requires non-nil arguments.")
 	}
 	defRequest := defReqJSON != nil && len(defReqJSON) > 0
 
-	return httprouter.Handle((&endpointDeps{ex, validator, requestsById, videoFetcher, categories, cfg, met, pbsAnalytics, disabledBidders, defRequest, defReqJSON, bidderMap}).VideoAuctionEndpoint), nil
+	return httprouter.Handle((&endpointDeps{ex, validator, requestsById, videoFetcher, categories, cfg, met, pbsAnalytics, disabledBidders, defRequest, defReqJSON, bidderMap, uidStore, nil}).VideoAuctionEndpoint), nil
 }
 
 /*
@@ -137,7 +138,14 @@ func (deps *endpointDeps) VideoAuctionEndpoint(w http.ResponseWriter, r *http.Re
 
 	var bidReq = &openrtb.BidRequest{}
 	if deps.defaultRequest {
-		if err := jsoniter.Unmarshal(deps.defReqJSON, bidReq); err != nil {
+		// Our OpenRTB 2.5 model has no place for the OpenRTB 2.6 fields, so they're moved into the ext locations which 2.5 callers use.
+		defReqJSON, err := openrtb_ext.MoveORTB26FieldsToExt(deps.defReqJSON)
+		if err != nil {
+			errL = []error{err}
+			handleError(labels, w, errL, ao)
+			return
+		}
+		if err := jsoniter.Unmarshal(defReqJSON, bidReq); err != nil {
 			err = fmt.Errorf("Invalid JSON in Default Request Settings: %s", err)
 			errL = []error{err}
 			return
@@ -187,7 +195,7 @@ func (deps *endpointDeps) VideoAuctionEndpoint(w http.ResponseWriter, r *http.Re
 	}
 	defer cancel()
 
-	usersyncs := usersync.ParsePBSCookieFromRequest(r, &(deps.cfg.HostCookie))
+	usersyncs, _ := deps.uidStore.ParsePBSCookieFromRequest(ctx, r, &(deps.cfg.HostCookie), deps.uidStore.FirstPartyIDFromUser(bidReq.User))
 	if bidReq.App != nil {
 		labels.Source = pbsmetrics.DemandApp
 	} else {
@@ -204,6 +212,7 @@ func (deps *endpointDeps) VideoAuctionEndpoint(w http.ResponseWriter, r *http.Re
 	//execute auction logic
 	response, err := deps.ex.HoldAuction(ctx, bidReq, usersyncs, labels, &deps.categories)
 	ao.Request = bidReq
+	ao.ActivityControl = privacy.NewActivityControl(&deps.cfg.Privacy, labels.PubID)
 	ao.Response = response
 	if err != nil {
 		errL := []error{err}
@@ -336,7 +345,11 @@ func (deps *endpointDeps) loadStoredImp(storedImpId string) (openrtb.Imp, []erro
 		return impr, err
 	}
 
-	if err := jsoniter.Unmarshal(imp[storedImpId], &impr); err != nil {
+	impJSON, moveErr := openrtb_ext.MoveImpORTB26FieldsToExt(imp[storedImpId])
+	if moveErr != nil {
+		return impr, []error{moveErr}
+	}
+	if err := jsoniter.Unmarshal(impJSON, &impr); err != nil {
 		return impr, []error{err}
 	}
 	return impr, nil
@@ -59,6 +59,26 @@ func TestVideoEndpointImpressionsNumber(t *testing.T) {
 
 }
 
+func TestVideoEndpointDefaultRequestORTB26(t *testing.T) {
+	ex := &mockExchangeVideo{}
+	reqData, err := ioutil.ReadFile("sample-requests/video/video_valid_sample.json")
+	if err != nil {
+		t.Fatalf("Failed to fetch a valid request: %v", err)
+	}
+	reqBody := string(getRequestPayload(t, reqData))
+	req := httptest.NewRequest("POST", "/openrtb2/video", strings.NewReader(reqBody))
+
+	deps := mockDeps(t, ex)
+	deps.defaultRequest = true
+	deps.defReqJSON = []byte(`{"regs":{"gdpr":1,"us_privacy":"1YNN"}}`)
+	deps.VideoAuctionEndpoint(httptest.NewRecorder(), req, nil)
+
+	if ex.lastRequest == nil {
+		t.Fatalf("The request never made it into the Exchange.")
+	}
+	assert.JSONEq(t, `{"gdpr":1,"us_privacy":"1YNN"}`, string(ex.lastRequest.Regs.Ext), "OpenRTB 2.6 fields should be moved into regs.ext")
+}
+
 func TestVideoEndpointImpressionsDuration(t *testing.T) {
 	ex := &mockExchangeVideo{}
 	reqData, err := ioutil.ReadFile("sample-requests/video/video_valid_sample_different_durations.json")
@@ -494,6 +514,8 @@ func mockDeps(t *testing.T, ex *mockExchangeVideo) *endpointDeps {
 		false,
 		[]byte{},
 		openrtb_ext.BidderMap,
+		nil,
+		nil,
 	}
 
 	return edep
@@ -2,19 +2,25 @@ package endpoints
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
 	"time"
 
+	"github.com/golang/glog"
 	"github.com/julienschmidt/httprouter"
 	"github.com/prebid/prebid-server/analytics"
 	"github.com/prebid/prebid-server/config"
 	"github.com/prebid/prebid-server/gdpr"
+	"github.com/prebid/prebid-server/gpp"
 	"github.com/prebid/prebid-server/openrtb_ext"
 	"github.com/prebid/prebid-server/pbsmetrics"
+	"github.com/prebid/prebid-server/privacy"
 	"github.com/prebid/prebid-server/usersync"
 )
 
-func NewSetUIDEndpoint(cfg config.HostCookie, perms gdpr.Permissions, pbsanalytics analytics.PBSAnalyticsModule, metrics pbsmetrics.MetricsEngine) httprouter.Handle {
+func NewSetUIDEndpoint(cfg config.HostCookie, perms gdpr.Permissions, privacyCfg *config.Privacy, pbsanalytics analytics.PBSAnalyticsModule, metrics pbsmetrics.MetricsEngine, uidStore *usersync.UIDStore) httprouter.Handle {
 	cookieTTL := time.Duration(cfg.TTL) * 24 * time.Hour
 	return httprouter.Handle(func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 		so := analytics.SetUIDObject{
@@ -24,7 +30,7 @@ func NewSetUIDEndpoint(cfg config.HostCookie, perms gdpr.Permissions, pbsanalyti
 
 		defer pbsanalytics.LogSetUIDObject(&so)
 
-		pc := usersync.ParsePBSCookieFromRequest(r, &cfg)
+		pc, fpid := uidStore.ParsePBSCookieFromRequest(r.Context(), r, &cfg, "")
 		if !pc.AllowSyncs() {
 			w.WriteHeader(http.StatusUnauthorized)
 			metrics.RecordUserIDSet(pbsmetrics.UserLabels{Action: pbsmetrics.RequestActionOptOut})
@@ -34,7 +40,20 @@ func NewSetUIDEndpoint(cfg config.HostCookie, perms gdpr.Permissions, pbsanalyti
 
 		query := r.URL.Query()
 		bidder := query.Get("bidder")
-		if shouldReturn, status, body := preventSyncsGDPR(query.Get("gdpr"), query.Get("gdpr_consent"), perms); shouldReturn {
+		gdprSignal, gdprConsent, err := gdprFromGPP(query.Get("gdpr"), query.Get("gdpr_consent"), query.Get("gpp"), query.Get("gpp_sid"))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(err.Error()))
+			metrics.RecordUserIDSet(pbsmetrics.UserLabels{
+				Action: pbsmetrics.RequestActionErr,
+				Bidder: openrtb_ext.BidderName(bidder),
+			})
+			so.Status = http.StatusBadRequest
+			return
+		}
+
+		gdprRule, status, body := gdprSyncRule(gdprSignal, gdprConsent, perms)
+		if status != 0 {
 			w.WriteHeader(status)
 			w.Write([]byte(body))
 			metrics.RecordUserIDSet(pbsmetrics.UserLabels{
@@ -45,6 +64,17 @@ func NewSetUIDEndpoint(cfg config.HostCookie, perms gdpr.Permissions, pbsanalyti
 			return
 		}
 
+		if shouldReturn, status, body, action := preventSyncs(bidder, query.Get("account"), query.Get("gpp_sid"), privacyCfg, gdprRule); shouldReturn {
+			w.WriteHeader(status)
+			w.Write([]byte(body))
+			metrics.RecordUserIDSet(pbsmetrics.UserLabels{
+				Action: action,
+				Bidder: openrtb_ext.BidderName(bidder),
+			})
+			so.Status = status
+			return
+		}
+
 		if bidder == "" {
 			w.WriteHeader(http.StatusBadRequest)
 			w.Write([]byte(`"bidder" query param is required`))
@@ -60,14 +90,51 @@ func NewSetUIDEndpoint(cfg config.HostCookie, perms gdpr.Permissions, pbsanalyti
 		uid := query.Get("uid")
 		so.UID = uid
 
-		var err error = nil
 		if uid == "" {
 			pc.Unsync(bidder)
 		} else {
 			err = pc.TrySync(bidder, uid)
 		}
 
-		if err == nil {
+		// Users who don't have a first-party ID yet get one, so that their UIDs can be saved on the server.
+		if uidStore != nil && fpid == "" && uidStore.FirstPartyIDFromRequest(r, "") == "" {
+			var issueErr error
+			if fpid, issueErr = uidStore.IssueFirstPartyID(w, &cfg, cookieTTL); issueErr != nil {
+				glog.Errorf("Failed to issue a first-party ID: %v", issueErr)
+			}
+		}
+
+		// Users with a first-party ID have their UIDs saved on the server, so the uids cookie is left alone.
+		var evicted []string
+		var saveErr error
+		if fpid != "" {
+			saveErr = uidStore.Save(r.Context(), fpid, pc, cookieTTL)
+		} else {
+			evicted, saveErr = pc.SetCookieOnResponse(w, &cfg, cookieTTL)
+		}
+		if saveErr != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			metrics.RecordUserIDSet(pbsmetrics.UserLabels{
+				Action: pbsmetrics.RequestActionErr,
+				Bidder: openrtb_ext.BidderName(bidder),
+			})
+			so.Status = http.StatusInternalServerError
+			so.Errors = append(so.Errors, saveErr)
+			return
+		}
+		if len(evicted) > 0 {
+			metrics.RecordCookieEvictions(len(evicted))
+		}
+
+		if err == nil && uid != "" && wasEvicted(bidder, evicted) {
+			// The cookie still gets set, since other UIDs may have been evicted to make room.
+			w.Write([]byte("The uids cookie is full, so the UID was not saved"))
+			metrics.RecordUserIDSet(pbsmetrics.UserLabels{
+				Action: pbsmetrics.RequestActionCookieFull,
+				Bidder: openrtb_ext.BidderName(bidder),
+			})
+			so.Errors = append(so.Errors, errors.New("the uids cookie is full, so the UID was not saved"))
+		} else if err == nil {
 			labels := pbsmetrics.UserLabels{
 				Action: pbsmetrics.RequestActionSet,
 				Bidder: openrtb_ext.BidderName(bidder),
@@ -75,37 +142,87 @@ func NewSetUIDEndpoint(cfg config.HostCookie, perms gdpr.Permissions, pbsanalyti
 			metrics.RecordUserIDSet(labels)
 			so.Success = true
 		}
-
-		pc.SetCookieOnResponse(w, cfg.Domain, cookieTTL)
 	})
 }
 
-func preventSyncsGDPR(gdprEnabled string, gdprConsent string, perms gdpr.Permissions) (bool, int, string) {
+func wasEvicted(familyName string, evicted []string) bool {
+	for _, evictedFamily := range evicted {
+		if evictedFamily == familyName {
+			return true
+		}
+	}
+	return false
+}
+
+// gdprFromGPP fills in the gdpr and gdpr_consent values from the GPP params, if the caller didn't send them.
+// The gpp_sid list decides whether GDPR applies, and the TCF EU v2 section of the gpp string becomes the consent string.
+func gdprFromGPP(gdprSignal string, gdprConsent string, gppString string, gppSID string) (string, string, error) {
+	if gdprSignal == "" {
+		sids, err := gpp.ParseSIDs(gppSID)
+		if err != nil {
+			return "", "", err
+		}
+		if applies := gpp.GDPRApplies(sids); applies != nil {
+			gdprSignal = strconv.Itoa(*applies)
+		}
+	}
+	if gdprConsent == "" && gppString != "" {
+		parsed, err := gpp.Parse(gppString)
+		if err != nil {
+			return "", "", fmt.Errorf("gpp was invalid. %v", err)
+		}
+		if tcf, ok := parsed.Section(gpp.SectionTCFEU2); ok {
+			gdprConsent = tcf
+		}
+	}
+	return gdprSignal, gdprConsent, nil
+}
+
+// preventSyncs checks the syncUser privacy activity for the bidder (cookie family) being synced.
+// The gdprRule, if any, decides when the host and account don't configure the activity.
+func preventSyncs(bidder string, account string, gppSID string, privacyCfg *config.Privacy, gdprRule privacy.DefaultRule) (bool, int, string, pbsmetrics.RequestAction) {
+	// An invalid gpp_sid has already been rejected by gdprFromGPP
+	sids, _ := gpp.ParseSIDs(gppSID)
+	activityControl := privacy.NewActivityControl(privacyCfg, account)
+	if gdprRule != nil {
+		activityControl = activityControl.WithDefault(privacy.ActivitySyncUser, gdprRule)
+	}
+	component := privacy.Component{Type: privacy.ComponentTypeBidder, Name: bidder}
+	allowed, byGDPR := activityControl.Decide(privacy.ActivitySyncUser, component, privacy.ActivityRequest{GPPSIDs: sids})
+	if allowed {
+		return false, 0, "", ""
+	}
+	if byGDPR {
+		return true, http.StatusOK, "The gdpr_consent string prevents cookies from being saved", pbsmetrics.RequestActionGDPR
+	}
+	return true, http.StatusUnavailableForLegalReasons, "The privacy activity controls prevent cookies from being saved", pbsmetrics.RequestActionPrivacy
+}
+
+// gdprSyncRule validates the GDPR params and returns the syncUser rule which enforces them, or nil if GDPR doesn't apply.
+// If the params can't be used, it returns the status and body which the request should be rejected with.
+func gdprSyncRule(gdprEnabled string, gdprConsent string, perms gdpr.Permissions) (privacy.DefaultRule, int, string) {
 	switch gdprEnabled {
 	case "0":
-		return false, 0, ""
+		return nil, 0, ""
 	case "1":
 		if gdprConsent == "" {
-			return true, http.StatusBadRequest, "gdpr_consent is required when gdpr=1"
+			return nil, http.StatusBadRequest, "gdpr_consent is required when gdpr=1"
 		}
 		fallthrough
 	case "":
-		if allowed, err := perms.HostCookiesAllowed(context.Background(), gdprConsent); err != nil {
+		allowed, err := perms.HostCookiesAllowed(context.Background(), gdprConsent)
+		if err != nil {
 			if _, ok := err.(*gdpr.ErrorMalformedConsent); ok {
-				return true, http.StatusBadRequest, "gdpr_consent was invalid. " + err.Error()
-			} else {
-				// We can't really distinguish between requests that are for a new version of the global vendor list, and
-				// ones which are simply malformed (version number is much too large).
-				// Since we try to fetch new versions as requests come in for them, PBS *should* self-correct
-				// rather quickly, meaning that most of these will be malformed strings.
-				return true, http.StatusBadRequest, "No global vendor list was available to interpret this consent string. If this is a new, valid version, it should become available soon."
+				return nil, http.StatusBadRequest, "gdpr_consent was invalid. " + err.Error()
 			}
-		} else if !allowed {
-			return true, http.StatusOK, "The gdpr_consent string prevents cookies from being saved"
-		} else {
-			return false, 0, ""
+			// We can't really distinguish between requests that are for a new version of the global vendor list, and
+			// ones which are simply malformed (version number is much too large).
+			// Since we try to fetch new versions as requests come in for them, PBS *should* self-correct
+			// rather quickly, meaning that most of these will be malformed strings.
+			return nil, http.StatusBadRequest, "No global vendor list was available to interpret this consent string. If this is a new, valid version, it should become available soon."
 		}
+		return func(privacy.Component) bool { return allowed }, 0, ""
 	default:
-		return true, http.StatusBadRequest, "the gdpr query param must be either 0 or 1. You gave " + gdprEnabled
+		return nil, http.StatusBadRequest, "the gdpr query param must be either 0 or 1. You gave " + gdprEnabled
 	}
 }
@@ -15,7 +15,9 @@ import (
 
 	analyticsConf "github.com/prebid/prebid-server/analytics/config"
 	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/pbsmetrics"
 	metricsConf "github.com/prebid/prebid-server/pbsmetrics/config"
+	"github.com/prebid/prebid-server/privacy"
 )
 
 func TestNormalSet(t *testing.T) {
@@ -79,6 +81,96 @@ func TestBadRequests(t *testing.T) {
 	assertBadRequest(t, "/setuid?uid=123", `"bidder" query param is required`)
 	assertBadRequest(t, "/setuid?bidder=appnexus&uid=123&gdpr=2", "the gdpr query param must be either 0 or 1. You gave 2")
 	assertBadRequest(t, "/setuid?bidder=appnexus&uid=123&gdpr=1", "gdpr_consent is required when gdpr=1")
+	assertBadRequest(t, "/setuid?bidder=appnexus&uid=123&gpp_sid=2", "gdpr_consent is required when gdpr=1")
+	assertBadRequest(t, "/setuid?bidder=appnexus&uid=123&gpp_sid=a", "gpp_sid must be a comma-separated list of integers. Got a")
+}
+
+func TestGPPInapplicableGDPR(t *testing.T) {
+	response := doRequest(makeRequest("/setuid?bidder=pubmatic&uid=123&gpp=DBABTA~1YNN&gpp_sid=6", nil), false, false)
+	assertIntsMatch(t, http.StatusOK, response.Code)
+	assertHasSyncs(t, response, map[string]string{
+		"pubmatic": "123",
+	})
+}
+
+func TestPreventSyncsActivities(t *testing.T) {
+	privacyCfg := &config.Privacy{
+		Activities: config.Activities{
+			SyncUser: config.Activity{
+				Rules: []config.ActivityRule{{
+					Allow:     false,
+					Condition: config.ActivityCondition{ComponentName: []string{"adnxs"}, GPPSID: []int{7}},
+				}},
+			},
+		},
+	}
+	shouldReturn, status, _, action := preventSyncs("adnxs", "", "7", privacyCfg, nil)
+	assertBoolsMatch(t, true, shouldReturn)
+	assertIntsMatch(t, http.StatusUnavailableForLegalReasons, status)
+	assertStringsMatch(t, string(pbsmetrics.RequestActionPrivacy), string(action))
+
+	shouldReturn, _, _, _ = preventSyncs("adnxs", "", "2", privacyCfg, nil)
+	assertBoolsMatch(t, false, shouldReturn)
+
+	shouldReturn, _, _, _ = preventSyncs("pubmatic", "", "7", privacyCfg, nil)
+	assertBoolsMatch(t, false, shouldReturn)
+}
+
+func TestPreventSyncsGDPRDefault(t *testing.T) {
+	allow := true
+	privacyCfg := &config.Privacy{
+		Activities: config.Activities{
+			SyncUser: config.Activity{
+				Rules: []config.ActivityRule{{
+					Allow:     true,
+					Condition: config.ActivityCondition{ComponentName: []string{"adnxs"}},
+				}},
+			},
+		},
+	}
+	gdprDenies := func(privacy.Component) bool { return false }
+
+	shouldReturn, status, body, action := preventSyncs("pubmatic", "", "", privacyCfg, gdprDenies)
+	assertBoolsMatch(t, true, shouldReturn)
+	assertIntsMatch(t, http.StatusOK, status)
+	assertStringsMatch(t, "The gdpr_consent string prevents cookies from being saved", body)
+	assertStringsMatch(t, string(pbsmetrics.RequestActionGDPR), string(action))
+
+	// Configured rules and defaults override GDPR
+	shouldReturn, _, _, _ = preventSyncs("adnxs", "", "", privacyCfg, gdprDenies)
+	assertBoolsMatch(t, false, shouldReturn)
+
+	privacyCfg.Activities.SyncUser.Default = &allow
+	shouldReturn, _, _, _ = preventSyncs("pubmatic", "", "", privacyCfg, gdprDenies)
+	assertBoolsMatch(t, false, shouldReturn)
+}
+
+func TestGDPRFromGPP(t *testing.T) {
+	gdprSignal, consent, err := gdprFromGPP("", "", "DBACNYA~CPXxRfAPXxRfAAfKABENB-CgAAAAAAAAAAYgAAAAAAAA~1YNN", "2,6")
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	assertStringsMatch(t, "1", gdprSignal)
+	assertStringsMatch(t, "CPXxRfAPXxRfAAfKABENB-CgAAAAAAAAAAYgAAAAAAAA", consent)
+
+	gdprSignal, consent, err = gdprFromGPP("0", "BONciguONcjGKADACHENAOLS1rAHDAFAAEAASABQAMwAeACEAFw", "DBABMA~CPXxRfAPXxRfAAfKABENB-CgAAAAAAAAAAYgAAAAAAAA", "2")
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	assertStringsMatch(t, "0", gdprSignal)
+	assertStringsMatch(t, "BONciguONcjGKADACHENAOLS1rAHDAFAAEAASABQAMwAeACEAFw", consent)
+
+	gdprSignal, consent, err = gdprFromGPP("", "", "", "")
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	assertStringsMatch(t, "", gdprSignal)
+	assertStringsMatch(t, "", consent)
+
+	_, _, err = gdprFromGPP("", "", "not-gpp", "")
+	if err == nil {
+		t.Error("Expected an error from an invalid gpp string")
+	}
 }
 
 func TestOptedOut(t *testing.T) {
@@ -127,7 +219,7 @@ func doRequest(req *http.Request, gdprAllowsHostCookies bool, gdprReturnsError b
 		allowPI:   true,
 	}
 	cfg := config.Configuration{}
-	endpoint := NewSetUIDEndpoint(cfg.HostCookie, perms, analyticsConf.NewPBSAnalytics(&cfg.Analytics), metricsConf.NewMetricsEngine(&cfg, openrtb_ext.BidderList()))
+	endpoint := NewSetUIDEndpoint(cfg.HostCookie, perms, &cfg.Privacy, analyticsConf.NewPBSAnalytics(&cfg.Analytics), metricsConf.NewMetricsEngine(&cfg, openrtb_ext.BidderList()), nil)
 	response := httptest.NewRecorder()
 	endpoint(response, req, nil)
 	return response
@@ -9,6 +9,8 @@ const (
 	BadServerResponseCode
 	FailedToRequestBidsCode
 	BidderTemporarilyDisabledCode
+	WarningCode
+	CircuitOpenCode
 )
 
 // We should use this code for any Error interface that is not in this package
@@ -104,6 +106,34 @@ func (err *BidderTemporarilyDisabled) Code() int {
 	return BidderTemporarilyDisabledCode
 }
 
+// Warning is used for problems which Prebid Server worked around, such as parts of the request which were
+// invalid and got ignored. The auction still runs, and the message is returned so that the caller can fix it.
+type Warning struct {
+	Message string
+}
+
+func (err *Warning) Error() string {
+	return err.Message
+}
+
+func (err *Warning) Code() int {
+	return WarningCode
+}
+
+// CircuitOpen is used when a Bidder was skipped because its circuit breaker is open. This happens after too many of
+// the recent requests to it failed or timed out, so that auctions don't keep spending their timeout on a Bidder which is down.
+type CircuitOpen struct {
+	Message string
+}
+
+func (err *CircuitOpen) Error() string {
+	return err.Message
+}
+
+func (err *CircuitOpen) Code() int {
+	return CircuitOpenCode
+}
+
 // DecodeError provides the error code for an error, as defined above
 func DecodeError(err error) int {
 	if ce, ok := err.(Coder); ok {
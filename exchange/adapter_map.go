@@ -39,48 +39,56 @@ import (
 // The newAdapterMap function is segregated to its own file to make it a simple and clean location for each Adapter
 // to register itself. No wading through Exchange code to find it.
 
-func newAdapterMap(client *http.Client, cfg *config.Configuration, infos adapters.BidderInfos) map[openrtb_ext.BidderName]AdaptedBidder {
-	ortbBidders := map[openrtb_ext.BidderName]adapters.Bidder{
-		openrtb_ext.BidderAdform:      adform.NewAdformBidder(client, cfg.Adapters[string(openrtb_ext.BidderAdform)].Endpoint),
-		openrtb_ext.BidderAdkernelAdn: adkernelAdn.NewAdkernelAdnAdapter(cfg.Adapters[strings.ToLower(string(openrtb_ext.BidderAdkernelAdn))].Endpoint),
-		openrtb_ext.BidderAdtelligent: adtelligent.NewAdtelligentBidder(cfg.Adapters[string(openrtb_ext.BidderAdtelligent)].Endpoint),
-		openrtb_ext.BidderAppnexus:    appnexus.NewAppNexusBidder(client, cfg.Adapters[string(openrtb_ext.BidderAppnexus)].Endpoint),
-		// TODO #615: Update the config setup so that the Beachfront URLs can be configured, and use those in TestRaceIntegration in exchange_test.go
-		openrtb_ext.BidderBeachfront: beachfront.NewBeachfrontBidder(),
-		openrtb_ext.BidderBrightroll: brightroll.NewBrightrollBidder(cfg.Adapters[string(openrtb_ext.BidderBrightroll)].Endpoint),
-		openrtb_ext.BidderConsumable: consumable.NewConsumableBidder(cfg.Adapters[string(openrtb_ext.BidderConsumable)].Endpoint),
-		openrtb_ext.BidderEPlanning:  eplanning.NewEPlanningBidder(client, cfg.Adapters[string(openrtb_ext.BidderEPlanning)].Endpoint),
-		openrtb_ext.BidderGumGum:     gumgum.NewGumGumBidder(cfg.Adapters[string(openrtb_ext.BidderGumGum)].Endpoint),
-		openrtb_ext.BidderOpenx:      openx.NewOpenxBidder(cfg.Adapters[string(openrtb_ext.BidderOpenx)].Endpoint),
-		openrtb_ext.BidderPubmatic:   pubmatic.NewPubmaticBidder(client, cfg.Adapters[string(openrtb_ext.BidderPubmatic)].Endpoint),
-		openrtb_ext.BidderRhythmone:  rhythmone.NewRhythmoneBidder(cfg.Adapters[string(openrtb_ext.BidderRhythmone)].Endpoint),
-		openrtb_ext.BidderRubicon: rubicon.NewRubiconBidder(
-			client,
-			cfg.Adapters[string(openrtb_ext.BidderRubicon)].Endpoint,
-			cfg.Adapters[string(openrtb_ext.BidderRubicon)].XAPI.Username,
-			cfg.Adapters[string(openrtb_ext.BidderRubicon)].XAPI.Password,
-			cfg.Adapters[string(openrtb_ext.BidderRubicon)].XAPI.Tracker),
-		openrtb_ext.BidderSomoaudience:   somoaudience.NewSomoaudienceBidder(cfg.Adapters[string(openrtb_ext.BidderSomoaudience)].Endpoint),
-		openrtb_ext.BidderSovrn:          sovrn.NewSovrnBidder(client, cfg.Adapters[string(openrtb_ext.BidderSovrn)].Endpoint),
-		openrtb_ext.Bidder33Across:       ttx.New33AcrossBidder(cfg.Adapters[string(openrtb_ext.Bidder33Across)].Endpoint),
-		openrtb_ext.BidderGrid:           grid.NewGridBidder(cfg.Adapters[string(openrtb_ext.BidderGrid)].Endpoint),
-		openrtb_ext.BidderSonobi:         sonobi.NewSonobiBidder(client, cfg.Adapters[string(openrtb_ext.BidderSonobi)].Endpoint),
-		openrtb_ext.BidderYieldmo:        yieldmo.NewYieldmoBidder(cfg.Adapters[string(openrtb_ext.BidderYieldmo)].Endpoint),
-		openrtb_ext.BidderGamoshi:        gamoshi.NewGamoshiBidder(cfg.Adapters[string(openrtb_ext.BidderGamoshi)].Endpoint),
-		openrtb_ext.BidderImprovedigital: improvedigital.NewImprovedigitalBidder(cfg.Adapters[string(openrtb_ext.BidderImprovedigital)].Endpoint),
+func newAdapterMap(client *http.Client, cfg *config.Configuration, infos adapters.BidderInfos, circuitBreakers *CircuitBreakers, shadowReporter *shadowReporter) map[openrtb_ext.BidderName]AdaptedBidder {
+	hostCfg := regionalConfig(cfg, infos, cfg.Region)
+	allBidders := newRegionAdapterMap(client, hostCfg, infos, cfg.Region, shadowReporter)
+
+	// Bidders whose endpoints are different in the regions which region_by_country sends requests to
+	// get an instance for each of those regions. The others only need the one for the host's region.
+	regionalBidders := make(map[openrtb_ext.BidderName]*regionalBidder)
+	for _, region := range countryRegions(cfg) {
+		regionCfg := regionalConfig(cfg, infos, region)
+		for key, adapter := range regionCfg.Adapters {
+			if adapter.Endpoint == hostCfg.Adapters[key].Endpoint {
+				delete(regionCfg.Adapters, key)
+			}
+		}
+		for name, bidder := range newRegionAdapterMap(client, regionCfg, infos, region, shadowReporter) {
+			regional, ok := regionalBidders[name]
+			if !ok {
+				regional = &regionalBidder{
+					bidders:         map[string]AdaptedBidder{cfg.Region: allBidders[name]},
+					defaultRegion:   cfg.Region,
+					regionByCountry: cfg.RegionByCountry,
+				}
+				regionalBidders[name] = regional
+			}
+			regional.bidders[region] = bidder
+		}
+	}
+	for name, regional := range regionalBidders {
+		allBidders[name] = regional
 	}
 
-	legacyBidders := map[openrtb_ext.BidderName]adapters.Adapter{
-		// TODO #267: Upgrade the Conversant adapter
-		openrtb_ext.BidderConversant: conversant.NewConversantAdapter(adapters.DefaultHTTPAdapterConfig, cfg.Adapters[string(openrtb_ext.BidderConversant)].Endpoint),
-		// TODO #211: Upgrade the Facebook adapter
-		openrtb_ext.BidderFacebook: audienceNetwork.NewAdapterFromFacebook(adapters.DefaultHTTPAdapterConfig, cfg.Adapters[strings.ToLower(string(openrtb_ext.BidderFacebook))].PlatformID),
-		// TODO #212: Upgrade the Index adapter
-		openrtb_ext.BidderIx: ix.NewIxAdapter(adapters.DefaultHTTPAdapterConfig, cfg.Adapters[strings.ToLower(string(openrtb_ext.BidderIx))].Endpoint),
-		// TODO #213: Upgrade the Lifestreet adapter
-		openrtb_ext.BidderLifestreet: lifestreet.NewLifestreetAdapter(adapters.DefaultHTTPAdapterConfig, cfg.Adapters[string(openrtb_ext.BidderLifestreet)].Endpoint),
-		// TODO #215: Upgrade the Pulsepoint adapter
-		openrtb_ext.BidderPulsepoint: pulsepoint.NewPulsePointAdapter(adapters.DefaultHTTPAdapterConfig, cfg.Adapters[string(openrtb_ext.BidderPulsepoint)].Endpoint),
+	// Apply any middleware used for global Bidder logic.
+	// The circuit breakers go inside ensureValidBids, so that invalid bids don't count as failures.
+	for name, bidder := range allBidders {
+		allBidders[name] = ensureValidBids(circuitBreakers.apply(name, bidder))
+	}
+
+	return allBidders
+}
+
+// newRegionAdapterMap makes the enabled Bidders in cfg.Adapters for one region. The endpoints in cfg must already be
+// the ones for the region, as regionalConfig makes them.
+func newRegionAdapterMap(client *http.Client, cfg *config.Configuration, infos adapters.BidderInfos, region string, shadowReporter *shadowReporter) map[openrtb_ext.BidderName]AdaptedBidder {
+	ortbBidders, legacyBidders := newBidders(client, cfg)
+	addHostAliases(client, cfg, ortbBidders, legacyBidders)
+
+	for name, adapter := range cfg.Adapters {
+		if adapter.Generic != nil {
+			ortbBidders[openrtb_ext.BidderName(name)] = newGenericBidder(name, &adapter, region)
+		}
 	}
 
 	allBidders := make(map[openrtb_ext.BidderName]AdaptedBidder, len(ortbBidders)+len(legacyBidders))
@@ -96,16 +104,151 @@ func newAdapterMap(client *http.Client, cfg *config.Configuration, infos adapter
 	for name, bidder := range ortbBidders {
 		// Clean out any disabled bidders
 		if isEnabledBidder(cfg.Adapters, string(name)) {
-			allBidders[name] = AdaptBidder(adapters.EnforceBidderInfo(bidder, infos[string(name)]), client)
+			info := infos[string(name)]
+			adapterCfg := cfg.Adapters[strings.ToLower(string(name))]
+			bidderClient := newBidderClient(client, string(name), adapterCfg.HTTP)
+			bidderAdapter := &BidderAdapter{
+				Bidder:            adapters.EnforceBidderInfo(bidder, info),
+				Client:            bidderClient,
+				MaxImpsPerRequest: info.MaxImpsPerRequest,
+				HTTP:              adapterCfg.HTTP,
+				TimeoutNotifier:   newTimeoutNotifier(bidder, bidderClient, adapterCfg.HTTP, &cfg.BidderTimeouts.Notifications),
+				Region:            region,
+			}
+			bidderAdapter.Shadow = newShadowMirror(name, bidderAdapter.Bidder, bidderClient, adapterCfg.HTTP, adapterCfg.Shadow, shadowReporter)
+			allBidders[name] = splitMultiFormatImps(bidderAdapter, info)
 		}
 	}
 
-	// Apply any middleware used for global Bidder logic.
-	for name, bidder := range allBidders {
-		allBidders[name] = ensureValidBids(bidder)
+	return allBidders
+}
+
+// ortbBuilders make each Bidder which Prebid Server has code for, from its adapters.{bidder} config.
+var ortbBuilders = map[openrtb_ext.BidderName]func(client *http.Client, cfg config.Adapter) adapters.Bidder{
+	openrtb_ext.BidderAdform: func(client *http.Client, cfg config.Adapter) adapters.Bidder {
+		return adform.NewAdformBidder(client, cfg.Endpoint)
+	},
+	openrtb_ext.BidderAdkernelAdn: func(client *http.Client, cfg config.Adapter) adapters.Bidder {
+		return adkernelAdn.NewAdkernelAdnAdapter(cfg.Endpoint)
+	},
+	openrtb_ext.BidderAdtelligent: func(client *http.Client, cfg config.Adapter) adapters.Bidder {
+		return adtelligent.NewAdtelligentBidder(cfg.Endpoint)
+	},
+	openrtb_ext.BidderAppnexus: func(client *http.Client, cfg config.Adapter) adapters.Bidder {
+		return appnexus.NewAppNexusBidder(client, cfg.Endpoint)
+	},
+	// TODO #615: Update the config setup so that the Beachfront URLs can be configured, and use those in TestRaceIntegration in exchange_test.go
+	openrtb_ext.BidderBeachfront: func(client *http.Client, cfg config.Adapter) adapters.Bidder {
+		return beachfront.NewBeachfrontBidder()
+	},
+	openrtb_ext.BidderBrightroll: func(client *http.Client, cfg config.Adapter) adapters.Bidder {
+		return brightroll.NewBrightrollBidder(cfg.Endpoint)
+	},
+	openrtb_ext.BidderConsumable: func(client *http.Client, cfg config.Adapter) adapters.Bidder {
+		return consumable.NewConsumableBidder(cfg.Endpoint)
+	},
+	openrtb_ext.BidderEPlanning: func(client *http.Client, cfg config.Adapter) adapters.Bidder {
+		return eplanning.NewEPlanningBidder(client, cfg.Endpoint)
+	},
+	openrtb_ext.BidderGumGum: func(client *http.Client, cfg config.Adapter) adapters.Bidder {
+		return gumgum.NewGumGumBidder(cfg.Endpoint)
+	},
+	openrtb_ext.BidderOpenx: func(client *http.Client, cfg config.Adapter) adapters.Bidder {
+		return openx.NewOpenxBidder(cfg.Endpoint)
+	},
+	openrtb_ext.BidderPubmatic: func(client *http.Client, cfg config.Adapter) adapters.Bidder {
+		return pubmatic.NewPubmaticBidder(client, cfg.Endpoint)
+	},
+	openrtb_ext.BidderRhythmone: func(client *http.Client, cfg config.Adapter) adapters.Bidder {
+		return rhythmone.NewRhythmoneBidder(cfg.Endpoint)
+	},
+	openrtb_ext.BidderRubicon: func(client *http.Client, cfg config.Adapter) adapters.Bidder {
+		return rubicon.NewRubiconBidder(client, cfg.Endpoint, cfg.XAPI.Username, cfg.XAPI.Password, cfg.XAPI.Tracker)
+	},
+	openrtb_ext.BidderSomoaudience: func(client *http.Client, cfg config.Adapter) adapters.Bidder {
+		return somoaudience.NewSomoaudienceBidder(cfg.Endpoint)
+	},
+	openrtb_ext.BidderSovrn: func(client *http.Client, cfg config.Adapter) adapters.Bidder {
+		return sovrn.NewSovrnBidder(client, cfg.Endpoint)
+	},
+	openrtb_ext.Bidder33Across: func(client *http.Client, cfg config.Adapter) adapters.Bidder {
+		return ttx.New33AcrossBidder(cfg.Endpoint)
+	},
+	openrtb_ext.BidderGrid: func(client *http.Client, cfg config.Adapter) adapters.Bidder {
+		return grid.NewGridBidder(cfg.Endpoint)
+	},
+	openrtb_ext.BidderSonobi: func(client *http.Client, cfg config.Adapter) adapters.Bidder {
+		return sonobi.NewSonobiBidder(client, cfg.Endpoint)
+	},
+	openrtb_ext.BidderYieldmo: func(client *http.Client, cfg config.Adapter) adapters.Bidder {
+		return yieldmo.NewYieldmoBidder(cfg.Endpoint)
+	},
+	openrtb_ext.BidderGamoshi: func(client *http.Client, cfg config.Adapter) adapters.Bidder {
+		return gamoshi.NewGamoshiBidder(cfg.Endpoint)
+	},
+	openrtb_ext.BidderImprovedigital: func(client *http.Client, cfg config.Adapter) adapters.Bidder {
+		return improvedigital.NewImprovedigitalBidder(cfg.Endpoint)
+	},
+}
+
+// legacyBuilders make each legacy Adapter which Prebid Server has code for, from its adapters.{bidder} config.
+var legacyBuilders = map[openrtb_ext.BidderName]func(cfg config.Adapter) adapters.Adapter{
+	// TODO #267: Upgrade the Conversant adapter
+	openrtb_ext.BidderConversant: func(cfg config.Adapter) adapters.Adapter {
+		return conversant.NewConversantAdapter(adapters.DefaultHTTPAdapterConfig, cfg.Endpoint)
+	},
+	// TODO #211: Upgrade the Facebook adapter
+	openrtb_ext.BidderFacebook: func(cfg config.Adapter) adapters.Adapter {
+		return audienceNetwork.NewAdapterFromFacebook(adapters.DefaultHTTPAdapterConfig, cfg.PlatformID)
+	},
+	// TODO #212: Upgrade the Index adapter
+	openrtb_ext.BidderIx: func(cfg config.Adapter) adapters.Adapter {
+		return ix.NewIxAdapter(adapters.DefaultHTTPAdapterConfig, cfg.Endpoint)
+	},
+	// TODO #213: Upgrade the Lifestreet adapter
+	openrtb_ext.BidderLifestreet: func(cfg config.Adapter) adapters.Adapter {
+		return lifestreet.NewLifestreetAdapter(adapters.DefaultHTTPAdapterConfig, cfg.Endpoint)
+	},
+	// TODO #215: Upgrade the Pulsepoint adapter
+	openrtb_ext.BidderPulsepoint: func(cfg config.Adapter) adapters.Adapter {
+		return pulsepoint.NewPulsePointAdapter(adapters.DefaultHTTPAdapterConfig, cfg.Endpoint)
+	},
+}
+
+// newBidders makes the Bidders and legacy Adapters for every bidder which Prebid Server has code for,
+// and which is enabled in cfg.Adapters.
+func newBidders(client *http.Client, cfg *config.Configuration) (map[openrtb_ext.BidderName]adapters.Bidder, map[openrtb_ext.BidderName]adapters.Adapter) {
+	ortbBidders := make(map[openrtb_ext.BidderName]adapters.Bidder, len(ortbBuilders))
+	for name, build := range ortbBuilders {
+		if isEnabledBidder(cfg.Adapters, string(name)) {
+			ortbBidders[name] = build(client, cfg.Adapters[strings.ToLower(string(name))])
+		}
 	}
 
-	return allBidders
+	legacyBidders := make(map[openrtb_ext.BidderName]adapters.Adapter, len(legacyBuilders))
+	for name, build := range legacyBuilders {
+		if isEnabledBidder(cfg.Adapters, string(name)) {
+			legacyBidders[name] = build(cfg.Adapters[strings.ToLower(string(name))])
+		}
+	}
+
+	return ortbBidders, legacyBidders
+}
+
+// addHostAliases adds the aliases which the host defines with adapters.{alias}.alias_of in its config.
+// Each alias gets an instance of the aliased bidder's adapter, made with the alias's config.
+func addHostAliases(client *http.Client, cfg *config.Configuration, ortbBidders map[openrtb_ext.BidderName]adapters.Bidder, legacyBidders map[openrtb_ext.BidderName]adapters.Adapter) {
+	for name, adapterCfg := range cfg.Adapters {
+		aliasOf, ok := adapterCfg.AliasedBidder()
+		if !ok {
+			continue
+		}
+		if build, ok := ortbBuilders[aliasOf]; ok {
+			ortbBidders[openrtb_ext.BidderName(name)] = build(client, adapterCfg)
+		} else if build, ok := legacyBuilders[aliasOf]; ok {
+			legacyBidders[openrtb_ext.BidderName(name)] = build(adapterCfg)
+		}
+	}
 }
 
 // isEnabledBidder Checks that a Bidder config exists and is not disabled
@@ -114,25 +257,20 @@ func isEnabledBidder(cfg map[string]config.Adapter, bidder string) bool {
 	return ok && !a.Disabled
 }
 
+// DisableBidders returns the bidders from origBidderList which are enabled in the config, as a list and a map keyed by name.
+// The bidders which aren't get an error message in disabledBidders.
 func DisableBidders(cfg map[string]config.Adapter, origBidderList []openrtb_ext.BidderName, disabledBidders map[string]string) (bidderList []openrtb_ext.BidderName, bidderMap map[string]openrtb_ext.BidderName) {
-	bidderMap = make(map[string]openrtb_ext.BidderName)
-	bidderList = origBidderList
-	for k, v := range openrtb_ext.BidderMap {
-		bidderMap[k] = v
-	}
-	// Set up error messages for disabled bidders
-	for a := range openrtb_ext.BidderMap {
+	bidderMap = make(map[string]openrtb_ext.BidderName, len(origBidderList))
+	bidderList = make([]openrtb_ext.BidderName, 0, len(origBidderList))
+	for _, bidder := range origBidderList {
+		a := string(bidder)
+		// Set up error messages for disabled bidders
 		if !isEnabledBidder(cfg, a) {
 			disabledBidders[a] = fmt.Sprintf("Bidder \"%s\" has been disabled on this instance of Prebid Server. Please work with the PBS host to enable this Bidder again.", a)
-			delete(bidderMap, a)
-			// remove this Bidder from the bidderList
-			// This could break if an adapter appears on the bidderList more than once, but in that case something else is very broken.
-			for i, b := range bidderList {
-				if string(b) == a {
-					bidderList = append(bidderList[:i], bidderList[i+1:]...)
-				}
-			}
+			continue
 		}
+		bidderMap[a] = bidder
+		bidderList = append(bidderList, bidder)
 	}
 	return bidderList, bidderMap
 }
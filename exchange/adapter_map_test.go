@@ -5,12 +5,13 @@ import (
 	"testing"
 
 	"github.com/prebid/prebid-server/adapters"
+	"github.com/prebid/prebid-server/adapters/appnexus"
 	"github.com/prebid/prebid-server/config"
 	"github.com/prebid/prebid-server/openrtb_ext"
 )
 
 func TestNewAdapterMap(t *testing.T) {
-	adapterMap := newAdapterMap(nil, &config.Configuration{Adapters: blankAdapterConfig(openrtb_ext.BidderList())}, adapters.ParseBidderInfos("../static/Bidder-info", openrtb_ext.BidderList()))
+	adapterMap := newAdapterMap(nil, &config.Configuration{Adapters: blankAdapterConfig(openrtb_ext.BidderList())}, adapters.ParseBidderInfos("../static/Bidder-info", openrtb_ext.BidderList()), nil, nil)
 	for _, bidderName := range openrtb_ext.BidderMap {
 		if bidder, ok := adapterMap[bidderName]; bidder == nil || !ok {
 			t.Errorf("adapterMap missing expected Bidder: %s", string(bidderName))
@@ -37,7 +38,7 @@ func TestNewAdapterMapDisabledAdapters(t *testing.T) {
 			}
 		}
 	}
-	adapterMap := newAdapterMap(nil, &config.Configuration{Adapters: cfgAdapters}, adapters.ParseBidderInfos("../static/Bidder-info", bidderList))
+	adapterMap := newAdapterMap(nil, &config.Configuration{Adapters: cfgAdapters}, adapters.ParseBidderInfos("../static/Bidder-info", bidderList), nil, nil)
 	for _, bidderName := range openrtb_ext.BidderMap {
 		if bidder, ok := adapterMap[bidderName]; bidder == nil || !ok {
 			if inList(bidderList, bidderName) {
@@ -51,6 +52,48 @@ func TestNewAdapterMapDisabledAdapters(t *testing.T) {
 	}
 }
 
+func TestNewAdapterMapGenericBidder(t *testing.T) {
+	genericConfig := &config.GenericAdapter{SiteMediaTypes: []string{"banner"}}
+	cfgAdapters := map[string]config.Adapter{
+		"somessp": {Endpoint: "http://some-ssp.com/bid", Generic: genericConfig},
+	}
+	infos := adapters.BidderInfos{"somessp": NewGenericBidderInfo(genericConfig)}
+	adapterMap := newAdapterMap(nil, &config.Configuration{Adapters: cfgAdapters}, infos, nil, nil)
+	if bidder, ok := adapterMap["somessp"]; bidder == nil || !ok {
+		t.Error("adapterMap missing the generic Bidder: somessp")
+	}
+}
+
+func TestAddHostAliases(t *testing.T) {
+	cfg := &config.Configuration{
+		Adapters: map[string]config.Adapter{
+			"appnexus":  {Endpoint: "http://ib.adnxs.com/openrtb2"},
+			"somealias": {Endpoint: "http://some-alias.com/openrtb2", AliasOf: "appnexus"},
+			"ix":        {Endpoint: "http://appnexus-us-east.lb.indexww.com/transbidder?p=184932"},
+			"legacy":    {Endpoint: "http://legacy-alias.com/bid", AliasOf: "ix"},
+		},
+	}
+	ortbBidders, legacyBidders := newBidders(nil, cfg)
+	addHostAliases(nil, cfg, ortbBidders, legacyBidders)
+
+	if alias, ok := ortbBidders["somealias"].(*appnexus.AppNexusAdapter); !ok || alias.URI != "http://some-alias.com/openrtb2" {
+		t.Errorf("The alias should have an appnexus adapter with its own endpoint. Got %v", ortbBidders["somealias"])
+	}
+	if parent := ortbBidders[openrtb_ext.BidderAppnexus].(*appnexus.AppNexusAdapter); parent.URI != "http://ib.adnxs.com/openrtb2" {
+		t.Errorf("The aliased bidder's endpoint should not change. Got %s", parent.URI)
+	}
+	if _, ok := legacyBidders["legacy"]; !ok {
+		t.Error("Aliases of legacy adapters should get a legacy adapter.")
+	}
+
+	aliasOrtbBidders := make(map[openrtb_ext.BidderName]adapters.Bidder)
+	aliasLegacyBidders := make(map[openrtb_ext.BidderName]adapters.Adapter)
+	addHostAliases(nil, cfg, aliasOrtbBidders, aliasLegacyBidders)
+	if len(aliasOrtbBidders) != 1 || len(aliasLegacyBidders) != 1 {
+		t.Errorf("Only the aliases should be added. Got %v and %v", aliasOrtbBidders, aliasLegacyBidders)
+	}
+}
+
 func inList(list []openrtb_ext.BidderName, name openrtb_ext.BidderName) bool {
 	for _, v := range list {
 		if v == name {
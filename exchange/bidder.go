@@ -1,15 +1,18 @@
 package exchange
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"regexp"
+	"strconv"
+	"time"
 
 	"github.com/mxmCherry/openrtb"
 	"github.com/prebid/prebid-server/adapters"
+	"github.com/prebid/prebid-server/config"
 	"github.com/prebid/prebid-server/currencies"
 	"github.com/prebid/prebid-server/errortypes"
 	"github.com/prebid/prebid-server/openrtb_ext"
@@ -86,10 +89,24 @@ func AdaptBidder(bidder adapters.Bidder, client *http.Client) AdaptedBidder {
 type BidderAdapter struct {
 	Bidder adapters.Bidder
 	Client *http.Client
+	// MaxImpsPerRequest is the most imps which the Bidder gets in one call to MakeRequests. It's unlimited if 0.
+	// See adapters.BidderInfo.MaxImpsPerRequest for what the Bidder must not do with the chunks it gets.
+	MaxImpsPerRequest int
+	// HTTP has the host's settings for the Bidder's requests. doRequest applies them to every request.
+	HTTP config.BidderHTTP
+	// TimeoutNotifier tells the Bidder about its requests which timed out. It's nil if the Bidder doesn't get told.
+	TimeoutNotifier *timeoutNotifier
+	// Region is the region whose endpoint the Bidder was made with. It fills in the endpoint macros
+	// which the Bidder leaves in its request URIs, and goes in the debug info.
+	Region string
+	// Shadow mirrors a sample of the requests to the Bidder's shadow endpoint. It's nil if the Bidder doesn't have one.
+	Shadow *shadowMirror
 }
 
 func (bidder *BidderAdapter) RequestBid(ctx context.Context, request *openrtb.BidRequest, name openrtb_ext.BidderName, bidAdjustment float64, conversions currencies.Conversions) (*PBSOrtbSeatBid, []error) {
-	reqData, errs := bidder.Bidder.MakeRequests(request)
+	reqData, chunkRequests, errs := bidder.makeRequests(ctx, request)
+	reqData, endpointErrs := bidder.resolveEndpoints(request, reqData, chunkRequests)
+	errs = append(errs, endpointErrs...)
 
 	if len(reqData) == 0 {
 		// If the adapter failed to generate both requests and errors, this is an error.
@@ -99,6 +116,8 @@ func (bidder *BidderAdapter) RequestBid(ctx context.Context, request *openrtb.Bi
 		return nil, errs
 	}
 
+	shadowCalls := bidder.Shadow.mirror(request, reqData, chunkRequests)
+
 	// Make any HTTP requests in parallel.
 	// If the Bidder only needs to make one, save some cycles by just using the current one.
 	responseChannel := make(chan *httpCallInfo, len(reqData))
@@ -125,12 +144,19 @@ func (bidder *BidderAdapter) RequestBid(ctx context.Context, request *openrtb.Bi
 		httpInfo := <-responseChannel
 		// If this is a test Bid, capture debugging info from the requests.
 		if request.Test == 1 {
-			seatBid.HTTPCalls = append(seatBid.HTTPCalls, makeExt(httpInfo))
+			httpCall := makeExt(httpInfo)
+			httpCall.Region = bidder.Region
+			seatBid.HTTPCalls = append(seatBid.HTTPCalls, httpCall)
 		}
 
 		if httpInfo.err == nil {
-			bidResponse, moreErrs := bidder.Bidder.MakeBids(request, httpInfo.request, httpInfo.response)
+			internalRequest := request
+			if chunkRequest, ok := chunkRequests[httpInfo.request]; ok {
+				internalRequest = chunkRequest
+			}
+			bidResponse, moreErrs := bidder.Bidder.MakeBids(internalRequest, httpInfo.request, httpInfo.response)
 			errs = append(errs, moreErrs...)
+			shadowCalls[httpInfo.request].finish(httpInfo, bidResponse)
 
 			if bidResponse != nil {
 				// Setup default Currency as `USD` is not set in Bid request nor Bid response
@@ -172,12 +198,160 @@ func (bidder *BidderAdapter) RequestBid(ctx context.Context, request *openrtb.Bi
 			}
 		} else {
 			errs = append(errs, httpInfo.err)
+			shadowCalls[httpInfo.request].finish(httpInfo, nil)
 		}
 	}
 
 	return seatBid, errs
 }
 
+// makeRequests calls the Bidder's MakeRequests. If the request has more than MaxImpsPerRequest imps, it's split into
+// chunks of at most that many imps, and the Bidder makes requests for each chunk in parallel. The Bidder must be safe
+// for concurrent use, which all Bidders have to be anyway. Chunks which aren't done by the time ctx is are dropped.
+//
+// The map it returns holds the chunk which each of the requests was made for, so that MakeBids gets the same one.
+// It's nil if the request wasn't split.
+func (bidder *BidderAdapter) makeRequests(ctx context.Context, request *openrtb.BidRequest) ([]*adapters.RequestData, map[*adapters.RequestData]*openrtb.BidRequest, []error) {
+	if bidder.MaxImpsPerRequest <= 0 || len(request.Imp) <= bidder.MaxImpsPerRequest {
+		reqData, errs := bidder.Bidder.MakeRequests(request)
+		return reqData, nil, errs
+	}
+
+	type chunkResult struct {
+		chunk   int
+		request *openrtb.BidRequest
+		reqData []*adapters.RequestData
+		errs    []error
+	}
+	numChunks := (len(request.Imp) + bidder.MaxImpsPerRequest - 1) / bidder.MaxImpsPerRequest
+	// The channel has room for every chunk, so that late ones don't block once nobody is waiting for them.
+	resultChannel := make(chan chunkResult, numChunks)
+	for chunk := 0; chunk < numChunks; chunk++ {
+		start := chunk * bidder.MaxImpsPerRequest
+		end := start + bidder.MaxImpsPerRequest
+		if end > len(request.Imp) {
+			end = len(request.Imp)
+		}
+		// The chunks share everything but their imps. See BidderInfo.MaxImpsPerRequest for what that means for Bidders.
+		chunkRequest := *request
+		// Cap the slice so that Bidders which append to their Imps don't overwrite the next chunk's.
+		chunkRequest.Imp = request.Imp[start:end:end]
+
+		go func(chunk int, chunkRequest *openrtb.BidRequest) {
+			reqData, errs := bidder.Bidder.MakeRequests(chunkRequest)
+			for i := range errs {
+				errs[i] = offsetImpIndexes(errs[i], chunk*bidder.MaxImpsPerRequest)
+			}
+			resultChannel <- chunkResult{chunk: chunk, request: chunkRequest, reqData: reqData, errs: errs}
+		}(chunk, &chunkRequest)
+	}
+
+	// Keep the chunks in order, so that the requests and errors come out the same way every time.
+	results := make([]*chunkResult, numChunks)
+	var timeoutErr error
+	for received := 0; received < numChunks && timeoutErr == nil; received++ {
+		select {
+		case result := <-resultChannel:
+			results[result.chunk] = &result
+		case <-ctx.Done():
+			timeoutErr = &errortypes.Timeout{Message: fmt.Sprintf("%d of the %d chunks of imps weren't ready before the deadline, so they weren't requested", numChunks-received, numChunks)}
+		}
+	}
+
+	var reqData []*adapters.RequestData
+	chunkRequests := make(map[*adapters.RequestData]*openrtb.BidRequest)
+	// Errors which apply to the whole request, like an unsupported platform, would otherwise be repeated for every chunk.
+	// They're found by their code and message. Errors which only some chunks return are about those chunks' imps,
+	// so they're all kept, even if they look the same. Errors about specific imps are always kept.
+	chunksWithError := make(map[chunkError]int)
+	numResults := 0
+	for _, result := range results {
+		if result == nil {
+			continue
+		}
+		numResults++
+		for _, oneReqData := range result.reqData {
+			reqData = append(reqData, oneReqData)
+			chunkRequests[oneReqData] = result.request
+		}
+		seenInChunk := make(map[chunkError]struct{}, len(result.errs))
+		for _, err := range result.errs {
+			key := newChunkError(err)
+			if _, seen := seenInChunk[key]; !seen {
+				seenInChunk[key] = struct{}{}
+				chunksWithError[key]++
+			}
+		}
+	}
+
+	var errs []error
+	reported := make(map[chunkError]struct{})
+	for _, result := range results {
+		if result == nil {
+			continue
+		}
+		for _, err := range result.errs {
+			key := newChunkError(err)
+			if chunksWithError[key] == numResults && !impIndexPattern.MatchString(key.message) {
+				if _, ok := reported[key]; ok {
+					continue
+				}
+				reported[key] = struct{}{}
+			}
+			errs = append(errs, err)
+		}
+	}
+	if timeoutErr != nil {
+		errs = append(errs, timeoutErr)
+	}
+	return reqData, chunkRequests, errs
+}
+
+// chunkError identifies the errors which makeRequests treats as the same.
+type chunkError struct {
+	code    int
+	message string
+}
+
+func newChunkError(err error) chunkError {
+	return chunkError{
+		code:    errortypes.DecodeError(err),
+		message: err.Error(),
+	}
+}
+
+// impIndexPattern finds the imp indexes in error messages, like the 0 in "request.imp[0] uses video".
+var impIndexPattern = regexp.MustCompile(`imp\[(\d+)\]`)
+
+// offsetImpIndexes rewrites the imp indexes in an error from a chunk's MakeRequests, so that they're the imps'
+// positions in the whole request. Only the errortypes can be rewritten. Other errors are returned as they are.
+func offsetImpIndexes(err error, offset int) error {
+	if offset == 0 || !impIndexPattern.MatchString(err.Error()) {
+		return err
+	}
+	message := impIndexPattern.ReplaceAllStringFunc(err.Error(), func(match string) string {
+		index, _ := strconv.Atoi(impIndexPattern.FindStringSubmatch(match)[1])
+		return fmt.Sprintf("imp[%d]", index+offset)
+	})
+	switch err.(type) {
+	case *errortypes.Timeout:
+		return &errortypes.Timeout{Message: message}
+	case *errortypes.BadInput:
+		return &errortypes.BadInput{Message: message}
+	case *errortypes.BadServerResponse:
+		return &errortypes.BadServerResponse{Message: message}
+	case *errortypes.FailedToRequestBids:
+		return &errortypes.FailedToRequestBids{Message: message}
+	case *errortypes.BidderTemporarilyDisabled:
+		return &errortypes.BidderTemporarilyDisabled{Message: message}
+	case *errortypes.Warning:
+		return &errortypes.Warning{Message: message}
+	case *errortypes.CircuitOpen:
+		return &errortypes.CircuitOpen{Message: message}
+	}
+	return err
+}
+
 // makeExt transforms information about the HTTP call into the contract class for the PBS response.
 func makeExt(httpInfo *httpCallInfo) *openrtb_ext.ExtHttpCall {
 	if httpInfo.err == nil {
@@ -200,31 +374,34 @@ func makeExt(httpInfo *httpCallInfo) *openrtb_ext.ExtHttpCall {
 // doRequest makes a request, handles the response, and returns the data needed by the
 // Bidder interface.
 func (bidder *BidderAdapter) doRequest(ctx context.Context, req *adapters.RequestData) *httpCallInfo {
-	httpReq, err := http.NewRequest(req.Method, req.Uri, bytes.NewBuffer(req.Body))
+	httpReq, err := newHTTPRequest(req, &bidder.HTTP)
 	if err != nil {
 		return &httpCallInfo{
 			request: req,
 			err:     err,
 		}
 	}
-	httpReq.Header = req.Headers
-
+	start := time.Now()
 	httpResp, err := ctxhttp.Do(ctx, bidder.Client, httpReq)
 	if err != nil {
 		if err == context.DeadlineExceeded {
 			err = &errortypes.Timeout{Message: err.Error()}
+			bidder.TimeoutNotifier.notify(req)
 		}
 		return &httpCallInfo{
 			request: req,
 			err:     err,
+			elapsed: time.Since(start),
 		}
 	}
 
 	respBody, err := ioutil.ReadAll(httpResp.Body)
+	elapsed := time.Since(start)
 	if err != nil {
 		return &httpCallInfo{
 			request: req,
 			err:     err,
+			elapsed: elapsed,
 		}
 	}
 	defer httpResp.Body.Close()
@@ -242,7 +419,8 @@ func (bidder *BidderAdapter) doRequest(ctx context.Context, req *adapters.Reques
 			Body:       respBody,
 			Headers:    httpResp.Header,
 		},
-		err: err,
+		err:     err,
+		elapsed: elapsed,
 	}
 }
 
@@ -250,4 +428,6 @@ type httpCallInfo struct {
 	request  *adapters.RequestData
 	response *adapters.ResponseData
 	err      error
+	// elapsed is how long the server took to respond, including reading the response body.
+	elapsed time.Duration
 }
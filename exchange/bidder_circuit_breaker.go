@@ -0,0 +1,241 @@
+package exchange
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/currencies"
+	"github.com/prebid/prebid-server/errortypes"
+	"github.com/prebid/prebid-server/openrtb_ext"
+	"github.com/prebid/prebid-server/pbsmetrics"
+)
+
+// circuitBreakerBuckets is how many pieces the circuit breakers split their windows into.
+// The oldest piece drops out of the error rate as time passes, so the window rolls in steps of window / circuitBreakerBuckets.
+const circuitBreakerBuckets = 10
+
+// CircuitBreakers are the circuit breakers for the Bidders which have them enabled in the config.
+// They're made before the Exchange, so that the admin server can report their states.
+type CircuitBreakers struct {
+	breakers map[openrtb_ext.BidderName]*circuitBreaker
+}
+
+// CircuitBreakerStatus is the state of a Bidder's circuit breaker, as reported on the admin server.
+type CircuitBreakerStatus struct {
+	Bidder string                         `json:"bidder"`
+	State  pbsmetrics.CircuitBreakerState `json:"state"`
+	// Requests and Failures are counted over the breaker's window, and only while it's closed.
+	Requests int `json:"requests"`
+	Failures int `json:"failures"`
+	// OpenedAt is when the breaker last opened, if it ever has.
+	OpenedAt *time.Time `json:"opened_at,omitempty"`
+}
+
+// NewCircuitBreakers makes the circuit breakers for the enabled Bidders whose circuit_breaker config is enabled.
+// The Bidders' states start out closed.
+func NewCircuitBreakers(cfg *config.Configuration, me pbsmetrics.MetricsEngine) *CircuitBreakers {
+	breakers := &CircuitBreakers{
+		breakers: make(map[openrtb_ext.BidderName]*circuitBreaker),
+	}
+	for _, bidder := range append(openrtb_ext.BidderList(), GenericBidders(cfg.Adapters)...) {
+		breakerCfg := cfg.BidderCircuitBreaker(string(bidder))
+		if breakerCfg.Enabled && isEnabledBidder(cfg.Adapters, string(bidder)) {
+			breakers.breakers[bidder] = newCircuitBreaker(bidder, breakerCfg, me)
+		}
+	}
+	return breakers
+}
+
+// States returns the states of the circuit breakers, sorted by Bidder.
+func (c *CircuitBreakers) States() []CircuitBreakerStatus {
+	if c == nil {
+		return []CircuitBreakerStatus{}
+	}
+	states := make([]CircuitBreakerStatus, 0, len(c.breakers))
+	for _, breaker := range c.breakers {
+		states = append(states, breaker.status())
+	}
+	sort.Slice(states, func(i, j int) bool {
+		return states[i].Bidder < states[j].Bidder
+	})
+	return states
+}
+
+// apply returns a Bidder which goes through the named Bidder's circuit breaker, if it has one.
+func (c *CircuitBreakers) apply(name openrtb_ext.BidderName, bidder AdaptedBidder) AdaptedBidder {
+	if c == nil {
+		return bidder
+	}
+	if breaker, ok := c.breakers[name]; ok {
+		return &circuitBreakerBidder{
+			bidder:  bidder,
+			breaker: breaker,
+		}
+	}
+	return bidder
+}
+
+type circuitBreakerBidder struct {
+	bidder  AdaptedBidder
+	breaker *circuitBreaker
+}
+
+func (b *circuitBreakerBidder) RequestBid(ctx context.Context, request *openrtb.BidRequest, name openrtb_ext.BidderName, bidAdjustment float64, conversions currencies.Conversions) (*PBSOrtbSeatBid, []error) {
+	if !b.breaker.allow() {
+		return nil, []error{&errortypes.CircuitOpen{
+			Message: fmt.Sprintf("Bidder %s was skipped, because too many of the recent requests to it failed or timed out.", b.breaker.bidder),
+		}}
+	}
+	seatBid, errs := b.bidder.RequestBid(ctx, request, name, bidAdjustment, conversions)
+	b.breaker.record(isBidderFailure(errs))
+	return seatBid, errs
+}
+
+// isBidderFailure says whether the errors from a request to a Bidder mean that its server is down or struggling.
+// Timeouts, bad responses and connection errors count. Other errors, such as bad input, don't.
+func isBidderFailure(errs []error) bool {
+	for _, err := range errs {
+		switch errortypes.DecodeError(err) {
+		case errortypes.TimeoutCode, errortypes.BadServerResponseCode:
+			return true
+		}
+		if _, ok := err.(net.Error); ok {
+			return true
+		}
+	}
+	return false
+}
+
+type circuitBreaker struct {
+	bidder      openrtb_ext.BidderName
+	cfg         config.CircuitBreaker
+	me          pbsmetrics.MetricsEngine
+	bucketWidth time.Duration
+	// now and random are swapped out by the tests.
+	now    func() time.Time
+	random func() float64
+
+	lock     sync.Mutex
+	state    pbsmetrics.CircuitBreakerState
+	openedAt time.Time
+	buckets  [circuitBreakerBuckets]circuitBreakerBucket
+}
+
+type circuitBreakerBucket struct {
+	start    time.Time
+	requests int
+	failures int
+}
+
+func newCircuitBreaker(bidder openrtb_ext.BidderName, cfg config.CircuitBreaker, me pbsmetrics.MetricsEngine) *circuitBreaker {
+	breaker := &circuitBreaker{
+		bidder:      bidder,
+		cfg:         cfg,
+		me:          me,
+		bucketWidth: time.Duration(cfg.WindowSeconds) * time.Second / circuitBreakerBuckets,
+		now:         time.Now,
+		random:      rand.Float64,
+		state:       pbsmetrics.CircuitBreakerClosed,
+	}
+	me.RecordAdapterCircuitBreakerState(bidder, breaker.state)
+	return breaker
+}
+
+// allow says whether a request should go to the Bidder. Open breakers go half-open once they've been open for long enough.
+func (b *circuitBreaker) allow() bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.state == pbsmetrics.CircuitBreakerOpen && b.now().Sub(b.openedAt) >= time.Duration(b.cfg.OpenSeconds)*time.Second {
+		b.setState(pbsmetrics.CircuitBreakerHalfOpen)
+	}
+	switch b.state {
+	case pbsmetrics.CircuitBreakerOpen:
+		return false
+	case pbsmetrics.CircuitBreakerHalfOpen:
+		return b.random() < b.cfg.ProbeRate
+	}
+	return true
+}
+
+// record counts the result of a request to the Bidder. Closed breakers open if the error rate is too high.
+// Half-open breakers close if the request succeeded, and open again if it failed.
+func (b *circuitBreaker) record(failed bool) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	switch b.state {
+	case pbsmetrics.CircuitBreakerHalfOpen:
+		if failed {
+			b.open()
+		} else {
+			b.buckets = [circuitBreakerBuckets]circuitBreakerBucket{}
+			b.setState(pbsmetrics.CircuitBreakerClosed)
+		}
+	case pbsmetrics.CircuitBreakerClosed:
+		bucket := b.currentBucket()
+		bucket.requests++
+		if failed {
+			bucket.failures++
+		}
+		requests, failures := b.counts()
+		if requests >= b.cfg.MinRequests && float64(failures) >= b.cfg.ErrorRate*float64(requests) {
+			b.open()
+		}
+	}
+}
+
+func (b *circuitBreaker) open() {
+	b.openedAt = b.now()
+	b.setState(pbsmetrics.CircuitBreakerOpen)
+}
+
+func (b *circuitBreaker) setState(state pbsmetrics.CircuitBreakerState) {
+	b.state = state
+	b.me.RecordAdapterCircuitBreakerState(b.bidder, state)
+}
+
+// currentBucket returns the bucket for the current time, emptying it first if it's left over from an older window.
+func (b *circuitBreaker) currentBucket() *circuitBreakerBucket {
+	start := b.now().Truncate(b.bucketWidth)
+	bucket := &b.buckets[(start.UnixNano()/int64(b.bucketWidth))%circuitBreakerBuckets]
+	if !bucket.start.Equal(start) {
+		*bucket = circuitBreakerBucket{start: start}
+	}
+	return bucket
+}
+
+// counts returns the requests and failures in the window.
+func (b *circuitBreaker) counts() (requests int, failures int) {
+	windowStart := b.now().Add(-time.Duration(b.cfg.WindowSeconds) * time.Second)
+	for _, bucket := range b.buckets {
+		if bucket.start.After(windowStart) {
+			requests += bucket.requests
+			failures += bucket.failures
+		}
+	}
+	return
+}
+
+func (b *circuitBreaker) status() CircuitBreakerStatus {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	status := CircuitBreakerStatus{
+		Bidder: string(b.bidder),
+		State:  b.state,
+	}
+	status.Requests, status.Failures = b.counts()
+	if !b.openedAt.IsZero() {
+		openedAt := b.openedAt
+		status.OpenedAt = &openedAt
+	}
+	return status
+}
@@ -0,0 +1,158 @@
+package exchange
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/errortypes"
+	"github.com/prebid/prebid-server/openrtb_ext"
+	"github.com/prebid/prebid-server/pbsmetrics"
+	metricsConf "github.com/prebid/prebid-server/pbsmetrics/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestCircuitBreakerOpensAndProbes(t *testing.T) {
+	breaker, clock := newTestCircuitBreaker(&metricsConf.DummyMetricsEngine{})
+	bidder := &mockAdaptedBidder{errorResponse: []error{&errortypes.Timeout{Message: "timed out"}}}
+	wrapped := &circuitBreakerBidder{bidder: bidder, breaker: breaker}
+
+	for i := 0; i < 3; i++ {
+		_, errs := wrapped.RequestBid(context.Background(), nil, openrtb_ext.BidderAppnexus, 1.0, nil)
+		assertNoCircuitOpen(t, errs)
+	}
+	assert.Equal(t, pbsmetrics.CircuitBreakerClosed, breaker.state, "The breaker shouldn't open before min_requests")
+
+	wrapped.RequestBid(context.Background(), nil, openrtb_ext.BidderAppnexus, 1.0, nil)
+	assert.Equal(t, pbsmetrics.CircuitBreakerOpen, breaker.state)
+
+	_, errs := wrapped.RequestBid(context.Background(), nil, openrtb_ext.BidderAppnexus, 1.0, nil)
+	if assert.Len(t, errs, 1) {
+		assert.Equal(t, errortypes.CircuitOpenCode, errortypes.DecodeError(errs[0]))
+	}
+
+	// Once open_seconds pass, only the probes should get through.
+	*clock = clock.Add(30 * time.Second)
+	breaker.random = func() float64 { return 0.5 }
+	_, errs = wrapped.RequestBid(context.Background(), nil, openrtb_ext.BidderAppnexus, 1.0, nil)
+	assert.Equal(t, errortypes.CircuitOpenCode, errortypes.DecodeError(errs[0]))
+	assert.Equal(t, pbsmetrics.CircuitBreakerHalfOpen, breaker.state)
+
+	breaker.random = func() float64 { return 0.05 }
+	wrapped.RequestBid(context.Background(), nil, openrtb_ext.BidderAppnexus, 1.0, nil)
+	assert.Equal(t, pbsmetrics.CircuitBreakerOpen, breaker.state, "A failed probe should open the breaker again")
+
+	*clock = clock.Add(30 * time.Second)
+	bidder.errorResponse = nil
+	_, errs = wrapped.RequestBid(context.Background(), nil, openrtb_ext.BidderAppnexus, 1.0, nil)
+	assert.Empty(t, errs)
+	assert.Equal(t, pbsmetrics.CircuitBreakerClosed, breaker.state, "A successful probe should close the breaker")
+
+	requests, failures := breaker.counts()
+	assert.Equal(t, 0, requests, "Closing the breaker should forget the old requests")
+	assert.Equal(t, 0, failures, "Closing the breaker should forget the old failures")
+}
+
+func TestCircuitBreakerErrorRate(t *testing.T) {
+	breaker, _ := newTestCircuitBreaker(&metricsConf.DummyMetricsEngine{})
+
+	breaker.record(true)
+	breaker.record(false)
+	breaker.record(false)
+	breaker.record(false)
+	breaker.record(true)
+	assert.Equal(t, pbsmetrics.CircuitBreakerClosed, breaker.state, "2 failures in 5 requests is under the error rate")
+
+	breaker.record(true)
+	assert.Equal(t, pbsmetrics.CircuitBreakerOpen, breaker.state, "3 failures in 6 requests is at the error rate")
+}
+
+func TestCircuitBreakerWindowRolls(t *testing.T) {
+	breaker, clock := newTestCircuitBreaker(&metricsConf.DummyMetricsEngine{})
+
+	breaker.record(true)
+	breaker.record(true)
+	*clock = clock.Add(30 * time.Second)
+	breaker.record(false)
+	requests, failures := breaker.counts()
+	assert.Equal(t, 3, requests)
+	assert.Equal(t, 2, failures)
+
+	// The first failures should have left the window, so this shouldn't open the breaker.
+	*clock = clock.Add(31 * time.Second)
+	breaker.record(true)
+	requests, failures = breaker.counts()
+	assert.Equal(t, 2, requests)
+	assert.Equal(t, 1, failures)
+	assert.Equal(t, pbsmetrics.CircuitBreakerClosed, breaker.state)
+}
+
+func TestCircuitBreakerMetrics(t *testing.T) {
+	me := &pbsmetrics.MetricsEngineMock{}
+	me.On("RecordAdapterCircuitBreakerState", openrtb_ext.BidderAppnexus, mock.Anything).Return()
+	breaker, _ := newTestCircuitBreaker(me)
+
+	for i := 0; i < 4; i++ {
+		breaker.record(true)
+	}
+
+	me.AssertCalled(t, "RecordAdapterCircuitBreakerState", openrtb_ext.BidderAppnexus, pbsmetrics.CircuitBreakerClosed)
+	me.AssertCalled(t, "RecordAdapterCircuitBreakerState", openrtb_ext.BidderAppnexus, pbsmetrics.CircuitBreakerOpen)
+}
+
+func TestIsBidderFailure(t *testing.T) {
+	assert.False(t, isBidderFailure(nil))
+	assert.False(t, isBidderFailure([]error{&errortypes.BadInput{Message: "bad input"}, errors.New("invalid bid")}))
+	assert.True(t, isBidderFailure([]error{&errortypes.Timeout{Message: "timed out"}}))
+	assert.True(t, isBidderFailure([]error{&errortypes.BadServerResponse{Message: "status 500"}}))
+	assert.True(t, isBidderFailure([]error{&url.Error{Op: "Post", URL: "http://bidder.com", Err: errors.New("connection refused")}}))
+}
+
+func TestNewCircuitBreakers(t *testing.T) {
+	cfg := &config.Configuration{
+		CircuitBreaker: config.CircuitBreaker{Enabled: true, ErrorRate: 0.5, MinRequests: 4, WindowSeconds: 60, OpenSeconds: 30, ProbeRate: 0.1},
+		Adapters: map[string]config.Adapter{
+			"appnexus": {},
+			"rubicon":  {CircuitBreaker: &config.CircuitBreaker{}},
+			"pubmatic": {Disabled: true},
+		},
+	}
+	breakers := NewCircuitBreakers(cfg, &metricsConf.DummyMetricsEngine{})
+
+	assert.Equal(t, []CircuitBreakerStatus{{Bidder: "appnexus", State: pbsmetrics.CircuitBreakerClosed}}, breakers.States())
+
+	bidder := &mockAdaptedBidder{}
+	assert.IsType(t, &circuitBreakerBidder{}, breakers.apply(openrtb_ext.BidderAppnexus, bidder))
+	assert.Equal(t, bidder, breakers.apply(openrtb_ext.BidderRubicon, bidder), "Bidders whose circuit breaker is disabled shouldn't be wrapped")
+
+	var noBreakers *CircuitBreakers
+	assert.Equal(t, bidder, noBreakers.apply(openrtb_ext.BidderAppnexus, bidder))
+	assert.Empty(t, noBreakers.States())
+}
+
+// newTestCircuitBreaker makes a breaker which opens when half of at least 4 requests in a minute fail,
+// and whose clock only moves when the test moves it.
+func newTestCircuitBreaker(me pbsmetrics.MetricsEngine) (*circuitBreaker, *time.Time) {
+	clock := time.Date(2019, 6, 1, 12, 0, 0, 0, time.UTC)
+	breaker := newCircuitBreaker(openrtb_ext.BidderAppnexus, config.CircuitBreaker{
+		Enabled:       true,
+		ErrorRate:     0.5,
+		MinRequests:   4,
+		WindowSeconds: 60,
+		OpenSeconds:   30,
+		ProbeRate:     0.1,
+	}, me)
+	breaker.now = func() time.Time { return clock }
+	return breaker, &clock
+}
+
+func assertNoCircuitOpen(t *testing.T, errs []error) {
+	t.Helper()
+	for _, err := range errs {
+		assert.NotEqual(t, errortypes.CircuitOpenCode, errortypes.DecodeError(err))
+	}
+}
@@ -0,0 +1,54 @@
+package exchange
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/adapters"
+	"github.com/prebid/prebid-server/errortypes"
+	"github.com/prebid/prebid-server/macros"
+)
+
+// resolveEndpoints resolves the endpoint macros which are still in the URIs of the Bidder's requests.
+// Most Bidders build their URIs from the host's endpoint without resolving it, so this is what lets hosts
+// use the macros in any Bidder's endpoint. Bidders which resolve the macros themselves don't leave any behind.
+//
+// Each URI gets the macros of the request which it was made for, which is a chunk of the auction's request
+// if the Bidder has a MaxImpsPerRequest. The zone and placement IDs come from the first imp of that request,
+// so endpoints which need them for every imp only work for Bidders whose MaxImpsPerRequest is 1.
+//
+// Requests whose URIs can't be resolved are dropped, with an error.
+func (bidder *BidderAdapter) resolveEndpoints(request *openrtb.BidRequest, reqData []*adapters.RequestData, chunkRequests map[*adapters.RequestData]*openrtb.BidRequest) ([]*adapters.RequestData, []error) {
+	var errs []error
+	resolved := reqData[:0]
+	for _, oneReqData := range reqData {
+		if !strings.Contains(oneReqData.Uri, "{{") {
+			resolved = append(resolved, oneReqData)
+			continue
+		}
+		madeFor := request
+		if chunkRequest, ok := chunkRequests[oneReqData]; ok {
+			madeFor = chunkRequest
+		}
+		uri, err := resolveEndpoint(oneReqData.Uri, madeFor, bidder.Region)
+		if err != nil {
+			errs = append(errs, &errortypes.FailedToRequestBids{
+				Message: fmt.Sprintf("Unable to resolve the endpoint macros in %s: %v", oneReqData.Uri, err),
+			})
+			continue
+		}
+		oneReqData.Uri = uri
+		resolved = append(resolved, oneReqData)
+	}
+	return resolved, errs
+}
+
+func resolveEndpoint(uri string, request *openrtb.BidRequest, region string) (string, error) {
+	endpointTemplate, err := template.New("endpointTemplate").Parse(uri)
+	if err != nil {
+		return "", err
+	}
+	return macros.ResolveEndpoint(*endpointTemplate, adapters.NewEndpointTemplateParams(request, region))
+}
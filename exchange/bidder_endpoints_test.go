@@ -0,0 +1,65 @@
+package exchange
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/currencies"
+	"github.com/prebid/prebid-server/errortypes"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolveEndpoints(t *testing.T) {
+	var lock sync.Mutex
+	var queries []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		queries = append(queries, r.URL.RawQuery)
+		lock.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	bidder := &BidderAdapter{
+		Bidder:            &impChunkBidder{uri: server.URL + "/bid?zone={{.ZoneID}}&account={{.AccountID}}&region={{.Region}}"},
+		Client:            server.Client(),
+		MaxImpsPerRequest: 1,
+		Region:            "eu-west",
+	}
+	request := &openrtb.BidRequest{
+		Site: &openrtb.Site{Publisher: &openrtb.Publisher{ID: "some account"}},
+		Imp: []openrtb.Imp{
+			{ID: "one", Ext: json.RawMessage(`{"bidder":{"zoneId":"zone&1"}}`)},
+			{ID: "three", Ext: json.RawMessage(`{"bidder":{"zone":3}}`)},
+		},
+	}
+	currencyConverter := currencies.NewRateConverterDefault()
+	bidder.RequestBid(context.Background(), request, "test", 1.0, currencyConverter.Rates())
+
+	sort.Strings(queries)
+	assert.Equal(t, []string{
+		"zone=3&account=some+account&region=eu-west",
+		"zone=zone%261&account=some+account&region=eu-west",
+	}, queries, "Each request's URI should get the escaped macros of the imps which it was made for")
+}
+
+func TestResolveEndpointsInvalid(t *testing.T) {
+	bidder := &BidderAdapter{
+		Bidder: &impChunkBidder{uri: "http://some-bidder.com/bid?zone={{.ZoneID"},
+		Client: http.DefaultClient,
+	}
+	request := &openrtb.BidRequest{Imp: []openrtb.Imp{{ID: "one"}}}
+	currencyConverter := currencies.NewRateConverterDefault()
+	seatBid, errs := bidder.RequestBid(context.Background(), request, "test", 1.0, currencyConverter.Rates())
+
+	assert.Nil(t, seatBid, "Requests whose URIs can't be resolved shouldn't be sent")
+	if assert.Len(t, errs, 2) {
+		assert.IsType(t, &errortypes.FailedToRequestBids{}, errs[1])
+	}
+}
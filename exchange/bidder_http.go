@@ -0,0 +1,91 @@
+package exchange
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+
+	"github.com/golang/glog"
+	"github.com/prebid/prebid-server/adapters"
+	"github.com/prebid/prebid-server/config"
+	"golang.org/x/net/http2"
+)
+
+// newBidderClient returns the HTTP client for a bidder. Bidders which configure their own connection pooling
+// or HTTP/2 get a client of their own, with the same TLS config as the shared one. The others share it.
+func newBidderClient(client *http.Client, name string, cfg config.BidderHTTP) *http.Client {
+	if cfg.MaxIdleConns == 0 && !cfg.HTTP2 {
+		return client
+	}
+
+	transport := &http.Transport{
+		Proxy:               http.ProxyFromEnvironment,
+		MaxIdleConns:        cfg.MaxIdleConns,
+		MaxIdleConnsPerHost: cfg.MaxIdleConns,
+	}
+	if client != nil {
+		if shared, ok := client.Transport.(*http.Transport); ok {
+			transport.TLSClientConfig = shared.TLSClientConfig
+			transport.IdleConnTimeout = shared.IdleConnTimeout
+			if cfg.MaxIdleConns == 0 {
+				transport.MaxIdleConns = shared.MaxIdleConns
+				transport.MaxIdleConnsPerHost = shared.MaxIdleConnsPerHost
+			}
+		}
+	}
+	// Transports with a custom TLS config only use HTTP/2 if they're configured for it explicitly.
+	if cfg.HTTP2 {
+		if err := http2.ConfigureTransport(transport); err != nil {
+			glog.Errorf("Failed to enable HTTP/2 for bidder %s. It will use HTTP/1.1. %v", name, err)
+		}
+	}
+	return &http.Client{Transport: transport}
+}
+
+// newHTTPRequest makes the HTTP request for a request from the Bidder, with the host's settings for the Bidder.
+// The RequestData isn't modified, so the debug output doesn't show the auth or the compressed body.
+func newHTTPRequest(req *adapters.RequestData, cfg *config.BidderHTTP) (*http.Request, error) {
+	var body io.Reader = bytes.NewBuffer(req.Body)
+	if cfg.Gzip {
+		compressed, err := gzipBody(req.Body)
+		if err != nil {
+			return nil, err
+		}
+		body = compressed
+	}
+	httpReq, err := http.NewRequest(req.Method, req.Uri, body)
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq.Header = make(http.Header, len(req.Headers)+len(cfg.Headers)+2)
+	for key, values := range req.Headers {
+		httpReq.Header[key] = values
+	}
+	for key, value := range cfg.Headers {
+		httpReq.Header.Set(key, value)
+	}
+	switch cfg.Auth.Type {
+	case "basic":
+		httpReq.SetBasicAuth(cfg.Auth.Username, cfg.Auth.Password)
+	case "bearer":
+		httpReq.Header.Set("Authorization", "Bearer "+cfg.Auth.Token)
+	}
+	if cfg.Gzip {
+		httpReq.Header.Set("Content-Encoding", "gzip")
+	}
+	return httpReq, nil
+}
+
+func gzipBody(body []byte) (*bytes.Buffer, error) {
+	compressed := &bytes.Buffer{}
+	writer := gzip.NewWriter(compressed)
+	if _, err := writer.Write(body); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return compressed, nil
+}
@@ -0,0 +1,91 @@
+package exchange
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/tls"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prebid/prebid-server/adapters"
+	"github.com/prebid/prebid-server/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHTTPSettings(t *testing.T) {
+	var received *http.Request
+	var receivedBody []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r
+		reader, err := gzip.NewReader(r.Body)
+		if assert.NoError(t, err, "The body should be gzipped") {
+			receivedBody, _ = ioutil.ReadAll(reader)
+		}
+		w.Write([]byte("{}"))
+	}))
+	defer server.Close()
+
+	bidder := &BidderAdapter{
+		Bidder: &mixedMultiBidder{},
+		Client: server.Client(),
+		HTTP: config.BidderHTTP{
+			Headers: map[string]string{"x-api-key": "some-key", "content-type": "text/plain"},
+			Auth:    config.BidderAuth{Type: "basic", Username: "user", Password: "pass"},
+			Gzip:    true,
+		},
+	}
+	req := &adapters.RequestData{
+		Method:  "POST",
+		Uri:     server.URL,
+		Body:    []byte(`{"id":"some-request"}`),
+		Headers: http.Header{"Content-Type": []string{"application/json"}},
+	}
+	callInfo := bidder.doRequest(context.Background(), req)
+	if !assert.NoError(t, callInfo.err) {
+		return
+	}
+
+	assert.Equal(t, `{"id":"some-request"}`, string(receivedBody))
+	assert.Equal(t, "gzip", received.Header.Get("Content-Encoding"))
+	assert.Equal(t, "some-key", received.Header.Get("X-Api-Key"))
+	assert.Equal(t, "text/plain", received.Header.Get("Content-Type"), "The host's headers should replace the adapter's")
+	username, password, ok := received.BasicAuth()
+	assert.True(t, ok)
+	assert.Equal(t, "user", username)
+	assert.Equal(t, "pass", password)
+
+	assert.Equal(t, `{"id":"some-request"}`, string(req.Body), "The request data should not be modified")
+	assert.Equal(t, http.Header{"Content-Type": []string{"application/json"}}, req.Headers, "The request data should not be modified")
+}
+
+func TestHTTPBearerAuth(t *testing.T) {
+	httpReq, err := newHTTPRequest(&adapters.RequestData{Method: "POST", Uri: "http://some-bidder.com"}, &config.BidderHTTP{
+		Auth: config.BidderAuth{Type: "bearer", Token: "some-token"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "Bearer some-token", httpReq.Header.Get("Authorization"))
+}
+
+func TestNewBidderClient(t *testing.T) {
+	tlsConfig := &tls.Config{}
+	shared := &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig, MaxIdleConns: 50, IdleConnTimeout: time.Minute}}
+
+	assert.Equal(t, shared, newBidderClient(shared, "appnexus", config.BidderHTTP{Gzip: true}), "Bidders without pooling or HTTP/2 settings should share the client")
+
+	client := newBidderClient(shared, "appnexus", config.BidderHTTP{MaxIdleConns: 10})
+	if transport, ok := client.Transport.(*http.Transport); assert.True(t, ok) {
+		assert.Equal(t, 10, transport.MaxIdleConns)
+		assert.Equal(t, 10, transport.MaxIdleConnsPerHost)
+		assert.Equal(t, time.Minute, transport.IdleConnTimeout)
+		assert.Equal(t, tlsConfig, transport.TLSClientConfig)
+	}
+
+	client = newBidderClient(shared, "appnexus", config.BidderHTTP{HTTP2: true})
+	if transport, ok := client.Transport.(*http.Transport); assert.True(t, ok) {
+		assert.Equal(t, 50, transport.MaxIdleConns)
+		assert.Contains(t, transport.TLSNextProto, "h2", "The transport should be able to use HTTP/2")
+	}
+}
@@ -0,0 +1,40 @@
+package exchange
+
+import (
+	"github.com/prebid/prebid-server/adapters"
+	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/openrtb_ext"
+)
+
+// OverrideBidderInfo returns a copy of the info with the parts which the overrides from the host's config define replaced.
+// Host aliases use it to change the info which they get from the bidder they're an alias of.
+func OverrideBidderInfo(info adapters.BidderInfo, overrides *config.BidderInfoOverrides) adapters.BidderInfo {
+	if overrides == nil {
+		return info
+	}
+	if overrides.MaintainerEmail != "" {
+		info.Maintainer = &adapters.MaintainerInfo{Email: overrides.MaintainerEmail}
+	}
+	if len(overrides.AppMediaTypes) > 0 || len(overrides.SiteMediaTypes) > 0 {
+		capabilities := adapters.CapabilitiesInfo{}
+		if info.Capabilities != nil {
+			capabilities = *info.Capabilities
+		}
+		if len(overrides.AppMediaTypes) > 0 {
+			capabilities.App = &adapters.PlatformInfo{MediaTypes: parseMediaTypes(overrides.AppMediaTypes)}
+		}
+		if len(overrides.SiteMediaTypes) > 0 {
+			capabilities.Site = &adapters.PlatformInfo{MediaTypes: parseMediaTypes(overrides.SiteMediaTypes)}
+		}
+		info.Capabilities = &capabilities
+	}
+	return info
+}
+
+func parseMediaTypes(mediaTypes []string) []openrtb_ext.BidType {
+	bidTypes := make([]openrtb_ext.BidType, 0, len(mediaTypes))
+	for _, mediaType := range mediaTypes {
+		bidTypes = append(bidTypes, openrtb_ext.BidType(mediaType))
+	}
+	return bidTypes
+}
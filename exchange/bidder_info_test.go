@@ -0,0 +1,33 @@
+package exchange
+
+import (
+	"testing"
+
+	"github.com/prebid/prebid-server/adapters"
+	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/openrtb_ext"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOverrideBidderInfo(t *testing.T) {
+	info := adapters.BidderInfo{
+		Maintainer: &adapters.MaintainerInfo{Email: "some-email@domain.com"},
+		Capabilities: &adapters.CapabilitiesInfo{
+			App:  &adapters.PlatformInfo{MediaTypes: []openrtb_ext.BidType{openrtb_ext.BidTypeBanner}},
+			Site: &adapters.PlatformInfo{MediaTypes: []openrtb_ext.BidType{openrtb_ext.BidTypeBanner}},
+		},
+		AliasOf: "appnexus",
+	}
+	overridden := OverrideBidderInfo(info, &config.BidderInfoOverrides{
+		MaintainerEmail: "alias-email@domain.com",
+		SiteMediaTypes:  []string{"banner", "video"},
+	})
+	assert.Equal(t, "alias-email@domain.com", overridden.Maintainer.Email)
+	assert.Equal(t, []openrtb_ext.BidType{openrtb_ext.BidTypeBanner}, overridden.Capabilities.App.MediaTypes)
+	assert.Equal(t, []openrtb_ext.BidType{openrtb_ext.BidTypeBanner, openrtb_ext.BidTypeVideo}, overridden.Capabilities.Site.MediaTypes)
+	assert.Equal(t, "appnexus", overridden.AliasOf)
+
+	assert.Equal(t, "some-email@domain.com", info.Maintainer.Email, "The original info should not be modified")
+	assert.Equal(t, []openrtb_ext.BidType{openrtb_ext.BidTypeBanner}, info.Capabilities.Site.MediaTypes, "The original info should not be modified")
+	assert.Equal(t, info, OverrideBidderInfo(info, nil))
+}
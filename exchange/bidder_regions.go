@@ -0,0 +1,73 @@
+package exchange
+
+import (
+	"context"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/adapters"
+	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/currencies"
+	"github.com/prebid/prebid-server/openrtb_ext"
+)
+
+// regionMacro matches the {{.Region}} macro in the bidders' endpoints.
+var regionMacro = regexp.MustCompile(`{{\s*\.Region\s*}}`)
+
+// countryRegions returns the regions other than the host's own which region_by_country sends requests to.
+func countryRegions(cfg *config.Configuration) []string {
+	var regions []string
+	seen := map[string]bool{strings.ToLower(cfg.Region): true}
+	for _, region := range cfg.RegionByCountry {
+		if !seen[strings.ToLower(region)] {
+			seen[strings.ToLower(region)] = true
+			regions = append(regions, region)
+		}
+	}
+	return regions
+}
+
+// regionalConfig returns a copy of cfg where each adapter's endpoint is its endpoint in the region,
+// with the {{.Region}} macro replaced by the value which the bidder's info gives the region.
+func regionalConfig(cfg *config.Configuration, infos adapters.BidderInfos, region string) *config.Configuration {
+	// The adapters' keys are lower case, but the infos' keys are the bidders' names.
+	infosByKey := make(map[string]adapters.BidderInfo, len(infos))
+	for name, info := range infos {
+		infosByKey[strings.ToLower(name)] = info
+	}
+
+	regionCfg := *cfg
+	regionCfg.Adapters = make(map[string]config.Adapter, len(cfg.Adapters))
+	for key, adapter := range cfg.Adapters {
+		macroValue := url.QueryEscape(infosByKey[key].RegionMacroValue(region))
+		adapter.Endpoint = regionMacro.ReplaceAllLiteralString(adapter.EndpointFor(region), macroValue)
+		regionCfg.Adapters[key] = adapter
+	}
+	return &regionCfg
+}
+
+// regionalBidder sends each request to the Bidder's instance for the region which the request's
+// device.geo.country maps to in region_by_country. Requests from the other countries go to the
+// instance for the host's region.
+type regionalBidder struct {
+	bidders         map[string]AdaptedBidder
+	defaultRegion   string
+	regionByCountry map[string]string
+}
+
+func (b *regionalBidder) RequestBid(ctx context.Context, request *openrtb.BidRequest, name openrtb_ext.BidderName, bidAdjustment float64, conversions currencies.Conversions) (*PBSOrtbSeatBid, []error) {
+	return b.bidderFor(request).RequestBid(ctx, request, name, bidAdjustment, conversions)
+}
+
+func (b *regionalBidder) bidderFor(request *openrtb.BidRequest) AdaptedBidder {
+	if request.Device != nil && request.Device.Geo != nil {
+		if region, ok := b.regionByCountry[strings.ToLower(request.Device.Geo.Country)]; ok {
+			if bidder, ok := b.bidders[region]; ok {
+				return bidder
+			}
+		}
+	}
+	return b.bidders[b.defaultRegion]
+}
@@ -0,0 +1,181 @@
+package exchange
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/adapters"
+	"github.com/prebid/prebid-server/adapters/appnexus"
+	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/currencies"
+	"github.com/prebid/prebid-server/openrtb_ext"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegionalConfig(t *testing.T) {
+	cfg := &config.Configuration{
+		Region: "us-east",
+		Adapters: map[string]config.Adapter{
+			"appnexus": {
+				Endpoint:          "http://us.adnxs.com/bid",
+				RegionalEndpoints: map[string]string{"eu-west": "http://eu.adnxs.com/bid"},
+			},
+			"adkerneladn": {Endpoint: "http://{{.Region}}.adkernel.com/bid?r={{ .Region }}"},
+		},
+	}
+	infos := adapters.BidderInfos{
+		"adkernelAdn": {RegionMacro: map[string]string{"eu-west": "eu&w"}},
+	}
+
+	usCfg := regionalConfig(cfg, infos, "us-east")
+	assert.Equal(t, "http://us.adnxs.com/bid", usCfg.Adapters["appnexus"].Endpoint)
+	assert.Equal(t, "http://us-east.adkernel.com/bid?r=us-east", usCfg.Adapters["adkerneladn"].Endpoint, "Regions without a macro value should use their own names")
+
+	euCfg := regionalConfig(cfg, infos, "eu-west")
+	assert.Equal(t, "http://eu.adnxs.com/bid", euCfg.Adapters["appnexus"].Endpoint)
+	assert.Equal(t, "http://eu%26w.adkernel.com/bid?r=eu%26w", euCfg.Adapters["adkerneladn"].Endpoint)
+
+	assert.Equal(t, "http://us.adnxs.com/bid", cfg.Adapters["appnexus"].Endpoint, "The host's config shouldn't change")
+}
+
+func TestCountryRegions(t *testing.T) {
+	cfg := &config.Configuration{
+		Region:          "us-east",
+		RegionByCountry: map[string]string{"deu": "eu-west", "fra": "eu-west", "usa": "us-east"},
+	}
+	assert.Equal(t, []string{"eu-west"}, countryRegions(cfg))
+	assert.Empty(t, countryRegions(&config.Configuration{Region: "us-east"}))
+}
+
+func TestRegionalBidder(t *testing.T) {
+	usBidder := &regionCapturingBidder{}
+	euBidder := &regionCapturingBidder{}
+	bidder := &regionalBidder{
+		bidders:         map[string]AdaptedBidder{"us-east": usBidder, "eu-west": euBidder},
+		defaultRegion:   "us-east",
+		regionByCountry: map[string]string{"deu": "eu-west", "jpn": "apac"},
+	}
+
+	bidder.RequestBid(context.Background(), &openrtb.BidRequest{Device: &openrtb.Device{Geo: &openrtb.Geo{Country: "DEU"}}}, openrtb_ext.BidderAppnexus, 1.0, nil)
+	assert.Equal(t, 1, euBidder.calls)
+
+	bidder.RequestBid(context.Background(), &openrtb.BidRequest{Device: &openrtb.Device{Geo: &openrtb.Geo{Country: "USA"}}}, openrtb_ext.BidderAppnexus, 1.0, nil)
+	bidder.RequestBid(context.Background(), &openrtb.BidRequest{Device: &openrtb.Device{Geo: &openrtb.Geo{Country: "JPN"}}}, openrtb_ext.BidderAppnexus, 1.0, nil)
+	bidder.RequestBid(context.Background(), &openrtb.BidRequest{}, openrtb_ext.BidderAppnexus, 1.0, nil)
+	assert.Equal(t, 3, usBidder.calls, "Requests from other countries, countries without an instance, or without a country should use the host's region")
+	assert.Equal(t, 1, euBidder.calls)
+}
+
+func TestNewAdapterMapRegions(t *testing.T) {
+	cfg := &config.Configuration{
+		Region:          "us-east",
+		RegionByCountry: map[string]string{"deu": "eu-west"},
+		Adapters: map[string]config.Adapter{
+			"appnexus": {
+				Endpoint:          "http://us.adnxs.com/bid",
+				RegionalEndpoints: map[string]string{"eu-west": "http://eu.adnxs.com/bid"},
+			},
+			"rubicon": {Endpoint: "http://rubiconproject.com/bid"},
+		},
+	}
+	infos := make(adapters.BidderInfos, len(openrtb_ext.BidderMap))
+	for name := range openrtb_ext.BidderMap {
+		infos[name] = adapters.BidderInfo{
+			Capabilities: &adapters.CapabilitiesInfo{Site: &adapters.PlatformInfo{}},
+		}
+	}
+	adapterMap := newAdapterMap(http.DefaultClient, cfg, infos, nil, nil)
+
+	appnexus, ok := unwrapBidder(adapterMap[openrtb_ext.BidderAppnexus]).(*regionalBidder)
+	if assert.True(t, ok, "Bidders with regional endpoints should pick their instance per request") {
+		assert.Equal(t, "http://us.adnxs.com/bid", appnexusURI(appnexus.bidders["us-east"]))
+		assert.Equal(t, "us-east", unwrapBidderAdapter(appnexus.bidders["us-east"]).Region)
+		assert.Equal(t, "http://eu.adnxs.com/bid", appnexusURI(appnexus.bidders["eu-west"]))
+		assert.Equal(t, "eu-west", unwrapBidderAdapter(appnexus.bidders["eu-west"]).Region)
+		assert.Len(t, appnexus.bidders, 2)
+	}
+	_, ok = unwrapBidder(adapterMap[openrtb_ext.BidderRubicon]).(*regionalBidder)
+	assert.False(t, ok, "Bidders with the same endpoint everywhere should only get one instance")
+}
+
+func TestNewRegionAdapterMapOnlyConfigured(t *testing.T) {
+	cfg := &config.Configuration{
+		Adapters: map[string]config.Adapter{
+			"appnexus":  {Endpoint: "http://eu.adnxs.com/bid"},
+			"somealias": {Endpoint: "http://eu.some-alias.com/bid", AliasOf: "appnexus"},
+		},
+	}
+	infos := adapters.BidderInfos{
+		"appnexus":  {Capabilities: &adapters.CapabilitiesInfo{Site: &adapters.PlatformInfo{}}},
+		"somealias": {Capabilities: &adapters.CapabilitiesInfo{Site: &adapters.PlatformInfo{}}},
+	}
+	regionBidders := newRegionAdapterMap(http.DefaultClient, cfg, infos, "eu-west", nil)
+	assert.Len(t, regionBidders, 2, "Only the bidders in the config should be built")
+	assert.Equal(t, "http://eu.some-alias.com/bid", appnexusURI(regionBidders["somealias"]))
+
+	ortbBidders, legacyBidders := newBidders(http.DefaultClient, cfg)
+	assert.Len(t, ortbBidders, 1, "Bidders which aren't configured shouldn't be made")
+	assert.Empty(t, legacyBidders)
+}
+
+func TestHTTPCallsHaveRegion(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("{}"))
+	}))
+	defer server.Close()
+
+	bidder := &BidderAdapter{
+		Bidder: &goodSingleBidder{
+			httpRequest: &adapters.RequestData{Method: "POST", Uri: server.URL + "/bid?r=eu", Body: []byte("{}")},
+			bidResponse: &adapters.BidderResponse{},
+		},
+		Client: server.Client(),
+		Region: "eu-west",
+	}
+	seatBid, _ := bidder.RequestBid(context.Background(), &openrtb.BidRequest{Test: 1}, openrtb_ext.BidderAppnexus, 1.0, currencies.NewRateConverterDefault().Rates())
+	if assert.NotNil(t, seatBid) && assert.Len(t, seatBid.HTTPCalls, 1) {
+		assert.Equal(t, server.URL+"/bid?r=eu", seatBid.HTTPCalls[0].Uri, "The call should show the URI which it was sent to")
+		assert.Equal(t, "eu-west", seatBid.HTTPCalls[0].Region)
+	}
+}
+
+// unwrapBidder removes the middleware which newAdapterMap puts around every Bidder.
+func unwrapBidder(bidder AdaptedBidder) AdaptedBidder {
+	if validated, ok := bidder.(*validatedBidder); ok {
+		bidder = validated.bidder
+	}
+	return bidder
+}
+
+func unwrapBidderAdapter(bidder AdaptedBidder) *BidderAdapter {
+	if splitter, ok := bidder.(*multiFormatSplittingBidder); ok {
+		bidder = splitter.bidder
+	}
+	adapter, _ := bidder.(*BidderAdapter)
+	if adapter == nil {
+		return &BidderAdapter{}
+	}
+	return adapter
+}
+
+// appnexusURI returns the endpoint which newAdapterMap made an appnexus Bidder with.
+func appnexusURI(bidder AdaptedBidder) string {
+	if infoAware, ok := unwrapBidderAdapter(bidder).Bidder.(*adapters.InfoAwareBidder); ok {
+		if adapter, ok := infoAware.Bidder.(*appnexus.AppNexusAdapter); ok {
+			return adapter.URI
+		}
+	}
+	return ""
+}
+
+type regionCapturingBidder struct {
+	calls int
+}
+
+func (b *regionCapturingBidder) RequestBid(ctx context.Context, request *openrtb.BidRequest, name openrtb_ext.BidderName, bidAdjustment float64, conversions currencies.Conversions) (*PBSOrtbSeatBid, []error) {
+	b.calls++
+	return nil, nil
+}
@@ -0,0 +1,174 @@
+package exchange
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/openrtb_ext"
+	"github.com/prebid/prebid-server/pbsmetrics"
+	"github.com/prebid/prebid-server/privacy"
+)
+
+// bidderRules decides which Bidders get called in an auction, using the host's static bidder_rules config.
+// It's made for a single auction, and remembers its decisions so that they can go in the debug output.
+type bidderRules struct {
+	rules []bidderRule
+	// random is swapped out by the tests.
+	random    func() float64
+	decisions []openrtb_ext.ExtBidderRuleDecision
+}
+
+type bidderRule struct {
+	name string
+	cfg  config.BidderRule
+}
+
+// bidderRulesRequest holds the signals from a request which bidder rules can match on.
+type bidderRulesRequest struct {
+	channel    string
+	deviceType int
+	country    string
+	region     string
+}
+
+// newBidderRules returns the rules for the given account (publisher ID), followed by the host's rules.
+// It returns nil if there aren't any. The bidderRules methods treat nil as "call every Bidder".
+func newBidderRules(cfg *config.BidderRules, account string) *bidderRules {
+	var rules []bidderRule
+	if account != "" {
+		// Viper lower-cases all map keys, so the account lookup has to be case-insensitive.
+		account = strings.ToLower(account)
+		for i, rule := range cfg.Accounts[account] {
+			rules = append(rules, newBidderRule(rule, fmt.Sprintf("accounts.%s[%d]", account, i)))
+		}
+	}
+	for i, rule := range cfg.Rules {
+		rules = append(rules, newBidderRule(rule, fmt.Sprintf("rules[%d]", i)))
+	}
+	if len(rules) == 0 {
+		return nil
+	}
+	return &bidderRules{
+		rules:  rules,
+		random: rand.Float64,
+	}
+}
+
+func newBidderRule(cfg config.BidderRule, defaultName string) bidderRule {
+	name := cfg.Name
+	if name == "" {
+		name = defaultName
+	}
+	return bidderRule{
+		name: name,
+		cfg:  cfg,
+	}
+}
+
+func newBidderRulesRequest(req *openrtb.BidRequest, requestType pbsmetrics.RequestType) bidderRulesRequest {
+	activityRequest := privacy.NewActivityRequest(req)
+	rulesRequest := bidderRulesRequest{
+		country: activityRequest.Country,
+		region:  activityRequest.Region,
+	}
+	switch requestType {
+	case pbsmetrics.ReqTypeORTB2Web:
+		rulesRequest.channel = "web"
+	case pbsmetrics.ReqTypeORTB2App:
+		rulesRequest.channel = "app"
+	case pbsmetrics.ReqTypeAMP:
+		rulesRequest.channel = "amp"
+	case pbsmetrics.ReqTypeVideo:
+		rulesRequest.channel = "video"
+	}
+	if req.Device != nil {
+		rulesRequest.deviceType = int(req.Device.DeviceType)
+	}
+	return rulesRequest
+}
+
+// call says whether the Bidder should be called on its imps. The first rule which matches decides.
+// The bidder is the name from the request, which may be an alias of the coreBidder. Rules can name either one.
+func (r *bidderRules) call(bidder string, coreBidder openrtb_ext.BidderName, imps []openrtb.Imp, req bidderRulesRequest) bool {
+	if r == nil {
+		return true
+	}
+	for _, rule := range r.rules {
+		if !rule.matches(bidder, coreBidder, imps, req) {
+			continue
+		}
+		called := rule.cfg.CallPercent >= 100 || r.random()*100 < rule.cfg.CallPercent
+		r.decisions = append(r.decisions, openrtb_ext.ExtBidderRuleDecision{
+			Bidder: bidder,
+			Rule:   rule.name,
+			Called: called,
+		})
+		return called
+	}
+	return true
+}
+
+// debugDecisions returns the decisions which the rules made, sorted by Bidder.
+func (r *bidderRules) debugDecisions() []openrtb_ext.ExtBidderRuleDecision {
+	if r == nil {
+		return nil
+	}
+	sort.Slice(r.decisions, func(i, j int) bool {
+		return r.decisions[i].Bidder < r.decisions[j].Bidder
+	})
+	return r.decisions
+}
+
+func (rule *bidderRule) matches(bidder string, coreBidder openrtb_ext.BidderName, imps []openrtb.Imp, req bidderRulesRequest) bool {
+	if len(rule.cfg.Bidders) > 0 && !containsFold(rule.cfg.Bidders, bidder) && !containsFold(rule.cfg.Bidders, string(coreBidder)) {
+		return false
+	}
+	condition := &rule.cfg.Condition
+	if len(condition.Channel) > 0 && !containsFold(condition.Channel, req.channel) {
+		return false
+	}
+	if len(condition.DeviceType) > 0 && !containsInt(condition.DeviceType, req.deviceType) {
+		return false
+	}
+	if len(condition.Geo) > 0 && !privacy.GeoMatches(condition.Geo, req.country, req.region) {
+		return false
+	}
+	if len(condition.MediaType) > 0 && !impsHaveMediaType(imps, condition.MediaType) {
+		return false
+	}
+	return true
+}
+
+func impsHaveMediaType(imps []openrtb.Imp, mediaTypes []string) bool {
+	for _, imp := range imps {
+		if (imp.Banner != nil && containsFold(mediaTypes, string(openrtb_ext.BidTypeBanner))) ||
+			(imp.Video != nil && containsFold(mediaTypes, string(openrtb_ext.BidTypeVideo))) ||
+			(imp.Audio != nil && containsFold(mediaTypes, string(openrtb_ext.BidTypeAudio))) ||
+			(imp.Native != nil && containsFold(mediaTypes, string(openrtb_ext.BidTypeNative))) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsInt(values []int, value int) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
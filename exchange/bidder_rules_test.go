@@ -0,0 +1,125 @@
+package exchange
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/openrtb_ext"
+	"github.com/prebid/prebid-server/pbsmetrics"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBidderRulesFirstMatchDecides(t *testing.T) {
+	rules := newBidderRules(&config.BidderRules{
+		Rules: []config.BidderRule{
+			{
+				Name:      "no-app-in-canada",
+				Bidders:   []string{"appnexus"},
+				Condition: config.BidderRuleCondition{Channel: []string{"app"}, Geo: []string{"CAN"}},
+			},
+			{
+				Bidders:     []string{"appnexus", "rubicon"},
+				CallPercent: 100,
+			},
+		},
+	}, "some-account")
+	imps := []openrtb.Imp{{ID: "imp-1", Banner: &openrtb.Banner{}}}
+	canadianApp := bidderRulesRequest{channel: "app", country: "CAN"}
+
+	assert.False(t, rules.call("appnexus", openrtb_ext.BidderAppnexus, imps, canadianApp))
+	assert.True(t, rules.call("appnexus", openrtb_ext.BidderAppnexus, imps, bidderRulesRequest{channel: "web", country: "CAN"}))
+	assert.True(t, rules.call("rubicon", openrtb_ext.BidderRubicon, imps, canadianApp))
+	assert.True(t, rules.call("pubmatic", openrtb_ext.BidderPubmatic, imps, canadianApp), "Bidders which no rules match should be called")
+
+	assert.Equal(t, []openrtb_ext.ExtBidderRuleDecision{
+		{Bidder: "appnexus", Rule: "no-app-in-canada", Called: false},
+		{Bidder: "appnexus", Rule: "rules[1]", Called: true},
+		{Bidder: "rubicon", Rule: "rules[1]", Called: true},
+	}, rules.debugDecisions())
+}
+
+func TestBidderRulesCallPercent(t *testing.T) {
+	rules := newBidderRules(&config.BidderRules{
+		Rules: []config.BidderRule{{CallPercent: 20}},
+	}, "")
+	imps := []openrtb.Imp{{ID: "imp-1", Banner: &openrtb.Banner{}}}
+
+	rules.random = func() float64 { return 0.19 }
+	assert.True(t, rules.call("appnexus", openrtb_ext.BidderAppnexus, imps, bidderRulesRequest{}))
+	rules.random = func() float64 { return 0.2 }
+	assert.False(t, rules.call("appnexus", openrtb_ext.BidderAppnexus, imps, bidderRulesRequest{}))
+}
+
+func TestBidderRulesAccounts(t *testing.T) {
+	cfg := &config.BidderRules{
+		Rules: []config.BidderRule{{Bidders: []string{"appnexus"}}},
+		Accounts: map[string][]config.BidderRule{
+			"some-account": {{Bidders: []string{"appnexus"}, CallPercent: 100}},
+		},
+	}
+	imps := []openrtb.Imp{{ID: "imp-1", Banner: &openrtb.Banner{}}}
+
+	rules := newBidderRules(cfg, "Some-Account")
+	assert.True(t, rules.call("appnexus", openrtb_ext.BidderAppnexus, imps, bidderRulesRequest{}), "Account rules should come before the host's")
+	assert.Equal(t, "accounts.some-account[0]", rules.debugDecisions()[0].Rule)
+
+	rules = newBidderRules(cfg, "other-account")
+	assert.False(t, rules.call("appnexus", openrtb_ext.BidderAppnexus, imps, bidderRulesRequest{}))
+
+	var noRules *bidderRules
+	assert.Nil(t, newBidderRules(&config.BidderRules{}, "some-account"))
+	assert.True(t, noRules.call("appnexus", openrtb_ext.BidderAppnexus, imps, bidderRulesRequest{}))
+	assert.Empty(t, noRules.debugDecisions())
+}
+
+func TestBidderRuleMatches(t *testing.T) {
+	rule := newBidderRule(config.BidderRule{
+		Bidders: []string{"appnexus"},
+		Condition: config.BidderRuleCondition{
+			DeviceType: []int{4, 5},
+			Geo:        []string{"USA.CA"},
+			MediaType:  []string{"video"},
+		},
+	}, "rules[0]")
+	videoImps := []openrtb.Imp{{ID: "imp-1", Banner: &openrtb.Banner{}}, {ID: "imp-2", Video: &openrtb.Video{}}}
+	tablet := bidderRulesRequest{deviceType: 5, country: "USA", region: "CA"}
+
+	assert.True(t, rule.matches("appnexus", openrtb_ext.BidderAppnexus, videoImps, tablet))
+	assert.True(t, rule.matches("brightroll", openrtb_ext.BidderAppnexus, videoImps, tablet), "Rules should match the aliases of their bidders")
+	assert.False(t, rule.matches("rubicon", openrtb_ext.BidderRubicon, videoImps, tablet))
+	assert.False(t, rule.matches("appnexus", openrtb_ext.BidderAppnexus, videoImps[:1], tablet), "Rules should only match the bidder's media types")
+	assert.False(t, rule.matches("appnexus", openrtb_ext.BidderAppnexus, videoImps, bidderRulesRequest{deviceType: 2, country: "USA", region: "CA"}))
+	assert.False(t, rule.matches("appnexus", openrtb_ext.BidderAppnexus, videoImps, bidderRulesRequest{deviceType: 5, country: "USA", region: "NY"}))
+}
+
+func TestNewBidderRulesRequest(t *testing.T) {
+	req := &openrtb.BidRequest{
+		App: &openrtb.App{Bundle: "com.example.app"},
+		Device: &openrtb.Device{
+			DeviceType: openrtb.DeviceTypePhone,
+			Geo:        &openrtb.Geo{Country: "USA", Region: "CA"},
+		},
+	}
+	assert.Equal(t, bidderRulesRequest{
+		channel:    "app",
+		deviceType: 4,
+		country:    "USA",
+		region:     "CA",
+	}, newBidderRulesRequest(req, pbsmetrics.ReqTypeORTB2App))
+	assert.Equal(t, "amp", newBidderRulesRequest(&openrtb.BidRequest{}, pbsmetrics.ReqTypeAMP).channel)
+}
+
+func TestBidderRulesDebugOutput(t *testing.T) {
+	e := &exchange{}
+	decisions := []openrtb_ext.ExtBidderRuleDecision{{Bidder: "appnexus", Rule: "rules[0]", Called: false}}
+
+	ext := e.makeExtBidResponse(nil, nil, &openrtb.BidRequest{Test: 1}, json.RawMessage(`{}`), decisions, nil, nil)
+	if assert.NotNil(t, ext.Debug) {
+		assert.Equal(t, decisions, ext.Debug.BidderRules)
+	}
+
+	ext = e.makeExtBidResponse(nil, nil, &openrtb.BidRequest{}, nil, decisions, nil, nil)
+	assert.Nil(t, ext.Debug, "The decisions should only be logged for test requests")
+}
@@ -0,0 +1,225 @@
+package exchange
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"runtime/debug"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/adapters"
+	"github.com/prebid/prebid-server/analytics"
+	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/openrtb_ext"
+	"github.com/prebid/prebid-server/pbsmetrics"
+)
+
+// shadowProductionWait is how long a shadow call waits for production's result after it has its own.
+// Production always hands its result over, so this only matters if the Bidder panicked.
+const shadowProductionWait = 10 * time.Second
+
+// shadowMirror sends a sample of a Bidder's requests to its shadow endpoint, and reports how the shadow
+// endpoint's responses compare with production's. It's nil for Bidders without a shadow endpoint.
+// The shadowMirror methods treat nil as "don't mirror anything".
+type shadowMirror struct {
+	name     openrtb_ext.BidderName
+	bidder   adapters.Bidder
+	endpoint *url.URL
+	cfg      config.BidderShadow
+	// adapter sends the shadow requests with the Bidder's client and HTTP settings.
+	adapter  *BidderAdapter
+	reporter *shadowReporter
+	// random is swapped out by the tests.
+	random func() float64
+}
+
+func newShadowMirror(name openrtb_ext.BidderName, bidder adapters.Bidder, client *http.Client, httpCfg config.BidderHTTP, cfg *config.BidderShadow, reporter *shadowReporter) *shadowMirror {
+	if cfg == nil || cfg.SamplingRate <= 0 {
+		return nil
+	}
+	endpoint, err := url.Parse(cfg.Endpoint)
+	if err != nil {
+		glog.Errorf("Not mirroring requests to %s's shadow endpoint %s: %v", name, cfg.Endpoint, err)
+		return nil
+	}
+	return &shadowMirror{
+		name:     name,
+		bidder:   bidder,
+		endpoint: endpoint,
+		cfg:      *cfg,
+		adapter: &BidderAdapter{
+			Client: client,
+			HTTP:   httpCfg,
+		},
+		reporter: reporter,
+		random:   rand.Float64,
+	}
+}
+
+// shadowCall is a request which is being mirrored. Production's result must be handed to it with finish,
+// so that the two can be compared.
+type shadowCall struct {
+	production chan analytics.ShadowCall
+}
+
+// mirror starts sending copies of the sampled requests to the shadow endpoint. It returns the shadow calls,
+// keyed by the production requests which they copy. The chunks are the ones which the requests were made for,
+// like the ones from BidderAdapter.makeRequests.
+func (m *shadowMirror) mirror(request *openrtb.BidRequest, reqData []*adapters.RequestData, chunkRequests map[*adapters.RequestData]*openrtb.BidRequest) map[*adapters.RequestData]*shadowCall {
+	if m == nil {
+		return nil
+	}
+	var calls map[*adapters.RequestData]*shadowCall
+	for _, req := range reqData {
+		if m.random() >= m.cfg.SamplingRate {
+			continue
+		}
+		if calls == nil {
+			calls = make(map[*adapters.RequestData]*shadowCall)
+		}
+		internalRequest := request
+		if chunkRequest, ok := chunkRequests[req]; ok {
+			internalRequest = chunkRequest
+		}
+		// The auction may change the request's top-level fields once production responds, so the shadow call
+		// gets its own copy of them.
+		requestCopy := *internalRequest
+		call := &shadowCall{
+			production: make(chan analytics.ShadowCall, 1),
+		}
+		calls[req] = call
+		go m.send(call, &requestCopy, req)
+	}
+	return calls
+}
+
+// finish hands production's result to the shadow call. The bid response must be the one which the Bidder made,
+// before the auction converts and adjusts its prices.
+func (c *shadowCall) finish(httpInfo *httpCallInfo, bidResponse *adapters.BidderResponse) {
+	if c == nil {
+		return
+	}
+	c.production <- newShadowCallResult(httpInfo, bidResponse)
+}
+
+// send runs in its own goroutine, outside of the auction's recoverSafely, so it recovers from the Bidder's panics itself.
+func (m *shadowMirror) send(call *shadowCall, request *openrtb.BidRequest, req *adapters.RequestData) {
+	defer func() {
+		if r := recover(); r != nil {
+			glog.Errorf("Shadow call recovered panic from Bidder %s: %v. Stack trace is: %v", m.name, r, string(debug.Stack()))
+			m.reporter.recordPanic(m.name)
+		}
+	}()
+
+	shadowReq := &adapters.RequestData{
+		Method:  req.Method,
+		Uri:     m.shadowURI(req.Uri),
+		Body:    req.Body,
+		Headers: req.Headers,
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(m.cfg.TimeoutMS)*time.Millisecond)
+	defer cancel()
+
+	httpInfo := m.adapter.doRequest(ctx, shadowReq)
+	var bidResponse *adapters.BidderResponse
+	if httpInfo.err == nil {
+		bidResponse, _ = m.bidder.MakeBids(request, shadowReq, httpInfo.response)
+	}
+	shadow := newShadowCallResult(httpInfo, bidResponse)
+
+	select {
+	case production := <-call.production:
+		m.reporter.report(m.name, production, shadow)
+	case <-time.After(shadowProductionWait):
+	}
+}
+
+// shadowURI replaces the scheme, host and path of a production request's URI with the shadow endpoint's.
+// The production request's query string is kept, after any which the shadow endpoint has.
+func (m *shadowMirror) shadowURI(uri string) string {
+	production, err := url.Parse(uri)
+	if err != nil {
+		return m.endpoint.String()
+	}
+	shadow := *m.endpoint
+	switch {
+	case shadow.RawQuery == "":
+		shadow.RawQuery = production.RawQuery
+	case production.RawQuery != "":
+		shadow.RawQuery += "&" + production.RawQuery
+	}
+	return shadow.String()
+}
+
+func newShadowCallResult(httpInfo *httpCallInfo, bidResponse *adapters.BidderResponse) analytics.ShadowCall {
+	result := analytics.ShadowCall{
+		LatencyMS: int64(httpInfo.elapsed / time.Millisecond),
+	}
+	if httpInfo.request != nil {
+		result.Uri = httpInfo.request.Uri
+	}
+	if httpInfo.response != nil {
+		result.Status = httpInfo.response.StatusCode
+	}
+	if httpInfo.err != nil {
+		result.Error = httpInfo.err.Error()
+	}
+	if bidResponse != nil {
+		for _, bid := range bidResponse.Bids {
+			if bid.Bid != nil {
+				result.Bids++
+				result.Price += bid.Bid.Price
+			}
+		}
+	}
+	return result
+}
+
+// shadowReporter records how the shadow endpoints' responses compare with production's in the metrics,
+// and logs them with any analytics modules which want them.
+type shadowReporter struct {
+	me        pbsmetrics.MetricsEngine
+	analytics analytics.ShadowModule
+}
+
+func newShadowReporter(me pbsmetrics.MetricsEngine, pbsAnalytics analytics.PBSAnalyticsModule) *shadowReporter {
+	shadowModule, _ := pbsAnalytics.(analytics.ShadowModule)
+	return &shadowReporter{
+		me:        me,
+		analytics: shadowModule,
+	}
+}
+
+func (r *shadowReporter) report(bidder openrtb_ext.BidderName, production analytics.ShadowCall, shadow analytics.ShadowCall) {
+	if r == nil {
+		return
+	}
+	if r.me != nil {
+		r.me.RecordAdapterShadow(pbsmetrics.AdapterShadowComparison{
+			Adapter:    bidder,
+			Status:     pbsmetrics.ShadowStatusOf(shadow.Status),
+			Fill:       pbsmetrics.ShadowFillOf(shadow.Bids - production.Bids),
+			Time:       time.Duration(shadow.LatencyMS) * time.Millisecond,
+			TimeDelta:  time.Duration(shadow.LatencyMS-production.LatencyMS) * time.Millisecond,
+			PriceDelta: shadow.Price - production.Price,
+		})
+	}
+	if r.analytics != nil {
+		r.analytics.LogShadowObject(&analytics.ShadowObject{
+			Bidder:     string(bidder),
+			Production: production,
+			Shadow:     shadow,
+		})
+	}
+}
+
+// recordPanic records a panic from the Bidder during a shadow call in the metrics.
+func (r *shadowReporter) recordPanic(bidder openrtb_ext.BidderName) {
+	if r == nil || r.me == nil {
+		return
+	}
+	r.me.RecordAdapterPanic(pbsmetrics.AdapterLabels{Adapter: bidder})
+}
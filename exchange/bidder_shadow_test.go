@@ -0,0 +1,212 @@
+package exchange
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/adapters"
+	"github.com/prebid/prebid-server/analytics"
+	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/currencies"
+	"github.com/prebid/prebid-server/openrtb_ext"
+	"github.com/prebid/prebid-server/pbsmetrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestShadowMirror(t *testing.T) {
+	shadowHeaders := make(chan http.Header, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/bid":
+			w.Write([]byte("1"))
+		case "/shadow":
+			shadowHeaders <- r.Header
+			w.Write([]byte("2"))
+		}
+	}))
+	defer server.Close()
+
+	bidder := &bidCountingBidder{uri: server.URL + "/bid?id=1"}
+	httpCfg := config.BidderHTTP{Headers: map[string]string{"X-Host": "pbs"}}
+	logger := &shadowLogger{objects: make(chan *analytics.ShadowObject, 1)}
+	bidderAdapter := &BidderAdapter{
+		Bidder: bidder,
+		Client: server.Client(),
+		HTTP:   httpCfg,
+		Shadow: newShadowMirror(openrtb_ext.BidderAppnexus, bidder, server.Client(), httpCfg, &config.BidderShadow{
+			Endpoint:     server.URL + "/shadow",
+			SamplingRate: 1,
+			TimeoutMS:    1000,
+		}, &shadowReporter{analytics: logger}),
+	}
+
+	seatBid, errs := bidderAdapter.RequestBid(context.Background(), &openrtb.BidRequest{}, openrtb_ext.BidderAppnexus, 2.0, currencies.NewRateConverterDefault().Rates())
+	assert.Empty(t, errs)
+	if assert.NotNil(t, seatBid) && assert.Len(t, seatBid.Bids, 1, "The shadow endpoint's bids shouldn't make it into the auction") {
+		assert.Equal(t, 2.0, seatBid.Bids[0].Bid.Price)
+	}
+
+	select {
+	case object := <-logger.objects:
+		assert.Equal(t, "appnexus", object.Bidder)
+		assert.Equal(t, server.URL+"/bid?id=1", object.Production.Uri)
+		assert.Equal(t, http.StatusOK, object.Production.Status)
+		assert.Equal(t, 1, object.Production.Bids)
+		assert.Equal(t, 1.0, object.Production.Price, "Production's prices should be the Bidder's, before any bid adjustments")
+		assert.Equal(t, server.URL+"/shadow?id=1", object.Shadow.Uri)
+		assert.Equal(t, http.StatusOK, object.Shadow.Status)
+		assert.Equal(t, 2, object.Shadow.Bids)
+		assert.Equal(t, 2.0, object.Shadow.Price)
+	case <-time.After(time.Second):
+		t.Fatal("The shadow call should have been reported")
+	}
+	assert.Equal(t, "pbs", (<-shadowHeaders).Get("X-Host"), "Shadow requests should get the Bidder's HTTP settings")
+}
+
+func TestShadowMirrorPanic(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/bid":
+			w.Write([]byte("1"))
+		case "/shadow":
+			w.Write([]byte("panic"))
+		}
+	}))
+	defer server.Close()
+
+	panics := make(chan struct{}, 1)
+	metricsMock := &pbsmetrics.MetricsEngineMock{}
+	metricsMock.On("RecordAdapterPanic", pbsmetrics.AdapterLabels{Adapter: openrtb_ext.BidderAppnexus}).Run(func(mock.Arguments) {
+		panics <- struct{}{}
+	}).Return()
+
+	bidder := &panickingBidder{bidCountingBidder{uri: server.URL + "/bid"}}
+	bidderAdapter := &BidderAdapter{
+		Bidder: bidder,
+		Client: server.Client(),
+		Shadow: newShadowMirror(openrtb_ext.BidderAppnexus, bidder, server.Client(), config.BidderHTTP{}, &config.BidderShadow{
+			Endpoint:     server.URL + "/shadow",
+			SamplingRate: 1,
+			TimeoutMS:    1000,
+		}, newShadowReporter(metricsMock, nil)),
+	}
+
+	seatBid, errs := bidderAdapter.RequestBid(context.Background(), &openrtb.BidRequest{}, openrtb_ext.BidderAppnexus, 1.0, currencies.NewRateConverterDefault().Rates())
+	assert.Empty(t, errs)
+	if assert.NotNil(t, seatBid) {
+		assert.Len(t, seatBid.Bids, 1, "A panic in the shadow call shouldn't affect production")
+	}
+
+	select {
+	case <-panics:
+	case <-time.After(time.Second):
+		t.Fatal("The shadow call's panic should have been recorded")
+	}
+	metricsMock.AssertExpectations(t)
+}
+
+func TestShadowMirrorSampling(t *testing.T) {
+	mirror := newShadowMirror(openrtb_ext.BidderAppnexus, &bidCountingBidder{}, http.DefaultClient, config.BidderHTTP{}, &config.BidderShadow{
+		Endpoint:     "http://shadow.com/bid",
+		SamplingRate: 0.5,
+		TimeoutMS:    100,
+	}, nil)
+	mirror.random = func() float64 { return 0.5 }
+	assert.Nil(t, mirror.mirror(&openrtb.BidRequest{}, []*adapters.RequestData{{Uri: "http://prod.com/bid"}}, nil), "Requests outside the sample shouldn't be mirrored")
+
+	var noMirror *shadowMirror
+	assert.Nil(t, noMirror.mirror(&openrtb.BidRequest{}, []*adapters.RequestData{{Uri: "http://prod.com/bid"}}, nil))
+	var noCall *shadowCall
+	noCall.finish(&httpCallInfo{}, nil)
+}
+
+func TestNewShadowMirror(t *testing.T) {
+	assert.Nil(t, newShadowMirror(openrtb_ext.BidderAppnexus, &bidCountingBidder{}, http.DefaultClient, config.BidderHTTP{}, nil, nil))
+	assert.Nil(t, newShadowMirror(openrtb_ext.BidderAppnexus, &bidCountingBidder{}, http.DefaultClient, config.BidderHTTP{}, &config.BidderShadow{Endpoint: "http://shadow.com/bid", TimeoutMS: 100}, nil), "Bidders with a sampling rate of 0 shouldn't be mirrored")
+}
+
+func TestShadowURI(t *testing.T) {
+	mirror := newShadowMirror(openrtb_ext.BidderAppnexus, &bidCountingBidder{}, http.DefaultClient, config.BidderHTTP{}, &config.BidderShadow{
+		Endpoint:     "https://beta.bidder.com/v2/bid",
+		SamplingRate: 1,
+		TimeoutMS:    100,
+	}, nil)
+	assert.Equal(t, "https://beta.bidder.com/v2/bid?pub=1&gdpr=0", mirror.shadowURI("http://bidder.com/v1/bid?pub=1&gdpr=0"))
+	assert.Equal(t, "https://beta.bidder.com/v2/bid", mirror.shadowURI("http://bidder.com/v1/bid"))
+
+	mirror.endpoint.RawQuery = "shadow=1"
+	assert.Equal(t, "https://beta.bidder.com/v2/bid?shadow=1&pub=1", mirror.shadowURI("http://bidder.com/v1/bid?pub=1"))
+	assert.Equal(t, "https://beta.bidder.com/v2/bid?shadow=1", mirror.shadowURI("http://bidder.com/v1/bid"))
+}
+
+func TestShadowReporterMetrics(t *testing.T) {
+	metricsMock := &pbsmetrics.MetricsEngineMock{}
+	metricsMock.On("RecordAdapterShadow", pbsmetrics.AdapterShadowComparison{
+		Adapter:    openrtb_ext.BidderAppnexus,
+		Status:     pbsmetrics.ShadowStatus5xx,
+		Fill:       pbsmetrics.ShadowFillFewer,
+		Time:       30 * time.Millisecond,
+		TimeDelta:  -50 * time.Millisecond,
+		PriceDelta: -1.5,
+	}).Return()
+
+	reporter := newShadowReporter(metricsMock, nil)
+	reporter.report(openrtb_ext.BidderAppnexus,
+		analytics.ShadowCall{Status: http.StatusOK, LatencyMS: 80, Bids: 1, Price: 1.5},
+		analytics.ShadowCall{Status: http.StatusServiceUnavailable, LatencyMS: 30},
+	)
+	metricsMock.AssertExpectations(t)
+
+	var noReporter *shadowReporter
+	noReporter.report(openrtb_ext.BidderAppnexus, analytics.ShadowCall{}, analytics.ShadowCall{})
+}
+
+// bidCountingBidder makes one request to its uri, and as many bids at a CPM of 1 as the number in the response body.
+type bidCountingBidder struct {
+	uri string
+}
+
+func (bidder *bidCountingBidder) MakeRequests(request *openrtb.BidRequest) ([]*adapters.RequestData, []error) {
+	return []*adapters.RequestData{{Method: "POST", Uri: bidder.uri, Body: []byte("{}")}}, nil
+}
+
+func (bidder *bidCountingBidder) MakeBids(internalRequest *openrtb.BidRequest, externalRequest *adapters.RequestData, response *adapters.ResponseData) (*adapters.BidderResponse, []error) {
+	count, err := strconv.Atoi(string(response.Body))
+	if err != nil {
+		return nil, []error{err}
+	}
+	bidResponse := adapters.NewBidderResponse()
+	for i := 0; i < count; i++ {
+		bidResponse.Bids = append(bidResponse.Bids, &adapters.TypedBid{
+			Bid:     &openrtb.Bid{ID: strconv.Itoa(i), Price: 1},
+			BidType: openrtb_ext.BidTypeBanner,
+		})
+	}
+	return bidResponse, nil
+}
+
+// panickingBidder panics in MakeBids if the response body is "panic".
+type panickingBidder struct {
+	bidCountingBidder
+}
+
+func (bidder *panickingBidder) MakeBids(internalRequest *openrtb.BidRequest, externalRequest *adapters.RequestData, response *adapters.ResponseData) (*adapters.BidderResponse, []error) {
+	if string(response.Body) == "panic" {
+		panic("the shadow response is bad")
+	}
+	return bidder.bidCountingBidder.MakeBids(internalRequest, externalRequest, response)
+}
+
+type shadowLogger struct {
+	objects chan *analytics.ShadowObject
+}
+
+func (l *shadowLogger) LogShadowObject(so *analytics.ShadowObject) {
+	l.objects <- so
+}
@@ -0,0 +1,194 @@
+package exchange
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/buger/jsonparser"
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/adapters"
+	"github.com/prebid/prebid-server/currencies"
+	"github.com/prebid/prebid-server/openrtb_ext"
+)
+
+// splitMultiFormatImps returns a Bidder which never sends imps with more than one media type to the argument Bidder,
+// if its static/bidder-info/{bidder}.yaml file says that it can't handle them.
+//
+// Each such imp is split into one imp per media type which the bidder supports, with IDs made from the original imp ID
+// and the media type. If the publisher prefers one of the imp's media types for this bidder, the imp keeps its ID and
+// only that media type instead. See preferredMediaType for where the preferences come from.
+//
+// The bids are mapped back to the original imp IDs, so the rest of the auction (and targeting) never sees the split imps.
+func splitMultiFormatImps(bidder AdaptedBidder, info adapters.BidderInfo) AdaptedBidder {
+	if info.Capabilities.SupportsMultiFormat() {
+		return bidder
+	}
+	return &multiFormatSplittingBidder{
+		bidder: bidder,
+		info:   info,
+	}
+}
+
+type multiFormatSplittingBidder struct {
+	bidder AdaptedBidder
+	info   adapters.BidderInfo
+}
+
+func (s *multiFormatSplittingBidder) RequestBid(ctx context.Context, request *openrtb.BidRequest, name openrtb_ext.BidderName, bidAdjustment float64, conversions currencies.Conversions) (*PBSOrtbSeatBid, []error) {
+	imps, originalImpIDs := splitImpsByMediaType(request, name, s.supportedMediaTypes(request))
+	if originalImpIDs == nil {
+		return s.bidder.RequestBid(ctx, request, name, bidAdjustment, conversions)
+	}
+
+	// Don't change the caller's request, since its Imps are compared against the bids later on.
+	splitRequest := *request
+	splitRequest.Imp = imps
+	seatBid, errs := s.bidder.RequestBid(ctx, &splitRequest, name, bidAdjustment, conversions)
+	if seatBid != nil {
+		for _, bid := range seatBid.Bids {
+			if bid == nil || bid.Bid == nil {
+				continue
+			}
+			if originalID, ok := originalImpIDs[bid.Bid.ImpID]; ok {
+				bid.Bid.ImpID = originalID
+			}
+		}
+	}
+	return seatBid, errs
+}
+
+// supportedMediaTypes returns the media types which the bidder supports for the request's platform.
+// It returns nil if the bidder doesn't support the platform at all, since those requests will be rejected anyway.
+func (s *multiFormatSplittingBidder) supportedMediaTypes(request *openrtb.BidRequest) []openrtb_ext.BidType {
+	var platform *adapters.PlatformInfo
+	if request.Site != nil {
+		platform = s.info.Capabilities.Site
+	}
+	if request.App != nil {
+		platform = s.info.Capabilities.App
+	}
+	if platform == nil {
+		return nil
+	}
+	return platform.MediaTypes
+}
+
+// splitImpsByMediaType returns the request's imps with every multi-format imp split by media type, along with a map
+// from the new imp IDs to the original ones. The map is nil if none of the imps needed to be split.
+func splitImpsByMediaType(request *openrtb.BidRequest, name openrtb_ext.BidderName, supported []openrtb_ext.BidType) ([]openrtb.Imp, map[string]string) {
+	var imps []openrtb.Imp
+	var originalImpIDs map[string]string
+	var usedIDs map[string]struct{}
+
+	for index, imp := range request.Imp {
+		mediaTypes := impMediaTypes(&imp, supported)
+		if len(mediaTypes) < 2 {
+			if imps != nil {
+				imps = append(imps, imp)
+			}
+			continue
+		}
+
+		if imps == nil {
+			// Filtering imps is expensive, so only copy them once we know that one needs to be split.
+			imps = make([]openrtb.Imp, index, len(request.Imp)+len(mediaTypes)-1)
+			copy(imps, request.Imp[:index])
+			originalImpIDs = make(map[string]string)
+			usedIDs = make(map[string]struct{}, len(request.Imp))
+			for _, otherImp := range request.Imp {
+				usedIDs[otherImp.ID] = struct{}{}
+			}
+		}
+
+		if preferred := preferredMediaType(request, &imp, name); preferred != "" && containsBidType(mediaTypes, preferred) {
+			imps = append(imps, impWithMediaType(imp, preferred))
+			continue
+		}
+		for _, mediaType := range mediaTypes {
+			splitImp := impWithMediaType(imp, mediaType)
+			splitImp.ID = uniqueImpID(fmt.Sprintf("%s-%s", imp.ID, mediaType), usedIDs)
+			originalImpIDs[splitImp.ID] = imp.ID
+			imps = append(imps, splitImp)
+		}
+	}
+	if imps == nil {
+		return request.Imp, nil
+	}
+	return imps, originalImpIDs
+}
+
+// impMediaTypes returns the media types defined on the imp which are also in supported.
+func impMediaTypes(imp *openrtb.Imp, supported []openrtb_ext.BidType) []openrtb_ext.BidType {
+	var mediaTypes []openrtb_ext.BidType
+	if imp.Banner != nil && containsBidType(supported, openrtb_ext.BidTypeBanner) {
+		mediaTypes = append(mediaTypes, openrtb_ext.BidTypeBanner)
+	}
+	if imp.Video != nil && containsBidType(supported, openrtb_ext.BidTypeVideo) {
+		mediaTypes = append(mediaTypes, openrtb_ext.BidTypeVideo)
+	}
+	if imp.Audio != nil && containsBidType(supported, openrtb_ext.BidTypeAudio) {
+		mediaTypes = append(mediaTypes, openrtb_ext.BidTypeAudio)
+	}
+	if imp.Native != nil && containsBidType(supported, openrtb_ext.BidTypeNative) {
+		mediaTypes = append(mediaTypes, openrtb_ext.BidTypeNative)
+	}
+	return mediaTypes
+}
+
+// preferredMediaType returns the media type which the publisher prefers for this bidder on the imp, or "" if there isn't one.
+//
+// The bidder's entry in imp.ext.prebid.preferredmediatype comes first. Otherwise, if the imp has an
+// imp.ext.prebid.adunitcode, the bidder's entry for that ad unit in request.ext.prebid.preferredmediatype is used.
+func preferredMediaType(request *openrtb.BidRequest, imp *openrtb.Imp, name openrtb_ext.BidderName) openrtb_ext.BidType {
+	if preferred, err := jsonparser.GetString(imp.Ext, "prebid", "preferredmediatype", name.String()); err == nil {
+		return openrtb_ext.BidType(preferred)
+	}
+	adUnitCode, err := jsonparser.GetString(imp.Ext, "prebid", "adunitcode")
+	if err != nil || adUnitCode == "" {
+		return ""
+	}
+	preferred, err := jsonparser.GetString(request.Ext, "prebid", "preferredmediatype", adUnitCode, name.String())
+	if err != nil {
+		return ""
+	}
+	return openrtb_ext.BidType(preferred)
+}
+
+// impWithMediaType returns a copy of the imp which only has the given media type.
+func impWithMediaType(imp openrtb.Imp, mediaType openrtb_ext.BidType) openrtb.Imp {
+	if mediaType != openrtb_ext.BidTypeBanner {
+		imp.Banner = nil
+	}
+	if mediaType != openrtb_ext.BidTypeVideo {
+		imp.Video = nil
+	}
+	if mediaType != openrtb_ext.BidTypeAudio {
+		imp.Audio = nil
+	}
+	if mediaType != openrtb_ext.BidTypeNative {
+		imp.Native = nil
+	}
+	return imp
+}
+
+// uniqueImpID returns id, with a numeric suffix if needed to make it unique among usedIDs, and adds it to them.
+func uniqueImpID(id string, usedIDs map[string]struct{}) string {
+	uniqueID := id
+	for n := 2; ; n++ {
+		if _, ok := usedIDs[uniqueID]; !ok {
+			break
+		}
+		uniqueID = fmt.Sprintf("%s-%d", id, n)
+	}
+	usedIDs[uniqueID] = struct{}{}
+	return uniqueID
+}
+
+func containsBidType(haystack []openrtb_ext.BidType, needle openrtb_ext.BidType) bool {
+	for _, bidType := range haystack {
+		if bidType == needle {
+			return true
+		}
+	}
+	return false
+}
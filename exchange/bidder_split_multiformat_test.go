@@ -0,0 +1,144 @@
+package exchange
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/adapters"
+	"github.com/prebid/prebid-server/currencies"
+	"github.com/prebid/prebid-server/openrtb_ext"
+	"github.com/stretchr/testify/assert"
+)
+
+func newMultiFormatInfo(multiFormat bool) adapters.BidderInfo {
+	return adapters.BidderInfo{
+		Capabilities: &adapters.CapabilitiesInfo{
+			Site: &adapters.PlatformInfo{
+				MediaTypes: []openrtb_ext.BidType{openrtb_ext.BidTypeBanner, openrtb_ext.BidTypeVideo},
+			},
+			MultiFormat: &multiFormat,
+		},
+	}
+}
+
+// impRecordingBidder remembers the imps it was sent, and bids on each of them.
+type impRecordingBidder struct {
+	imps []openrtb.Imp
+}
+
+func (b *impRecordingBidder) RequestBid(ctx context.Context, request *openrtb.BidRequest, name openrtb_ext.BidderName, bidAdjustment float64, conversions currencies.Conversions) (*PBSOrtbSeatBid, []error) {
+	b.imps = request.Imp
+	seatBid := &PBSOrtbSeatBid{}
+	for _, imp := range request.Imp {
+		seatBid.Bids = append(seatBid.Bids, &PBSOrtbBid{Bid: &openrtb.Bid{ID: "bid-" + imp.ID, ImpID: imp.ID}})
+	}
+	return seatBid, nil
+}
+
+func TestSplitMultiFormatImpsSupported(t *testing.T) {
+	bidder := &impRecordingBidder{}
+	assert.Equal(t, bidder, splitMultiFormatImps(bidder, newMultiFormatInfo(true)))
+	assert.Equal(t, bidder, splitMultiFormatImps(bidder, adapters.BidderInfo{Capabilities: &adapters.CapabilitiesInfo{}}), "Bidders should support multiformat by default")
+}
+
+func TestSplitMultiFormatImps(t *testing.T) {
+	bidder := &impRecordingBidder{}
+	request := &openrtb.BidRequest{
+		Site: &openrtb.Site{Page: "www.some.domain.com"},
+		Imp: []openrtb.Imp{
+			{ID: "banner-only", Banner: &openrtb.Banner{}},
+			{ID: "imp", Banner: &openrtb.Banner{}, Video: &openrtb.Video{}, Native: &openrtb.Native{}},
+			{ID: "imp-video", Video: &openrtb.Video{}},
+		},
+	}
+	seatBid, errs := splitMultiFormatImps(bidder, newMultiFormatInfo(false)).RequestBid(context.Background(), request, "appnexus", 1.0, nil)
+	assert.Empty(t, errs)
+
+	if assert.Len(t, bidder.imps, 4) {
+		assert.Equal(t, "banner-only", bidder.imps[0].ID)
+		assert.Equal(t, "imp-banner", bidder.imps[1].ID)
+		assert.NotNil(t, bidder.imps[1].Banner)
+		assert.Nil(t, bidder.imps[1].Video)
+		assert.Nil(t, bidder.imps[1].Native, "Split imps should only have one media type")
+		assert.Equal(t, "imp-video-2", bidder.imps[2].ID, "Split imp IDs should not collide with existing ones")
+		assert.Nil(t, bidder.imps[2].Banner)
+		assert.NotNil(t, bidder.imps[2].Video)
+		assert.Equal(t, "imp-video", bidder.imps[3].ID)
+	}
+
+	impIDs := make([]string, 0, len(seatBid.Bids))
+	for _, bid := range seatBid.Bids {
+		impIDs = append(impIDs, bid.Bid.ImpID)
+	}
+	assert.Equal(t, []string{"banner-only", "imp", "imp", "imp-video"}, impIDs, "Bids should be mapped back to the original imps")
+	assert.Len(t, request.Imp, 3, "The original request should not be modified")
+	assert.NotNil(t, request.Imp[1].Video, "The original request should not be modified")
+}
+
+func TestSplitMultiFormatImpsPreferredMediaType(t *testing.T) {
+	bidder := &impRecordingBidder{}
+	request := &openrtb.BidRequest{
+		Site: &openrtb.Site{Page: "www.some.domain.com"},
+		Imp: []openrtb.Imp{{
+			ID:     "imp",
+			Banner: &openrtb.Banner{},
+			Video:  &openrtb.Video{},
+			Ext:    json.RawMessage(`{"appnexus":{"placementId":1},"prebid":{"preferredmediatype":{"appnexus":"video","rubicon":"banner"}}}`),
+		}},
+	}
+	seatBid, _ := splitMultiFormatImps(bidder, newMultiFormatInfo(false)).RequestBid(context.Background(), request, "appnexus", 1.0, nil)
+
+	if assert.Len(t, bidder.imps, 1) {
+		assert.Equal(t, "imp", bidder.imps[0].ID)
+		assert.Nil(t, bidder.imps[0].Banner)
+		assert.NotNil(t, bidder.imps[0].Video)
+	}
+	assert.Equal(t, "imp", seatBid.Bids[0].Bid.ImpID)
+}
+
+func TestSplitMultiFormatImpsPreferredMediaTypeByAdUnit(t *testing.T) {
+	bidder := &impRecordingBidder{}
+	request := &openrtb.BidRequest{
+		Site: &openrtb.Site{Page: "www.some.domain.com"},
+		Imp: []openrtb.Imp{{
+			ID:     "top",
+			Banner: &openrtb.Banner{},
+			Video:  &openrtb.Video{},
+			Ext:    json.RawMessage(`{"appnexus":{"placementId":1},"prebid":{"adunitcode":"div-top"}}`),
+		}, {
+			ID:     "override",
+			Banner: &openrtb.Banner{},
+			Video:  &openrtb.Video{},
+			Ext:    json.RawMessage(`{"appnexus":{"placementId":1},"prebid":{"adunitcode":"div-top","preferredmediatype":{"appnexus":"banner"}}}`),
+		}, {
+			ID:     "other",
+			Banner: &openrtb.Banner{},
+			Video:  &openrtb.Video{},
+			Ext:    json.RawMessage(`{"appnexus":{"placementId":1},"prebid":{"adunitcode":"div-other"}}`),
+		}},
+		Ext: json.RawMessage(`{"prebid":{"preferredmediatype":{"div-top":{"appnexus":"video","rubicon":"banner"}}}}`),
+	}
+	splitMultiFormatImps(bidder, newMultiFormatInfo(false)).RequestBid(context.Background(), request, "appnexus", 1.0, nil)
+
+	if assert.Len(t, bidder.imps, 4) {
+		assert.Equal(t, "top", bidder.imps[0].ID)
+		assert.NotNil(t, bidder.imps[0].Video, "The ad unit's preference should be used")
+		assert.Nil(t, bidder.imps[0].Banner, "The ad unit's preference should be used")
+		assert.Equal(t, "override", bidder.imps[1].ID)
+		assert.NotNil(t, bidder.imps[1].Banner, "The imp's own preference should win")
+		assert.Equal(t, "other-banner", bidder.imps[2].ID, "Ad units without a preference should be split")
+		assert.Equal(t, "other-video", bidder.imps[3].ID, "Ad units without a preference should be split")
+	}
+}
+
+func TestSplitMultiFormatImpsNothingToSplit(t *testing.T) {
+	bidder := &impRecordingBidder{}
+	request := &openrtb.BidRequest{
+		App: &openrtb.App{ID: "some-app"},
+		Imp: []openrtb.Imp{{ID: "imp", Banner: &openrtb.Banner{}, Video: &openrtb.Video{}}},
+	}
+	splitMultiFormatImps(bidder, newMultiFormatInfo(false)).RequestBid(context.Background(), request, "appnexus", 1.0, nil)
+	assert.Equal(t, request.Imp, bidder.imps, "Requests for unsupported platforms should be left for EnforceBidderInfo to reject")
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
@@ -13,6 +14,7 @@ import (
 	"github.com/mxmCherry/openrtb"
 	"github.com/prebid/prebid-server/adapters"
 	"github.com/prebid/prebid-server/currencies"
+	"github.com/prebid/prebid-server/errortypes"
 	"github.com/prebid/prebid-server/openrtb_ext"
 	"github.com/stretchr/testify/assert"
 )
@@ -973,6 +975,122 @@ func TestErrorReporting(t *testing.T) {
 	}
 }
 
+// TestMaxImpsPerRequest makes sure that requests with too many imps are split into chunks,
+// and that each response is processed with the chunk that it was made for.
+// Because this is done in parallel, it should be run under the race detector.
+func TestMaxImpsPerRequest(t *testing.T) {
+	server := httptest.NewServer(mockHandler(200, "getBody", "responseJson"))
+	defer server.Close()
+
+	bidder := &BidderAdapter{
+		Bidder:            &impChunkBidder{uri: server.URL},
+		Client:            server.Client(),
+		MaxImpsPerRequest: 2,
+	}
+	request := &openrtb.BidRequest{
+		Imp:  []openrtb.Imp{{ID: "one"}, {ID: "two"}, {ID: "three"}, {ID: "four"}, {ID: "five"}},
+		Site: &openrtb.Site{ID: "original-site"},
+	}
+	currencyConverter := currencies.NewRateConverterDefault()
+	seatBid, errs := bidder.RequestBid(context.Background(), request, "test", 1.0, currencyConverter.Rates())
+
+	assert.Equal(t, []error{
+		errors.New("The requests weren't ideal."),
+		&errortypes.BadInput{Message: "The imp has no size."},
+		&errortypes.BadInput{Message: "request.imp[1] is too small."},
+		&errortypes.BadInput{Message: "request.imp[2] is too small."},
+		&errortypes.BadInput{Message: "request.imp[3] is too small."},
+		&errortypes.BadInput{Message: "The imp has no size."},
+		&errortypes.BadInput{Message: "request.imp[4] is too small."},
+	}, errs, "Errors which every chunk returns should only be reported once, and the others should all be kept with their imps' indexes in the whole request")
+	impIDs := make(map[string]string, len(seatBid.Bids))
+	for _, bid := range seatBid.Bids {
+		impIDs[bid.Bid.ImpID] = bid.Bid.ID
+	}
+	assert.Equal(t, map[string]string{
+		"one":   "one,two",
+		"two":   "one,two",
+		"three": "three,four",
+		"four":  "three,four",
+		"five":  "five",
+	}, impIDs, "Each chunk's bids should be made from the chunk's own request")
+	assert.Len(t, request.Imp, 5, "The original request should not be modified")
+	assert.Equal(t, "original-site", request.Site.ID, "The original request's site should not be modified")
+}
+
+// TestMaxImpsPerRequestDeadline makes sure that chunks which aren't ready by the deadline are dropped.
+func TestMaxImpsPerRequestDeadline(t *testing.T) {
+	unblock := make(chan struct{})
+	defer close(unblock)
+	bidder := &BidderAdapter{
+		Bidder:            &impChunkBidder{uri: "http://bidder.com", blockOn: "three", unblock: unblock},
+		MaxImpsPerRequest: 2,
+	}
+	request := &openrtb.BidRequest{
+		Imp: []openrtb.Imp{{ID: "one"}, {ID: "two"}, {ID: "three"}, {ID: "four"}, {ID: "five"}},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	reqData, chunkRequests, errs := bidder.makeRequests(ctx, request)
+
+	bodies := make([]string, 0, len(reqData))
+	for _, oneReqData := range reqData {
+		bodies = append(bodies, string(oneReqData.Body))
+		assert.NotNil(t, chunkRequests[oneReqData])
+	}
+	assert.Equal(t, []string{"one,two", "five"}, bodies, "The blocked chunk should be dropped")
+	assert.Contains(t, errs, &errortypes.Timeout{Message: "1 of the 3 chunks of imps weren't ready before the deadline, so they weren't requested"})
+}
+
+// impChunkBidder makes one request for all the imps it gets, and bids on each of them
+// with the imp IDs of the internal request which MakeBids gets as the bid ID.
+// It returns an error for the whole request, one for each of the imps "two" and "five",
+// and one with the index of each imp except "one". Like 33across, it replaces the request's Site with its own.
+// If blockOn is set, MakeRequests waits for unblock before handling the chunk with that imp.
+type impChunkBidder struct {
+	uri     string
+	blockOn string
+	unblock chan struct{}
+}
+
+func (bidder *impChunkBidder) MakeRequests(request *openrtb.BidRequest) ([]*adapters.RequestData, []error) {
+	impIDs := make([]string, 0, len(request.Imp))
+	errs := []error{errors.New("The requests weren't ideal.")}
+	for i, imp := range request.Imp {
+		if imp.ID == bidder.blockOn {
+			<-bidder.unblock
+		}
+		impIDs = append(impIDs, imp.ID)
+		if imp.ID == "two" || imp.ID == "five" {
+			errs = append(errs, &errortypes.BadInput{Message: "The imp has no size."})
+		}
+		if imp.ID != "one" {
+			errs = append(errs, &errortypes.BadInput{Message: fmt.Sprintf("request.imp[%d] is too small.", i)})
+		}
+	}
+	if request.Site != nil {
+		site := *request.Site
+		site.ID = "bidder-site"
+		request.Site = &site
+	}
+	return []*adapters.RequestData{{
+		Method: "POST",
+		Uri:    bidder.uri,
+		Body:   []byte(strings.Join(impIDs, ",")),
+	}}, errs
+}
+
+func (bidder *impChunkBidder) MakeBids(internalRequest *openrtb.BidRequest, externalRequest *adapters.RequestData, response *adapters.ResponseData) (*adapters.BidderResponse, []error) {
+	bidResponse := &adapters.BidderResponse{}
+	for _, imp := range internalRequest.Imp {
+		bidResponse.Bids = append(bidResponse.Bids, &adapters.TypedBid{
+			Bid:     &openrtb.Bid{ID: string(externalRequest.Body), ImpID: imp.ID},
+			BidType: openrtb_ext.BidTypeBanner,
+		})
+	}
+	return bidResponse, nil
+}
+
 type goodSingleBidder struct {
 	bidRequest   *openrtb.BidRequest
 	httpRequest  *adapters.RequestData
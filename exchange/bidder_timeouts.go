@@ -0,0 +1,237 @@
+package exchange
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/adapters"
+	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/errortypes"
+	"github.com/prebid/prebid-server/openrtb_ext"
+	"golang.org/x/net/context/ctxhttp"
+)
+
+// bidderTimeouts works out how long each Bidder gets in an auction. Bidders with a tmax_ms in their adapters config
+// always get that long. If the percentile is enabled, the others get that percentile of their recent response times.
+type bidderTimeouts struct {
+	cfg      config.BidderTimeouts
+	adapters map[string]config.Adapter
+
+	lock      sync.Mutex
+	latencies map[openrtb_ext.BidderName]*bidderLatencies
+}
+
+// timedOut is the sample for a call which timed out. The call's elapsed time is just the deadline which stopped it,
+// so it only says that the Bidder's response time was longer than that.
+const timedOut = time.Duration(math.MaxInt64)
+
+// bidderLatencies holds a Bidder's recent response times, and the deadline which they give it.
+type bidderLatencies struct {
+	samples []time.Duration
+	// next is where the next sample goes, once the samples are full.
+	next int
+	// sinceUpdate counts the samples which were recorded since the tmax was last worked out.
+	sinceUpdate int
+	tmax        time.Duration
+}
+
+func newBidderTimeouts(cfg *config.Configuration) *bidderTimeouts {
+	return &bidderTimeouts{
+		cfg:       cfg.BidderTimeouts,
+		adapters:  cfg.Adapters,
+		latencies: make(map[openrtb_ext.BidderName]*bidderLatencies),
+	}
+}
+
+// apply returns the context for a call to the Bidder, with the Bidder's deadline if it has one.
+// If the Bidder has a deadline, or there's a network latency buffer, the request's tmax is set to
+// the time left before the deadline, minus the buffer.
+func (t *bidderTimeouts) apply(ctx context.Context, bidder openrtb_ext.BidderName, request *openrtb.BidRequest) (context.Context, context.CancelFunc) {
+	cancel := func() {}
+	if t == nil {
+		return ctx, cancel
+	}
+	tmax := t.tmax(bidder)
+	if tmax > 0 {
+		deadline := time.Now().Add(tmax)
+		if auctionDeadline, ok := ctx.Deadline(); !ok || deadline.Before(auctionDeadline) {
+			ctx, cancel = context.WithDeadline(ctx, deadline)
+		}
+	}
+	if tmax > 0 || t.cfg.NetworkLatencyBufferMS > 0 {
+		if deadline, ok := ctx.Deadline(); ok {
+			request.TMax = int64(time.Until(deadline)/time.Millisecond) - int64(t.cfg.NetworkLatencyBufferMS)
+			if request.TMax < 1 {
+				request.TMax = 1
+			}
+		}
+	}
+	return ctx, cancel
+}
+
+// tmax returns how long the Bidder gets, or 0 if it gets the auction's whole timeout.
+func (t *bidderTimeouts) tmax(bidder openrtb_ext.BidderName) time.Duration {
+	if adapter, ok := t.adapters[strings.ToLower(string(bidder))]; ok && adapter.TmaxMS > 0 {
+		return time.Duration(adapter.TmaxMS) * time.Millisecond
+	}
+	if t.cfg.Percentile <= 0 {
+		return 0
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if latencies, ok := t.latencies[bidder]; ok {
+		return latencies.tmax
+	}
+	return 0
+}
+
+// record adds the response time of a call to the Bidder to the samples which the percentile is taken over.
+// Calls which the Bidder's circuit breaker stopped don't count, since they never reached the Bidder.
+//
+// Calls which timed out count as slower than any which didn't. If the percentile lands on one of them,
+// the deadline was too short for the Bidder's response times, so the Bidder gets the auction's whole timeout
+// until enough of its responses show how long they take now.
+func (t *bidderTimeouts) record(bidder openrtb_ext.BidderName, elapsed time.Duration, errs []error) {
+	if t == nil || t.cfg.Percentile <= 0 || hasErrorCode(errs, errortypes.CircuitOpenCode) {
+		return
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	latencies, ok := t.latencies[bidder]
+	if !ok {
+		latencies = &bidderLatencies{
+			samples: make([]time.Duration, 0, t.cfg.Samples),
+		}
+		t.latencies[bidder] = latencies
+	}
+	if hasErrorCode(errs, errortypes.TimeoutCode) {
+		elapsed = timedOut
+	}
+	if len(latencies.samples) < t.cfg.Samples {
+		latencies.samples = append(latencies.samples, elapsed)
+	} else {
+		latencies.samples[latencies.next] = elapsed
+		latencies.next = (latencies.next + 1) % len(latencies.samples)
+	}
+	latencies.sinceUpdate++
+
+	// Sorting the samples on every call would be too slow, so the tmax is only worked out again
+	// once a tenth of them have been replaced.
+	if len(latencies.samples) == t.cfg.Samples && latencies.sinceUpdate >= (t.cfg.Samples+9)/10 {
+		latencies.sinceUpdate = 0
+		latencies.tmax = percentile(latencies.samples, t.cfg.Percentile)
+		if latencies.tmax == timedOut {
+			latencies.tmax = 0
+		} else if minTmax := time.Duration(t.cfg.MinMS) * time.Millisecond; latencies.tmax < minTmax {
+			latencies.tmax = minTmax
+		}
+	}
+}
+
+// percentile returns the nearest-rank percentile of the samples.
+func percentile(samples []time.Duration, p float64) time.Duration {
+	sorted := make([]time.Duration, len(samples))
+	copy(sorted, samples)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i] < sorted[j]
+	})
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// timeoutNotifier sends a Bidder's timeout notifications. It's nil for Bidders which don't make them,
+// or if they're disabled. The timeoutNotifier methods treat nil as "don't send any".
+type timeoutNotifier struct {
+	bidder adapters.TimeoutBidder
+	client *http.Client
+	http   config.BidderHTTP
+	cfg    config.TimeoutNotifications
+	// now and random are swapped out by the tests.
+	now    func() time.Time
+	random func() float64
+
+	lock   sync.Mutex
+	second int64
+	sent   int
+}
+
+func newTimeoutNotifier(bidder adapters.Bidder, client *http.Client, httpCfg config.BidderHTTP, cfg *config.TimeoutNotifications) *timeoutNotifier {
+	timeoutBidder, ok := bidder.(adapters.TimeoutBidder)
+	if !ok || !cfg.Enabled {
+		return nil
+	}
+	return &timeoutNotifier{
+		bidder: timeoutBidder,
+		client: client,
+		http:   httpCfg,
+		cfg:    *cfg,
+		now:    time.Now,
+		random: rand.Float64,
+	}
+}
+
+// notify tells the Bidder that the request timed out, if this timeout is sampled and the Bidder
+// hasn't had too many notifications this second. The notification is sent in the background.
+func (n *timeoutNotifier) notify(req *adapters.RequestData) {
+	if n == nil || !n.allow() {
+		return
+	}
+	go n.send(req)
+}
+
+func (n *timeoutNotifier) allow() bool {
+	if n.random() >= n.cfg.SamplingRate {
+		return false
+	}
+
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	if second := n.now().Unix(); second != n.second {
+		n.second = second
+		n.sent = 0
+	}
+	if n.sent >= n.cfg.MaxPerSecond {
+		return false
+	}
+	n.sent++
+	return true
+}
+
+// send makes and sends the notification. Nobody waits for it, so any errors are dropped.
+func (n *timeoutNotifier) send(req *adapters.RequestData) {
+	notification, errs := n.bidder.MakeTimeoutNotification(req)
+	if notification == nil || len(errs) > 0 {
+		return
+	}
+	httpReq, err := newHTTPRequest(notification, &n.http)
+	if err != nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(n.cfg.TimeoutMS)*time.Millisecond)
+	defer cancel()
+
+	httpResp, err := ctxhttp.Do(ctx, n.client, httpReq)
+	if err != nil {
+		return
+	}
+	// Reading the body lets the connection go back in the pool.
+	io.Copy(ioutil.Discard, httpResp.Body)
+	httpResp.Body.Close()
+}
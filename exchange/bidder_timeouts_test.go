@@ -0,0 +1,270 @@
+package exchange
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/adapters"
+	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/currencies"
+	"github.com/prebid/prebid-server/errortypes"
+	"github.com/prebid/prebid-server/openrtb_ext"
+	"github.com/prebid/prebid-server/pbsmetrics"
+	metricsConf "github.com/prebid/prebid-server/pbsmetrics/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBidderTimeoutsStaticTmax(t *testing.T) {
+	timeouts := newBidderTimeouts(&config.Configuration{
+		BidderTimeouts: config.BidderTimeouts{NetworkLatencyBufferMS: 50},
+		Adapters: map[string]config.Adapter{
+			"appnexus": {TmaxMS: 300},
+		},
+	})
+	auctionCtx, auctionCancel := context.WithTimeout(context.Background(), time.Second)
+	defer auctionCancel()
+
+	request := &openrtb.BidRequest{TMax: 1000}
+	ctx, cancel := timeouts.apply(auctionCtx, openrtb_ext.BidderAppnexus, request)
+	defer cancel()
+	deadline, _ := ctx.Deadline()
+	assert.InDelta(t, 300, time.Until(deadline)/time.Millisecond, 10, "The bidder's deadline should be its tmax_ms")
+	assert.InDelta(t, 250, request.TMax, 10, "The request's tmax should leave room for the network latency buffer")
+
+	request = &openrtb.BidRequest{TMax: 1000}
+	ctx, cancel = timeouts.apply(auctionCtx, openrtb_ext.BidderRubicon, request)
+	defer cancel()
+	assert.Equal(t, auctionCtx, ctx, "Bidders without a tmax should get the auction's deadline")
+	assert.InDelta(t, 950, request.TMax, 10, "The buffer should apply to every bidder")
+}
+
+func TestBidderTimeoutsAuctionDeadlineWins(t *testing.T) {
+	timeouts := newBidderTimeouts(&config.Configuration{
+		Adapters: map[string]config.Adapter{
+			"appnexus": {TmaxMS: 5000},
+		},
+	})
+	auctionCtx, auctionCancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer auctionCancel()
+
+	request := &openrtb.BidRequest{TMax: 200}
+	ctx, cancel := timeouts.apply(auctionCtx, openrtb_ext.BidderAppnexus, request)
+	defer cancel()
+	assert.Equal(t, auctionCtx, ctx)
+	assert.InDelta(t, 200, request.TMax, 10)
+}
+
+func TestBidderTimeoutsWithoutConfig(t *testing.T) {
+	timeouts := newBidderTimeouts(&config.Configuration{})
+	request := &openrtb.BidRequest{TMax: 1000}
+	ctx, cancel := timeouts.apply(context.Background(), openrtb_ext.BidderAppnexus, request)
+	defer cancel()
+	assert.Equal(t, context.Background(), ctx)
+	assert.Equal(t, int64(1000), request.TMax, "The request's tmax shouldn't change if nothing is configured")
+
+	var noTimeouts *bidderTimeouts
+	ctx, cancel = noTimeouts.apply(context.Background(), openrtb_ext.BidderAppnexus, request)
+	defer cancel()
+	assert.Equal(t, context.Background(), ctx)
+	noTimeouts.record(openrtb_ext.BidderAppnexus, time.Second, nil)
+}
+
+func TestBidderTimeoutsPercentile(t *testing.T) {
+	timeouts := newBidderTimeouts(&config.Configuration{
+		BidderTimeouts: config.BidderTimeouts{Percentile: 90, Samples: 10, MinMS: 50},
+		Adapters: map[string]config.Adapter{
+			"rubicon": {TmaxMS: 300},
+		},
+	})
+
+	for i := 1; i < 10; i++ {
+		timeouts.record(openrtb_ext.BidderAppnexus, time.Duration(i*100)*time.Millisecond, nil)
+	}
+	assert.Equal(t, time.Duration(0), timeouts.tmax(openrtb_ext.BidderAppnexus), "Bidders shouldn't get a deadline before there are enough samples")
+
+	timeouts.record(openrtb_ext.BidderAppnexus, time.Second, nil)
+	assert.Equal(t, 900*time.Millisecond, timeouts.tmax(openrtb_ext.BidderAppnexus))
+
+	timeouts.record(openrtb_ext.BidderAppnexus, time.Millisecond, []error{&errortypes.CircuitOpen{Message: "circuit open"}})
+	assert.Len(t, timeouts.latencies[openrtb_ext.BidderAppnexus].samples, 10)
+	assert.Equal(t, 0, timeouts.latencies[openrtb_ext.BidderAppnexus].next, "Calls which the circuit breaker stopped shouldn't count")
+
+	// New samples replace the oldest ones.
+	for i := 0; i < 10; i++ {
+		timeouts.record(openrtb_ext.BidderAppnexus, 10*time.Millisecond, nil)
+	}
+	assert.Equal(t, 50*time.Millisecond, timeouts.tmax(openrtb_ext.BidderAppnexus), "The deadline shouldn't go under min_ms")
+
+	assert.Equal(t, 300*time.Millisecond, timeouts.tmax(openrtb_ext.BidderRubicon), "A static tmax_ms should win over the percentile")
+}
+
+func TestBidderTimeoutsRisingLatency(t *testing.T) {
+	timeouts := newBidderTimeouts(&config.Configuration{
+		BidderTimeouts: config.BidderTimeouts{Percentile: 90, Samples: 10},
+	})
+	for i := 0; i < 10; i++ {
+		timeouts.record(openrtb_ext.BidderAppnexus, 100*time.Millisecond, nil)
+	}
+	assert.Equal(t, 100*time.Millisecond, timeouts.tmax(openrtb_ext.BidderAppnexus))
+
+	// The bidder slows down, so its calls hit the 100ms deadline.
+	timedOut := []error{&errortypes.Timeout{Message: "context deadline exceeded"}}
+	for i := 0; i < 2; i++ {
+		timeouts.record(openrtb_ext.BidderAppnexus, 100*time.Millisecond, timedOut)
+	}
+	assert.Equal(t, time.Duration(0), timeouts.tmax(openrtb_ext.BidderAppnexus), "Timeouts should lift the deadline once the percentile lands on them")
+
+	for i := 0; i < 10; i++ {
+		timeouts.record(openrtb_ext.BidderAppnexus, 400*time.Millisecond, nil)
+	}
+	assert.Equal(t, 400*time.Millisecond, timeouts.tmax(openrtb_ext.BidderAppnexus), "The deadline should rise to the bidder's new response times")
+}
+
+func TestGetAllBidsAppliesBidderTimeouts(t *testing.T) {
+	bidder := &deadlineCapturingBidder{}
+	e := &exchange{
+		adapterMap: map[openrtb_ext.BidderName]AdaptedBidder{
+			openrtb_ext.BidderAppnexus: bidder,
+		},
+		me: &metricsConf.DummyMetricsEngine{},
+		bidderTimeouts: newBidderTimeouts(&config.Configuration{
+			Adapters: map[string]config.Adapter{
+				"appnexus": {TmaxMS: 100},
+			},
+		}),
+	}
+	cleanRequests := map[openrtb_ext.BidderName]*openrtb.BidRequest{
+		openrtb_ext.BidderAppnexus: {TMax: 1000},
+	}
+	blabels := map[openrtb_ext.BidderName]*pbsmetrics.AdapterLabels{
+		openrtb_ext.BidderAppnexus: {Adapter: openrtb_ext.BidderAppnexus},
+	}
+	auctionCtx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	e.getAllBids(auctionCtx, cleanRequests, nil, nil, blabels, nil)
+
+	if assert.True(t, bidder.hasDeadline) {
+		assert.InDelta(t, 100, bidder.timeLeft/time.Millisecond, 10)
+	}
+	assert.InDelta(t, 100, bidder.tmax, 10)
+}
+
+func TestPercentile(t *testing.T) {
+	samples := []time.Duration{5, 1, 4, 2, 3}
+	assert.Equal(t, time.Duration(1), percentile(samples, 1))
+	assert.Equal(t, time.Duration(3), percentile(samples, 50))
+	assert.Equal(t, time.Duration(5), percentile(samples, 95))
+	assert.Equal(t, time.Duration(5), percentile(samples, 100))
+	assert.Equal(t, []time.Duration{5, 1, 4, 2, 3}, samples, "The samples shouldn't be reordered")
+}
+
+func TestTimeoutNotifications(t *testing.T) {
+	notifications := make(chan *http.Request, 1)
+	bidderServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/notify" {
+			notifications <- r
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}))
+	defer bidderServer.Close()
+
+	bidder := &timeoutNotifyingBidder{
+		goodSingleBidder: goodSingleBidder{
+			httpRequest: &adapters.RequestData{Method: "POST", Uri: bidderServer.URL + "/bid", Body: []byte("{}")},
+		},
+		notifyURI: bidderServer.URL + "/notify",
+	}
+	bidderAdapter := &BidderAdapter{
+		Bidder: bidder,
+		Client: bidderServer.Client(),
+		HTTP:   config.BidderHTTP{Headers: map[string]string{"X-Host": "pbs"}},
+		TimeoutNotifier: newTimeoutNotifier(bidder, bidderServer.Client(), config.BidderHTTP{Headers: map[string]string{"X-Host": "pbs"}}, &config.TimeoutNotifications{
+			Enabled:      true,
+			SamplingRate: 1,
+			MaxPerSecond: 10,
+			TimeoutMS:    1000,
+		}),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+	_, errs := bidderAdapter.RequestBid(ctx, &openrtb.BidRequest{}, openrtb_ext.BidderAppnexus, 1.0, nil)
+	if assert.Len(t, errs, 1) {
+		assert.Equal(t, errortypes.TimeoutCode, errortypes.DecodeError(errs[0]))
+	}
+
+	select {
+	case notification := <-notifications:
+		assert.Equal(t, "GET", notification.Method)
+		assert.Equal(t, "pbs", notification.Header.Get("X-Host"), "Notifications should get the bidder's HTTP settings")
+	case <-time.After(time.Second):
+		t.Error("The bidder should have been notified about the timeout")
+	}
+	assert.Equal(t, bidderServer.URL+"/bid", bidder.timedOut.Uri)
+}
+
+func TestTimeoutNotifierLimits(t *testing.T) {
+	clock := time.Date(2019, 6, 1, 12, 0, 0, 0, time.UTC)
+	notifier := newTimeoutNotifier(&timeoutNotifyingBidder{}, http.DefaultClient, config.BidderHTTP{}, &config.TimeoutNotifications{
+		Enabled:      true,
+		SamplingRate: 0.5,
+		MaxPerSecond: 2,
+		TimeoutMS:    100,
+	})
+	notifier.now = func() time.Time { return clock }
+
+	notifier.random = func() float64 { return 0.5 }
+	assert.False(t, notifier.allow(), "Timeouts outside the sample shouldn't get notifications")
+
+	notifier.random = func() float64 { return 0.1 }
+	assert.True(t, notifier.allow())
+	assert.True(t, notifier.allow())
+	assert.False(t, notifier.allow(), "The bidder shouldn't get more than max_per_second notifications")
+
+	clock = clock.Add(time.Second)
+	assert.True(t, notifier.allow(), "The limit should reset every second")
+}
+
+func TestNewTimeoutNotifier(t *testing.T) {
+	enabled := &config.TimeoutNotifications{Enabled: true, SamplingRate: 1, MaxPerSecond: 1, TimeoutMS: 100}
+	assert.NotNil(t, newTimeoutNotifier(&timeoutNotifyingBidder{}, http.DefaultClient, config.BidderHTTP{}, enabled))
+	assert.Nil(t, newTimeoutNotifier(&goodSingleBidder{}, http.DefaultClient, config.BidderHTTP{}, enabled), "Bidders which don't make notifications shouldn't get a notifier")
+	assert.Nil(t, newTimeoutNotifier(&timeoutNotifyingBidder{}, http.DefaultClient, config.BidderHTTP{}, &config.TimeoutNotifications{}))
+
+	var noNotifier *timeoutNotifier
+	noNotifier.notify(&adapters.RequestData{})
+}
+
+type deadlineCapturingBidder struct {
+	hasDeadline bool
+	timeLeft    time.Duration
+	tmax        int64
+}
+
+func (b *deadlineCapturingBidder) RequestBid(ctx context.Context, request *openrtb.BidRequest, name openrtb_ext.BidderName, bidAdjustment float64, conversions currencies.Conversions) (*PBSOrtbSeatBid, []error) {
+	var deadline time.Time
+	deadline, b.hasDeadline = ctx.Deadline()
+	b.timeLeft = time.Until(deadline)
+	b.tmax = request.TMax
+	return nil, nil
+}
+
+type timeoutNotifyingBidder struct {
+	goodSingleBidder
+	notifyURI string
+	timedOut  *adapters.RequestData
+}
+
+func (bidder *timeoutNotifyingBidder) MakeTimeoutNotification(req *adapters.RequestData) (*adapters.RequestData, []error) {
+	bidder.timedOut = req
+	return &adapters.RequestData{
+		Method: "GET",
+		Uri:    bidder.notifyURI,
+	}, nil
+}
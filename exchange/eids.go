@@ -0,0 +1,158 @@
+package exchange
+
+import (
+	"github.com/buger/jsonparser"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/openrtb_ext"
+)
+
+// digiTrustSource is the eids source which request.user.ext.digitrust gets converted into.
+const digiTrustSource = "digitru.st"
+
+// userEids holds the user's extended IDs, and the publisher's rules about which bidders may see each of them.
+type userEids struct {
+	ext         openrtb_ext.ExtUser
+	eids        []openrtb_ext.ExtUserEid
+	permissions map[string][]string
+}
+
+// extractUserEids reads the user's extended IDs from request.user.ext, along with the
+// request.ext.prebid.data.eidpermissions. It returns nil if the user doesn't have any.
+//
+// The legacy request.user.ext.tpid and request.user.ext.digitrust fields are converted into eids too.
+// If one of them has the same source as an entry in request.user.ext.eids, the eids entry wins.
+func extractUserEids(req *openrtb.BidRequest) (*userEids, error) {
+	if req.User == nil || len(req.User.Ext) == 0 {
+		return nil, nil
+	}
+	var userExt openrtb_ext.ExtUser
+	if err := jsoniter.Unmarshal(req.User.Ext, &userExt); err != nil {
+		return nil, err
+	}
+
+	eids := make([]openrtb_ext.ExtUserEid, 0, len(userExt.Eids)+len(userExt.TpID)+1)
+	sources := make(map[string]struct{}, cap(eids))
+	addEid := func(eid openrtb_ext.ExtUserEid) {
+		if _, ok := sources[eid.Source]; !ok {
+			sources[eid.Source] = struct{}{}
+			eids = append(eids, eid)
+		}
+	}
+	for _, eid := range userExt.Eids {
+		addEid(eid)
+	}
+	for _, tpid := range userExt.TpID {
+		addEid(openrtb_ext.ExtUserEid{
+			Source: tpid.Source,
+			UIDs:   []openrtb_ext.ExtUserEidUID{{ID: tpid.UID}},
+		})
+	}
+	if userExt.DigiTrust != nil && userExt.DigiTrust.ID != "" {
+		addEid(openrtb_ext.ExtUserEid{
+			Source: digiTrustSource,
+			UIDs:   []openrtb_ext.ExtUserEidUID{{ID: userExt.DigiTrust.ID}},
+		})
+	}
+	if len(eids) == 0 {
+		return nil, nil
+	}
+
+	permissions, err := extractEidPermissions(req)
+	if err != nil {
+		return nil, err
+	}
+	return &userEids{
+		ext:         userExt,
+		eids:        eids,
+		permissions: permissions,
+	}, nil
+}
+
+// extractEidPermissions returns the bidders which may see each source from request.ext.prebid.data.eidpermissions.
+// Sources which aren't in the map can be seen by every bidder.
+func extractEidPermissions(req *openrtb.BidRequest) (map[string][]string, error) {
+	permissionsJSON, dataType, _, err := jsonparser.Get(req.Ext, "prebid", "data", "eidpermissions")
+	if err != nil || dataType != jsonparser.Array {
+		return nil, nil
+	}
+	var permissions []openrtb_ext.ExtRequestPrebidDataEidPermission
+	if err := jsoniter.Unmarshal(permissionsJSON, &permissions); err != nil {
+		return nil, err
+	}
+	bySource := make(map[string][]string, len(permissions))
+	for _, permission := range permissions {
+		bySource[permission.Source] = permission.Bidders
+	}
+	return bySource, nil
+}
+
+// allowed returns true if the bidder may see the IDs from source.
+//
+// In this function, "givenBidder" may or may not be an alias. "coreBidder" must *not* be an alias.
+// Permissions may name either one.
+func (u *userEids) allowed(source string, givenBidder string, coreBidder openrtb_ext.BidderName) bool {
+	bidders, ok := u.permissions[source]
+	return !ok || bidderListed(bidders, givenBidder, coreBidder)
+}
+
+// prepareEids sets request.user.ext.eids to the IDs which this bidder may see. The legacy tpid and digitrust
+// fields are filtered by the same rules, so that adapters which still read them don't get anything extra.
+// The request.ext.prebid.data.eidpermissions are removed, so that bidders can't see which other bidders are involved.
+//
+// This *will* mutate the request, but will *not* mutate any objects nested inside it.
+func prepareEids(req *openrtb.BidRequest, givenBidder string, coreBidder openrtb_ext.BidderName, userEids *userEids) error {
+	if len(req.Ext) > 0 {
+		// jsonparser.Delete works in-place, so make sure the shared ext doesn't get changed.
+		ext := append([]byte(nil), req.Ext...)
+		req.Ext = jsonparser.Delete(ext, "prebid", "data", "eidpermissions")
+	}
+	if userEids == nil || req.User == nil {
+		return nil
+	}
+
+	eids := make([]openrtb_ext.ExtUserEid, 0, len(userEids.eids))
+	for _, eid := range userEids.eids {
+		if userEids.allowed(eid.Source, givenBidder, coreBidder) {
+			eids = append(eids, eid)
+		}
+	}
+	var tpids []openrtb_ext.ExtUserTpID
+	for _, tpid := range userEids.ext.TpID {
+		if userEids.allowed(tpid.Source, givenBidder, coreBidder) {
+			tpids = append(tpids, tpid)
+		}
+	}
+
+	// jsonparser.Set and Delete work in-place, so make sure the shared ext doesn't get changed.
+	ext := append([]byte(nil), req.User.Ext...)
+	var err error
+	if ext, err = setOrDeleteJSON(ext, "eids", eids, len(eids) > 0); err != nil {
+		return err
+	}
+	if len(userEids.ext.TpID) > 0 {
+		if ext, err = setOrDeleteJSON(ext, "tpid", tpids, len(tpids) > 0); err != nil {
+			return err
+		}
+	}
+	if userEids.ext.DigiTrust != nil && !userEids.allowed(digiTrustSource, givenBidder, coreBidder) {
+		ext = jsonparser.Delete(ext, "digitrust")
+	}
+
+	user := *req.User
+	user.Ext = ext
+	req.User = &user
+	return nil
+}
+
+// setOrDeleteJSON sets key in data to the JSON for value if keep is true, and deletes it otherwise.
+func setOrDeleteJSON(data []byte, key string, value interface{}, keep bool) ([]byte, error) {
+	if !keep {
+		return jsonparser.Delete(data, key), nil
+	}
+	valueJSON, err := jsoniter.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	return jsonparser.Set(data, valueJSON, key)
+}
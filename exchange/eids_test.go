@@ -0,0 +1,101 @@
+package exchange
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/openrtb_ext"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtractUserEidsConvertsLegacyFields(t *testing.T) {
+	req := &openrtb.BidRequest{
+		User: &openrtb.User{
+			Ext: json.RawMessage(`{"eids":[{"source":"a.com","uids":[{"id":"from-eids"}]}],"tpid":[{"source":"a.com","uid":"from-tpid"},{"source":"b.com","uid":"b-id"}],"digitrust":{"id":"digi-id","keyv":1,"pref":0}}`),
+		},
+	}
+	eids, err := extractUserEids(req)
+	assert.NoError(t, err)
+	if assert.NotNil(t, eids) {
+		assert.Equal(t, []openrtb_ext.ExtUserEid{
+			{Source: "a.com", UIDs: []openrtb_ext.ExtUserEidUID{{ID: "from-eids"}}},
+			{Source: "b.com", UIDs: []openrtb_ext.ExtUserEidUID{{ID: "b-id"}}},
+			{Source: digiTrustSource, UIDs: []openrtb_ext.ExtUserEidUID{{ID: "digi-id"}}},
+		}, eids.eids)
+	}
+}
+
+func TestExtractUserEidsNone(t *testing.T) {
+	eids, err := extractUserEids(&openrtb.BidRequest{User: &openrtb.User{Ext: json.RawMessage(`{"consent":"abc"}`)}})
+	assert.NoError(t, err)
+	assert.Nil(t, eids)
+}
+
+func TestPrepareEidsPermissions(t *testing.T) {
+	userExt := json.RawMessage(`{"consent":"abc","eids":[{"source":"a.com","uids":[{"id":"a-id"}]},{"source":"b.com","uids":[{"id":"b-id"}]}],"tpid":[{"source":"b.com","uid":"b-id"}],"digitrust":{"id":"digi-id","keyv":1,"pref":0}}`)
+	req := &openrtb.BidRequest{
+		User: &openrtb.User{Ext: userExt},
+		Ext:  json.RawMessage(`{"prebid":{"data":{"eidpermissions":[{"source":"a.com","bidders":["*"]},{"source":"b.com","bidders":["appnexus"]},{"source":"digitru.st","bidders":["rubicon"]}]}}}`),
+	}
+	eids, err := extractUserEids(req)
+	assert.NoError(t, err)
+
+	testCases := []struct {
+		description string
+		givenBidder string
+		coreBidder  openrtb_ext.BidderName
+		expectedExt string
+	}{
+		{
+			description: "Core bidder named in the permissions",
+			givenBidder: "appnexus",
+			coreBidder:  openrtb_ext.BidderAppnexus,
+			expectedExt: `{"consent":"abc","eids":[{"source":"a.com","uids":[{"id":"a-id"}]},{"source":"b.com","uids":[{"id":"b-id"}]}],"tpid":[{"source":"b.com","uid":"b-id"}]}`,
+		},
+		{
+			description: "Alias of a bidder named in the permissions",
+			givenBidder: "brightroll",
+			coreBidder:  openrtb_ext.BidderAppnexus,
+			expectedExt: `{"consent":"abc","eids":[{"source":"a.com","uids":[{"id":"a-id"}]},{"source":"b.com","uids":[{"id":"b-id"}]}],"tpid":[{"source":"b.com","uid":"b-id"}]}`,
+		},
+		{
+			description: "Bidder only allowed by the wildcard and digitrust",
+			givenBidder: "rubicon",
+			coreBidder:  openrtb_ext.BidderRubicon,
+			expectedExt: `{"consent":"abc","eids":[{"source":"a.com","uids":[{"id":"a-id"}]},{"source":"digitru.st","uids":[{"id":"digi-id"}]}],"digitrust":{"id":"digi-id","keyv":1,"pref":0}}`,
+		},
+	}
+
+	requestExt := string(req.Ext)
+	for _, test := range testCases {
+		reqCopy := *req
+		err := prepareEids(&reqCopy, test.givenBidder, test.coreBidder, eids)
+		assert.NoError(t, err, test.description)
+		assert.JSONEq(t, test.expectedExt, string(reqCopy.User.Ext), test.description)
+		assert.JSONEq(t, `{"prebid":{"data":{}}}`, string(reqCopy.Ext), test.description+": bidders shouldn't see the eidpermissions")
+	}
+	assert.Equal(t, userExt, req.User.Ext, "The original request should not be modified")
+	assert.Equal(t, requestExt, string(req.Ext), "The original request should not be modified")
+}
+
+func TestPrepareEidsRemovesPermissionsWithoutEids(t *testing.T) {
+	req := &openrtb.BidRequest{
+		Ext: json.RawMessage(`{"prebid":{"data":{"eidpermissions":[{"source":"a.com","bidders":["rubicon"]}]},"debug":true}}`),
+	}
+	eids, err := extractUserEids(req)
+	assert.NoError(t, err)
+	assert.NoError(t, prepareEids(req, "appnexus", openrtb_ext.BidderAppnexus, eids))
+	assert.JSONEq(t, `{"prebid":{"data":{},"debug":true}}`, string(req.Ext))
+}
+
+func TestPrepareEidsRemovesEmptyList(t *testing.T) {
+	req := &openrtb.BidRequest{
+		User: &openrtb.User{Ext: json.RawMessage(`{"eids":[{"source":"a.com","uids":[{"id":"a-id"}]}]}`)},
+		Ext:  json.RawMessage(`{"prebid":{"data":{"eidpermissions":[{"source":"a.com","bidders":["rubicon"]}]}}}`),
+	}
+	eids, err := extractUserEids(req)
+	assert.NoError(t, err)
+	assert.NoError(t, prepareEids(req, "appnexus", openrtb_ext.BidderAppnexus, eids))
+	assert.JSONEq(t, `{}`, string(req.User.Ext))
+}
@@ -17,13 +17,16 @@ import (
 	"github.com/golang/glog"
 	"github.com/mxmCherry/openrtb"
 	"github.com/prebid/prebid-server/adapters"
+	"github.com/prebid/prebid-server/analytics"
 	"github.com/prebid/prebid-server/config"
 	"github.com/prebid/prebid-server/currencies"
 	"github.com/prebid/prebid-server/errortypes"
 	"github.com/prebid/prebid-server/gdpr"
+	"github.com/prebid/prebid-server/geolocation"
 	"github.com/prebid/prebid-server/openrtb_ext"
 	"github.com/prebid/prebid-server/pbsmetrics"
 	"github.com/prebid/prebid-server/prebid_cache_client"
+	"github.com/prebid/prebid-server/privacy"
 )
 
 // Exchange runs Auctions. Implementations must be threadsafe, and will be shared across many goroutines.
@@ -47,6 +50,13 @@ type exchange struct {
 	currencyConverter   *currencies.RateConverter
 	UsersyncIfAmbiguous bool
 	defaultTTLs         config.DefaultTTLs
+	privacyConfig       config.Privacy
+	geoResolver         *geolocation.Resolver
+	geoFailClosed       bool
+	schainASI           string
+	trafficShaper       *trafficShaper
+	bidderRulesConfig   config.BidderRules
+	bidderTimeouts      *bidderTimeouts
 }
 
 // Container to pass out response Ext data from the GetAllBids goroutines back into the main thread
@@ -61,10 +71,10 @@ type BidResponseWrapper struct {
 	Bidder       openrtb_ext.BidderName
 }
 
-func NewExchange(client *http.Client, cache prebid_cache_client.Client, cfg *config.Configuration, metricsEngine pbsmetrics.MetricsEngine, infos adapters.BidderInfos, gDPR gdpr.Permissions, currencyConverter *currencies.RateConverter) Exchange {
+func NewExchange(client *http.Client, cache prebid_cache_client.Client, cfg *config.Configuration, metricsEngine pbsmetrics.MetricsEngine, infos adapters.BidderInfos, gDPR gdpr.Permissions, currencyConverter *currencies.RateConverter, geoResolver *geolocation.Resolver, circuitBreakers *CircuitBreakers, pbsAnalytics analytics.PBSAnalyticsModule) Exchange {
 	e := new(exchange)
 
-	e.adapterMap = newAdapterMap(client, cfg, infos)
+	e.adapterMap = newAdapterMap(client, cfg, infos, circuitBreakers, newShadowReporter(metricsEngine, pbsAnalytics))
 	e.cache = cache
 	e.cacheTime = time.Duration(cfg.CacheURL.ExpectedTimeMillis) * time.Millisecond
 	e.me = metricsEngine
@@ -72,6 +82,13 @@ func NewExchange(client *http.Client, cache prebid_cache_client.Client, cfg *con
 	e.currencyConverter = currencyConverter
 	e.UsersyncIfAmbiguous = cfg.GDPR.UsersyncIfAmbiguous
 	e.defaultTTLs = cfg.CacheURL.DefaultTTLs
+	e.privacyConfig = cfg.Privacy
+	e.geoResolver = geoResolver
+	e.geoFailClosed = cfg.GDPR.GeoLocation.FailClosed
+	e.schainASI = cfg.SChain.ASI
+	e.trafficShaper = newTrafficShaper(&cfg.TrafficShaping)
+	e.bidderRulesConfig = cfg.BidderRules
+	e.bidderTimeouts = newBidderTimeouts(cfg)
 	return e
 }
 
@@ -86,9 +103,17 @@ func (e *exchange) HoldAuction(ctx context.Context, bidRequest *openrtb.BidReque
 		}
 	}
 
+	// Decide whether GDPR applies from the IP address, if the request doesn't say.
+	applyGeoLocation(bidRequest, e.geoResolver, e.geoFailClosed)
+
 	// Slice of BidRequests, each a copy of the original cleaned to only contain Bidder data for the named Bidder
 	blabels := make(map[openrtb_ext.BidderName]*pbsmetrics.AdapterLabels)
-	cleanRequests, aliases, errs := CleanOpenRTBRequests(ctx, bidRequest, usersyncs, blabels, labels, e.gDPR, e.UsersyncIfAmbiguous)
+	activityControl := privacy.NewActivityControl(&e.privacyConfig, labels.PubID)
+	rules := newBidderRules(&e.bidderRulesConfig, labels.PubID)
+	cleanRequests, aliases, errs := CleanOpenRTBRequests(ctx, bidRequest, usersyncs, blabels, labels, e.gDPR, e.UsersyncIfAmbiguous, activityControl, rules)
+	// The metrics labels don't have the publisher ID on every endpoint, so the account comes from the request itself.
+	accountID, _ := toAccountId(bidRequest)
+	errs = append(errs, prepareSChains(bidRequest, cleanRequests, aliases, e.schainASI, accountID)...)
 
 	// List of bidders we have requests for.
 	liveAdapters := make([]openrtb_ext.BidderName, len(cleanRequests))
@@ -139,7 +164,7 @@ func (e *exchange) HoldAuction(ctx context.Context, bidRequest *openrtb.BidReque
 	// Get Currency rates conversions for the Auction
 	conversions := e.currencyConverter.Rates()
 
-	adapterBids, adapterExtra := e.getAllBids(auctionCtx, cleanRequests, aliases, bidAdjustmentFactors, blabels, conversions)
+	adapterBids, adapterExtra, shapedBidders := e.getAllBids(auctionCtx, cleanRequests, aliases, bidAdjustmentFactors, blabels, conversions)
 	bidCategory, adapterBids, err := applyCategoryMapping(requestExt, adapterBids, *categoriesFetcher, targData)
 	auc := NewAuction(adapterBids, len(bidRequest.Imp))
 	if err != nil {
@@ -155,7 +180,7 @@ func (e *exchange) HoldAuction(ctx context.Context, bidRequest *openrtb.BidReque
 		targData.SetTargeting(auc, bidRequest.App != nil, bidCategory)
 	}
 	// Build the response
-	return e.buildBidResponse(ctx, liveAdapters, adapterBids, bidRequest, resolvedRequest, adapterExtra, errs)
+	return e.buildBidResponse(ctx, liveAdapters, adapterBids, bidRequest, resolvedRequest, adapterExtra, rules.debugDecisions(), shapedBidders, errs)
 }
 
 func (e *exchange) makeAuctionContext(ctx context.Context, needsCache bool) (auctionCtx context.Context, cancel func()) {
@@ -170,15 +195,25 @@ func (e *exchange) makeAuctionContext(ctx context.Context, needsCache bool) (auc
 }
 
 // This piece sends all the requests to the Bidder adapters and gathers the results.
-func (e *exchange) getAllBids(ctx context.Context, cleanRequests map[openrtb_ext.BidderName]*openrtb.BidRequest, aliases map[string]string, bidAdjustments map[string]float64, blabels map[openrtb_ext.BidderName]*pbsmetrics.AdapterLabels, conversions currencies.Conversions) (map[openrtb_ext.BidderName]*PBSOrtbSeatBid, map[openrtb_ext.BidderName]*SeatResponseExtra) {
+// It also returns the Bidders which traffic shaping skipped, sorted by name.
+func (e *exchange) getAllBids(ctx context.Context, cleanRequests map[openrtb_ext.BidderName]*openrtb.BidRequest, aliases map[string]string, bidAdjustments map[string]float64, blabels map[openrtb_ext.BidderName]*pbsmetrics.AdapterLabels, conversions currencies.Conversions) (map[openrtb_ext.BidderName]*PBSOrtbSeatBid, map[openrtb_ext.BidderName]*SeatResponseExtra, []openrtb_ext.BidderName) {
 	// Set up pointers to the Bid results
 	adapterBids := make(map[openrtb_ext.BidderName]*PBSOrtbSeatBid, len(cleanRequests))
 	adapterExtra := make(map[openrtb_ext.BidderName]*SeatResponseExtra, len(cleanRequests))
 	chBids := make(chan *BidResponseWrapper, len(cleanRequests))
+	calls := 0
+	var shapedBidders []openrtb_ext.BidderName
 
 	for bidderName, req := range cleanRequests {
 		// Here we actually call the adapters and collect the Bids.
 		coreBidder := ResolveBidder(string(bidderName), aliases)
+		// Skip the Bidders which are unlikely to bid on this inventory.
+		if e.trafficShaper.skip(bidderName, req, blabels[coreBidder].PubID) {
+			e.me.RecordAdapterCallSkipped(coreBidder)
+			shapedBidders = append(shapedBidders, bidderName)
+			continue
+		}
+		calls++
 		bidderRunner := e.recoverSafely(func(aName openrtb_ext.BidderName, coreBidder openrtb_ext.BidderName, request *openrtb.BidRequest, bidlabels *pbsmetrics.AdapterLabels, conversions currencies.Conversions) {
 			// Passing in aName so a doesn't change out from under the go routine
 			if bidlabels.Adapter == "" {
@@ -197,10 +232,15 @@ func (e *exchange) getAllBids(ctx context.Context, cleanRequests map[openrtb_ext
 			if givenAdjustment, ok := bidAdjustments[string(aName)]; ok {
 				adjustmentFactor = givenAdjustment
 			}
-			bids, err := e.adapterMap[coreBidder].RequestBid(ctx, request, aName, adjustmentFactor, conversions)
+			// Give the Bidder its own deadline, if it has one.
+			bidderCtx, cancel := e.bidderTimeouts.apply(ctx, coreBidder, request)
+			bids, err := e.adapterMap[coreBidder].RequestBid(bidderCtx, request, aName, adjustmentFactor, conversions)
+			cancel()
+			e.trafficShaper.record(aName, request, bids, err)
 
 			// Add in time reporting
 			elapsed := time.Since(start)
+			e.bidderTimeouts.record(coreBidder, elapsed, err)
 			brw.AdapterBids = bids
 			// Structure to record extra tracking data generated during bidding
 			ae := new(SeatResponseExtra)
@@ -225,13 +265,16 @@ func (e *exchange) getAllBids(ctx context.Context, cleanRequests map[openrtb_ext
 		go bidderRunner(bidderName, coreBidder, req, blabels[coreBidder], conversions)
 	}
 	// Wait for the bidders to do their thing
-	for i := 0; i < len(cleanRequests); i++ {
+	for i := 0; i < calls; i++ {
 		brw := <-chBids
 		adapterBids[brw.Bidder] = brw.AdapterBids
 		adapterExtra[brw.Bidder] = brw.AdapterExtra
 	}
 
-	return adapterBids, adapterExtra
+	sort.Slice(shapedBidders, func(i, j int) bool {
+		return shapedBidders[i] < shapedBidders[j]
+	})
+	return adapterBids, adapterExtra, shapedBidders
 }
 
 func (e *exchange) recoverSafely(inner func(openrtb_ext.BidderName, openrtb_ext.BidderName, *openrtb.BidRequest, *pbsmetrics.AdapterLabels, currencies.Conversions), chBids chan *BidResponseWrapper) func(openrtb_ext.BidderName, openrtb_ext.BidderName, *openrtb.BidRequest, *pbsmetrics.AdapterLabels, currencies.Conversions) {
@@ -273,6 +316,8 @@ func ErrorsToMetric(errs []error) map[pbsmetrics.AdapterError]struct{} {
 			ret[pbsmetrics.AdapterErrorBadServerResponse] = s
 		case errortypes.FailedToRequestBidsCode:
 			ret[pbsmetrics.AdapterErrorFailedToRequestBids] = s
+		case errortypes.CircuitOpenCode:
+			ret[pbsmetrics.AdapterErrorCircuitOpen] = s
 		default:
 			ret[pbsmetrics.AdapterErrorUnknown] = s
 		}
@@ -290,7 +335,7 @@ func ErrsToBidderErrors(errs []error) []openrtb_ext.ExtBidderError {
 }
 
 // This piece takes all the Bids supplied by the adapters and crafts an openRTB response to send back to the requester
-func (e *exchange) buildBidResponse(ctx context.Context, liveAdapters []openrtb_ext.BidderName, adapterBids map[openrtb_ext.BidderName]*PBSOrtbSeatBid, bidRequest *openrtb.BidRequest, resolvedRequest json.RawMessage, adapterExtra map[openrtb_ext.BidderName]*SeatResponseExtra, errList []error) (*openrtb.BidResponse, error) {
+func (e *exchange) buildBidResponse(ctx context.Context, liveAdapters []openrtb_ext.BidderName, adapterBids map[openrtb_ext.BidderName]*PBSOrtbSeatBid, bidRequest *openrtb.BidRequest, resolvedRequest json.RawMessage, adapterExtra map[openrtb_ext.BidderName]*SeatResponseExtra, bidderRuleDecisions []openrtb_ext.ExtBidderRuleDecision, shapedBidders []openrtb_ext.BidderName, errList []error) (*openrtb.BidResponse, error) {
 	bidResponse := new(openrtb.BidResponse)
 
 	bidResponse.ID = bidRequest.ID
@@ -312,7 +357,7 @@ func (e *exchange) buildBidResponse(ctx context.Context, liveAdapters []openrtb_
 
 	bidResponse.SeatBid = seatBids
 
-	bidResponseExt := e.makeExtBidResponse(adapterBids, adapterExtra, bidRequest, resolvedRequest, errList)
+	bidResponseExt := e.makeExtBidResponse(adapterBids, adapterExtra, bidRequest, resolvedRequest, bidderRuleDecisions, shapedBidders, errList)
 	buffer := &bytes.Buffer{}
 	enc := json.NewEncoder(buffer)
 	enc.SetEscapeHTML(false)
@@ -478,7 +523,7 @@ func getPrimaryAdServer(adServerId int) (string, error) {
 }
 
 // Extract all the data from the SeatBids and build the ExtBidResponse
-func (e *exchange) makeExtBidResponse(adapterBids map[openrtb_ext.BidderName]*PBSOrtbSeatBid, adapterExtra map[openrtb_ext.BidderName]*SeatResponseExtra, req *openrtb.BidRequest, resolvedRequest json.RawMessage, errList []error) *openrtb_ext.ExtBidResponse {
+func (e *exchange) makeExtBidResponse(adapterBids map[openrtb_ext.BidderName]*PBSOrtbSeatBid, adapterExtra map[openrtb_ext.BidderName]*SeatResponseExtra, req *openrtb.BidRequest, resolvedRequest json.RawMessage, bidderRuleDecisions []openrtb_ext.ExtBidderRuleDecision, shapedBidders []openrtb_ext.BidderName, errList []error) *openrtb_ext.ExtBidResponse {
 	bidResponseExt := &openrtb_ext.ExtBidResponse{
 		Errors:               make(map[openrtb_ext.BidderName][]openrtb_ext.ExtBidderError, len(adapterBids)),
 		ResponseTimeMillis:   make(map[openrtb_ext.BidderName]int, len(adapterBids)),
@@ -486,7 +531,11 @@ func (e *exchange) makeExtBidResponse(adapterBids map[openrtb_ext.BidderName]*PB
 	}
 	if req.Test == 1 {
 		bidResponseExt.Debug = &openrtb_ext.ExtResponseDebug{
-			HttpCalls: make(map[openrtb_ext.BidderName][]*openrtb_ext.ExtHttpCall),
+			HttpCalls:   make(map[openrtb_ext.BidderName][]*openrtb_ext.ExtHttpCall),
+			BidderRules: bidderRuleDecisions,
+		}
+		if len(shapedBidders) > 0 {
+			bidResponseExt.Debug.TrafficShaping = &openrtb_ext.ExtTrafficShapingDebug{Skipped: shapedBidders}
 		}
 		if err := jsoniter.Unmarshal(resolvedRequest, &bidResponseExt.Debug.ResolvedRequest); err != nil {
 			glog.Errorf("Error unmarshalling Bid request snapshot: %v", err)
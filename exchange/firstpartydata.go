@@ -0,0 +1,167 @@
+package exchange
+
+import (
+	"encoding/json"
+
+	"github.com/buger/jsonparser"
+	jsonpatch "github.com/evanphx/json-patch"
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/openrtb_ext"
+)
+
+// firstPartyData holds the publisher's rules about which bidders see the request's first party data,
+// and the extra first party data which only some bidders should get.
+type firstPartyData struct {
+	// bidders may see the request's first party data. If it's nil, every bidder can.
+	bidders       []string
+	bidderConfigs []openrtb_ext.ExtRequestPrebidBidderConfig
+}
+
+// extractFirstPartyData reads request.ext.prebid.data.bidders and request.ext.prebid.bidderconfig.
+// It returns nil if the request has neither of them.
+func extractFirstPartyData(req *openrtb.BidRequest) (*firstPartyData, error) {
+	var fpd firstPartyData
+	if biddersJSON, dataType, _, err := jsonparser.Get(req.Ext, "prebid", "data", "bidders"); err == nil && dataType == jsonparser.Array {
+		if err := json.Unmarshal(biddersJSON, &fpd.bidders); err != nil {
+			return nil, err
+		}
+		if fpd.bidders == nil {
+			fpd.bidders = []string{}
+		}
+	}
+	if configsJSON, dataType, _, err := jsonparser.Get(req.Ext, "prebid", "bidderconfig"); err == nil && dataType == jsonparser.Array {
+		if err := json.Unmarshal(configsJSON, &fpd.bidderConfigs); err != nil {
+			return nil, err
+		}
+	}
+	if fpd.bidders == nil && len(fpd.bidderConfigs) == 0 {
+		return nil, nil
+	}
+	return &fpd, nil
+}
+
+// prepareFirstPartyData strips the request's first party data if this bidder isn't allowed to see it, and then
+// merges in the request.ext.prebid.bidderconfig which applies to this bidder. Those ext.prebid fields are removed,
+// along with the request.ext.prebid.data.eidpermissions, so that bidders can't see what the others were sent.
+//
+// This *will* mutate the request, but will *not* mutate any objects nested inside it.
+//
+// In this function, "givenBidder" may or may not be an alias. "coreBidder" must *not* be an alias.
+func prepareFirstPartyData(req *openrtb.BidRequest, givenBidder string, coreBidder openrtb_ext.BidderName, fpd *firstPartyData) error {
+	if fpd == nil {
+		return nil
+	}
+	if fpd.bidders != nil && !bidderListed(fpd.bidders, givenBidder, coreBidder) {
+		cleanFirstPartyData(req)
+	}
+
+	for _, bidderConfig := range fpd.bidderConfigs {
+		if !bidderListed(bidderConfig.Bidders, givenBidder, coreBidder) || bidderConfig.Config == nil || bidderConfig.Config.ORTB2 == nil {
+			continue
+		}
+		ortb2 := bidderConfig.Config.ORTB2
+		if len(ortb2.Site) > 0 && req.Site != nil {
+			site := &openrtb.Site{}
+			if err := mergeFirstPartyData(req.Site, ortb2.Site, site); err != nil {
+				return err
+			}
+			req.Site = site
+		}
+		if len(ortb2.App) > 0 && req.App != nil {
+			app := &openrtb.App{}
+			if err := mergeFirstPartyData(req.App, ortb2.App, app); err != nil {
+				return err
+			}
+			req.App = app
+		}
+		if len(ortb2.User) > 0 {
+			original := req.User
+			if original == nil {
+				original = &openrtb.User{}
+			}
+			user := &openrtb.User{}
+			if err := mergeFirstPartyData(original, ortb2.User, user); err != nil {
+				return err
+			}
+			req.User = user
+		}
+	}
+
+	if len(req.Ext) > 0 {
+		// jsonparser.Delete works in-place, so make sure the shared ext doesn't get changed.
+		ext := append([]byte(nil), req.Ext...)
+		ext = jsonparser.Delete(ext, "prebid", "bidderconfig")
+		ext = jsonparser.Delete(ext, "prebid", "data", "eidpermissions")
+		req.Ext = jsonparser.Delete(ext, "prebid", "data", "bidders")
+	}
+	return nil
+}
+
+// mergeFirstPartyData applies patch to the JSON for original, like a JSON merge patch, and stores the result in merged.
+func mergeFirstPartyData(original interface{}, patch json.RawMessage, merged interface{}) error {
+	originalJSON, err := json.Marshal(original)
+	if err != nil {
+		return err
+	}
+	mergedJSON, err := jsonpatch.MergePatch(originalJSON, patch)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(mergedJSON, merged)
+}
+
+// bidderListed returns true if bidders contains the bidder by either name, or the "*" wildcard.
+func bidderListed(bidders []string, givenBidder string, coreBidder openrtb_ext.BidderName) bool {
+	for _, bidder := range bidders {
+		if bidder == "*" || bidder == givenBidder || bidder == string(coreBidder) {
+			return true
+		}
+	}
+	return false
+}
+
+// cleanFirstPartyData removes the site, app and user first party data from the request.
+// Any objects which get modified are copied first.
+func cleanFirstPartyData(req *openrtb.BidRequest) {
+	if req.Site != nil {
+		site := *req.Site
+		site.Keywords = ""
+		site.Ext = deleteExtData(site.Ext)
+		site.Content = cleanContentData(site.Content)
+		req.Site = &site
+	}
+	if req.App != nil {
+		app := *req.App
+		app.Keywords = ""
+		app.Ext = deleteExtData(app.Ext)
+		app.Content = cleanContentData(app.Content)
+		req.App = &app
+	}
+	if req.User != nil {
+		user := *req.User
+		user.Keywords = ""
+		user.Data = nil
+		user.Ext = deleteExtData(user.Ext)
+		req.User = &user
+	}
+}
+
+func cleanContentData(content *openrtb.Content) *openrtb.Content {
+	if content == nil || content.Data == nil {
+		return content
+	}
+	contentCopy := *content
+	contentCopy.Data = nil
+	return &contentCopy
+}
+
+func deleteExtData(ext json.RawMessage) json.RawMessage {
+	if len(ext) == 0 {
+		return ext
+	}
+	if _, dataType, _, _ := jsonparser.Get(ext, "data"); dataType == jsonparser.NotExist {
+		return ext
+	}
+	// jsonparser.Delete works in-place, so make sure the shared ext doesn't get changed.
+	return jsonparser.Delete(append([]byte(nil), ext...), "data")
+}
@@ -0,0 +1,121 @@
+package exchange
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/openrtb_ext"
+	"github.com/stretchr/testify/assert"
+)
+
+func newFirstPartyDataRequest(ext string) *openrtb.BidRequest {
+	return &openrtb.BidRequest{
+		Site: &openrtb.Site{
+			Page:     "www.some.domain.com",
+			Keywords: "site-keyword",
+			Ext:      json.RawMessage(`{"amp":0,"data":{"section":"sports"}}`),
+		},
+		User: &openrtb.User{
+			ID:       "our-id",
+			Keywords: "user-keyword",
+			Data:     []openrtb.Data{{ID: "some-data"}},
+			Ext:      json.RawMessage(`{"consent":"abc","data":{"interests":["cars"]}}`),
+		},
+		Ext: json.RawMessage(ext),
+	}
+}
+
+func TestExtractFirstPartyDataNone(t *testing.T) {
+	fpd, err := extractFirstPartyData(newFirstPartyDataRequest(`{"prebid":{"data":{"eidpermissions":[]}}}`))
+	assert.NoError(t, err)
+	assert.Nil(t, fpd)
+}
+
+func TestPrepareFirstPartyDataBidders(t *testing.T) {
+	req := newFirstPartyDataRequest(`{"prebid":{"data":{"bidders":["appnexus"]}}}`)
+	fpd, err := extractFirstPartyData(req)
+	assert.NoError(t, err)
+
+	allowed := *req
+	assert.NoError(t, prepareFirstPartyData(&allowed, "brightroll", openrtb_ext.BidderAppnexus, fpd))
+	assert.Equal(t, "site-keyword", allowed.Site.Keywords)
+	assert.Equal(t, "user-keyword", allowed.User.Keywords)
+	assert.Len(t, allowed.User.Data, 1)
+	assert.JSONEq(t, `{"amp":0,"data":{"section":"sports"}}`, string(allowed.Site.Ext))
+
+	denied := *req
+	assert.NoError(t, prepareFirstPartyData(&denied, "rubicon", openrtb_ext.BidderRubicon, fpd))
+	assert.Empty(t, denied.Site.Keywords)
+	assert.Empty(t, denied.User.Keywords)
+	assert.Nil(t, denied.User.Data)
+	assert.Equal(t, "our-id", denied.User.ID)
+	assert.JSONEq(t, `{"amp":0}`, string(denied.Site.Ext))
+	assert.JSONEq(t, `{"consent":"abc"}`, string(denied.User.Ext))
+	assert.JSONEq(t, `{"prebid":{"data":{}}}`, string(denied.Ext))
+
+	assert.Equal(t, "site-keyword", req.Site.Keywords, "The original request should not be modified")
+	assert.JSONEq(t, `{"consent":"abc","data":{"interests":["cars"]}}`, string(req.User.Ext), "The original request should not be modified")
+	assert.JSONEq(t, `{"prebid":{"data":{"bidders":["appnexus"]}}}`, string(req.Ext), "The original request should not be modified")
+}
+
+func TestPrepareFirstPartyDataBidderConfig(t *testing.T) {
+	req := newFirstPartyDataRequest(`{"prebid":{"data":{"bidders":["appnexus"]},"bidderconfig":[{"bidders":["rubicon"],"config":{"ortb2":{"site":{"keywords":"rubicon-keyword","ext":{"data":{"section":"news"}}},"user":{"yob":1980}}}}]}}`)
+	fpd, err := extractFirstPartyData(req)
+	assert.NoError(t, err)
+
+	rubicon := *req
+	assert.NoError(t, prepareFirstPartyData(&rubicon, "rubicon", openrtb_ext.BidderRubicon, fpd))
+	assert.Equal(t, "www.some.domain.com", rubicon.Site.Page)
+	assert.Equal(t, "rubicon-keyword", rubicon.Site.Keywords)
+	assert.JSONEq(t, `{"amp":0,"data":{"section":"news"}}`, string(rubicon.Site.Ext))
+	assert.Equal(t, "our-id", rubicon.User.ID)
+	assert.Equal(t, 1980, int(rubicon.User.Yob))
+	assert.JSONEq(t, `{"prebid":{"data":{}}}`, string(rubicon.Ext))
+
+	appnexus := *req
+	assert.NoError(t, prepareFirstPartyData(&appnexus, "appnexus", openrtb_ext.BidderAppnexus, fpd))
+	assert.Equal(t, "site-keyword", appnexus.Site.Keywords)
+	assert.Zero(t, appnexus.User.Yob)
+
+	assert.Equal(t, "site-keyword", req.Site.Keywords, "The original request should not be modified")
+	assert.Zero(t, req.User.Yob, "The original request should not be modified")
+}
+
+func TestPrepareFirstPartyDataRemovesEidPermissions(t *testing.T) {
+	req := newFirstPartyDataRequest(`{"prebid":{"data":{"bidders":["appnexus"],"eidpermissions":[{"source":"a.com","bidders":["rubicon"]}]}}}`)
+	fpd, err := extractFirstPartyData(req)
+	assert.NoError(t, err)
+
+	appnexus := *req
+	assert.NoError(t, prepareFirstPartyData(&appnexus, "appnexus", openrtb_ext.BidderAppnexus, fpd))
+	assert.JSONEq(t, `{"prebid":{"data":{}}}`, string(appnexus.Ext), "Bidders shouldn't see which other bidders get which eids")
+}
+
+func TestPrepareUserDataCantRestoreHiddenEids(t *testing.T) {
+	req := &openrtb.BidRequest{
+		User: &openrtb.User{Ext: json.RawMessage(`{"eids":[{"source":"a.com","uids":[{"id":"a-id"}]}]}`)},
+		Ext:  json.RawMessage(`{"prebid":{"data":{"eidpermissions":[{"source":"a.com","bidders":["rubicon"]}]},"bidderconfig":[{"bidders":["appnexus"],"config":{"ortb2":{"user":{"ext":{"eids":[{"source":"a.com","uids":[{"id":"a-id"}]}]}}}}}]}}`),
+	}
+	eids, err := extractUserEids(req)
+	assert.NoError(t, err)
+	fpd, err := extractFirstPartyData(req)
+	assert.NoError(t, err)
+
+	assert.NoError(t, prepareUserData(req, "appnexus", openrtb_ext.BidderAppnexus, eids, fpd))
+	assert.JSONEq(t, `{}`, string(req.User.Ext), "A bidderconfig shouldn't send the eids which the eidpermissions hide")
+	assert.JSONEq(t, `{"prebid":{"data":{}}}`, string(req.Ext))
+}
+
+func TestPrepareFirstPartyDataBidderConfigWithoutUser(t *testing.T) {
+	req := &openrtb.BidRequest{
+		Ext: json.RawMessage(`{"prebid":{"bidderconfig":[{"bidders":["*"],"config":{"ortb2":{"user":{"keywords":"k"}}}}]}}`),
+	}
+	fpd, err := extractFirstPartyData(req)
+	assert.NoError(t, err)
+	assert.NoError(t, prepareFirstPartyData(req, "appnexus", openrtb_ext.BidderAppnexus, fpd))
+	if assert.NotNil(t, req.User) {
+		assert.Equal(t, "k", req.User.Keywords)
+	}
+	assert.JSONEq(t, `{"prebid":{}}`, string(req.Ext))
+}
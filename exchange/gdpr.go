@@ -1,10 +1,18 @@
 package exchange
 
 import (
+	"context"
+	"strconv"
 	"strings"
 
+	"github.com/buger/jsonparser"
+	"github.com/golang/glog"
 	jsoniter "github.com/json-iterator/go"
 	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/gdpr"
+	"github.com/prebid/prebid-server/geolocation"
+	"github.com/prebid/prebid-server/gpp"
+	"github.com/prebid/prebid-server/privacy"
 )
 
 // ExtractGDPR will pull the gdpr flag from an openrtb request
@@ -14,6 +22,10 @@ func extractGDPR(bidRequest *openrtb.BidRequest, usersyncIfAmbiguous bool) (gdpr
 	if bidRequest.Regs != nil {
 		err = jsoniter.Unmarshal(bidRequest.Regs.Ext, &re)
 	}
+	if re.GDPR == nil && err == nil {
+		// Fall back to the GPP sections which apply, if the caller sent them.
+		re.GDPR = gpp.GDPRApplies(re.gppSIDs())
+	}
 	if re.GDPR == nil || err != nil {
 		if usersyncIfAmbiguous {
 			gdpr = 0
@@ -37,39 +49,150 @@ func extractConsent(bidRequest *openrtb.BidRequest) (consent string) {
 		return
 	}
 	consent = ue.Consent
+	if consent == "" {
+		consent = extractGPPConsent(bidRequest)
+	}
 	return
 }
 
+// extractGPPConsent pulls the TCF EU v2 section out of the GPP string, if there is one.
+func extractGPPConsent(bidRequest *openrtb.BidRequest) string {
+	if bidRequest.Regs == nil {
+		return ""
+	}
+	var re regsExt
+	if err := jsoniter.Unmarshal(bidRequest.Regs.Ext, &re); err != nil || re.GPP == "" {
+		return ""
+	}
+	parsed, err := gpp.Parse(re.GPP)
+	if err != nil {
+		return ""
+	}
+	tcf, _ := parsed.Section(gpp.SectionTCFEU2)
+	return tcf
+}
+
 type userExt struct {
 	Consent string `json:"consent,omitempty"`
 }
 
 type regsExt struct {
-	GDPR *int `json:"gdpr,omitempty"`
+	GDPR   *int   `json:"gdpr,omitempty"`
+	GPP    string `json:"gpp,omitempty"`
+	GPPSID []int  `json:"gpp_sid,omitempty"`
 }
 
-// cleanPI removes IP address last byte, device ID, buyer ID, and rounds off latitude/longitude
-func cleanPI(bidRequest *openrtb.BidRequest, isAMP bool) {
-	if bidRequest.User != nil {
-		// Need to duplicate pointer objects
-		user := *bidRequest.User
-		bidRequest.User = &user
-		if isAMP == false {
-			bidRequest.User.BuyerUID = ""
+func (re *regsExt) gppSIDs() []gpp.SectionID {
+	sids := make([]gpp.SectionID, len(re.GPPSID))
+	for i, sid := range re.GPPSID {
+		sids[i] = gpp.SectionID(sid)
+	}
+	return sids
+}
+
+// applyGeoLocation finds the country of the device. If the request doesn't have a device.geo.country, it looks up
+// the country of the device's IP address and fills it in. If the request doesn't say whether GDPR applies,
+// regs.ext.gdpr is set from the country. If the country isn't known, it is set to 1 when failClosed is true,
+// and otherwise left for extractGDPR to resolve.
+//
+// The request's Device and Regs are replaced with copies, so that shared objects don't get changed.
+func applyGeoLocation(bidRequest *openrtb.BidRequest, resolver *geolocation.Resolver, failClosed bool) {
+	if resolver == nil || bidRequest.Device == nil {
+		return
+	}
+	country := ""
+	if bidRequest.Device.Geo != nil {
+		country = strings.ToUpper(bidRequest.Device.Geo.Country)
+	}
+	if country == "" {
+		country = lookupCountry(bidRequest, resolver)
+	}
+
+	if gdprDefined(bidRequest) {
+		return
+	}
+	if country != "" {
+		if geolocation.GDPRApplies(country) {
+			setRegsGDPR(bidRequest, 1)
+		} else {
+			setRegsGDPR(bidRequest, 0)
 		}
-		bidRequest.User.Geo = cleanGeo(bidRequest.User.Geo)
+	} else if failClosed {
+		setRegsGDPR(bidRequest, 1)
 	}
-	if bidRequest.Device != nil {
-		// Need to duplicate pointer objects
+}
+
+// lookupCountry looks up the country of the device's IP address, and sets device.geo.country to it if it's known.
+func lookupCountry(bidRequest *openrtb.BidRequest, resolver *geolocation.Resolver) string {
+	ip := bidRequest.Device.IP
+	if ip == "" {
+		ip = bidRequest.Device.IPv6
+	}
+	if ip == "" {
+		return ""
+	}
+
+	country, err := resolver.Country(ip)
+	if err != nil {
+		glog.Warningf("geolocation lookup failed for %s: %v", ip, err)
+	}
+	if country != "" {
 		device := *bidRequest.Device
+		geo := openrtb.Geo{}
+		if device.Geo != nil {
+			geo = *device.Geo
+		}
+		geo.Country = country
+		device.Geo = &geo
 		bidRequest.Device = &device
-		bidRequest.Device.DIDMD5 = ""
-		bidRequest.Device.DIDSHA1 = ""
-		bidRequest.Device.DPIDMD5 = ""
-		bidRequest.Device.DPIDSHA1 = ""
-		bidRequest.Device.IP = cleanIP(bidRequest.Device.IP)
-		bidRequest.Device.IPv6 = cleanIPv6(bidRequest.Device.IPv6)
-		bidRequest.Device.Geo = cleanGeo(bidRequest.Device.Geo)
+	}
+	return country
+}
+
+// gdprDefined is true if the request says whether GDPR applies, through regs.ext.gdpr or the GPP section IDs.
+// Invalid regs.ext counts as defined, so that it isn't overwritten.
+func gdprDefined(bidRequest *openrtb.BidRequest) bool {
+	if bidRequest.Regs == nil || len(bidRequest.Regs.Ext) == 0 {
+		return false
+	}
+	var re regsExt
+	if err := jsoniter.Unmarshal(bidRequest.Regs.Ext, &re); err != nil {
+		return true
+	}
+	return re.GDPR != nil || gpp.GDPRApplies(re.gppSIDs()) != nil
+}
+
+func setRegsGDPR(bidRequest *openrtb.BidRequest, gdpr int) {
+	regs := openrtb.Regs{}
+	if bidRequest.Regs != nil {
+		regs = *bidRequest.Regs
+	}
+	value := []byte(strconv.Itoa(gdpr))
+	if len(regs.Ext) == 0 {
+		regs.Ext = []byte(`{"gdpr":` + string(value) + `}`)
+	} else {
+		ext := make([]byte, len(regs.Ext))
+		copy(ext, regs.Ext)
+		if newExt, err := jsonparser.Set(ext, value, "gdpr"); err == nil {
+			regs.Ext = newExt
+		}
+	}
+	bidRequest.Regs = &regs
+}
+
+// gdprPersonalInfoRule is the default rule for the activities which send the user's personal info to bidders,
+// when GDPR applies. Bidders whose vendors don't have consent are denied. Aliases use their core bidder's consent,
+// which fixes #820. If the consent can't be checked, the bidder is allowed, as it always has been.
+func gdprPersonalInfoRule(ctx context.Context, perms gdpr.Permissions, consent string, aliases map[string]string) privacy.DefaultRule {
+	// The rule decides more than one activity for each bidder, so the answers are kept.
+	allowed := make(map[string]bool)
+	return func(component privacy.Component) bool {
+		if ok, decided := allowed[component.Name]; decided {
+			return ok
+		}
+		ok, err := perms.PersonalInfoAllowed(ctx, ResolveBidder(component.Name, aliases), consent)
+		allowed[component.Name] = ok || err != nil
+		return allowed[component.Name]
 	}
 }
 
@@ -1,10 +1,17 @@
 package exchange
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"net"
 	"testing"
 
 	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/geolocation"
+	"github.com/prebid/prebid-server/openrtb_ext"
+	metricsConf "github.com/prebid/prebid-server/pbsmetrics/config"
+	"github.com/prebid/prebid-server/privacy"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -43,12 +50,163 @@ func TestGDPRUnknown(t *testing.T) {
 
 }
 
+func TestExtractGDPRFromGPP(t *testing.T) {
+	gdprTest := openrtb.BidRequest{
+		Regs: &openrtb.Regs{
+			Ext: json.RawMessage(`{"gpp": "DBACNYA~CPXxRfAPXxRfAAfKABENB-CgAAAAAAAAAAYgAAAAAAAA~1YNN", "gpp_sid": [2, 6]}`),
+		},
+	}
+	assert.Equal(t, 1, extractGDPR(&gdprTest, true))
+	assert.Equal(t, "CPXxRfAPXxRfAAfKABENB-CgAAAAAAAAAAYgAAAAAAAA", extractConsent(&gdprTest))
+
+	gdprTest.Regs.Ext = json.RawMessage(`{"gpp": "DBABTA~1YNN", "gpp_sid": [6]}`)
+	assert.Equal(t, 0, extractGDPR(&gdprTest, false))
+	assert.Equal(t, "", extractConsent(&gdprTest))
+
+	// An explicit regs.ext.gdpr and user.ext.consent take priority over GPP
+	gdprTest.Regs.Ext = json.RawMessage(`{"gdpr": 0, "gpp": "DBABMA~CPXxRfAPXxRfAAfKABENB-CgAAAAAAAAAAYgAAAAAAAA", "gpp_sid": [2]}`)
+	gdprTest.User = &openrtb.User{
+		Ext: json.RawMessage(`{"consent": "BOS2bx5OS2bx5ABABBAAABoAAAAAFA"}`),
+	}
+	assert.Equal(t, 0, extractGDPR(&gdprTest, false))
+	assert.Equal(t, "BOS2bx5OS2bx5ABABBAAABoAAAAAFA", extractConsent(&gdprTest))
+}
+
+type mockCountryLookup map[string]string
+
+func (m mockCountryLookup) Country(ip net.IP) (string, error) {
+	return m[ip.String()], nil
+}
+
+func newTestGeoResolver() *geolocation.Resolver {
+	lookup := mockCountryLookup{"1.2.3.4": "DE", "2001:db8::1": "FR", "8.8.8.8": "US"}
+	return geolocation.NewResolver(lookup, 0, 0, &metricsConf.DummyMetricsEngine{})
+}
+
+func TestApplyGeoLocation(t *testing.T) {
+	testCases := []struct {
+		description     string
+		device          *openrtb.Device
+		regs            *openrtb.Regs
+		failClosed      bool
+		expectedCountry string
+		expectedRegsExt string
+	}{
+		{
+			description:     "EEA IP without regs",
+			device:          &openrtb.Device{IP: "1.2.3.4"},
+			expectedCountry: "DEU",
+			expectedRegsExt: `{"gdpr":1}`,
+		},
+		{
+			description:     "EEA IPv6 with other regs.ext fields",
+			device:          &openrtb.Device{IPv6: "2001:db8::1"},
+			regs:            &openrtb.Regs{Ext: json.RawMessage(`{"us_privacy":"1---"}`)},
+			expectedCountry: "FRA",
+			expectedRegsExt: `{"us_privacy":"1---","gdpr":1}`,
+		},
+		{
+			description:     "Non-EEA IP",
+			device:          &openrtb.Device{IP: "8.8.8.8"},
+			expectedCountry: "USA",
+			expectedRegsExt: `{"gdpr":0}`,
+		},
+		{
+			description:     "The request's gdpr signal wins",
+			device:          &openrtb.Device{IP: "1.2.3.4"},
+			regs:            &openrtb.Regs{Ext: json.RawMessage(`{"gdpr":0}`)},
+			expectedCountry: "DEU",
+			expectedRegsExt: `{"gdpr":0}`,
+		},
+		{
+			description:     "The request's device country is used instead of the IP's",
+			device:          &openrtb.Device{IP: "1.2.3.4", Geo: &openrtb.Geo{Country: "USA"}},
+			expectedCountry: "USA",
+			expectedRegsExt: `{"gdpr":0}`,
+		},
+		{
+			description:     "The request's device country doesn't need an IP",
+			device:          &openrtb.Device{Geo: &openrtb.Geo{Country: "deu"}},
+			expectedCountry: "deu",
+			expectedRegsExt: `{"gdpr":1}`,
+		},
+		{
+			description: "Unknown IP fails open",
+			device:      &openrtb.Device{IP: "9.9.9.9"},
+		},
+		{
+			description:     "Unknown IP fails closed",
+			device:          &openrtb.Device{IP: "9.9.9.9"},
+			failClosed:      true,
+			expectedRegsExt: `{"gdpr":1}`,
+		},
+	}
+
+	resolver := newTestGeoResolver()
+	for _, test := range testCases {
+		originalDevice := *test.device
+		bidRequest := &openrtb.BidRequest{Device: test.device, Regs: test.regs}
+		applyGeoLocation(bidRequest, resolver, test.failClosed)
+
+		country := ""
+		if bidRequest.Device.Geo != nil {
+			country = bidRequest.Device.Geo.Country
+		}
+		assert.Equal(t, test.expectedCountry, country, test.description)
+		if test.expectedRegsExt == "" {
+			assert.Nil(t, bidRequest.Regs, test.description)
+		} else {
+			assert.JSONEq(t, test.expectedRegsExt, string(bidRequest.Regs.Ext), test.description)
+		}
+		assert.Equal(t, originalDevice, *test.device, test.description+": the original device should not change")
+	}
+}
+
+func TestApplyGeoLocationSkipsKnownCountries(t *testing.T) {
+	lookup := &countingCountryLookup{}
+	resolver := geolocation.NewResolver(lookup, 0, 0, &metricsConf.DummyMetricsEngine{})
+	applyGeoLocation(&openrtb.BidRequest{Device: &openrtb.Device{IP: "1.2.3.4", Geo: &openrtb.Geo{Country: "FRA"}}}, resolver, false)
+	assert.Equal(t, 0, lookup.calls, "Requests with a device country shouldn't be looked up")
+
+	applyGeoLocation(&openrtb.BidRequest{Device: &openrtb.Device{IP: "1.2.3.4"}}, resolver, false)
+	assert.Equal(t, 1, lookup.calls)
+}
+
+type countingCountryLookup struct {
+	calls int
+}
+
+func (l *countingCountryLookup) Country(ip net.IP) (string, error) {
+	l.calls++
+	return "", nil
+}
+
+func TestApplyGeoLocationDisabled(t *testing.T) {
+	bidRequest := &openrtb.BidRequest{Device: &openrtb.Device{IP: "1.2.3.4"}}
+	applyGeoLocation(bidRequest, nil, true)
+	assert.Nil(t, bidRequest.Device.Geo)
+	assert.Nil(t, bidRequest.Regs)
+}
+
+func TestGDPRPersonalInfoRule(t *testing.T) {
+	rule := gdprPersonalInfoRule(context.Background(), &permissionsMock{}, "some-consent", map[string]string{"brightroll": "appnexus"})
+	assert.True(t, rule(privacy.Component{Type: privacy.ComponentTypeBidder, Name: "appnexus"}))
+	assert.True(t, rule(privacy.Component{Type: privacy.ComponentTypeBidder, Name: "brightroll"}), "Aliases should use their core bidder's consent")
+	assert.False(t, rule(privacy.Component{Type: privacy.ComponentTypeBidder, Name: "rubicon"}))
+
+	rule = gdprPersonalInfoRule(context.Background(), &failingPermissions{}, "some-consent", nil)
+	assert.True(t, rule(privacy.Component{Type: privacy.ComponentTypeBidder, Name: "rubicon"}), "Bidders should be allowed if the consent can't be checked")
+}
+
+// TestCleanPI checks that the scrubbing for a GDPR denial removes the IP address last byte, the device IDs
+// and the buyer ID, and rounds off latitude/longitude.
 func TestCleanPI(t *testing.T) {
 	bidReqOrig := openrtb.BidRequest{}
 
 	bidReqCopy := bidReqOrig
-	// Make sure cleanIP handles the empty case
-	cleanPI(&bidReqCopy, false)
+	// Make sure the cleaning handles the empty case
+	cleanUserFPD(&bidReqCopy)
+	cleanPreciseGeo(&bidReqCopy)
 
 	// Add values to clean
 	bidReqOrig.User = &openrtb.User{
@@ -66,7 +224,8 @@ func TestCleanPI(t *testing.T) {
 	// Make a shallow copy
 	bidReqCopy = bidReqOrig
 
-	cleanPI(&bidReqCopy, false)
+	cleanUserFPD(&bidReqCopy)
+	cleanPreciseGeo(&bidReqCopy)
 
 	// Verify cleaned values
 	assertStringEmpty(t, bidReqCopy.User.BuyerUID)
@@ -83,41 +242,15 @@ func TestCleanPI(t *testing.T) {
 	assert.Equal(t, "2001:0db8:85a3:0000:0000:8a2e:0370:7334", bidReqOrig.Device.IPv6)
 	assert.Equal(t, 123.4567, bidReqOrig.Device.Geo.Lat)
 	assert.Equal(t, 7.9836, bidReqOrig.Device.Geo.Lon)
-
 }
 
-func TestCleanPIAmp(t *testing.T) {
-	bidReqOrig := openrtb.BidRequest{}
-
-	bidReqCopy := bidReqOrig
-	// Make sure cleanIP handles the empty case
-	cleanPI(&bidReqCopy, false)
-
-	// Add values to clean
-	bidReqOrig.User = &openrtb.User{
-		BuyerUID: "abc123",
-	}
-	bidReqOrig.Device = &openrtb.Device{
-		DIDMD5: "teapot",
-		IP:     "12.123.56.128",
-		IPv6:   "2001:0db8:85a3:0000:0000:8a2e:0370:7334",
-		Geo: &openrtb.Geo{
-			Lat: 123.4567,
-			Lon: 7.9836,
-		},
-	}
-	// Make a shallow copy
-	bidReqCopy = bidReqOrig
-
-	cleanPI(&bidReqCopy, true)
+// failingPermissions can't check any consent strings.
+type failingPermissions struct {
+	permissionsMock
+}
 
-	// Verify cleaned values
-	assert.Equal(t, "abc123", bidReqCopy.User.BuyerUID)
-	assertStringEmpty(t, bidReqCopy.Device.DIDMD5)
-	assert.Equal(t, "12.123.56.0", bidReqCopy.Device.IP)
-	assert.Equal(t, "2001:0db8:85a3:0000:0000:8a2e:0370:0000", bidReqCopy.Device.IPv6)
-	assert.Equal(t, 123.46, bidReqCopy.Device.Geo.Lat)
-	assert.Equal(t, 7.98, bidReqCopy.Device.Geo.Lon)
+func (p *failingPermissions) PersonalInfoAllowed(ctx context.Context, bidder openrtb_ext.BidderName, consent string) (bool, error) {
+	return false, errors.New("no vendor list")
 }
 
 func assertStringEmpty(t *testing.T, str string) {
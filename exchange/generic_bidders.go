@@ -0,0 +1,55 @@
+package exchange
+
+import (
+	"sort"
+
+	"github.com/prebid/prebid-server/adapters"
+	"github.com/prebid/prebid-server/adapters/generic"
+	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/openrtb_ext"
+)
+
+// GenericBidders returns the bidders which the host defines in the adapters.{bidder}.generic section of its config,
+// sorted by name. They aren't in the openrtb_ext.BidderMap, so Prebid Server's setup has to pass them along
+// to everything which needs to know about them.
+func GenericBidders(cfg map[string]config.Adapter) []openrtb_ext.BidderName {
+	var bidders []openrtb_ext.BidderName
+	for name, adapter := range cfg {
+		if adapter.Generic != nil {
+			bidders = append(bidders, openrtb_ext.BidderName(name))
+		}
+	}
+	sort.Slice(bidders, func(i, j int) bool {
+		return bidders[i] < bidders[j]
+	})
+	return bidders
+}
+
+// NewGenericBidderInfo makes the bidder info which the static/bidder-info/{bidder}.yaml file holds for other bidders.
+func NewGenericBidderInfo(cfg *config.GenericAdapter) adapters.BidderInfo {
+	info := adapters.BidderInfo{
+		Maintainer: &adapters.MaintainerInfo{
+			Email: cfg.MaintainerEmail,
+		},
+		Capabilities: &adapters.CapabilitiesInfo{},
+	}
+	if len(cfg.AppMediaTypes) > 0 {
+		info.Capabilities.App = &adapters.PlatformInfo{MediaTypes: parseMediaTypes(cfg.AppMediaTypes)}
+	}
+	if len(cfg.SiteMediaTypes) > 0 {
+		info.Capabilities.Site = &adapters.PlatformInfo{MediaTypes: parseMediaTypes(cfg.SiteMediaTypes)}
+	}
+	return info
+}
+
+// newGenericBidder makes the Bidder for a generic bidder, from the adapters.{bidder} section of the host's config.
+func newGenericBidder(name string, cfg *config.Adapter, region string) adapters.Bidder {
+	return generic.NewGenericBidder(name, cfg.Endpoint, region, generic.Options{
+		Headers:         cfg.Generic.Headers,
+		ParamsPlacement: cfg.Generic.ParamsPlacement,
+		BidTypeExtField: cfg.Generic.BidTypeExtField,
+		DefaultBidType:  openrtb_ext.BidType(cfg.Generic.DefaultBidType),
+		Currency:        cfg.Generic.Currency,
+		TimeoutEndpoint: cfg.Generic.TimeoutEndpoint,
+	})
+}
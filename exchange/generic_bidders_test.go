@@ -0,0 +1,32 @@
+package exchange
+
+import (
+	"testing"
+
+	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/openrtb_ext"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenericBidders(t *testing.T) {
+	bidders := GenericBidders(map[string]config.Adapter{
+		"appnexus":   {Endpoint: "http://ib.adnxs.com/openrtb2"},
+		"somessp":    {Endpoint: "http://some-ssp.com/bid", Generic: &config.GenericAdapter{}},
+		"anotherssp": {Endpoint: "http://another-ssp.com/bid", Generic: &config.GenericAdapter{}},
+	})
+	assert.Equal(t, []openrtb_ext.BidderName{"anotherssp", "somessp"}, bidders)
+	_, inBidderMap := openrtb_ext.BidderMap["somessp"]
+	assert.False(t, inBidderMap, "generic bidders shouldn't be added to the BidderMap")
+}
+
+func TestNewGenericBidderInfo(t *testing.T) {
+	info := NewGenericBidderInfo(&config.GenericAdapter{
+		MaintainerEmail: "someone@some-ssp.com",
+		SiteMediaTypes:  []string{"banner", "video"},
+	})
+	assert.Equal(t, "someone@some-ssp.com", info.Maintainer.Email)
+	assert.Nil(t, info.Capabilities.App)
+	if assert.NotNil(t, info.Capabilities.Site) {
+		assert.Equal(t, []openrtb_ext.BidType{openrtb_ext.BidTypeBanner, openrtb_ext.BidTypeVideo}, info.Capabilities.Site.MediaTypes)
+	}
+}
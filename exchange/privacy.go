@@ -0,0 +1,76 @@
+package exchange
+
+import (
+	"github.com/buger/jsonparser"
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/privacy"
+)
+
+// enforceActivities scrubs the data from a bidder's request which the activity controls don't allow it to see.
+// The request must already be a copy made for this bidder. Any objects which get modified are copied first.
+func enforceActivities(bidRequest *openrtb.BidRequest, bidder privacy.Component, activityControl privacy.ActivityControl, activityRequest privacy.ActivityRequest) {
+	if !activityControl.Allow(privacy.ActivityTransmitUserFPD, bidder, activityRequest) {
+		cleanUserFPD(bidRequest)
+	}
+	if !activityControl.Allow(privacy.ActivityTransmitPreciseGeo, bidder, activityRequest) {
+		cleanPreciseGeo(bidRequest)
+	}
+	if !activityControl.Allow(privacy.ActivityTransmitEIDs, bidder, activityRequest) {
+		cleanEIDs(bidRequest)
+	}
+}
+
+// cleanUserFPD removes the user's first party data, buyer ID and device IDs
+func cleanUserFPD(bidRequest *openrtb.BidRequest) {
+	if bidRequest.User != nil {
+		user := *bidRequest.User
+		user.ID = ""
+		user.BuyerUID = ""
+		user.Yob = 0
+		user.Gender = ""
+		user.Keywords = ""
+		user.Data = nil
+		bidRequest.User = &user
+	}
+	if bidRequest.Device != nil {
+		device := *bidRequest.Device
+		device.IFA = ""
+		device.DIDMD5 = ""
+		device.DIDSHA1 = ""
+		device.DPIDMD5 = ""
+		device.DPIDSHA1 = ""
+		device.MACMD5 = ""
+		device.MACSHA1 = ""
+		bidRequest.Device = &device
+	}
+}
+
+// cleanPreciseGeo truncates the IP addresses and rounds off latitude/longitude
+func cleanPreciseGeo(bidRequest *openrtb.BidRequest) {
+	if bidRequest.User != nil {
+		user := *bidRequest.User
+		user.Geo = cleanGeo(user.Geo)
+		bidRequest.User = &user
+	}
+	if bidRequest.Device != nil {
+		device := *bidRequest.Device
+		device.IP = cleanIP(device.IP)
+		device.IPv6 = cleanIPv6(device.IPv6)
+		device.Geo = cleanGeo(device.Geo)
+		bidRequest.Device = &device
+	}
+}
+
+// cleanEIDs removes the extended IDs from user.ext
+func cleanEIDs(bidRequest *openrtb.BidRequest) {
+	if bidRequest.User == nil || len(bidRequest.User.Ext) == 0 {
+		return
+	}
+	if _, dataType, _, _ := jsonparser.Get(bidRequest.User.Ext, "eids"); dataType == jsonparser.NotExist {
+		return
+	}
+	user := *bidRequest.User
+	// jsonparser.Delete works in-place, so make sure the shared ext doesn't get changed.
+	user.Ext = jsonparser.Delete(append([]byte(nil), user.Ext...), "eids")
+	bidRequest.User = &user
+}
@@ -0,0 +1,62 @@
+package exchange
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/privacy"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnforceActivities(t *testing.T) {
+	deny := false
+	activityControl := privacy.NewActivityControl(&config.Privacy{
+		Activities: config.Activities{
+			TransmitUserFPD:    config.Activity{Default: &deny},
+			TransmitPreciseGeo: config.Activity{Default: &deny},
+			TransmitEIDs:       config.Activity{Default: &deny},
+		},
+	}, "")
+
+	orig := &openrtb.BidRequest{
+		User: &openrtb.User{
+			ID:       "our-id",
+			BuyerUID: "their-id",
+			Yob:      1980,
+			Geo:      &openrtb.Geo{Lat: 123.456789, Lon: 12.3456789},
+			Ext:      json.RawMessage(`{"consent":"some-consent","eids":[{"source":"adserver.org"}]}`),
+		},
+		Device: &openrtb.Device{
+			IFA:  "ifa",
+			IP:   "132.173.230.74",
+			IPv6: "2001:0db8:85a3:0000:0000:8a2e:0370:7334",
+		},
+	}
+	bidReq := *orig
+	enforceActivities(&bidReq, privacy.Component{Type: privacy.ComponentTypeBidder, Name: "appnexus"}, activityControl, privacy.ActivityRequest{})
+
+	assert.Empty(t, bidReq.User.ID)
+	assert.Empty(t, bidReq.User.BuyerUID)
+	assert.Zero(t, bidReq.User.Yob)
+	assert.Equal(t, 123.46, bidReq.User.Geo.Lat)
+	assert.JSONEq(t, `{"consent":"some-consent"}`, string(bidReq.User.Ext))
+	assert.Empty(t, bidReq.Device.IFA)
+	assert.Equal(t, "132.173.230.0", bidReq.Device.IP)
+	assert.Equal(t, "2001:0db8:85a3:0000:0000:8a2e:0370:0000", bidReq.Device.IPv6)
+
+	assert.Equal(t, "our-id", orig.User.ID, "The original user should not be modified")
+	assert.Equal(t, "ifa", orig.Device.IFA, "The original device should not be modified")
+	assert.JSONEq(t, `{"consent":"some-consent","eids":[{"source":"adserver.org"}]}`, string(orig.User.Ext))
+}
+
+func TestEnforceActivitiesAllowed(t *testing.T) {
+	bidReq := &openrtb.BidRequest{
+		User:   &openrtb.User{ID: "our-id"},
+		Device: &openrtb.Device{IP: "132.173.230.74"},
+	}
+	enforceActivities(bidReq, privacy.Component{Type: privacy.ComponentTypeBidder, Name: "appnexus"}, privacy.ActivityControl{}, privacy.ActivityRequest{})
+	assert.Equal(t, "our-id", bidReq.User.ID)
+	assert.Equal(t, "132.173.230.74", bidReq.Device.IP)
+}
@@ -0,0 +1,204 @@
+package exchange
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/buger/jsonparser"
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/errortypes"
+	"github.com/prebid/prebid-server/openrtb_ext"
+)
+
+// schainVersion is the version of the IAB SupplyChain object which Prebid Server creates.
+const schainVersion = "1.0"
+
+// prepareSChains sets source.ext.schain on each bidder's request.
+//
+// Bidders get the schain from request.ext.prebid.schains which names them, or else the one for "*", or else the
+// request's own source.ext.schain. Invalid schains are ignored, and returned as warnings. An invalid source.ext.schain
+// is removed from every bidder's request. If the host configured an schain.asi, a node for Prebid Server is added to
+// the end of each bidder's schain, with the account as the seller.
+//
+// request.ext.prebid.schains is removed from the bidders' requests, so that they can't see what the others were sent.
+// The objects nested inside requestsByBidder are copied before they're modified.
+func prepareSChains(orig *openrtb.BidRequest, requestsByBidder map[openrtb_ext.BidderName]*openrtb.BidRequest, aliases map[string]string, hostASI string, accountID string) []error {
+	schains, errs := extractSChains(orig)
+	sourceSChain, sourceErr := extractSourceSChain(orig)
+	if sourceErr != nil {
+		errs = append(errs, sourceErr)
+	}
+	_, extDataType, _, _ := jsonparser.Get(orig.Ext, "prebid", "schains")
+	if extDataType == jsonparser.NotExist && sourceErr == nil && (hostASI == "" || accountID == "") {
+		return errs
+	}
+
+	var hostNode *openrtb_ext.ExtRequestPrebidSChainSChainNode
+	if hostASI != "" && accountID != "" {
+		hp := 1
+		hostNode = &openrtb_ext.ExtRequestPrebidSChainSChainNode{
+			ASI: hostASI,
+			SID: accountID,
+			HP:  &hp,
+		}
+	}
+
+	for bidder, bidReq := range requestsByBidder {
+		if extDataType != jsonparser.NotExist {
+			// jsonparser.Delete works in-place, so make sure the shared ext doesn't get changed.
+			bidReq.Ext = jsonparser.Delete(append([]byte(nil), bidReq.Ext...), "prebid", "schains")
+		}
+
+		schain, ok := schains[bidder.String()]
+		if !ok {
+			schain, ok = schains[string(ResolveBidder(bidder.String(), aliases))]
+		}
+		if !ok {
+			schain, ok = schains["*"]
+		}
+		if !ok {
+			schain = sourceSChain
+		}
+		if schain == nil && hostNode == nil {
+			if sourceErr != nil {
+				removeSChain(bidReq)
+			}
+			continue
+		}
+		if schain == nil {
+			schain = &openrtb_ext.ExtRequestPrebidSChainSChain{Ver: schainVersion}
+		}
+		if err := setSChain(bidReq, schain, hostNode); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// extractSChains returns the valid request.ext.prebid.schains, keyed by the bidders which they're for.
+func extractSChains(orig *openrtb.BidRequest) (map[string]*openrtb_ext.ExtRequestPrebidSChainSChain, []error) {
+	schainsJSON, dataType, _, err := jsonparser.Get(orig.Ext, "prebid", "schains")
+	if err != nil || dataType != jsonparser.Array {
+		return nil, nil
+	}
+	var schains []*openrtb_ext.ExtRequestPrebidSChain
+	if err := json.Unmarshal(schainsJSON, &schains); err != nil {
+		return nil, []error{&errortypes.Warning{Message: fmt.Sprintf("request.ext.prebid.schains is invalid and was ignored: %v", err)}}
+	}
+
+	var errs []error
+	byBidder := make(map[string]*openrtb_ext.ExtRequestPrebidSChainSChain)
+	for index, schain := range schains {
+		if schain == nil {
+			continue
+		}
+		if err := validateSChain(&schain.SChain); err != nil {
+			errs = append(errs, &errortypes.Warning{Message: fmt.Sprintf("request.ext.prebid.schains[%d].schain is invalid and was ignored: %v", index, err)})
+			continue
+		}
+		if len(schain.Bidders) == 0 {
+			errs = append(errs, &errortypes.Warning{Message: fmt.Sprintf("request.ext.prebid.schains[%d] has no bidders and was ignored", index)})
+			continue
+		}
+		for _, bidder := range schain.Bidders {
+			if _, ok := byBidder[bidder]; ok {
+				errs = append(errs, &errortypes.Warning{Message: fmt.Sprintf("request.ext.prebid.schains has more than one schain for bidder %s. Only the first one was used.", bidder)})
+				continue
+			}
+			byBidder[bidder] = &schain.SChain
+		}
+	}
+	return byBidder, errs
+}
+
+// extractSourceSChain returns the request's own source.ext.schain, or nil if it doesn't have one.
+// If the request's schain is invalid, it returns a warning instead.
+func extractSourceSChain(orig *openrtb.BidRequest) (*openrtb_ext.ExtRequestPrebidSChainSChain, error) {
+	if orig.Source == nil {
+		return nil, nil
+	}
+	schainJSON, dataType, _, err := jsonparser.Get(orig.Source.Ext, "schain")
+	if err != nil || dataType == jsonparser.Null {
+		return nil, nil
+	}
+	var schain openrtb_ext.ExtRequestPrebidSChainSChain
+	if dataType != jsonparser.Object {
+		err = errors.New("schain must be an object")
+	} else if err = json.Unmarshal(schainJSON, &schain); err == nil {
+		err = validateSChain(&schain)
+	}
+	if err != nil {
+		return nil, &errortypes.Warning{Message: fmt.Sprintf("request.source.ext.schain is invalid and was removed: %v", err)}
+	}
+	return &schain, nil
+}
+
+func validateSChain(schain *openrtb_ext.ExtRequestPrebidSChainSChain) error {
+	if schain.Ver == "" {
+		return errors.New("missing required field: \"ver\"")
+	}
+	if schain.Complete != 0 && schain.Complete != 1 {
+		return fmt.Errorf("complete must be 0 or 1. Got %d", schain.Complete)
+	}
+	if len(schain.Nodes) == 0 {
+		return errors.New("nodes must contain at least one element")
+	}
+	for index, node := range schain.Nodes {
+		if node == nil {
+			return fmt.Errorf("nodes[%d] must be an object", index)
+		}
+		if node.ASI == "" {
+			return fmt.Errorf("nodes[%d] missing required field: \"asi\"", index)
+		}
+		if node.SID == "" {
+			return fmt.Errorf("nodes[%d] missing required field: \"sid\"", index)
+		}
+		if node.HP == nil {
+			return fmt.Errorf("nodes[%d] missing required field: \"hp\"", index)
+		}
+		if *node.HP != 0 && *node.HP != 1 {
+			return fmt.Errorf("nodes[%d].hp must be 0 or 1. Got %d", index, *node.HP)
+		}
+	}
+	return nil
+}
+
+// setSChain puts the schain into the bidder's source.ext.schain, with the host's node added to the end if it isn't nil.
+func setSChain(bidReq *openrtb.BidRequest, schain *openrtb_ext.ExtRequestPrebidSChainSChain, hostNode *openrtb_ext.ExtRequestPrebidSChainSChainNode) error {
+	schainCopy := *schain
+	if hostNode != nil {
+		schainCopy.Nodes = make([]*openrtb_ext.ExtRequestPrebidSChainSChainNode, 0, len(schain.Nodes)+1)
+		schainCopy.Nodes = append(append(schainCopy.Nodes, schain.Nodes...), hostNode)
+	}
+	schainJSON, err := json.Marshal(schainCopy)
+	if err != nil {
+		return err
+	}
+
+	var source openrtb.Source
+	if bidReq.Source != nil {
+		source = *bidReq.Source
+	}
+	sourceExt := []byte("{}")
+	if len(source.Ext) > 0 {
+		// jsonparser.Set works in-place, so make sure the shared ext doesn't get changed.
+		sourceExt = append([]byte(nil), source.Ext...)
+	}
+	if source.Ext, err = jsonparser.Set(sourceExt, schainJSON, "schain"); err != nil {
+		return err
+	}
+	bidReq.Source = &source
+	return nil
+}
+
+// removeSChain deletes source.ext.schain from the bidder's request.
+func removeSChain(bidReq *openrtb.BidRequest) {
+	if bidReq.Source == nil || len(bidReq.Source.Ext) == 0 {
+		return
+	}
+	source := *bidReq.Source
+	// jsonparser.Delete works in-place, so make sure the shared ext doesn't get changed.
+	source.Ext = jsonparser.Delete(append([]byte(nil), source.Ext...), "schain")
+	bidReq.Source = &source
+}
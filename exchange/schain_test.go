@@ -0,0 +1,152 @@
+package exchange
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/errortypes"
+	"github.com/prebid/prebid-server/openrtb_ext"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	appnexusSChain = `{"complete":1,"nodes":[{"asi":"appnexus-seller.com","sid":"1","hp":1}],"ver":"1.0"}`
+	defaultSChain  = `{"complete":1,"nodes":[{"asi":"everyone-seller.com","sid":"2","hp":1}],"ver":"1.0"}`
+	requestSChain  = `{"complete":0,"nodes":[{"asi":"source-seller.com","sid":"3","hp":1}],"ver":"1.0"}`
+)
+
+func newSChainRequests(orig *openrtb.BidRequest) map[openrtb_ext.BidderName]*openrtb.BidRequest {
+	requests := make(map[openrtb_ext.BidderName]*openrtb.BidRequest)
+	for _, bidder := range []openrtb_ext.BidderName{"appnexus", "brightroll", "rubicon"} {
+		reqCopy := *orig
+		requests[bidder] = &reqCopy
+	}
+	return requests
+}
+
+func schainOf(t *testing.T, bidReq *openrtb.BidRequest) string {
+	t.Helper()
+	if bidReq.Source == nil {
+		return ""
+	}
+	var sourceExt map[string]json.RawMessage
+	if err := json.Unmarshal(bidReq.Source.Ext, &sourceExt); err != nil {
+		t.Fatalf("Bad source.ext: %v", err)
+	}
+	return string(sourceExt["schain"])
+}
+
+func TestPrepareSChainsByBidder(t *testing.T) {
+	orig := &openrtb.BidRequest{
+		Source: &openrtb.Source{TID: "some-tid", Ext: json.RawMessage(`{"schain":` + requestSChain + `}`)},
+		Ext:    json.RawMessage(`{"prebid":{"aliases":{"brightroll":"appnexus"},"schains":[{"bidders":["appnexus"],"schain":` + appnexusSChain + `}]}}`),
+	}
+	requests := newSChainRequests(orig)
+	errs := prepareSChains(orig, requests, map[string]string{"brightroll": "appnexus"}, "", "")
+
+	assert.Empty(t, errs)
+	assert.JSONEq(t, appnexusSChain, schainOf(t, requests["appnexus"]))
+	assert.JSONEq(t, appnexusSChain, schainOf(t, requests["brightroll"]), "Aliases should get the schain for their core bidder")
+	assert.JSONEq(t, requestSChain, schainOf(t, requests["rubicon"]), "Unlisted bidders should keep the request's schain")
+	assert.Equal(t, "some-tid", requests["rubicon"].Source.TID)
+	for bidder, bidReq := range requests {
+		assert.JSONEq(t, `{"prebid":{"aliases":{"brightroll":"appnexus"}}}`, string(bidReq.Ext), "ext.prebid.schains should be removed for %s", bidder)
+	}
+	assert.JSONEq(t, `{"schain":`+requestSChain+`}`, string(orig.Source.Ext), "The original request should not be modified")
+}
+
+func TestPrepareSChainsWildcardAndHostNode(t *testing.T) {
+	orig := &openrtb.BidRequest{
+		Ext: json.RawMessage(`{"prebid":{"schains":[{"bidders":["appnexus"],"schain":` + appnexusSChain + `},{"bidders":["*"],"schain":` + defaultSChain + `}]}}`),
+	}
+	requests := newSChainRequests(orig)
+	errs := prepareSChains(orig, requests, nil, "prebid-host.com", "some-account")
+
+	assert.Empty(t, errs)
+	assert.JSONEq(t, `{"complete":1,"nodes":[{"asi":"appnexus-seller.com","sid":"1","hp":1},{"asi":"prebid-host.com","sid":"some-account","hp":1}],"ver":"1.0"}`, schainOf(t, requests["appnexus"]))
+	assert.JSONEq(t, `{"complete":1,"nodes":[{"asi":"everyone-seller.com","sid":"2","hp":1},{"asi":"prebid-host.com","sid":"some-account","hp":1}],"ver":"1.0"}`, schainOf(t, requests["rubicon"]))
+}
+
+func TestPrepareSChainsHostNodeOnly(t *testing.T) {
+	orig := &openrtb.BidRequest{}
+	requests := newSChainRequests(orig)
+	errs := prepareSChains(orig, requests, nil, "prebid-host.com", "some-account")
+
+	assert.Empty(t, errs)
+	assert.JSONEq(t, `{"complete":0,"nodes":[{"asi":"prebid-host.com","sid":"some-account","hp":1}],"ver":"1.0"}`, schainOf(t, requests["rubicon"]))
+	assert.Nil(t, orig.Source, "The original request should not be modified")
+}
+
+func TestPrepareSChainsNothingToDo(t *testing.T) {
+	orig := &openrtb.BidRequest{}
+	requests := newSChainRequests(orig)
+	assert.Empty(t, prepareSChains(orig, requests, nil, "prebid-host.com", ""))
+	assert.Nil(t, requests["rubicon"].Source, "The host node needs an account")
+}
+
+func TestPrepareSChainsInvalid(t *testing.T) {
+	orig := &openrtb.BidRequest{
+		Ext: json.RawMessage(`{"prebid":{"schains":[` +
+			`{"bidders":["appnexus"],"schain":{"complete":1,"nodes":[{"asi":"seller.com","hp":1}],"ver":"1.0"}},` +
+			`{"bidders":[],"schain":` + defaultSChain + `},` +
+			`{"bidders":["rubicon"],"schain":` + defaultSChain + `},` +
+			`{"bidders":["rubicon"],"schain":` + appnexusSChain + `}` +
+			`]}}`),
+	}
+	requests := newSChainRequests(orig)
+	errs := prepareSChains(orig, requests, nil, "", "")
+
+	assert.Equal(t, []error{
+		&errortypes.Warning{Message: `request.ext.prebid.schains[0].schain is invalid and was ignored: nodes[0] missing required field: "sid"`},
+		&errortypes.Warning{Message: "request.ext.prebid.schains[1] has no bidders and was ignored"},
+		&errortypes.Warning{Message: "request.ext.prebid.schains has more than one schain for bidder rubicon. Only the first one was used."},
+	}, errs)
+	assert.Empty(t, schainOf(t, requests["appnexus"]))
+	assert.JSONEq(t, defaultSChain, schainOf(t, requests["rubicon"]))
+}
+
+func TestPrepareSChainsInvalidSource(t *testing.T) {
+	orig := &openrtb.BidRequest{
+		Source: &openrtb.Source{TID: "some-tid", Ext: json.RawMessage(`{"schain":{"complete":1,"nodes":[],"ver":"1.0"},"other":1}`)},
+	}
+	requests := newSChainRequests(orig)
+	errs := prepareSChains(orig, requests, nil, "", "")
+
+	assert.Equal(t, []error{
+		&errortypes.Warning{Message: "request.source.ext.schain is invalid and was removed: nodes must contain at least one element"},
+	}, errs)
+	for bidder, bidReq := range requests {
+		assert.JSONEq(t, `{"other":1}`, string(bidReq.Source.Ext), "The invalid schain should be removed for %s", bidder)
+		assert.Equal(t, "some-tid", bidReq.Source.TID)
+	}
+	assert.Contains(t, string(orig.Source.Ext), "schain", "The original request should not be modified")
+
+	requests = newSChainRequests(orig)
+	prepareSChains(orig, requests, nil, "prebid-host.com", "some-account")
+	assert.JSONEq(t, `{"complete":0,"nodes":[{"asi":"prebid-host.com","sid":"some-account","hp":1}],"ver":"1.0"}`, schainOf(t, requests["rubicon"]),
+		"The host node should replace an invalid schain")
+}
+
+func TestValidateSChainHP(t *testing.T) {
+	hpErrors := map[string]string{
+		`"hp":1`: "",
+		`"hp":0`: "",
+		`"hp":2`: "nodes[0].hp must be 0 or 1. Got 2",
+		``:       `nodes[0] missing required field: "hp"`,
+	}
+	for hp, expected := range hpErrors {
+		node := `{"asi":"seller.com","sid":"1"`
+		if hp != "" {
+			node += "," + hp
+		}
+		var schain openrtb_ext.ExtRequestPrebidSChainSChain
+		assert.NoError(t, json.Unmarshal([]byte(`{"complete":1,"nodes":[`+node+`}],"ver":"1.0"}`), &schain))
+		err := validateSChain(&schain)
+		if expected == "" {
+			assert.NoError(t, err, hp)
+		} else {
+			assert.EqualError(t, err, expected, hp)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package exchange
+
+import (
+	"math"
+	"math/rand"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/errortypes"
+	"github.com/prebid/prebid-server/openrtb_ext"
+)
+
+// trafficShaper learns how often each Bidder bids on each kind of inventory, so that the Exchange can skip
+// the calls to Bidders which are unlikely to bid. The bid rates decay over time, so that they follow changes in demand.
+type trafficShaper struct {
+	cfg              config.TrafficShaping
+	halfLife         time.Duration
+	disabledAccounts map[string]struct{}
+	// now and random are swapped out by the tests.
+	now    func() time.Time
+	random func() float64
+
+	lock      sync.Mutex
+	rates     map[trafficShapingKey]*bidRate
+	lastSweep time.Time
+}
+
+// trafficShapingKey is the kind of inventory which an imp is, for a Bidder.
+type trafficShapingKey struct {
+	bidder openrtb_ext.BidderName
+	// inventory is the app bundle or site domain.
+	inventory string
+	mediaType openrtb_ext.BidType
+	size      string
+	country   string
+}
+
+// bidRate counts the imps which a Bidder got and bid on. The counts decay with the configured half life.
+type bidRate struct {
+	imps    float64
+	bids    float64
+	updated time.Time
+}
+
+// newTrafficShaper returns nil if traffic shaping is disabled. The trafficShaper methods treat nil as "call every Bidder".
+func newTrafficShaper(cfg *config.TrafficShaping) *trafficShaper {
+	if !cfg.Enabled {
+		return nil
+	}
+	// Viper lowercases the accounts' keys, and the publisher IDs are matched case-insensitively.
+	disabledAccounts := make(map[string]struct{}, len(cfg.Accounts))
+	for account, accountCfg := range cfg.Accounts {
+		if accountCfg.Disabled {
+			disabledAccounts[strings.ToLower(account)] = struct{}{}
+		}
+	}
+	return &trafficShaper{
+		cfg:              *cfg,
+		halfLife:         time.Duration(cfg.HalfLifeSeconds) * time.Second,
+		disabledAccounts: disabledAccounts,
+		now:              time.Now,
+		random:           rand.Float64,
+		rates:            make(map[trafficShapingKey]*bidRate),
+	}
+}
+
+// skip says whether the call to the Bidder should be skipped. It should be, if the Bidder's bid rate is too low for every imp
+// in the request, unless the call is one of the exploration calls which go through anyway.
+func (s *trafficShaper) skip(bidder openrtb_ext.BidderName, request *openrtb.BidRequest, account string) bool {
+	if s == nil || len(request.Imp) == 0 {
+		return false
+	}
+	if _, disabled := s.disabledAccounts[strings.ToLower(account)]; disabled {
+		return false
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	now := s.now()
+	for i := range request.Imp {
+		rate, ok := s.rates[newTrafficShapingKey(bidder, request, &request.Imp[i])]
+		if !ok {
+			return false
+		}
+		imps, bids := rate.decayed(now, s.halfLife)
+		if imps < float64(s.cfg.MinRequests) || bids >= s.cfg.MinBidRate*imps {
+			return false
+		}
+	}
+	return s.random() >= s.cfg.ExplorationRate
+}
+
+// record counts the imps in a request to the Bidder, and which of them it bid on. Requests which failed
+// don't count, since they don't say anything about whether the Bidder would have bid.
+func (s *trafficShaper) record(bidder openrtb_ext.BidderName, request *openrtb.BidRequest, seatBid *PBSOrtbSeatBid, errs []error) {
+	if s == nil || isBidderFailure(errs) || hasErrorCode(errs, errortypes.CircuitOpenCode) {
+		return
+	}
+	bidImps := make(map[string]struct{})
+	if seatBid != nil {
+		for _, bid := range seatBid.Bids {
+			bidImps[bid.Bid.ImpID] = struct{}{}
+		}
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	now := s.now()
+	for i := range request.Imp {
+		key := newTrafficShapingKey(bidder, request, &request.Imp[i])
+		rate, ok := s.rates[key]
+		if !ok {
+			if rate = s.newBidRate(now); rate == nil {
+				continue
+			}
+			s.rates[key] = rate
+		}
+		rate.imps, rate.bids = rate.decayed(now, s.halfLife)
+		rate.updated = now
+		rate.imps++
+		if _, ok := bidImps[request.Imp[i].ID]; ok {
+			rate.bids++
+		}
+	}
+}
+
+// newBidRate returns a new bidRate, or nil if there isn't room for one. When the map is full, the bid rates which
+// have decayed to almost nothing get removed. This happens at most once per half life, to keep it cheap.
+func (s *trafficShaper) newBidRate(now time.Time) *bidRate {
+	if len(s.rates) >= s.cfg.MaxEntries && now.Sub(s.lastSweep) >= s.halfLife {
+		s.lastSweep = now
+		for key, rate := range s.rates {
+			if imps, _ := rate.decayed(now, s.halfLife); imps < 1 {
+				delete(s.rates, key)
+			}
+		}
+	}
+	if len(s.rates) >= s.cfg.MaxEntries {
+		return nil
+	}
+	return &bidRate{updated: now}
+}
+
+// decayed returns the counts as of now.
+func (r *bidRate) decayed(now time.Time, halfLife time.Duration) (imps float64, bids float64) {
+	factor := math.Exp2(-float64(now.Sub(r.updated)) / float64(halfLife))
+	return r.imps * factor, r.bids * factor
+}
+
+func newTrafficShapingKey(bidder openrtb_ext.BidderName, request *openrtb.BidRequest, imp *openrtb.Imp) trafficShapingKey {
+	key := trafficShapingKey{
+		bidder: bidder,
+	}
+	if request.App != nil {
+		key.inventory = request.App.Bundle
+	} else if request.Site != nil {
+		key.inventory = request.Site.Domain
+		if key.inventory == "" {
+			if pageURL, err := url.Parse(request.Site.Page); err == nil {
+				key.inventory = pageURL.Hostname()
+			}
+		}
+	}
+	if request.Device != nil && request.Device.Geo != nil {
+		key.country = request.Device.Geo.Country
+	}
+	switch {
+	case imp.Banner != nil:
+		key.mediaType = openrtb_ext.BidTypeBanner
+		if imp.Banner.W != nil && imp.Banner.H != nil {
+			key.size = formatSize(*imp.Banner.W, *imp.Banner.H)
+		} else if len(imp.Banner.Format) > 0 {
+			key.size = formatSize(imp.Banner.Format[0].W, imp.Banner.Format[0].H)
+		}
+	case imp.Video != nil:
+		key.mediaType = openrtb_ext.BidTypeVideo
+		key.size = formatSize(imp.Video.W, imp.Video.H)
+	case imp.Audio != nil:
+		key.mediaType = openrtb_ext.BidTypeAudio
+	case imp.Native != nil:
+		key.mediaType = openrtb_ext.BidTypeNative
+	}
+	return key
+}
+
+func formatSize(w uint64, h uint64) string {
+	return strconv.FormatUint(w, 10) + "x" + strconv.FormatUint(h, 10)
+}
+
+func hasErrorCode(errs []error, code int) bool {
+	for _, err := range errs {
+		if errortypes.DecodeError(err) == code {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,192 @@
+package exchange
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/errortypes"
+	"github.com/prebid/prebid-server/openrtb_ext"
+	"github.com/prebid/prebid-server/pbsmetrics"
+	metrics "github.com/rcrowley/go-metrics"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrafficShaperSkipsLowBidRates(t *testing.T) {
+	shaper, _ := newTestTrafficShaper()
+	request := newTrafficShapingRequest("imp-1")
+
+	assert.False(t, shaper.skip(openrtb_ext.BidderAppnexus, request, "some-account"), "Bidders shouldn't be skipped before there's a bid rate")
+	for i := 0; i < 9; i++ {
+		shaper.record(openrtb_ext.BidderAppnexus, request, nil, nil)
+	}
+	assert.False(t, shaper.skip(openrtb_ext.BidderAppnexus, request, "some-account"), "Bidders shouldn't be skipped before min_requests")
+
+	shaper.record(openrtb_ext.BidderAppnexus, request, nil, nil)
+	assert.True(t, shaper.skip(openrtb_ext.BidderAppnexus, request, "some-account"))
+	assert.False(t, shaper.skip(openrtb_ext.BidderRubicon, request, "some-account"), "Other bidders should have their own bid rates")
+	assert.False(t, shaper.skip(openrtb_ext.BidderAppnexus, request, "Disabled-Account"), "Accounts with traffic shaping disabled should call every bidder")
+	assert.True(t, shaper.skip(openrtb_ext.BidderAppnexus, request, "enabled-account"), "Accounts which don't disable traffic shaping should still be shaped")
+
+	shaper.random = func() float64 { return 0.01 }
+	assert.False(t, shaper.skip(openrtb_ext.BidderAppnexus, request, "some-account"), "Exploration calls should go through")
+}
+
+func TestTrafficShaperKeepsLikelyBidders(t *testing.T) {
+	shaper, _ := newTestTrafficShaper()
+	request := newTrafficShapingRequest("imp-1", "imp-2")
+	request.Imp[1].Banner.Format[0] = openrtb.Format{W: 728, H: 90}
+	bids := &PBSOrtbSeatBid{Bids: []*PBSOrtbBid{{Bid: &openrtb.Bid{ImpID: "imp-2"}}}}
+
+	for i := 0; i < 10; i++ {
+		shaper.record(openrtb_ext.BidderAppnexus, request, bids, nil)
+	}
+	assert.False(t, shaper.skip(openrtb_ext.BidderAppnexus, request, "some-account"), "The bidder should still be called, since it bids on imp-2")
+	assert.True(t, shaper.skip(openrtb_ext.BidderAppnexus, newTrafficShapingRequest("imp-1"), "some-account"), "Requests with only imp-1 should be skipped")
+}
+
+func TestTrafficShaperIgnoresFailures(t *testing.T) {
+	shaper, _ := newTestTrafficShaper()
+	request := newTrafficShapingRequest("imp-1")
+
+	for i := 0; i < 10; i++ {
+		shaper.record(openrtb_ext.BidderAppnexus, request, nil, []error{&errortypes.Timeout{Message: "timed out"}})
+		shaper.record(openrtb_ext.BidderAppnexus, request, nil, []error{&errortypes.CircuitOpen{Message: "circuit open"}})
+	}
+	assert.Empty(t, shaper.rates)
+}
+
+func TestTrafficShaperDecay(t *testing.T) {
+	shaper, clock := newTestTrafficShaper()
+	request := newTrafficShapingRequest("imp-1")
+
+	for i := 0; i < 16; i++ {
+		shaper.record(openrtb_ext.BidderAppnexus, request, nil, nil)
+	}
+	assert.True(t, shaper.skip(openrtb_ext.BidderAppnexus, request, "some-account"))
+
+	// After a half life, the 16 imps only count as 8, which is under min_requests.
+	*clock = clock.Add(time.Hour)
+	assert.False(t, shaper.skip(openrtb_ext.BidderAppnexus, request, "some-account"))
+}
+
+func TestTrafficShaperMaxEntries(t *testing.T) {
+	shaper, clock := newTestTrafficShaper()
+	shaper.cfg.MaxEntries = 1
+
+	shaper.record(openrtb_ext.BidderAppnexus, newTrafficShapingRequest("imp-1"), nil, nil)
+	shaper.record(openrtb_ext.BidderRubicon, newTrafficShapingRequest("imp-1"), nil, nil)
+	assert.Len(t, shaper.rates, 1)
+
+	// Once the first bid rate decays to almost nothing, it should make room for new ones.
+	*clock = clock.Add(2 * time.Hour)
+	shaper.record(openrtb_ext.BidderRubicon, newTrafficShapingRequest("imp-1"), nil, nil)
+	if assert.Len(t, shaper.rates, 1) {
+		for key := range shaper.rates {
+			assert.Equal(t, openrtb_ext.BidderRubicon, key.bidder)
+		}
+	}
+}
+
+func TestNewTrafficShapingKey(t *testing.T) {
+	w, h := uint64(300), uint64(250)
+	request := &openrtb.BidRequest{
+		Site:   &openrtb.Site{Page: "https://www.example.com/news"},
+		Device: &openrtb.Device{Geo: &openrtb.Geo{Country: "USA"}},
+	}
+	assert.Equal(t, trafficShapingKey{
+		bidder:    openrtb_ext.BidderAppnexus,
+		inventory: "www.example.com",
+		mediaType: openrtb_ext.BidTypeBanner,
+		size:      "300x250",
+		country:   "USA",
+	}, newTrafficShapingKey(openrtb_ext.BidderAppnexus, request, &openrtb.Imp{Banner: &openrtb.Banner{W: &w, H: &h}}))
+
+	request = &openrtb.BidRequest{App: &openrtb.App{Bundle: "com.example.app"}}
+	assert.Equal(t, trafficShapingKey{
+		bidder:    openrtb_ext.BidderAppnexus,
+		inventory: "com.example.app",
+		mediaType: openrtb_ext.BidTypeVideo,
+		size:      "640x480",
+	}, newTrafficShapingKey(openrtb_ext.BidderAppnexus, request, &openrtb.Imp{Video: &openrtb.Video{W: 640, H: 480}}))
+}
+
+func TestGetAllBidsSkipsShapedBidders(t *testing.T) {
+	shaper, _ := newTestTrafficShaper()
+	request := newTrafficShapingRequest("imp-1")
+	for i := 0; i < 10; i++ {
+		shaper.record(openrtb_ext.BidderAppnexus, request, nil, nil)
+	}
+	registry := metrics.NewRegistry()
+	me := pbsmetrics.NewMetrics(registry, []openrtb_ext.BidderName{openrtb_ext.BidderAppnexus, openrtb_ext.BidderRubicon})
+	e := &exchange{
+		adapterMap: map[openrtb_ext.BidderName]AdaptedBidder{
+			openrtb_ext.BidderAppnexus: &mockAdaptedBidder{},
+			openrtb_ext.BidderRubicon:  &mockAdaptedBidder{},
+		},
+		me:            me,
+		trafficShaper: shaper,
+	}
+	cleanRequests := map[openrtb_ext.BidderName]*openrtb.BidRequest{
+		openrtb_ext.BidderAppnexus: request,
+		openrtb_ext.BidderRubicon:  request,
+	}
+	blabels := map[openrtb_ext.BidderName]*pbsmetrics.AdapterLabels{
+		openrtb_ext.BidderAppnexus: {Adapter: openrtb_ext.BidderAppnexus, PubID: "some-account"},
+		openrtb_ext.BidderRubicon:  {Adapter: openrtb_ext.BidderRubicon, PubID: "some-account"},
+	}
+
+	_, adapterExtra, shapedBidders := e.getAllBids(context.Background(), cleanRequests, nil, nil, blabels, nil)
+
+	assert.NotContains(t, adapterExtra, openrtb_ext.BidderAppnexus)
+	assert.Contains(t, adapterExtra, openrtb_ext.BidderRubicon)
+	assert.Equal(t, []openrtb_ext.BidderName{openrtb_ext.BidderAppnexus}, shapedBidders)
+	assert.Equal(t, int64(1), me.AdapterMetrics[openrtb_ext.BidderAppnexus].SkippedMeter.Count())
+}
+
+// newTestTrafficShaper makes a shaper which skips bidders whose bid rate is under 10% over at least 10 imps,
+// with a one hour half life. Its clock only moves when the test moves it, and it never explores unless the test changes random.
+func newTestTrafficShaper() (*trafficShaper, *time.Time) {
+	clock := time.Date(2019, 6, 1, 12, 0, 0, 0, time.UTC)
+	shaper := newTrafficShaper(&config.TrafficShaping{
+		Enabled:         true,
+		MinBidRate:      0.1,
+		MinRequests:     10,
+		ExplorationRate: 0.05,
+		HalfLifeSeconds: 3600,
+		MaxEntries:      100,
+		Accounts:        map[string]config.AccountTrafficShaping{"disabled-account": {Disabled: true}, "enabled-account": {}},
+	})
+	shaper.now = func() time.Time { return clock }
+	shaper.random = func() float64 { return 0.5 }
+	return shaper, &clock
+}
+
+func newTrafficShapingRequest(impIDs ...string) *openrtb.BidRequest {
+	request := &openrtb.BidRequest{
+		Site: &openrtb.Site{Domain: "example.com"},
+	}
+	for _, id := range impIDs {
+		request.Imp = append(request.Imp, openrtb.Imp{
+			ID:     id,
+			Banner: &openrtb.Banner{Format: []openrtb.Format{{W: 300, H: 250}}},
+		})
+	}
+	return request
+}
+
+func TestTrafficShapingDebugOutput(t *testing.T) {
+	e := &exchange{}
+	shapedBidders := []openrtb_ext.BidderName{openrtb_ext.BidderAppnexus}
+
+	ext := e.makeExtBidResponse(nil, nil, &openrtb.BidRequest{Test: 1}, json.RawMessage(`{}`), nil, shapedBidders, nil)
+	if assert.NotNil(t, ext.Debug) && assert.NotNil(t, ext.Debug.TrafficShaping) {
+		assert.Equal(t, shapedBidders, ext.Debug.TrafficShaping.Skipped)
+	}
+
+	ext = e.makeExtBidResponse(nil, nil, &openrtb.BidRequest{Test: 1}, json.RawMessage(`{}`), nil, nil, nil)
+	assert.Nil(t, ext.Debug.TrafficShaping, "The debug output should only have traffic shaping if it skipped any bidders")
+}
@@ -12,6 +12,7 @@ import (
 	"github.com/prebid/prebid-server/gdpr"
 	"github.com/prebid/prebid-server/openrtb_ext"
 	"github.com/prebid/prebid-server/pbsmetrics"
+	"github.com/prebid/prebid-server/privacy"
 )
 
 // CleanOpenRTBRequests splits the input request into requests which are sanitized for each Bidder. Intended behavior is:
@@ -19,13 +20,17 @@ import (
 //   1. BidRequest.Imp[].Ext will only contain the "prebid" field and a "Bidder" field which has the params for the intended Bidder.
 //   2. Every BidRequest.Imp[] requested Bids from the Bidder who keys it.
 //   3. BidRequest.User.BuyerUID will be set to that Bidder's ID.
+//   4. Bidders which the privacy activity controls don't allow will be dropped, and the others scrubbed as needed.
+//   5. Bidders which the host's bidder rules don't call will be dropped, before their requests are built.
 func CleanOpenRTBRequests(ctx context.Context,
 	orig *openrtb.BidRequest,
 	usersyncs IdFetcher,
 	blables map[openrtb_ext.BidderName]*pbsmetrics.AdapterLabels,
 	labels pbsmetrics.Labels,
 	gDPR gdpr.Permissions,
-	usersyncIfAmbiguous bool) (requestsByBidder map[openrtb_ext.BidderName]*openrtb.BidRequest, aliases map[string]string, errs []error) {
+	usersyncIfAmbiguous bool,
+	activityControl privacy.ActivityControl,
+	rules *bidderRules) (requestsByBidder map[openrtb_ext.BidderName]*openrtb.BidRequest, aliases map[string]string, errs []error) {
 
 	impsByBidder, errs := splitImps(orig.Imp)
 	if len(errs) > 0 {
@@ -37,19 +42,35 @@ func CleanOpenRTBRequests(ctx context.Context,
 		return
 	}
 
+	// Drop the bidders which the host's bidder rules don't call
+	if rules != nil {
+		rulesRequest := newBidderRulesRequest(orig, labels.RType)
+		for bidder, imps := range impsByBidder {
+			if !rules.call(bidder, ResolveBidder(bidder, aliases), imps, rulesRequest) {
+				delete(impsByBidder, bidder)
+			}
+		}
+	}
+
 	requestsByBidder, errs = splitBidRequest(orig, impsByBidder, aliases, usersyncs, blables, labels)
 
-	// Clean PI from bidrequests if not allowed per GDPR
-	gdpr := extractGDPR(orig, usersyncIfAmbiguous)
-	consent := extractConsent(orig)
-	if gdpr == 1 {
-		for bidder, bidReq := range requestsByBidder {
-			// Fixes #820
-			coreBidder := ResolveBidder(bidder.String(), aliases)
-			if ok, err := gDPR.PersonalInfoAllowed(ctx, coreBidder, consent); !ok && err == nil {
-				cleanPI(bidReq, labels.RType == pbsmetrics.ReqTypeAMP)
-			}
+	// GDPR decides whether bidders get the user's personal info, unless the host or account configures those activities
+	if extractGDPR(orig, usersyncIfAmbiguous) == 1 {
+		personalInfoRule := gdprPersonalInfoRule(ctx, gDPR, extractConsent(orig), aliases)
+		activityControl = activityControl.
+			WithDefault(privacy.ActivityTransmitUserFPD, personalInfoRule).
+			WithDefault(privacy.ActivityTransmitPreciseGeo, personalInfoRule)
+	}
+
+	// Drop or scrub requests for bidders which the privacy activity controls don't allow
+	activityRequest := privacy.NewActivityRequest(orig)
+	for bidder, bidReq := range requestsByBidder {
+		component := privacy.Component{Type: privacy.ComponentTypeBidder, Name: bidder.String()}
+		if !activityControl.Allow(privacy.ActivityFetchBids, component, activityRequest) {
+			delete(requestsByBidder, bidder)
+			continue
 		}
+		enforceActivities(bidReq, component, activityControl, activityRequest)
 	}
 
 	return
@@ -61,6 +82,15 @@ func splitBidRequest(req *openrtb.BidRequest, impsByBidder map[string][]openrtb.
 	if err != nil {
 		return nil, []error{err}
 	}
+	eids, err := extractUserEids(req)
+	if err != nil {
+		return nil, []error{err}
+	}
+	fpd, err := extractFirstPartyData(req)
+	if err != nil {
+		return nil, []error{err}
+	}
+	var errs []error
 	for bidder, imps := range impsByBidder {
 		reqCopy := *req
 		coreBidder := ResolveBidder(bidder, aliases)
@@ -79,10 +109,25 @@ func splitBidRequest(req *openrtb.BidRequest, impsByBidder map[string][]openrtb.
 		} else {
 			blabels[coreBidder].CookieFlag = pbsmetrics.CookieFlagYes
 		}
+		if err := prepareUserData(&reqCopy, bidder, coreBidder, eids, fpd); err != nil {
+			errs = append(errs, err)
+			continue
+		}
 		reqCopy.Imp = imps
 		requestsByBidder[openrtb_ext.BidderName(bidder)] = &reqCopy
 	}
-	return requestsByBidder, nil
+	return requestsByBidder, errs
+}
+
+// prepareUserData applies the first party data and the eid permissions for this bidder. The eid permissions go last,
+// so that a request.ext.prebid.bidderconfig can't put back the eids which they hide from the bidder.
+//
+// In this function, "givenBidder" may or may not be an alias. "coreBidder" must *not* be an alias.
+func prepareUserData(req *openrtb.BidRequest, givenBidder string, coreBidder openrtb_ext.BidderName, eids *userEids, fpd *firstPartyData) error {
+	if err := prepareFirstPartyData(req, givenBidder, coreBidder, fpd); err != nil {
+		return err
+	}
+	return prepareEids(req, givenBidder, coreBidder, eids)
 }
 
 // extractBuyerUIDs parses the values from user.Ext.prebid.buyeruids, and then deletes those values from the Ext.
@@ -107,7 +152,7 @@ func extractBuyerUIDs(user *openrtb.User) (map[string]string, error) {
 	// as long as user.Ext.prebid exists.
 	buyerUIDs := userExt.Prebid.BuyerUIDs
 	userExt.Prebid = nil
-	if userExt.Consent != "" || userExt.DigiTrust != nil {
+	if userExt.Consent != "" || userExt.DigiTrust != nil || len(userExt.TpID) > 0 || len(userExt.Eids) > 0 {
 		if newUserExtBytes, err := jsoniter.Marshal(userExt); err != nil {
 			return nil, err
 		} else {
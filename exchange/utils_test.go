@@ -6,8 +6,10 @@ import (
 	"testing"
 
 	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/config"
 	"github.com/prebid/prebid-server/openrtb_ext"
 	"github.com/prebid/prebid-server/pbsmetrics"
+	"github.com/prebid/prebid-server/privacy"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -76,7 +78,7 @@ func TestCleanOpenRTBRequests(t *testing.T) {
 	}
 
 	for _, test := range testCases {
-		reqByBidders, _, err := CleanOpenRTBRequests(context.Background(), test.req, &emptyUsersync{}, map[openrtb_ext.BidderName]*pbsmetrics.AdapterLabels{}, pbsmetrics.Labels{}, &permissionsMock{}, true)
+		reqByBidders, _, err := CleanOpenRTBRequests(context.Background(), test.req, &emptyUsersync{}, map[openrtb_ext.BidderName]*pbsmetrics.AdapterLabels{}, pbsmetrics.Labels{}, &permissionsMock{}, true, privacy.ActivityControl{}, nil)
 		if test.hasError {
 			assert.NotNil(t, err, "Error shouldn't be nil")
 		} else {
@@ -86,6 +88,75 @@ func TestCleanOpenRTBRequests(t *testing.T) {
 	}
 }
 
+func TestCleanOpenRTBRequestsActivities(t *testing.T) {
+	deny := false
+	privacyConfig := &config.Privacy{
+		Activities: config.Activities{
+			FetchBids: config.Activity{
+				Rules: []config.ActivityRule{{
+					Allow:     false,
+					Condition: config.ActivityCondition{ComponentName: []string{"brightroll"}},
+				}},
+			},
+			TransmitUserFPD: config.Activity{Default: &deny},
+		},
+	}
+	activityControl := privacy.NewActivityControl(privacyConfig, "some-publisher-id")
+
+	req := newAdapterAliasBidRequest(t)
+	reqByBidders, _, errs := CleanOpenRTBRequests(context.Background(), req, &emptyUsersync{}, map[openrtb_ext.BidderName]*pbsmetrics.AdapterLabels{}, pbsmetrics.Labels{}, &permissionsMock{}, true, activityControl, nil)
+	assert.Empty(t, errs)
+	assert.Len(t, reqByBidders, 1)
+	if appnexusReq, ok := reqByBidders[openrtb_ext.BidderAppnexus]; assert.True(t, ok) {
+		assert.Empty(t, appnexusReq.User.ID)
+		assert.Empty(t, appnexusReq.Device.IFA)
+		assert.Equal(t, "132.173.230.74", appnexusReq.Device.IP)
+	}
+	assert.Equal(t, "our-id", req.User.ID, "The original request should not be modified")
+}
+
+func TestCleanOpenRTBRequestsActivitiesOverrideGDPR(t *testing.T) {
+	privacyConfig := &config.Privacy{
+		Activities: config.Activities{
+			TransmitUserFPD: config.Activity{
+				Rules: []config.ActivityRule{{
+					Allow:     true,
+					Condition: config.ActivityCondition{ComponentName: []string{"rubicon"}},
+				}},
+			},
+		},
+	}
+	activityControl := privacy.NewActivityControl(privacyConfig, "some-publisher-id")
+
+	reqByBidders, _, errs := CleanOpenRTBRequests(context.Background(), newRaceCheckingRequest(t), &emptyUsersync{}, map[openrtb_ext.BidderName]*pbsmetrics.AdapterLabels{}, pbsmetrics.Labels{}, &permissionsMock{}, true, activityControl, nil)
+	assert.Empty(t, errs)
+	if rubiconReq, ok := reqByBidders[openrtb_ext.BidderRubicon]; assert.True(t, ok) {
+		assert.NotEmpty(t, rubiconReq.User.BuyerUID, "The configured rule should override the GDPR default")
+		assert.Equal(t, "132.173.230.0", rubiconReq.Device.IP, "The GDPR default should still apply to the precise geo")
+	}
+	for bidderName, bidderReq := range reqByBidders {
+		if bidderName != openrtb_ext.BidderRubicon && bidderName != openrtb_ext.BidderAppnexus {
+			assert.Empty(t, bidderReq.User.BuyerUID, "The GDPR default should apply to bidders without a configured rule")
+		}
+	}
+}
+
+func TestCleanOpenRTBRequestsBidderRules(t *testing.T) {
+	rules := newBidderRules(&config.BidderRules{
+		Rules: []config.BidderRule{{
+			Bidders:   []string{"brightroll"},
+			Condition: config.BidderRuleCondition{Channel: []string{"web"}},
+		}},
+	}, "some-publisher-id")
+
+	req := newAdapterAliasBidRequest(t)
+	reqByBidders, _, errs := CleanOpenRTBRequests(context.Background(), req, &emptyUsersync{}, map[openrtb_ext.BidderName]*pbsmetrics.AdapterLabels{}, pbsmetrics.Labels{RType: pbsmetrics.ReqTypeORTB2Web}, &permissionsMock{}, true, privacy.ActivityControl{}, rules)
+	assert.Empty(t, errs)
+	assert.Len(t, reqByBidders, 1)
+	assert.Contains(t, reqByBidders, openrtb_ext.BidderAppnexus)
+	assert.Equal(t, []openrtb_ext.ExtBidderRuleDecision{{Bidder: "brightroll", Rule: "rules[0]", Called: false}}, rules.debugDecisions())
+}
+
 // newAdapterAliasBidRequest builds a BidRequest with aliases
 func newAdapterAliasBidRequest(t *testing.T) *openrtb.BidRequest {
 	dnt := int8(1)
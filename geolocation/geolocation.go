@@ -0,0 +1,76 @@
+// Package geolocation resolves IP addresses to countries.
+//
+// PBS uses this to decide whether GDPR applies to requests which don't say so themselves.
+package geolocation
+
+import (
+	"errors"
+	"net"
+
+	"github.com/coocood/freecache"
+	"github.com/golang/glog"
+	"github.com/prebid/prebid-server/pbsmetrics"
+)
+
+// CountryLookup finds the country for an IP address.
+type CountryLookup interface {
+	// Country returns the ISO-3166-1 alpha-2 country code for the IP, or "" if it isn't known.
+	Country(ip net.IP) (string, error)
+}
+
+// Resolver wraps a CountryLookup with caching and metrics.
+type Resolver struct {
+	lookup     CountryLookup
+	cache      *freecache.Cache
+	ttlSeconds int
+	metrics    pbsmetrics.MetricsEngine
+}
+
+// NewResolver makes a Resolver. If cacheSizeBytes is <= 0, results won't be cached.
+// For no TTL, use ttlSeconds <= 0.
+func NewResolver(lookup CountryLookup, cacheSizeBytes int, ttlSeconds int, metrics pbsmetrics.MetricsEngine) *Resolver {
+	resolver := &Resolver{
+		lookup:     lookup,
+		ttlSeconds: ttlSeconds,
+		metrics:    metrics,
+	}
+	if cacheSizeBytes > 0 {
+		resolver.cache = freecache.NewCache(cacheSizeBytes)
+	}
+	return resolver
+}
+
+// Country returns the ISO-3166-1 alpha-3 country code for the IP, or "" if it isn't known.
+func (r *Resolver) Country(ipString string) (string, error) {
+	if r.cache != nil {
+		if country, err := r.cache.Get([]byte(ipString)); err == nil {
+			r.metrics.RecordGeoLocationLookup(pbsmetrics.GeoLocationCacheHit)
+			return string(country), nil
+		}
+	}
+
+	ip := net.ParseIP(ipString)
+	if ip == nil {
+		r.metrics.RecordGeoLocationLookup(pbsmetrics.GeoLocationError)
+		return "", errors.New("invalid IP address: " + ipString)
+	}
+	alpha2, err := r.lookup.Country(ip)
+	if err != nil {
+		r.metrics.RecordGeoLocationLookup(pbsmetrics.GeoLocationError)
+		return "", err
+	}
+
+	country := Alpha3(alpha2)
+	if country == "" {
+		r.metrics.RecordGeoLocationLookup(pbsmetrics.GeoLocationNotFound)
+	} else {
+		r.metrics.RecordGeoLocationLookup(pbsmetrics.GeoLocationFound)
+	}
+	// Unknown IPs are cached too, so that they don't keep hitting the database.
+	if r.cache != nil {
+		if err := r.cache.Set([]byte(ipString), []byte(country), r.ttlSeconds); err != nil {
+			glog.Errorf("error saving geolocation in freecache: %v", err)
+		}
+	}
+	return country, nil
+}
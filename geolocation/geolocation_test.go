@@ -0,0 +1,81 @@
+package geolocation
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/prebid/prebid-server/pbsmetrics"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockLookup struct {
+	countries map[string]string
+	calls     int
+}
+
+func (m *mockLookup) Country(ip net.IP) (string, error) {
+	m.calls++
+	if ip.String() == "6.6.6.6" {
+		return "", errors.New("lookup failed")
+	}
+	return m.countries[ip.String()], nil
+}
+
+func TestResolverCountry(t *testing.T) {
+	metrics := &pbsmetrics.MetricsEngineMock{}
+	metrics.On("RecordGeoLocationLookup", pbsmetrics.GeoLocationFound).Return()
+	metrics.On("RecordGeoLocationLookup", pbsmetrics.GeoLocationNotFound).Return()
+	metrics.On("RecordGeoLocationLookup", pbsmetrics.GeoLocationError).Return()
+	resolver := NewResolver(&mockLookup{countries: map[string]string{"1.2.3.4": "DE"}}, 0, 0, metrics)
+
+	country, err := resolver.Country("1.2.3.4")
+	assert.NoError(t, err)
+	assert.Equal(t, "DEU", country)
+
+	country, err = resolver.Country("5.5.5.5")
+	assert.NoError(t, err)
+	assert.Equal(t, "", country)
+
+	_, err = resolver.Country("6.6.6.6")
+	assert.Error(t, err)
+
+	_, err = resolver.Country("not an ip")
+	assert.Error(t, err)
+
+	metrics.AssertNumberOfCalls(t, "RecordGeoLocationLookup", 4)
+	metrics.AssertCalled(t, "RecordGeoLocationLookup", pbsmetrics.GeoLocationFound)
+	metrics.AssertCalled(t, "RecordGeoLocationLookup", pbsmetrics.GeoLocationNotFound)
+	metrics.AssertCalled(t, "RecordGeoLocationLookup", pbsmetrics.GeoLocationError)
+}
+
+func TestResolverCache(t *testing.T) {
+	metrics := &pbsmetrics.MetricsEngineMock{}
+	metrics.On("RecordGeoLocationLookup", pbsmetrics.GeoLocationFound).Return()
+	metrics.On("RecordGeoLocationLookup", pbsmetrics.GeoLocationNotFound).Return()
+	metrics.On("RecordGeoLocationLookup", pbsmetrics.GeoLocationCacheHit).Return()
+	lookup := &mockLookup{countries: map[string]string{"1.2.3.4": "GB"}}
+	resolver := NewResolver(lookup, 512*1024, 60, metrics)
+
+	for i := 0; i < 3; i++ {
+		country, err := resolver.Country("1.2.3.4")
+		assert.NoError(t, err)
+		assert.Equal(t, "GBR", country)
+
+		country, err = resolver.Country("5.5.5.5")
+		assert.NoError(t, err)
+		assert.Equal(t, "", country)
+	}
+
+	assert.Equal(t, 2, lookup.calls, "unknown IPs should be cached too")
+	metrics.AssertNumberOfCalls(t, "RecordGeoLocationLookup", 6)
+}
+
+func TestGDPRApplies(t *testing.T) {
+	assert.True(t, GDPRApplies(Alpha3("DE")))
+	assert.True(t, GDPRApplies(Alpha3("GB")))
+	assert.True(t, GDPRApplies(Alpha3("NO")))
+	assert.False(t, GDPRApplies(Alpha3("US")))
+	assert.False(t, GDPRApplies(Alpha3("CH")))
+	assert.False(t, GDPRApplies(Alpha3("")))
+}
@@ -0,0 +1,58 @@
+package geolocation
+
+import (
+	"net"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// MaxMindDB looks up countries in a MaxMind DB (.mmdb) file, like GeoLite2-Country or GeoIP2-City.
+type MaxMindDB struct {
+	reader *maxminddb.Reader
+}
+
+// maxMindRecord holds the parts of a GeoIP2 record which PBS needs.
+// The records in the Country and City databases both have these fields.
+type maxMindRecord struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	RegisteredCountry struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"registered_country"`
+}
+
+// OpenMaxMindDB opens the database file.
+func OpenMaxMindDB(path string) (*MaxMindDB, error) {
+	reader, err := maxminddb.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return &MaxMindDB{reader: reader}, nil
+}
+
+// NewMaxMindDB reads a database which has already been loaded into memory.
+func NewMaxMindDB(buffer []byte) (*MaxMindDB, error) {
+	reader, err := maxminddb.FromBytes(buffer)
+	if err != nil {
+		return nil, err
+	}
+	return &MaxMindDB{reader: reader}, nil
+}
+
+// Country returns the ISO-3166-1 alpha-2 country code for the IP, or "" if the database doesn't have one.
+func (db *MaxMindDB) Country(ip net.IP) (string, error) {
+	var record maxMindRecord
+	if err := db.reader.Lookup(ip, &record); err != nil {
+		return "", err
+	}
+	if record.Country.ISOCode != "" {
+		return record.Country.ISOCode, nil
+	}
+	return record.RegisteredCountry.ISOCode, nil
+}
+
+// Close releases the database file.
+func (db *MaxMindDB) Close() error {
+	return db.reader.Close()
+}
@@ -0,0 +1,193 @@
+package geolocation
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIPv4Database(t *testing.T) {
+	for _, recordSize := range []uint{24, 28, 32} {
+		db, err := NewMaxMindDB(buildTestDB(4, recordSize))
+		if !assert.NoError(t, err, "record_size %d", recordSize) {
+			continue
+		}
+		assertCountry(t, db, "1.2.3.4", "DE")
+		assertCountry(t, db, "8.8.8.8", "US")
+		assertCountry(t, db, "9.9.9.9", "")
+
+		_, err = db.Country(net.ParseIP("2001:db8::1"))
+		assert.Error(t, err, "IPv6 lookups in an IPv4 database should fail")
+	}
+}
+
+func TestIPv6Database(t *testing.T) {
+	for _, recordSize := range []uint{24, 28, 32} {
+		db, err := NewMaxMindDB(buildTestDB(6, recordSize))
+		if !assert.NoError(t, err, "record_size %d", recordSize) {
+			continue
+		}
+		assertCountry(t, db, "1.2.3.4", "DE")
+		assertCountry(t, db, "::ffff:1.2.3.4", "DE")
+		assertCountry(t, db, "8.8.8.8", "US")
+		assertCountry(t, db, "2001:db8::1", "FR")
+		assertCountry(t, db, "2001:db9::1", "")
+	}
+}
+
+func TestInvalidDatabase(t *testing.T) {
+	_, err := NewMaxMindDB([]byte("not a database"))
+	assert.Error(t, err)
+
+	_, err = OpenMaxMindDB("does/not/exist.mmdb")
+	assert.Error(t, err)
+}
+
+// The MaxMind DB format details which buildTestDB needs. For the full spec, see https://maxmind.github.io/MaxMind-DB/
+var metadataStartMarker = []byte("\xAB\xCD\xEFMaxMind.com")
+
+const (
+	dataSectionSeparatorSize = 16
+
+	typePointer byte = 1
+	typeString  byte = 2
+	typeUint16  byte = 5
+	typeUint32  byte = 6
+	typeMap     byte = 7
+)
+
+func assertCountry(t *testing.T, db *MaxMindDB, ip string, expected string) {
+	t.Helper()
+	country, err := db.Country(net.ParseIP(ip))
+	assert.NoError(t, err, ip)
+	assert.Equal(t, expected, country, ip)
+}
+
+// buildTestDB writes a small database with these networks:
+//
+//	1.0.0.0/8     => country DE
+//	8.0.0.0/8     => registered_country US
+//	2001:db8::/32 => country FR (IPv6 databases only)
+func buildTestDB(ipVersion uint, recordSize uint) []byte {
+	// The DE country map is stored once, and the DE record points at it.
+	var data []byte
+	deCountry := len(data)
+	data = append(data, encodeTestMap("iso_code", encodeTestString("DE"))...)
+	deRecord := len(data)
+	data = append(data, encodeTestMap("country", []byte{typePointer << 5, byte(deCountry)})...)
+	usRecord := len(data)
+	data = append(data, encodeTestMap("registered_country", encodeTestMap("iso_code", encodeTestString("US")))...)
+	frRecord := len(data)
+	data = append(data, encodeTestMap("country", encodeTestMap("iso_code", encodeTestString("FR")))...)
+
+	tree := &testTree{}
+	tree.newNode()
+	ipv4Prefix := 0
+	if ipVersion == 6 {
+		ipv4Prefix = 96
+	}
+	tree.insert(append(make([]byte, ipv4Prefix/8), 1), ipv4Prefix+8, deRecord)
+	tree.insert(append(make([]byte, ipv4Prefix/8), 8), ipv4Prefix+8, usRecord)
+	if ipVersion == 6 {
+		tree.insert(net.ParseIP("2001:db8::"), 32, frRecord)
+	}
+
+	buffer := tree.encode(recordSize)
+	buffer = append(buffer, make([]byte, dataSectionSeparatorSize)...)
+	buffer = append(buffer, data...)
+	buffer = append(buffer, metadataStartMarker...)
+	buffer = append(buffer, encodeTestMap(
+		"node_count", encodeTestUint(typeUint32, uint32(len(tree.nodes))),
+		"record_size", encodeTestUint(typeUint16, uint32(recordSize)),
+		"ip_version", encodeTestUint(typeUint16, uint32(ipVersion)),
+	)...)
+	return buffer
+}
+
+type testTree struct {
+	nodes []*testNode
+}
+
+type testNode struct {
+	index    int
+	children [2]*testNode
+	// data holds the data section offset + 1 for records which point at data.
+	data [2]int
+}
+
+func (tree *testTree) newNode() *testNode {
+	node := &testNode{index: len(tree.nodes)}
+	tree.nodes = append(tree.nodes, node)
+	return node
+}
+
+func (tree *testTree) insert(address []byte, prefixLength int, dataOffset int) {
+	node := tree.nodes[0]
+	for i := 0; i < prefixLength; i++ {
+		bit := (address[i/8] >> (7 - uint(i%8))) & 1
+		if i == prefixLength-1 {
+			node.data[bit] = dataOffset + 1
+		} else {
+			if node.children[bit] == nil {
+				node.children[bit] = tree.newNode()
+			}
+			node = node.children[bit]
+		}
+	}
+}
+
+func (tree *testTree) encode(recordSize uint) []byte {
+	nodeCount := uint32(len(tree.nodes))
+	var buffer []byte
+	for _, node := range tree.nodes {
+		var records [2]uint32
+		for bit := 0; bit < 2; bit++ {
+			switch {
+			case node.children[bit] != nil:
+				records[bit] = uint32(node.children[bit].index)
+			case node.data[bit] != 0:
+				records[bit] = nodeCount + dataSectionSeparatorSize + uint32(node.data[bit]-1)
+			default:
+				records[bit] = nodeCount
+			}
+		}
+		switch recordSize {
+		case 28:
+			buffer = append(buffer, byte(records[0]>>16), byte(records[0]>>8), byte(records[0]))
+			buffer = append(buffer, byte((records[0]>>24)<<4|(records[1]>>24)&0x0F))
+			buffer = append(buffer, byte(records[1]>>16), byte(records[1]>>8), byte(records[1]))
+		case 32:
+			buffer = append(buffer, make([]byte, 8)...)
+			binary.BigEndian.PutUint32(buffer[len(buffer)-8:], records[0])
+			binary.BigEndian.PutUint32(buffer[len(buffer)-4:], records[1])
+		default:
+			for _, record := range records {
+				buffer = append(buffer, byte(record>>16), byte(record>>8), byte(record))
+			}
+		}
+	}
+	return buffer
+}
+
+func encodeTestString(value string) []byte {
+	return append([]byte{typeString<<5 | byte(len(value))}, value...)
+}
+
+func encodeTestUint(dataType byte, value uint32) []byte {
+	if dataType == typeUint16 {
+		return []byte{dataType<<5 | 2, byte(value >> 8), byte(value)}
+	}
+	return []byte{dataType<<5 | 4, byte(value >> 24), byte(value >> 16), byte(value >> 8), byte(value)}
+}
+
+// encodeTestMap encodes alternating string keys and encoded values as a map.
+func encodeTestMap(keysAndValues ...interface{}) []byte {
+	buffer := []byte{typeMap<<5 | byte(len(keysAndValues)/2)}
+	for i := 0; i < len(keysAndValues); i += 2 {
+		buffer = append(buffer, encodeTestString(keysAndValues[i].(string))...)
+		buffer = append(buffer, keysAndValues[i+1].([]byte)...)
+	}
+	return buffer
+}
@@ -33,6 +33,7 @@ require (
 	github.com/mxmCherry/openrtb v11.0.0+incompatible
 	github.com/onsi/ginkgo v1.8.0 // indirect
 	github.com/onsi/gomega v1.5.0 // indirect
+	github.com/oschwald/maxminddb-golang v1.3.1
 	github.com/pelletier/go-toml v1.2.0 // indirect
 	github.com/prebid/go-gdpr v0.0.0-20180627153529-92f359d7fe49
 	github.com/prometheus/client_golang v0.0.0-20180623155954-77e8f2ddcfed
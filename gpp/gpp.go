@@ -0,0 +1,230 @@
+// Package gpp decodes IAB Global Privacy Platform (GPP) strings.
+//
+// PBS only needs enough of the format to find out which sections are present, and to hand the
+// raw section strings to the code which already understands them (e.g. the gdpr package for TCF).
+// For the full spec, see https://github.com/InteractiveAdvertisingBureau/Global-Privacy-Platform
+package gpp
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// SectionID identifies a section inside a GPP string. These are the IDs from the GPP section registry.
+type SectionID int
+
+const (
+	SectionTCFEU1 SectionID = 1
+	SectionTCFEU2 SectionID = 2
+	SectionTCFCA  SectionID = 5
+	SectionUSPV1  SectionID = 6
+	SectionUSNat  SectionID = 7
+	SectionUSCA   SectionID = 8
+	SectionUSVA   SectionID = 9
+	SectionUSCO   SectionID = 10
+	SectionUSUT   SectionID = 11
+	SectionUSCT   SectionID = 12
+)
+
+const (
+	headerType    = 3
+	headerVersion = 1
+)
+
+// GPP is a parsed GPP string.
+type GPP struct {
+	// Version is the version of the GPP header.
+	Version int
+	// SectionTypes lists the sections in the same order as Sections.
+	SectionTypes []SectionID
+	// Sections holds the raw, still-encoded value of every section in the string.
+	Sections []string
+}
+
+// Parse decodes the header of a GPP string and splits out its sections.
+// The sections themselves are not decoded. A string with only a header is valid, and has no sections.
+func Parse(gppString string) (GPP, error) {
+	parts := strings.Split(gppString, "~")
+
+	version, sectionTypes, err := parseHeader(parts[0])
+	if err != nil {
+		return GPP{}, err
+	}
+	if len(sectionTypes) != len(parts)-1 {
+		return GPP{}, fmt.Errorf("gpp header lists %d sections, but the string contains %d", len(sectionTypes), len(parts)-1)
+	}
+
+	return GPP{
+		Version:      version,
+		SectionTypes: sectionTypes,
+		Sections:     parts[1:],
+	}, nil
+}
+
+// Section returns the raw value of the section with the given ID, if it exists.
+func (g GPP) Section(id SectionID) (string, bool) {
+	for i, sectionType := range g.SectionTypes {
+		if sectionType == id {
+			return g.Sections[i], true
+		}
+	}
+	return "", false
+}
+
+// ParseSIDs parses a comma-separated list of section IDs, like the "gpp_sid" query param.
+func ParseSIDs(sids string) ([]SectionID, error) {
+	if sids == "" {
+		return nil, nil
+	}
+	parts := strings.Split(sids, ",")
+	parsed := make([]SectionID, 0, len(parts))
+	for _, part := range parts {
+		id, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil {
+			return nil, fmt.Errorf("gpp_sid must be a comma-separated list of integers. Got %s", sids)
+		}
+		parsed = append(parsed, SectionID(id))
+	}
+	return parsed, nil
+}
+
+// ContainsSID is true if the id appears in the list of applicable sections.
+func ContainsSID(sids []SectionID, id SectionID) bool {
+	for _, sid := range sids {
+		if sid == id {
+			return true
+		}
+	}
+	return false
+}
+
+// GDPRApplies translates the applicable sections into the PBS "gdpr" signal.
+// It returns nil if the sections don't say anything either way.
+func GDPRApplies(sids []SectionID) *int {
+	if len(sids) == 0 {
+		return nil
+	}
+	gdpr := 0
+	if ContainsSID(sids, SectionTCFEU2) {
+		gdpr = 1
+	}
+	return &gdpr
+}
+
+func parseHeader(header string) (int, []SectionID, error) {
+	data, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(header, "="))
+	if err != nil {
+		return 0, nil, fmt.Errorf("gpp header is not valid base64: %v", err)
+	}
+	r := &bitReader{data: data}
+
+	headerTypeValue, err := r.readInt(6)
+	if err != nil {
+		return 0, nil, err
+	}
+	if headerTypeValue != headerType {
+		return 0, nil, fmt.Errorf("gpp header has type %d. Expected %d", headerTypeValue, headerType)
+	}
+	version, err := r.readInt(6)
+	if err != nil {
+		return 0, nil, err
+	}
+	if version != headerVersion {
+		return 0, nil, fmt.Errorf("gpp header version %d is not supported", version)
+	}
+	sectionTypes, err := r.readFibonacciRange()
+	if err != nil {
+		return 0, nil, err
+	}
+	return version, sectionTypes, nil
+}
+
+// bitReader reads big-endian bit fields from a byte slice.
+type bitReader struct {
+	data   []byte
+	offset int
+}
+
+func (r *bitReader) readBit() (bool, error) {
+	if r.offset >= len(r.data)*8 {
+		return false, errors.New("gpp header ended unexpectedly")
+	}
+	bit := r.data[r.offset/8]&(0x80>>uint(r.offset%8)) != 0
+	r.offset++
+	return bit, nil
+}
+
+func (r *bitReader) readInt(bits int) (int, error) {
+	value := 0
+	for i := 0; i < bits; i++ {
+		bit, err := r.readBit()
+		if err != nil {
+			return 0, err
+		}
+		value <<= 1
+		if bit {
+			value |= 1
+		}
+	}
+	return value, nil
+}
+
+// readFibonacci reads a Fibonacci-coded integer. These end with two consecutive 1 bits.
+func (r *bitReader) readFibonacci() (int, error) {
+	value := 0
+	prev, curr := 1, 1
+	lastBit := false
+	for {
+		bit, err := r.readBit()
+		if err != nil {
+			return 0, err
+		}
+		if bit && lastBit {
+			return value, nil
+		}
+		if bit {
+			value += curr
+		}
+		prev, curr = curr, prev+curr
+		lastBit = bit
+	}
+}
+
+// readFibonacciRange reads a list of IDs, where each ID is encoded as an offset from the one before it.
+func (r *bitReader) readFibonacciRange() ([]SectionID, error) {
+	numEntries, err := r.readInt(12)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]SectionID, 0, numEntries)
+	last := 0
+	for i := 0; i < numEntries; i++ {
+		isRange, err := r.readBit()
+		if err != nil {
+			return nil, err
+		}
+		start, err := r.readFibonacci()
+		if err != nil {
+			return nil, err
+		}
+		start += last
+		last = start
+		if !isRange {
+			ids = append(ids, SectionID(start))
+			continue
+		}
+		end, err := r.readFibonacci()
+		if err != nil {
+			return nil, err
+		}
+		end += last
+		last = end
+		for id := start; id <= end; id++ {
+			ids = append(ids, SectionID(id))
+		}
+	}
+	return ids, nil
+}
@@ -0,0 +1,79 @@
+package gpp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSingleSection(t *testing.T) {
+	parsed, err := Parse("DBABMA~CPXxRfAPXxRfAAfKABENB-CgAAAAAAAAAAYgAAAAAAAA")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, parsed.Version)
+	assert.Equal(t, []SectionID{SectionTCFEU2}, parsed.SectionTypes)
+
+	tcf, ok := parsed.Section(SectionTCFEU2)
+	assert.True(t, ok)
+	assert.Equal(t, "CPXxRfAPXxRfAAfKABENB-CgAAAAAAAAAAYgAAAAAAAA", tcf)
+
+	_, ok = parsed.Section(SectionUSPV1)
+	assert.False(t, ok)
+}
+
+func TestParseMultipleSections(t *testing.T) {
+	parsed, err := Parse("DBACNYA~CPXxRfAPXxRfAAfKABENB-CgAAAAAAAAAAYgAAAAAAAA~1YNN")
+	assert.NoError(t, err)
+	assert.Equal(t, []SectionID{SectionTCFEU2, SectionUSPV1}, parsed.SectionTypes)
+
+	usp, ok := parsed.Section(SectionUSPV1)
+	assert.True(t, ok)
+	assert.Equal(t, "1YNN", usp)
+}
+
+func TestParseHeaderOnly(t *testing.T) {
+	parsed, err := Parse("DBAA")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, parsed.Version)
+	assert.Empty(t, parsed.SectionTypes)
+	assert.Empty(t, parsed.Sections)
+
+	_, ok := parsed.Section(SectionTCFEU2)
+	assert.False(t, ok)
+}
+
+func TestParseErrors(t *testing.T) {
+	badStrings := []string{
+		"",
+		"DBABMA",
+		"!!!~1YNN",
+		"DBACNYA~1YNN",
+		"BBABMA~1YNN",
+	}
+	for _, bad := range badStrings {
+		_, err := Parse(bad)
+		assert.Error(t, err, "Expected an error parsing %q", bad)
+	}
+}
+
+func TestParseSIDs(t *testing.T) {
+	sids, err := ParseSIDs("2,6")
+	assert.NoError(t, err)
+	assert.Equal(t, []SectionID{SectionTCFEU2, SectionUSPV1}, sids)
+
+	sids, err = ParseSIDs("2,300")
+	assert.NoError(t, err)
+	assert.Equal(t, []SectionID{SectionTCFEU2, 300}, sids, "Section IDs shouldn't be limited to a byte")
+
+	sids, err = ParseSIDs("")
+	assert.NoError(t, err)
+	assert.Nil(t, sids)
+
+	_, err = ParseSIDs("2,a")
+	assert.Error(t, err)
+}
+
+func TestGDPRApplies(t *testing.T) {
+	assert.Nil(t, GDPRApplies(nil))
+	assert.Equal(t, 1, *GDPRApplies([]SectionID{SectionUSPV1, SectionTCFEU2}))
+	assert.Equal(t, 0, *GDPRApplies([]SectionID{SectionUSPV1}))
+}
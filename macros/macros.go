@@ -1,14 +1,31 @@
 package macros
 
 import (
+	"net/url"
 	"strings"
 	"text/template"
 )
 
-// EndpointTemplateParams specifies params for an endpoint template
+// EndpointTemplateParams specifies params for an endpoint template.
+//
+// Adapters can fill in the ones which come from the request with adapters.NewEndpointTemplateParams,
+// and then set any others which come from their own params.
 type EndpointTemplateParams struct {
 	Host        string
-	PublisherID int
+	PublisherID string
+	// AccountID is the ID of the publisher's account with Prebid Server.
+	AccountID string
+	// ZoneID and PlacementID come from the bidder's params for the imp.
+	ZoneID      string
+	PlacementID string
+	// GDPR is "1" if GDPR applies to the request, "0" if it doesn't, or "" if that's unknown.
+	GDPR        string
+	GDPRConsent string
+	USPrivacy   string
+	// MediaType is banner, video, audio or native.
+	MediaType string
+	// Region is the host's region, from the region in its config.
+	Region string
 }
 
 // UserSyncTemplateParams specifies params for an user sync URL template
@@ -27,3 +44,22 @@ func ResolveMacros(aTemplate template.Template, params interface{}) (string, err
 	res := strBuilder.String()
 	return res, nil
 }
+
+// ResolveEndpoint resolves the macros in an endpoint template. Unlike ResolveMacros, it escapes the params,
+// so that the endpoint is a valid URL no matter what the request had in it. The Host is path-escaped, so that
+// it can have a port. All the others are query-escaped.
+func ResolveEndpoint(endpointTemplate template.Template, params EndpointTemplateParams) (string, error) {
+	escaped := EndpointTemplateParams{
+		Host:        url.PathEscape(params.Host),
+		PublisherID: url.QueryEscape(params.PublisherID),
+		AccountID:   url.QueryEscape(params.AccountID),
+		ZoneID:      url.QueryEscape(params.ZoneID),
+		PlacementID: url.QueryEscape(params.PlacementID),
+		GDPR:        url.QueryEscape(params.GDPR),
+		GDPRConsent: url.QueryEscape(params.GDPRConsent),
+		USPrivacy:   url.QueryEscape(params.USPrivacy),
+		MediaType:   url.QueryEscape(params.MediaType),
+		Region:      url.QueryEscape(params.Region),
+	}
+	return ResolveMacros(endpointTemplate, escaped)
+}
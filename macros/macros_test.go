@@ -18,7 +18,7 @@ func TestResolveMacros(t *testing.T) {
 		result    string
 		hasError  bool
 	}{
-		{aTemplate: *endpointTemplate, params: EndpointTemplateParams{Host: "SomeHost", PublisherID: 1}, result: "http://SomeHost/publisher/1", hasError: false},
+		{aTemplate: *endpointTemplate, params: EndpointTemplateParams{Host: "SomeHost", PublisherID: "1"}, result: "http://SomeHost/publisher/1", hasError: false},
 		{aTemplate: *endpointTemplate, params: UserSyncTemplateParams{GDPR: "SomeGDPR", GDPRConsent: "SomeGDPRConsent"}, result: "", hasError: true},
 	}
 
@@ -34,3 +34,25 @@ func TestResolveMacros(t *testing.T) {
 		}
 	}
 }
+
+func TestResolveEndpoint(t *testing.T) {
+	endpointTemplate, _ := template.New("endpointTemplate").Parse("http://{{.Region}}.{{.Host}}/bid/{{.PublisherID}}?account={{.AccountID}}&zone={{.ZoneID}}&placement={{.PlacementID}}&gdpr={{.GDPR}}&consent={{.GDPRConsent}}&us_privacy={{.USPrivacy}}&type={{.MediaType}}")
+	res, err := ResolveEndpoint(*endpointTemplate, EndpointTemplateParams{
+		Host:        "some-host.com",
+		PublisherID: "1",
+		AccountID:   "some account",
+		ZoneID:      "a&b=c",
+		PlacementID: "12",
+		GDPR:        "1",
+		GDPRConsent: "BONV8oqONXwgmADACHENAO7pqzAAppY",
+		USPrivacy:   "1YNN",
+		MediaType:   "video",
+		Region:      "us-east",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "http://us-east.some-host.com/bid/1?account=some+account&zone=a%26b%3Dc&placement=12&gdpr=1&consent=BONV8oqONXwgmADACHENAO7pqzAAppY&us_privacy=1YNN&type=video", res)
+
+	unknownTemplate, _ := template.New("endpointTemplate").Parse("http://some-host.com/{{.Unknown}}")
+	_, err = ResolveEndpoint(*unknownTemplate, EndpointTemplateParams{})
+	assert.Error(t, err, "Templates with unknown macros should not resolve")
+}
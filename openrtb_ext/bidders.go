@@ -48,7 +48,9 @@ const (
 	BidderYieldmo        BidderName = "yieldmo"
 )
 
-// BidderMap stores all the valid OpenRTB 2.x Bidders in the project. This map *must not* be mutated.
+// BidderMap stores all the valid OpenRTB 2.x Bidders in the project. This map *must not* be mutated,
+// except by RegisterHostAlias when Prebid Server starts. Generic bidders, which the host defines in its config,
+// aren't in it.
 var BidderMap = map[string]BidderName{
 	"33across":        Bidder33Across,
 	"adkernelAdn":     BidderAdkernelAdn,
@@ -87,6 +89,45 @@ func BidderList() []BidderName {
 	return bidders
 }
 
+// hostAliases maps the aliases which hosts define in their config to the bidders they're aliases of.
+var hostAliases = make(map[BidderName]BidderName)
+
+// RegisterHostAlias adds an alias which the host defines with adapters.{alias}.alias_of in its config to the BidderMap,
+// so that it's a bidder of its own. This *must* be called before anything else uses the BidderMap, because it isn't
+// safe for concurrent use.
+func RegisterHostAlias(name string, aliasOf BidderName) error {
+	if _, ok := BidderMap[name]; ok {
+		return fmt.Errorf("alias %s has the same name as another bidder", name)
+	}
+	if _, ok := BidderMap[string(aliasOf)]; !ok || IsHostAlias(aliasOf) {
+		return fmt.Errorf("alias %s is an alias of %s, which isn't a bidder", name, aliasOf)
+	}
+	BidderMap[name] = BidderName(name)
+	hostAliases[BidderName(name)] = aliasOf
+	return nil
+}
+
+// IsHostAlias returns true if the bidder was added by RegisterHostAlias.
+func IsHostAlias(name BidderName) bool {
+	_, ok := hostAliases[name]
+	return ok
+}
+
+// HostAliasOf returns the bidder which a host alias is an alias of, or false if the bidder isn't a host alias.
+func HostAliasOf(name BidderName) (BidderName, bool) {
+	aliasOf, ok := hostAliases[name]
+	return aliasOf, ok
+}
+
+// genericParamsSchema validates the params of generic bidders which don't have a schema in the schema directory.
+// Prebid Server doesn't know what these bidders expect, so it passes along any object.
+const genericParamsSchema = `{
+  "$schema": "http://json-schema.org/draft-04/schema#",
+  "title": "Generic Adapter Params",
+  "description": "A schema which validates params accepted by bidders which the host defines in its config",
+  "type": "object"
+}`
+
 func (name BidderName) MarshalJSON() ([]byte, error) {
 	return []byte(name), nil
 }
@@ -110,7 +151,15 @@ type BidderParamValidator interface {
 
 // NewBidderParamsValidator makes a BidderParamValidator, assuming all the necessary files exist in the filesystem.
 // This will error if, for example, a Bidder gets added but no JSON schema is written for them.
-func NewBidderParamsValidator(schemaDirectory string) (BidderParamValidator, error) {
+//
+// The genericBidders are the ones which the host defines in its config. They may have a schema file,
+// but Prebid Server doesn't know what they expect, so any object is accepted if they don't.
+func NewBidderParamsValidator(schemaDirectory string, genericBidders ...BidderName) (BidderParamValidator, error) {
+	isGeneric := make(map[string]struct{}, len(genericBidders))
+	for _, bidderName := range genericBidders {
+		isGeneric[string(bidderName)] = struct{}{}
+	}
+
 	fileInfos, err := ioutil.ReadDir(schemaDirectory)
 	if err != nil {
 		return nil, fmt.Errorf("Failed to read JSON schemas from directory %s. %v", schemaDirectory, err)
@@ -120,7 +169,8 @@ func NewBidderParamsValidator(schemaDirectory string) (BidderParamValidator, err
 	schemas := make(map[BidderName]*gojsonschema.Schema, 50)
 	for _, fileInfo := range fileInfos {
 		bidderName := strings.TrimSuffix(fileInfo.Name(), ".json")
-		if _, isValid := BidderMap[bidderName]; !isValid {
+		_, isGenericBidder := isGeneric[bidderName]
+		if _, isValid := BidderMap[bidderName]; !isValid && !isGenericBidder {
 			return nil, fmt.Errorf("File %s/%s does not match a valid BidderName.", schemaDirectory, fileInfo.Name())
 		}
 		toOpen, err := filepath.Abs(filepath.Join(schemaDirectory, fileInfo.Name()))
@@ -142,6 +192,24 @@ func NewBidderParamsValidator(schemaDirectory string) (BidderParamValidator, err
 		schemaContents[BidderName(bidderName)] = string(fileBytes)
 	}
 
+	for _, bidderName := range genericBidders {
+		if _, ok := schemas[bidderName]; ok {
+			continue
+		}
+		loadedSchema, err := gojsonschema.NewSchema(gojsonschema.NewStringLoader(genericParamsSchema))
+		if err != nil {
+			return nil, fmt.Errorf("Failed to load the generic json schema for %s: %v", bidderName, err)
+		}
+		schemas[bidderName] = loadedSchema
+		schemaContents[bidderName] = genericParamsSchema
+	}
+
+	// Host aliases take the same params as the bidders they're aliases of.
+	for alias, aliasOf := range hostAliases {
+		schemas[alias] = schemas[aliasOf]
+		schemaContents[alias] = schemaContents[aliasOf]
+	}
+
 	return &bidderParamValidator{
 		schemaContents: schemaContents,
 		parsedSchemas:  schemas,
@@ -67,3 +67,59 @@ func adapterInList(t *testing.T, a BidderName, l []BidderName) {
 		t.Errorf("Adapter %s not found in the adapter map!", a)
 	}
 }
+
+func TestGenericBidder(t *testing.T) {
+	genericValidator, err := NewBidderParamsValidator("../static/bidder-params", "somegeneric")
+	if err != nil {
+		t.Fatalf("Unexpected error making the validator: %v", err)
+	}
+	if err := genericValidator.Validate("somegeneric", json.RawMessage(`{"anything":1}`)); err != nil {
+		t.Errorf("Generic bidders should accept any object as params. Error was: %v", err)
+	}
+	if err := genericValidator.Validate("somegeneric", json.RawMessage(`"not an object"`)); err == nil {
+		t.Error("Generic bidder params should be objects.")
+	}
+	if genericValidator.Schema("somegeneric") != genericParamsSchema {
+		t.Error("Generic bidders without a schema file should use the generic schema.")
+	}
+	if _, ok := BidderMap["somegeneric"]; ok {
+		t.Error("Generic bidders should not be added to the BidderMap.")
+	}
+}
+
+func TestHostAlias(t *testing.T) {
+	if err := RegisterHostAlias("appnexus", BidderRubicon); err == nil {
+		t.Error("Host aliases should not be able to replace other bidders.")
+	}
+	if err := RegisterHostAlias("somealias", "unknown"); err == nil {
+		t.Error("Host aliases should be aliases of known bidders.")
+	}
+
+	if err := RegisterHostAlias("somealias", BidderAppnexus); err != nil {
+		t.Fatalf("Unexpected error registering a host alias: %v", err)
+	}
+	defer func() {
+		delete(BidderMap, "somealias")
+		delete(hostAliases, "somealias")
+	}()
+	if err := RegisterHostAlias("otheralias", "somealias"); err == nil {
+		t.Error("Host aliases should not be aliases of other host aliases.")
+	}
+	if aliasOf, ok := HostAliasOf("somealias"); !ok || aliasOf != BidderAppnexus {
+		t.Errorf("HostAliasOf should return the aliased bidder. Got %s, %t", aliasOf, ok)
+	}
+	if IsHostAlias(BidderAppnexus) {
+		t.Error("IsHostAlias should only be true for registered host aliases.")
+	}
+
+	aliasValidator, err := NewBidderParamsValidator("../static/bidder-params")
+	if err != nil {
+		t.Fatalf("Unexpected error making the validator: %v", err)
+	}
+	if aliasValidator.Schema("somealias") != aliasValidator.Schema(BidderAppnexus) {
+		t.Error("Host aliases should use the schema of the bidder they're an alias of.")
+	}
+	if err := aliasValidator.Validate("somealias", json.RawMessage(`{"placementId":"not a number"}`)); err == nil {
+		t.Error("Host alias params should be validated like the aliased bidder's.")
+	}
+}
@@ -23,6 +23,13 @@ type ExtImpPrebid struct {
 	// at this time
 	// https://github.com/prebid/prebid-server/pull/846#issuecomment-476352224
 	Bidder map[string]json.RawMessage `json:"bidder"`
+
+	// IsRewardedInventory is 1 if the user gets a reward for viewing the ad. OpenRTB 2.6 callers send this as imp.rwdd.
+	IsRewardedInventory int8 `json:"is_rewarded_inventory,omitempty"`
+
+	// PreferredMediaType names the media type which each bidder should be sent, if it can't handle imps with
+	// more than one media type. It's keyed by bidder name or alias.
+	PreferredMediaType map[string]BidType `json:"preferredmediatype,omitempty"`
 }
 
 // ExtStoredRequest defines the contract for bidrequest.imp[i].ext.prebid.storedrequest
@@ -0,0 +1,175 @@
+package openrtb_ext
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/buger/jsonparser"
+)
+
+// OpenRTB versions which bidders can declare in the openrtb_version field of static/bidder-info/{bidder}.yaml.
+const (
+	OpenRTBVersion25 = "2.5"
+	OpenRTBVersion26 = "2.6"
+)
+
+// ortb26Field is a field which OpenRTB 2.6 defines on an object, and which OpenRTB 2.5 callers send in an ext.
+type ortb26Field struct {
+	// object is the path to the object which holds the field, or nil for fields of each imp.
+	object []string
+	field  string
+	// extPath is where the field lives in OpenRTB 2.5, relative to the object.
+	extPath []string
+}
+
+var ortb26Fields = []ortb26Field{
+	{object: []string{"regs"}, field: "gdpr", extPath: []string{"ext", "gdpr"}},
+	{object: []string{"regs"}, field: "us_privacy", extPath: []string{"ext", "us_privacy"}},
+	{object: []string{"regs"}, field: "gpp", extPath: []string{"ext", "gpp"}},
+	{object: []string{"regs"}, field: "gpp_sid", extPath: []string{"ext", "gpp_sid"}},
+	{object: []string{"user"}, field: "consent", extPath: []string{"ext", "consent"}},
+	{object: []string{"user"}, field: "eids", extPath: []string{"ext", "eids"}},
+	{object: []string{"source"}, field: "schain", extPath: []string{"ext", "schain"}},
+	{object: nil, field: "rwdd", extPath: []string{"ext", "prebid", "is_rewarded_inventory"}},
+}
+
+// MoveORTB26FieldsToExt moves the OpenRTB 2.6 fields of a bid request into the ext locations which OpenRTB 2.5
+// callers use for them, since the OpenRTB 2.5 model has no place for them. Prebid Server works with requests in this form.
+// If both locations are defined, the one in the ext wins.
+func MoveORTB26FieldsToExt(requestJSON []byte) ([]byte, error) {
+	return moveORTB26Fields(requestJSON, true)
+}
+
+// MoveExtFieldsToORTB26 undoes MoveORTB26FieldsToExt, for bidders which expect OpenRTB 2.6 requests.
+// If both locations are defined, the OpenRTB 2.6 one wins.
+func MoveExtFieldsToORTB26(requestJSON []byte) ([]byte, error) {
+	return moveORTB26Fields(requestJSON, false)
+}
+
+// MoveImpORTB26FieldsToExt does the same as MoveORTB26FieldsToExt for a single imp, like a Stored Imp.
+func MoveImpORTB26FieldsToExt(impJSON []byte) ([]byte, error) {
+	// jsonparser.Set and Delete work in-place, so make sure the caller's data doesn't get changed.
+	impJSON, _, err := moveFieldsOfImp(append([]byte(nil), impJSON...), true, "imp")
+	return impJSON, err
+}
+
+func moveORTB26Fields(requestJSON []byte, toExt bool) ([]byte, error) {
+	// jsonparser.Set and Delete work in-place, so make sure the caller's data doesn't get changed.
+	requestJSON = append([]byte(nil), requestJSON...)
+	for _, f := range ortb26Fields {
+		if f.object == nil {
+			continue
+		}
+		var err error
+		if requestJSON, _, err = f.move(requestJSON, f.object, toExt, "request"); err != nil {
+			return nil, err
+		}
+	}
+	return moveImpFields(requestJSON, toExt)
+}
+
+// moveImpFields moves the fields of each imp. The imps are left alone if any of them isn't an object,
+// since the request will fail validation anyway.
+func moveImpFields(requestJSON []byte, toExt bool) ([]byte, error) {
+	impsJSON, dataType, _, err := jsonparser.Get(requestJSON, "imp")
+	if err != nil || dataType != jsonparser.Array {
+		return requestJSON, nil
+	}
+
+	var imps [][]byte
+	changed := false
+	valid := true
+	var moveErr error
+	_, err = jsonparser.ArrayEach(impsJSON, func(imp []byte, dataType jsonparser.ValueType, _ int, _ error) {
+		if dataType != jsonparser.Object || moveErr != nil {
+			valid = false
+			return
+		}
+		var moved bool
+		if imp, moved, moveErr = moveFieldsOfImp(append([]byte(nil), imp...), toExt, fmt.Sprintf("request.imp[%d]", len(imps))); moveErr != nil {
+			return
+		}
+		changed = changed || moved
+		imps = append(imps, imp)
+	})
+	if moveErr != nil {
+		return nil, moveErr
+	}
+	if err != nil || !valid || !changed {
+		return requestJSON, nil
+	}
+
+	newImps := make([]byte, 0, len(impsJSON)+len(imps))
+	newImps = append(newImps, '[')
+	for index, imp := range imps {
+		if index > 0 {
+			newImps = append(newImps, ',')
+		}
+		newImps = append(newImps, imp...)
+	}
+	newImps = append(newImps, ']')
+	return jsonparser.Set(requestJSON, newImps, "imp")
+}
+
+// moveFieldsOfImp moves the fields of one imp. It returns true if any of them were found.
+func moveFieldsOfImp(imp []byte, toExt bool, location string) ([]byte, bool, error) {
+	changed := false
+	for _, f := range ortb26Fields {
+		if f.object != nil {
+			continue
+		}
+		var moved bool
+		var err error
+		if imp, moved, err = f.move(imp, nil, toExt, location); err != nil {
+			return nil, false, err
+		}
+		changed = changed || moved
+	}
+	return imp, changed, nil
+}
+
+// move moves the field of the object at objectPath in data between its OpenRTB 2.6 and ext locations.
+// It returns true if the field was found. The location names the data in error messages.
+func (f ortb26Field) move(data []byte, objectPath []string, toExt bool, location string) ([]byte, bool, error) {
+	from := append(append([]string(nil), objectPath...), f.field)
+	to := append(append([]string(nil), objectPath...), f.extPath...)
+	if !toExt {
+		from, to = to, from
+	}
+
+	value, dataType, _, err := jsonparser.Get(data, from...)
+	if dataType == jsonparser.NotExist {
+		return data, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("%s.%s is invalid: %v", location, strings.Join(from, "."), err)
+	}
+	// Copy the value before deleting, since Delete works in-place on the underlying array.
+	value = append([]byte(nil), value...)
+	data = deleteAndPrune(data, from, len(objectPath))
+	if _, toType, _, _ := jsonparser.Get(data, to...); toType != jsonparser.NotExist {
+		return data, true, nil
+	}
+	if dataType == jsonparser.String {
+		value = []byte(`"` + string(value) + `"`)
+	}
+	if data, err = jsonparser.Set(data, value, to...); err != nil {
+		return nil, false, fmt.Errorf("%s.%s could not be moved to %s.%s: %v", location, strings.Join(from, "."), location, strings.Join(to, "."), err)
+	}
+	return data, true, nil
+}
+
+// deleteAndPrune deletes path from data, along with any objects on the path which are left empty.
+// The first keep elements of the path are never deleted.
+func deleteAndPrune(data []byte, path []string, keep int) []byte {
+	data = jsonparser.Delete(data, path...)
+	for end := len(path) - 1; end > keep; end-- {
+		parent, dataType, _, err := jsonparser.Get(data, path[:end]...)
+		if err != nil || dataType != jsonparser.Object || len(bytes.TrimSpace(parent[1:len(parent)-1])) > 0 {
+			break
+		}
+		data = jsonparser.Delete(data, path[:end]...)
+	}
+	return data
+}
@@ -0,0 +1,75 @@
+package openrtb_ext_test
+
+import (
+	"testing"
+
+	"github.com/prebid/prebid-server/openrtb_ext"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	ortb26Request = `{"id":"some-id","imp":[{"id":"imp-1","rwdd":1,"ext":{"appnexus":{"placementId":1}}},{"id":"imp-2"}],` +
+		`"regs":{"gdpr":1,"us_privacy":"1YNN"},"user":{"consent":"some-consent","eids":[{"source":"a.com","uids":[{"id":"a-id"}]}]},` +
+		`"source":{"tid":"some-tid","schain":{"complete":1,"nodes":[],"ver":"1.0"}}}`
+	ortb25Request = `{"id":"some-id","imp":[{"id":"imp-1","ext":{"appnexus":{"placementId":1},"prebid":{"is_rewarded_inventory":1}}},{"id":"imp-2"}],` +
+		`"regs":{"ext":{"gdpr":1,"us_privacy":"1YNN"}},"user":{"ext":{"consent":"some-consent","eids":[{"source":"a.com","uids":[{"id":"a-id"}]}]}},` +
+		`"source":{"tid":"some-tid","ext":{"schain":{"complete":1,"nodes":[],"ver":"1.0"}}}}`
+)
+
+func TestMoveORTB26FieldsToExt(t *testing.T) {
+	original := []byte(ortb26Request)
+	converted, err := openrtb_ext.MoveORTB26FieldsToExt(original)
+	assert.NoError(t, err)
+	assert.JSONEq(t, ortb25Request, string(converted))
+	assert.Equal(t, ortb26Request, string(original), "The input should not be modified")
+}
+
+func TestMoveORTB26FieldsToExtExtWins(t *testing.T) {
+	converted, err := openrtb_ext.MoveORTB26FieldsToExt([]byte(`{"user":{"consent":"new","ext":{"consent":"old"}}}`))
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"user":{"ext":{"consent":"old"}}}`, string(converted))
+}
+
+func TestMoveORTB26FieldsToExtInvalidImps(t *testing.T) {
+	converted, err := openrtb_ext.MoveORTB26FieldsToExt([]byte(`{"imp":[{"rwdd":1},"not-an-imp"]}`))
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"imp":[{"rwdd":1},"not-an-imp"]}`, string(converted), "Invalid imps should be left for validation to reject")
+}
+
+func TestMoveExtFieldsToORTB26(t *testing.T) {
+	original := []byte(ortb25Request)
+	converted, err := openrtb_ext.MoveExtFieldsToORTB26(original)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"id":"some-id","imp":[{"id":"imp-1","rwdd":1,"ext":{"appnexus":{"placementId":1}}},{"id":"imp-2"}],`+
+		`"regs":{"gdpr":1,"us_privacy":"1YNN"},"user":{"consent":"some-consent","eids":[{"source":"a.com","uids":[{"id":"a-id"}]}]},`+
+		`"source":{"tid":"some-tid","schain":{"complete":1,"nodes":[],"ver":"1.0"}}}`, string(converted))
+	assert.Equal(t, ortb25Request, string(original), "The input should not be modified")
+}
+
+func TestMoveImpORTB26FieldsToExt(t *testing.T) {
+	impJSON := []byte(`{"id":"imp-1","rwdd":1}`)
+	moved, err := openrtb_ext.MoveImpORTB26FieldsToExt(impJSON)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"id":"imp-1","ext":{"prebid":{"is_rewarded_inventory":1}}}`, string(moved))
+	assert.Equal(t, `{"id":"imp-1","rwdd":1}`, string(impJSON), "The caller's data should not be modified")
+}
+
+func TestMoveRegsGPPToExt(t *testing.T) {
+	requestJSON, err := openrtb_ext.MoveORTB26FieldsToExt([]byte(`{"regs":{"gpp":"DBABTA~1YNN","gpp_sid":[6]}}`))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	assert.JSONEq(t, `{"regs":{"ext":{"gpp":"DBABTA~1YNN","gpp_sid":[6]}}}`, string(requestJSON))
+
+	requestJSON, err = openrtb_ext.MoveORTB26FieldsToExt([]byte(`{"regs":{"gpp":"DBABTA~1YNN","ext":{"gpp":"DBABMA~CPXxRfAPXxRfAAfKABENB-CgAAAAAAAAAAYgAAAAAAAA"}}}`))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	assert.JSONEq(t, `{"regs":{"ext":{"gpp":"DBABMA~CPXxRfAPXxRfAAfKABENB-CgAAAAAAAAAAYgAAAAAAAA"}}}`, string(requestJSON))
+
+	requestJSON, err = openrtb_ext.MoveORTB26FieldsToExt([]byte(`{"id":"some-id"}`))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	assert.JSONEq(t, `{"id":"some-id"}`, string(requestJSON))
+}
@@ -6,4 +6,14 @@ type ExtRegs struct {
 	// GDPR should be "1" if the caller believes the user is subject to GDPR laws, "0" if not, and undefined
 	// if it's unknown. For more info on this parameter, see: https://iabtechlab.com/wp-content/uploads/2018/02/OpenRTB_Advisory_GDPR_2018-02.pdf
 	GDPR *int8 `json:"gdpr,omitempty"`
+
+	// GPP is an IAB Global Privacy Platform string. OpenRTB 2.6 callers may send this as "regs.gpp",
+	// in which case it will be moved here. For more info, see: https://github.com/InteractiveAdvertisingBureau/Global-Privacy-Platform
+	GPP string `json:"gpp,omitempty"`
+
+	// GPPSID lists the GPP sections which apply to this request.
+	GPPSID []int `json:"gpp_sid,omitempty"`
+
+	// USPrivacy is the IAB US Privacy (CCPA) string.
+	USPrivacy string `json:"us_privacy,omitempty"`
 }
@@ -1,6 +1,7 @@
 package openrtb_ext
 
 import (
+	"encoding/json"
 	"errors"
 
 	jsoniter "github.com/json-iterator/go"
@@ -13,11 +14,48 @@ type ExtRequest struct {
 
 // ExtRequestPrebid defines the contract for bidrequest.ext.prebid
 type ExtRequestPrebid struct {
-	Aliases              map[string]string      `json:"aliases,omitempty"`
-	BidAdjustmentFactors map[string]float64     `json:"bidadjustmentfactors,omitempty"`
-	Cache                *ExtRequestPrebidCache `json:"cache,omitempty"`
-	StoredRequest        *ExtStoredRequest      `json:"storedrequest,omitempty"`
-	Targeting            *ExtRequestTargeting   `json:"targeting,omitempty"`
+	Aliases              map[string]string              `json:"aliases,omitempty"`
+	BidAdjustmentFactors map[string]float64             `json:"bidadjustmentfactors,omitempty"`
+	Cache                *ExtRequestPrebidCache         `json:"cache,omitempty"`
+	StoredRequest        *ExtStoredRequest              `json:"storedrequest,omitempty"`
+	Targeting            *ExtRequestTargeting           `json:"targeting,omitempty"`
+	Data                 *ExtRequestPrebidData          `json:"data,omitempty"`
+	BidderConfigs        []ExtRequestPrebidBidderConfig `json:"bidderconfig,omitempty"`
+	SChains              []*ExtRequestPrebidSChain      `json:"schains,omitempty"`
+}
+
+// ExtRequestPrebidData defines the contract for bidrequest.ext.prebid.data
+type ExtRequestPrebidData struct {
+	// Bidders lists the bidders which may see the request's first party data. If it's undefined, every bidder can.
+	Bidders        []string                            `json:"bidders,omitempty"`
+	EidPermissions []ExtRequestPrebidDataEidPermission `json:"eidpermissions,omitempty"`
+}
+
+// ExtRequestPrebidDataEidPermission defines the contract for bidrequest.ext.prebid.data.eidpermissions[]
+// Only the listed bidders will get the user.ext.eids with this source. A bidder of "*" allows every bidder.
+type ExtRequestPrebidDataEidPermission struct {
+	Source  string   `json:"source"`
+	Bidders []string `json:"bidders"`
+}
+
+// ExtRequestPrebidBidderConfig defines the contract for bidrequest.ext.prebid.bidderconfig[]
+// The Config is merged into the requests which are sent to the listed bidders. A bidder of "*" matches every bidder.
+type ExtRequestPrebidBidderConfig struct {
+	Bidders []string         `json:"bidders"`
+	Config  *ExtBidderConfig `json:"config"`
+}
+
+// ExtBidderConfig defines the contract for bidrequest.ext.prebid.bidderconfig[].config
+type ExtBidderConfig struct {
+	ORTB2 *ExtBidderConfigORTB `json:"ortb2"`
+}
+
+// ExtBidderConfigORTB defines the contract for bidrequest.ext.prebid.bidderconfig[].config.ortb2
+// Each object is merged into the matching object of the bidder's request, like a JSON merge patch.
+type ExtBidderConfigORTB struct {
+	Site json.RawMessage `json:"site,omitempty"`
+	App  json.RawMessage `json:"app,omitempty"`
+	User json.RawMessage `json:"user,omitempty"`
 }
 
 // ExtRequestPrebidCache defines the contract for bidrequest.ext.prebid.cache
@@ -25,6 +25,23 @@ type ExtResponseDebug struct {
 	HttpCalls map[BidderName][]*ExtHttpCall `json:"httpcalls,omitempty"`
 	// Request after resolution of stored requests and debug overrides
 	ResolvedRequest *openrtb.BidRequest `json:"resolvedrequest,omitempty"`
+	// BidderRules lists the decisions which the host's bidder rules made for the request
+	BidderRules []ExtBidderRuleDecision `json:"bidderrules,omitempty"`
+	// TrafficShaping lists the bidders which traffic shaping skipped, because they're unlikely to bid on the request
+	TrafficShaping *ExtTrafficShapingDebug `json:"trafficshaping,omitempty"`
+}
+
+// ExtTrafficShapingDebug defines the contract for bidresponse.ext.debug.trafficshaping
+type ExtTrafficShapingDebug struct {
+	Skipped []BidderName `json:"skipped"`
+}
+
+// ExtBidderRuleDecision defines the contract for bidresponse.ext.debug.bidderrules[i]
+type ExtBidderRuleDecision struct {
+	Bidder string `json:"bidder"`
+	// Rule is the name of the rule which decided whether to call the bidder
+	Rule   string `json:"rule"`
+	Called bool   `json:"called"`
 }
 
 // ExtResponseSyncData defines the contract for bidresponse.ext.usersync.{bidder}
@@ -52,6 +69,8 @@ type ExtHttpCall struct {
 	RequestBody  string `json:"requestbody"`
 	ResponseBody string `json:"responsebody"`
 	Status       int    `json:"status"`
+	// Region is the region whose endpoint the call was made to.
+	Region string `json:"region,omitempty"`
 }
 
 // CookieStatus describes the allowed values for bidresponse.ext.usersync.{bidder}.status
@@ -0,0 +1,37 @@
+package openrtb_ext
+
+import "encoding/json"
+
+// ExtSource defines the contract for bidrequest.source.ext
+type ExtSource struct {
+	SChain *ExtRequestPrebidSChainSChain `json:"schain,omitempty"`
+}
+
+// ExtRequestPrebidSChain defines the contract for bidrequest.ext.prebid.schains[]
+// The SChain is sent to the listed bidders in source.ext.schain. A bidder of "*" matches every bidder
+// which isn't listed by another entry.
+type ExtRequestPrebidSChain struct {
+	Bidders []string                     `json:"bidders,omitempty"`
+	SChain  ExtRequestPrebidSChainSChain `json:"schain"`
+}
+
+// ExtRequestPrebidSChainSChain defines the contract for an IAB SupplyChain object.
+// For more info, see: https://github.com/InteractiveAdvertisingBureau/openrtb/blob/master/supplychainobject.md
+type ExtRequestPrebidSChainSChain struct {
+	Complete int                                 `json:"complete"`
+	Nodes    []*ExtRequestPrebidSChainSChainNode `json:"nodes"`
+	Ver      string                              `json:"ver"`
+	Ext      json.RawMessage                     `json:"ext,omitempty"`
+}
+
+// ExtRequestPrebidSChainSChainNode defines the contract for one node of an IAB SupplyChain object.
+// HP is 1 if the node is in the payment flow, and 0 if it isn't. It's a pointer so that a missing hp can be told apart from 0.
+type ExtRequestPrebidSChainSChainNode struct {
+	ASI    string          `json:"asi"`
+	SID    string          `json:"sid"`
+	RID    string          `json:"rid,omitempty"`
+	Name   string          `json:"name,omitempty"`
+	Domain string          `json:"domain,omitempty"`
+	HP     *int            `json:"hp"`
+	Ext    json.RawMessage `json:"ext,omitempty"`
+}
@@ -1,5 +1,7 @@
 package openrtb_ext
 
+import "encoding/json"
+
 // ExtUser defines the contract for bidrequest.user.ext
 type ExtUser struct {
 	// Consent is a GDPR consent string. See "Advised Extensions" of
@@ -14,6 +16,8 @@ type ExtUser struct {
 	DigiTrust *ExtUserDigiTrust `json:"digitrust,omitempty"`
 
 	TpID []ExtUserTpID `json:"tpid,omitempty"`
+
+	Eids []ExtUserEid `json:"eids,omitempty"`
 }
 
 // ExtUserPrebid defines the contract for bidrequest.user.ext.prebid
@@ -35,3 +39,19 @@ type ExtUserTpID struct {
 	Source string `json:"source"`
 	UID    string `json:"uid"`
 }
+
+// ExtUserEid defines the contract for bidrequest.user.ext.eids
+// Each one holds the IDs which one identity provider (the source) knows the user by.
+// For more info, see: https://github.com/prebid/Prebid.js/blob/master/modules/userId/eids.md
+type ExtUserEid struct {
+	Source string          `json:"source"`
+	UIDs   []ExtUserEidUID `json:"uids"`
+	Ext    json.RawMessage `json:"ext,omitempty"`
+}
+
+// ExtUserEidUID defines the contract for bidrequest.user.ext.eids[].uids[]
+type ExtUserEidUID struct {
+	ID    string          `json:"id"`
+	Atype int             `json:"atype,omitempty"`
+	Ext   json.RawMessage `json:"ext,omitempty"`
+}
@@ -153,6 +153,41 @@ func (me *MultiMetricsEngine) RecordStoredImpCacheResult(cacheResult pbsmetrics.
 	}
 }
 
+// RecordGeoLocationLookup across all engines
+func (me *MultiMetricsEngine) RecordGeoLocationLookup(result pbsmetrics.GeoLocationResult) {
+	for _, thisME := range *me {
+		thisME.RecordGeoLocationLookup(result)
+	}
+}
+
+// RecordCookieEvictions across all engines
+func (me *MultiMetricsEngine) RecordCookieEvictions(count int) {
+	for _, thisME := range *me {
+		thisME.RecordCookieEvictions(count)
+	}
+}
+
+// RecordAdapterCallSkipped across all engines
+func (me *MultiMetricsEngine) RecordAdapterCallSkipped(adapter openrtb_ext.BidderName) {
+	for _, thisME := range *me {
+		thisME.RecordAdapterCallSkipped(adapter)
+	}
+}
+
+// RecordAdapterShadow across all engines
+func (me *MultiMetricsEngine) RecordAdapterShadow(comparison pbsmetrics.AdapterShadowComparison) {
+	for _, thisME := range *me {
+		thisME.RecordAdapterShadow(comparison)
+	}
+}
+
+// RecordAdapterCircuitBreakerState across all engines
+func (me *MultiMetricsEngine) RecordAdapterCircuitBreakerState(adapter openrtb_ext.BidderName, state pbsmetrics.CircuitBreakerState) {
+	for _, thisME := range *me {
+		thisME.RecordAdapterCircuitBreakerState(adapter, state)
+	}
+}
+
 // RecordAdapterCookieSync across all engines
 func (me *MultiMetricsEngine) RecordAdapterCookieSync(adapter openrtb_ext.BidderName, gdprBlocked bool) {
 	for _, thisME := range *me {
@@ -244,3 +279,28 @@ func (me *DummyMetricsEngine) RecordStoredReqCacheResult(cacheResult pbsmetrics.
 func (me *DummyMetricsEngine) RecordStoredImpCacheResult(cacheResult pbsmetrics.CacheResult, inc int) {
 	return
 }
+
+// RecordGeoLocationLookup as a noop
+func (me *DummyMetricsEngine) RecordGeoLocationLookup(result pbsmetrics.GeoLocationResult) {
+	return
+}
+
+// RecordCookieEvictions as a noop
+func (me *DummyMetricsEngine) RecordCookieEvictions(count int) {
+	return
+}
+
+// RecordAdapterCallSkipped as a noop
+func (me *DummyMetricsEngine) RecordAdapterCallSkipped(adapter openrtb_ext.BidderName) {
+	return
+}
+
+// RecordAdapterShadow as a noop
+func (me *DummyMetricsEngine) RecordAdapterShadow(comparison pbsmetrics.AdapterShadowComparison) {
+	return
+}
+
+// RecordAdapterCircuitBreakerState as a noop
+func (me *DummyMetricsEngine) RecordAdapterCircuitBreakerState(adapter openrtb_ext.BidderName, state pbsmetrics.CircuitBreakerState) {
+	return
+}
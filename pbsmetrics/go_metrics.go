@@ -24,18 +24,22 @@ type Metrics struct {
 	RequestTimer               metrics.Timer
 	StoredReqCacheMeter        map[CacheResult]metrics.Meter
 	StoredImpCacheMeter        map[CacheResult]metrics.Meter
+	GeoLocationMeter           map[GeoLocationResult]metrics.Meter
 
 	// Metrics for OpenRTB requests specifically. So we can track what % of RequestsMeter are OpenRTB
 	// and know when legacy requests have been abandoned.
-	RequestStatuses       map[RequestType]map[RequestStatus]metrics.Meter
-	AmpNoCookieMeter      metrics.Meter
-	CookieSyncMeter       metrics.Meter
-	CookieSyncGen         map[openrtb_ext.BidderName]metrics.Meter
-	CookieSyncGDPRPrevent map[openrtb_ext.BidderName]metrics.Meter
-	userSyncOptout        metrics.Meter
-	userSyncBadRequest    metrics.Meter
-	userSyncSet           map[openrtb_ext.BidderName]metrics.Meter
-	userSyncGDPRPrevent   map[openrtb_ext.BidderName]metrics.Meter
+	RequestStatuses        map[RequestType]map[RequestStatus]metrics.Meter
+	AmpNoCookieMeter       metrics.Meter
+	CookieSyncMeter        metrics.Meter
+	CookieSyncGen          map[openrtb_ext.BidderName]metrics.Meter
+	CookieSyncGDPRPrevent  map[openrtb_ext.BidderName]metrics.Meter
+	userSyncOptout         metrics.Meter
+	userSyncBadRequest     metrics.Meter
+	userSyncSet            map[openrtb_ext.BidderName]metrics.Meter
+	userSyncGDPRPrevent    map[openrtb_ext.BidderName]metrics.Meter
+	userSyncPrivacyPrevent map[openrtb_ext.BidderName]metrics.Meter
+	userSyncCookieFull     metrics.Meter
+	CookieEvictionMeter    metrics.Meter
 
 	AdapterMetrics map[openrtb_ext.BidderName]*AdapterMetrics
 	// Don't export accountMetrics because we need helper functions here to insure its properly populated dynamically
@@ -56,7 +60,17 @@ type AdapterMetrics struct {
 	PriceHistogram    metrics.Histogram
 	BidsReceivedMeter metrics.Meter
 	PanicMeter        metrics.Meter
+	SkippedMeter      metrics.Meter
 	MarkupMetrics     map[openrtb_ext.BidType]*MarkupDeliveryMetrics
+	// CircuitBreakerGauges are 1 for the state which the adapter's circuit breaker is in, and 0 for the others
+	CircuitBreakerGauges map[CircuitBreakerState]metrics.Gauge
+	// The Shadow metrics compare the requests which were mirrored to the adapter's shadow endpoint with production.
+	// The time delta is in milliseconds, and the price delta is in thousandths of a CPM.
+	ShadowTimer        metrics.Timer
+	ShadowTimeDelta    metrics.Histogram
+	ShadowPriceDelta   metrics.Histogram
+	ShadowStatusMeters map[ShadowStatus]metrics.Meter
+	ShadowFillMeters   map[ShadowFill]metrics.Meter
 }
 
 type MarkupDeliveryMetrics struct {
@@ -98,6 +112,7 @@ func NewBlankMetrics(registry metrics.Registry, exchanges []openrtb_ext.BidderNa
 		RequestTimer:               &metrics.NilTimer{},
 		StoredReqCacheMeter:        make(map[CacheResult]metrics.Meter),
 		StoredImpCacheMeter:        make(map[CacheResult]metrics.Meter),
+		GeoLocationMeter:           make(map[GeoLocationResult]metrics.Meter),
 		AmpNoCookieMeter:           blankMeter,
 		CookieSyncMeter:            blankMeter,
 		CookieSyncGen:              make(map[openrtb_ext.BidderName]metrics.Meter),
@@ -106,6 +121,9 @@ func NewBlankMetrics(registry metrics.Registry, exchanges []openrtb_ext.BidderNa
 		userSyncBadRequest:         blankMeter,
 		userSyncSet:                make(map[openrtb_ext.BidderName]metrics.Meter),
 		userSyncGDPRPrevent:        make(map[openrtb_ext.BidderName]metrics.Meter),
+		userSyncPrivacyPrevent:     make(map[openrtb_ext.BidderName]metrics.Meter),
+		userSyncCookieFull:         blankMeter,
+		CookieEvictionMeter:        blankMeter,
 
 		AdapterMetrics: make(map[openrtb_ext.BidderName]*AdapterMetrics, len(exchanges)),
 		accountMetrics: make(map[string]*accountMetrics),
@@ -116,6 +134,10 @@ func NewBlankMetrics(registry metrics.Registry, exchanges []openrtb_ext.BidderNa
 		newMetrics.AdapterMetrics[a] = makeBlankAdapterMetrics()
 	}
 
+	for _, r := range GeoLocationResults() {
+		newMetrics.GeoLocationMeter[r] = blankMeter
+	}
+
 	for _, t := range RequestTypes() {
 		newMetrics.RequestStatuses[t] = make(map[RequestStatus]metrics.Meter)
 		for _, s := range RequestStatuses() {
@@ -146,11 +168,14 @@ func NewMetrics(registry metrics.Registry, exchanges []openrtb_ext.BidderName) *
 	newMetrics.CookieSyncMeter = metrics.GetOrRegisterMeter("cookie_sync_requests", registry)
 	newMetrics.userSyncBadRequest = metrics.GetOrRegisterMeter("usersync.bad_requests", registry)
 	newMetrics.userSyncOptout = metrics.GetOrRegisterMeter("usersync.opt_outs", registry)
+	newMetrics.userSyncCookieFull = metrics.GetOrRegisterMeter("usersync.cookie_full", registry)
+	newMetrics.CookieEvictionMeter = metrics.GetOrRegisterMeter("usersync.cookie_evictions", registry)
 	for _, a := range exchanges {
 		newMetrics.CookieSyncGen[a] = metrics.GetOrRegisterMeter(fmt.Sprintf("cookie_sync.%s.gen", string(a)), registry)
 		newMetrics.CookieSyncGDPRPrevent[a] = metrics.GetOrRegisterMeter(fmt.Sprintf("cookie_sync.%s.gdpr_prevent", string(a)), registry)
 		newMetrics.userSyncSet[a] = metrics.GetOrRegisterMeter(fmt.Sprintf("usersync.%s.sets", string(a)), registry)
 		newMetrics.userSyncGDPRPrevent[a] = metrics.GetOrRegisterMeter(fmt.Sprintf("usersync.%s.gdpr_prevent", string(a)), registry)
+		newMetrics.userSyncPrivacyPrevent[a] = metrics.GetOrRegisterMeter(fmt.Sprintf("usersync.%s.privacy_prevent", string(a)), registry)
 		registerAdapterMetrics(registry, "adapter", string(a), newMetrics.AdapterMetrics[a])
 	}
 	for typ, statusMap := range newMetrics.RequestStatuses {
@@ -162,9 +187,13 @@ func NewMetrics(registry metrics.Registry, exchanges []openrtb_ext.BidderName) *
 		newMetrics.StoredReqCacheMeter[cacheRes] = metrics.GetOrRegisterMeter(fmt.Sprintf("stored_request_cache_%s", string(cacheRes)), registry)
 		newMetrics.StoredImpCacheMeter[cacheRes] = metrics.GetOrRegisterMeter(fmt.Sprintf("stored_imp_cache_%s", string(cacheRes)), registry)
 	}
+	for _, geoRes := range GeoLocationResults() {
+		newMetrics.GeoLocationMeter[geoRes] = metrics.GetOrRegisterMeter(fmt.Sprintf("geolocation_%s", string(geoRes)), registry)
+	}
 
 	newMetrics.userSyncSet[unknownBidder] = metrics.GetOrRegisterMeter("usersync.unknown.sets", registry)
 	newMetrics.userSyncGDPRPrevent[unknownBidder] = metrics.GetOrRegisterMeter("usersync.unknown.gdpr_prevent", registry)
+	newMetrics.userSyncPrivacyPrevent[unknownBidder] = metrics.GetOrRegisterMeter("usersync.unknown.privacy_prevent", registry)
 	return newMetrics
 }
 
@@ -180,11 +209,28 @@ func makeBlankAdapterMetrics() *AdapterMetrics {
 		PriceHistogram:    &metrics.NilHistogram{},
 		BidsReceivedMeter: blankMeter,
 		PanicMeter:        blankMeter,
+		SkippedMeter:      blankMeter,
 		MarkupMetrics:     makeBlankBidMarkupMetrics(),
+
+		CircuitBreakerGauges: make(map[CircuitBreakerState]metrics.Gauge),
+		ShadowTimer:          &metrics.NilTimer{},
+		ShadowTimeDelta:      &metrics.NilHistogram{},
+		ShadowPriceDelta:     &metrics.NilHistogram{},
+		ShadowStatusMeters:   make(map[ShadowStatus]metrics.Meter),
+		ShadowFillMeters:     make(map[ShadowFill]metrics.Meter),
 	}
 	for _, err := range AdapterErrors() {
 		newAdapter.ErrorMeters[err] = blankMeter
 	}
+	for _, state := range CircuitBreakerStates() {
+		newAdapter.CircuitBreakerGauges[state] = metrics.NilGauge{}
+	}
+	for _, status := range ShadowStatuses() {
+		newAdapter.ShadowStatusMeters[status] = blankMeter
+	}
+	for _, fill := range ShadowFills() {
+		newAdapter.ShadowFillMeters[fill] = blankMeter
+	}
 	return newAdapter
 }
 
@@ -223,6 +269,21 @@ func registerAdapterMetrics(registry metrics.Registry, adapterOrAccount string,
 		am.BidsReceivedMeter = metrics.GetOrRegisterMeter(fmt.Sprintf("%[1]s.%[2]s.bids_received", adapterOrAccount, exchange), registry)
 	}
 	am.PanicMeter = metrics.GetOrRegisterMeter(fmt.Sprintf("%[1]s.%[2]s.requests.panic", adapterOrAccount, exchange), registry)
+	if adapterOrAccount == "adapter" {
+		am.SkippedMeter = metrics.GetOrRegisterMeter(fmt.Sprintf("%[1]s.%[2]s.requests.skipped", adapterOrAccount, exchange), registry)
+		for state := range am.CircuitBreakerGauges {
+			am.CircuitBreakerGauges[state] = metrics.GetOrRegisterGauge(fmt.Sprintf("%[1]s.%[2]s.circuit_breaker.%[3]s", adapterOrAccount, exchange, state), registry)
+		}
+		am.ShadowTimer = metrics.GetOrRegisterTimer(fmt.Sprintf("%[1]s.%[2]s.shadow.request_time", adapterOrAccount, exchange), registry)
+		am.ShadowTimeDelta = metrics.GetOrRegisterHistogram(fmt.Sprintf("%[1]s.%[2]s.shadow.time_delta", adapterOrAccount, exchange), registry, metrics.NewExpDecaySample(1028, 0.015))
+		am.ShadowPriceDelta = metrics.GetOrRegisterHistogram(fmt.Sprintf("%[1]s.%[2]s.shadow.price_delta", adapterOrAccount, exchange), registry, metrics.NewExpDecaySample(1028, 0.015))
+		for status := range am.ShadowStatusMeters {
+			am.ShadowStatusMeters[status] = metrics.GetOrRegisterMeter(fmt.Sprintf("%[1]s.%[2]s.shadow.status.%[3]s", adapterOrAccount, exchange, status), registry)
+		}
+		for fill := range am.ShadowFillMeters {
+			am.ShadowFillMeters[fill] = metrics.GetOrRegisterMeter(fmt.Sprintf("%[1]s.%[2]s.shadow.fill.%[3]s", adapterOrAccount, exchange, fill), registry)
+		}
+	}
 }
 
 func makeDeliveryMetrics(registry metrics.Registry, prefix string, bidType openrtb_ext.BidType) *MarkupDeliveryMetrics {
@@ -443,6 +504,63 @@ func (me *Metrics) RecordUserIDSet(userLabels UserLabels) {
 		doMark(userLabels.Bidder, me.userSyncSet)
 	case RequestActionGDPR:
 		doMark(userLabels.Bidder, me.userSyncGDPRPrevent)
+	case RequestActionPrivacy:
+		doMark(userLabels.Bidder, me.userSyncPrivacyPrevent)
+	case RequestActionCookieFull:
+		me.userSyncCookieFull.Mark(1)
+	}
+}
+
+// RecordCookieEvictions implements a part of the MetricsEngine interface. Records the UIDs which were evicted
+// from the uids cookie to keep it under the max size
+func (me *Metrics) RecordCookieEvictions(count int) {
+	me.CookieEvictionMeter.Mark(int64(count))
+}
+
+// RecordAdapterCallSkipped implements a part of the MetricsEngine interface. Records the calls to the adapter
+// which traffic shaping skipped
+func (me *Metrics) RecordAdapterCallSkipped(adapter openrtb_ext.BidderName) {
+	am, ok := me.AdapterMetrics[adapter]
+	if !ok {
+		glog.Errorf("Trying to run adapter skipped call metrics on %s: adapter metrics not found", string(adapter))
+		return
+	}
+	am.SkippedMeter.Mark(1)
+}
+
+// RecordAdapterShadow implements a part of the MetricsEngine interface. Records how a request which was mirrored
+// to the adapter's shadow endpoint compared with production
+func (me *Metrics) RecordAdapterShadow(comparison AdapterShadowComparison) {
+	am, ok := me.AdapterMetrics[comparison.Adapter]
+	if !ok {
+		glog.Errorf("Trying to run adapter shadow metrics on %s: adapter metrics not found", string(comparison.Adapter))
+		return
+	}
+	am.ShadowStatusMeters[comparison.Status].Mark(1)
+	am.ShadowFillMeters[comparison.Fill].Mark(1)
+	// The times and prices of shadow requests which failed would only skew the deltas.
+	if comparison.Status == ShadowStatusError {
+		return
+	}
+	am.ShadowTimer.Update(comparison.Time)
+	am.ShadowTimeDelta.Update(int64(comparison.TimeDelta / time.Millisecond))
+	am.ShadowPriceDelta.Update(int64(comparison.PriceDelta * 1000))
+}
+
+// RecordAdapterCircuitBreakerState implements a part of the MetricsEngine interface. Records the state
+// which the adapter's circuit breaker is in
+func (me *Metrics) RecordAdapterCircuitBreakerState(adapter openrtb_ext.BidderName, state CircuitBreakerState) {
+	am, ok := me.AdapterMetrics[adapter]
+	if !ok {
+		glog.Errorf("Trying to run adapter circuit breaker metrics on %s: adapter metrics not found", string(adapter))
+		return
+	}
+	for s, gauge := range am.CircuitBreakerGauges {
+		if s == state {
+			gauge.Update(1)
+		} else {
+			gauge.Update(0)
+		}
 	}
 }
 
@@ -456,6 +574,11 @@ func (me *Metrics) RecordStoredImpCacheResult(cacheResult CacheResult, inc int)
 	me.StoredImpCacheMeter[cacheResult].Mark(int64(inc))
 }
 
+// RecordGeoLocationLookup implements a part of the MetricsEngine interface. Records the result of an IP geolocation lookup
+func (me *Metrics) RecordGeoLocationLookup(result GeoLocationResult) {
+	me.GeoLocationMeter[result].Mark(1)
+}
+
 func doMark(bidder openrtb_ext.BidderName, meters map[openrtb_ext.BidderName]metrics.Meter) {
 	met, ok := meters[bidder]
 	if ok {
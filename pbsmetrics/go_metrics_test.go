@@ -2,6 +2,7 @@ package pbsmetrics
 
 import (
 	"testing"
+	"time"
 
 	"github.com/prebid/prebid-server/openrtb_ext"
 	metrics "github.com/rcrowley/go-metrics"
@@ -25,6 +26,8 @@ func TestNewMetrics(t *testing.T) {
 	ensureContains(t, registry, "usersync.appnexus.gdpr_prevent", m.userSyncGDPRPrevent["appnexus"])
 	ensureContains(t, registry, "usersync.rubicon.gdpr_prevent", m.userSyncGDPRPrevent["rubicon"])
 	ensureContains(t, registry, "usersync.unknown.gdpr_prevent", m.userSyncGDPRPrevent["unknown"])
+	ensureContains(t, registry, "usersync.appnexus.privacy_prevent", m.userSyncPrivacyPrevent["appnexus"])
+	ensureContains(t, registry, "usersync.unknown.privacy_prevent", m.userSyncPrivacyPrevent["unknown"])
 
 	ensureContains(t, registry, "requests.ok.legacy", m.RequestStatuses[ReqTypeLegacy][RequestStatusOK])
 	ensureContains(t, registry, "requests.badinput.legacy", m.RequestStatuses[ReqTypeLegacy][RequestStatusBadInput])
@@ -75,6 +78,99 @@ func TestRecordGDPRRejection(t *testing.T) {
 	VerifyMetrics(t, "GDPR sync rejects", m.userSyncGDPRPrevent[openrtb_ext.BidderAppnexus].Count(), 1)
 }
 
+func TestRecordPrivacyRejection(t *testing.T) {
+	registry := metrics.NewRegistry()
+	m := NewMetrics(registry, []openrtb_ext.BidderName{openrtb_ext.BidderAppnexus})
+	m.RecordUserIDSet(UserLabels{
+		Action: RequestActionPrivacy,
+		Bidder: openrtb_ext.BidderAppnexus,
+	})
+	VerifyMetrics(t, "Privacy sync rejects", m.userSyncPrivacyPrevent[openrtb_ext.BidderAppnexus].Count(), 1)
+	VerifyMetrics(t, "GDPR sync rejects", m.userSyncGDPRPrevent[openrtb_ext.BidderAppnexus].Count(), 0)
+}
+
+func TestRecordGeoLocationLookup(t *testing.T) {
+	registry := metrics.NewRegistry()
+	m := NewMetrics(registry, []openrtb_ext.BidderName{openrtb_ext.BidderAppnexus})
+	m.RecordGeoLocationLookup(GeoLocationFound)
+	m.RecordGeoLocationLookup(GeoLocationCacheHit)
+	m.RecordGeoLocationLookup(GeoLocationCacheHit)
+	ensureContains(t, registry, "geolocation_found", m.GeoLocationMeter[GeoLocationFound])
+	VerifyMetrics(t, "Geolocation found", m.GeoLocationMeter[GeoLocationFound].Count(), 1)
+	VerifyMetrics(t, "Geolocation cache hits", m.GeoLocationMeter[GeoLocationCacheHit].Count(), 2)
+	VerifyMetrics(t, "Geolocation errors", m.GeoLocationMeter[GeoLocationError].Count(), 0)
+}
+
+func TestRecordCookieEvictions(t *testing.T) {
+	registry := metrics.NewRegistry()
+	m := NewMetrics(registry, []openrtb_ext.BidderName{openrtb_ext.BidderAppnexus})
+	m.RecordCookieEvictions(2)
+	m.RecordCookieEvictions(1)
+	m.RecordUserIDSet(UserLabels{Action: RequestActionCookieFull, Bidder: openrtb_ext.BidderAppnexus})
+	ensureContains(t, registry, "usersync.cookie_evictions", m.CookieEvictionMeter)
+	VerifyMetrics(t, "Cookie evictions", m.CookieEvictionMeter.Count(), 3)
+	VerifyMetrics(t, "Cookie full", m.userSyncCookieFull.Count(), 1)
+}
+
+func TestRecordAdapterCallSkipped(t *testing.T) {
+	registry := metrics.NewRegistry()
+	m := NewMetrics(registry, []openrtb_ext.BidderName{openrtb_ext.BidderAppnexus})
+	m.RecordAdapterCallSkipped(openrtb_ext.BidderAppnexus)
+	m.RecordAdapterCallSkipped(openrtb_ext.BidderAppnexus)
+	am := m.AdapterMetrics[openrtb_ext.BidderAppnexus]
+	ensureContains(t, registry, "adapter.appnexus.requests.skipped", am.SkippedMeter)
+	VerifyMetrics(t, "Skipped calls", am.SkippedMeter.Count(), 2)
+}
+
+func TestRecordAdapterCircuitBreakerState(t *testing.T) {
+	registry := metrics.NewRegistry()
+	m := NewMetrics(registry, []openrtb_ext.BidderName{openrtb_ext.BidderAppnexus})
+	m.RecordAdapterCircuitBreakerState(openrtb_ext.BidderAppnexus, CircuitBreakerOpen)
+	m.RecordAdapterCircuitBreakerState(openrtb_ext.BidderAppnexus, CircuitBreakerHalfOpen)
+	gauges := m.AdapterMetrics[openrtb_ext.BidderAppnexus].CircuitBreakerGauges
+	ensureContains(t, registry, "adapter.appnexus.circuit_breaker.half_open", gauges[CircuitBreakerHalfOpen])
+	VerifyMetrics(t, "Circuit breaker half open", gauges[CircuitBreakerHalfOpen].Value(), 1)
+	VerifyMetrics(t, "Circuit breaker open", gauges[CircuitBreakerOpen].Value(), 0)
+	VerifyMetrics(t, "Circuit breaker closed", gauges[CircuitBreakerClosed].Value(), 0)
+}
+
+func TestRecordAdapterShadow(t *testing.T) {
+	registry := metrics.NewRegistry()
+	m := NewMetrics(registry, []openrtb_ext.BidderName{openrtb_ext.BidderAppnexus})
+	m.RecordAdapterShadow(AdapterShadowComparison{
+		Adapter:    openrtb_ext.BidderAppnexus,
+		Status:     ShadowStatus2xx,
+		Fill:       ShadowFillSame,
+		Time:       100 * time.Millisecond,
+		TimeDelta:  -20 * time.Millisecond,
+		PriceDelta: 0.25,
+	})
+	m.RecordAdapterShadow(AdapterShadowComparison{
+		Adapter: openrtb_ext.BidderAppnexus,
+		Status:  ShadowStatusError,
+		Fill:    ShadowFillFewer,
+	})
+	am := m.AdapterMetrics[openrtb_ext.BidderAppnexus]
+	ensureContains(t, registry, "adapter.appnexus.shadow.status.2xx", am.ShadowStatusMeters[ShadowStatus2xx])
+	ensureContains(t, registry, "adapter.appnexus.shadow.fill.fewer", am.ShadowFillMeters[ShadowFillFewer])
+	VerifyMetrics(t, "Shadow 2xx", am.ShadowStatusMeters[ShadowStatus2xx].Count(), 1)
+	VerifyMetrics(t, "Shadow errors", am.ShadowStatusMeters[ShadowStatusError].Count(), 1)
+	VerifyMetrics(t, "Shadow same fill", am.ShadowFillMeters[ShadowFillSame].Count(), 1)
+	VerifyMetrics(t, "Shadow fewer fill", am.ShadowFillMeters[ShadowFillFewer].Count(), 1)
+	VerifyMetrics(t, "Shadow times", am.ShadowTimer.Count(), 1)
+	VerifyMetrics(t, "Shadow time delta", am.ShadowTimeDelta.Max(), -20)
+	VerifyMetrics(t, "Shadow price delta", am.ShadowPriceDelta.Max(), 250)
+}
+
+func TestShadowStatusOf(t *testing.T) {
+	expected := map[int]ShadowStatus{204: ShadowStatus2xx, 301: ShadowStatus3xx, 400: ShadowStatus4xx, 503: ShadowStatus5xx, 0: ShadowStatusError}
+	for status, class := range expected {
+		if actual := ShadowStatusOf(status); actual != class {
+			t.Errorf("Bad shadow status for %d: expected %s, got %s.", status, class, actual)
+		}
+	}
+}
+
 func ensureContains(t *testing.T, registry metrics.Registry, name string, metric interface{}) {
 	t.Helper()
 	if inRegistry := registry.Get(name); inRegistry == nil {
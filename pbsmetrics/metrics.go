@@ -152,6 +152,7 @@ const (
 	AdapterErrorBadServerResponse   AdapterError = "badserverresponse"
 	AdapterErrorTimeout             AdapterError = "timeout"
 	AdapterErrorFailedToRequestBids AdapterError = "failedtorequestbid"
+	AdapterErrorCircuitOpen         AdapterError = "circuitopen"
 	AdapterErrorUnknown             AdapterError = "unknown_error"
 )
 
@@ -161,6 +162,7 @@ func AdapterErrors() []AdapterError {
 		AdapterErrorBadServerResponse,
 		AdapterErrorTimeout,
 		AdapterErrorFailedToRequestBids,
+		AdapterErrorCircuitOpen,
 		AdapterErrorUnknown,
 	}
 }
@@ -181,6 +183,131 @@ func CacheResults() []CacheResult {
 	}
 }
 
+// GeoLocationResult : The outcome of an IP geolocation lookup
+type GeoLocationResult string
+
+const (
+	// GeoLocationCacheHit means the country was already cached for the IP
+	GeoLocationCacheHit GeoLocationResult = "cache_hit"
+	// GeoLocationFound means the database had a country for the IP
+	GeoLocationFound GeoLocationResult = "found"
+	// GeoLocationNotFound means the database had no country for the IP
+	GeoLocationNotFound GeoLocationResult = "not_found"
+	// GeoLocationError means the lookup failed, e.g. because the IP was invalid
+	GeoLocationError GeoLocationResult = "error"
+)
+
+// GeoLocationResults returns the possible geolocation lookup results
+func GeoLocationResults() []GeoLocationResult {
+	return []GeoLocationResult{
+		GeoLocationCacheHit,
+		GeoLocationFound,
+		GeoLocationNotFound,
+		GeoLocationError,
+	}
+}
+
+// CircuitBreakerState is the state of a Bidder's circuit breaker
+type CircuitBreakerState string
+
+const (
+	// CircuitBreakerClosed means that all requests go to the Bidder
+	CircuitBreakerClosed CircuitBreakerState = "closed"
+	// CircuitBreakerOpen means that the Bidder is being skipped
+	CircuitBreakerOpen CircuitBreakerState = "open"
+	// CircuitBreakerHalfOpen means that only a fraction of the requests go to the Bidder, to probe whether it has recovered
+	CircuitBreakerHalfOpen CircuitBreakerState = "half_open"
+)
+
+// CircuitBreakerStates returns the possible circuit breaker states
+func CircuitBreakerStates() []CircuitBreakerState {
+	return []CircuitBreakerState{
+		CircuitBreakerClosed,
+		CircuitBreakerOpen,
+		CircuitBreakerHalfOpen,
+	}
+}
+
+// AdapterShadowComparison compares a request which was mirrored to a Bidder's shadow endpoint with the same
+// request to its production endpoint. The deltas are the shadow endpoint's value minus the production endpoint's.
+type AdapterShadowComparison struct {
+	Adapter openrtb_ext.BidderName
+	Status  ShadowStatus
+	Fill    ShadowFill
+	// Time is how long the shadow endpoint took to respond
+	Time      time.Duration
+	TimeDelta time.Duration
+	// PriceDelta is the difference in the total CPM of the bids, before any currency conversions or bid adjustments
+	PriceDelta float64
+}
+
+// ShadowStatus is the class of the HTTP status which a Bidder's shadow endpoint responded with
+type ShadowStatus string
+
+const (
+	ShadowStatus2xx ShadowStatus = "2xx"
+	ShadowStatus3xx ShadowStatus = "3xx"
+	ShadowStatus4xx ShadowStatus = "4xx"
+	ShadowStatus5xx ShadowStatus = "5xx"
+	// ShadowStatusError means that the shadow endpoint didn't respond, or that its response couldn't be read
+	ShadowStatusError ShadowStatus = "error"
+)
+
+// ShadowStatuses returns the possible shadow statuses
+func ShadowStatuses() []ShadowStatus {
+	return []ShadowStatus{
+		ShadowStatus2xx,
+		ShadowStatus3xx,
+		ShadowStatus4xx,
+		ShadowStatus5xx,
+		ShadowStatusError,
+	}
+}
+
+// ShadowStatusOf returns the class of an HTTP status. Statuses outside of 200-599 are errors.
+func ShadowStatusOf(status int) ShadowStatus {
+	switch {
+	case status >= 200 && status < 300:
+		return ShadowStatus2xx
+	case status >= 300 && status < 400:
+		return ShadowStatus3xx
+	case status >= 400 && status < 500:
+		return ShadowStatus4xx
+	case status >= 500 && status < 600:
+		return ShadowStatus5xx
+	}
+	return ShadowStatusError
+}
+
+// ShadowFill compares the number of bids from a Bidder's shadow endpoint with the number from its production endpoint
+type ShadowFill string
+
+const (
+	ShadowFillMore  ShadowFill = "more"
+	ShadowFillSame  ShadowFill = "same"
+	ShadowFillFewer ShadowFill = "fewer"
+)
+
+// ShadowFills returns the possible shadow fills
+func ShadowFills() []ShadowFill {
+	return []ShadowFill{
+		ShadowFillMore,
+		ShadowFillSame,
+		ShadowFillFewer,
+	}
+}
+
+// ShadowFillOf returns the shadow fill for the shadow endpoint's bids minus the production endpoint's
+func ShadowFillOf(delta int) ShadowFill {
+	switch {
+	case delta > 0:
+		return ShadowFillMore
+	case delta < 0:
+		return ShadowFillFewer
+	}
+	return ShadowFillSame
+}
+
 // UserLabels : Labels for /setuid endpoint
 type UserLabels struct {
 	Action RequestAction
@@ -192,10 +319,13 @@ type RequestAction string
 
 // /setuid action labels
 const (
-	RequestActionSet    RequestAction = "set"
-	RequestActionOptOut RequestAction = "opt_out"
-	RequestActionGDPR   RequestAction = "gdpr"
-	RequestActionErr    RequestAction = "err"
+	RequestActionSet     RequestAction = "set"
+	RequestActionOptOut  RequestAction = "opt_out"
+	RequestActionGDPR    RequestAction = "gdpr"
+	RequestActionPrivacy RequestAction = "privacy"
+	// RequestActionCookieFull means the UID wasn't saved, because the uids cookie had no room for it
+	RequestActionCookieFull RequestAction = "cookie_full"
+	RequestActionErr        RequestAction = "err"
 )
 
 // MetricsEngine is a generic interface to record PBS metrics into the desired backend
@@ -222,4 +352,9 @@ type MetricsEngine interface {
 	RecordUserIDSet(userLabels UserLabels) // Function should verify bidder values
 	RecordStoredReqCacheResult(cacheResult CacheResult, inc int)
 	RecordStoredImpCacheResult(cacheResult CacheResult, inc int)
+	RecordGeoLocationLookup(result GeoLocationResult)
+	RecordCookieEvictions(count int) // UIDs evicted from the uids cookie to keep it under the max size
+	RecordAdapterCircuitBreakerState(adapter openrtb_ext.BidderName, state CircuitBreakerState)
+	RecordAdapterCallSkipped(adapter openrtb_ext.BidderName) // Calls which traffic shaping skipped, because the bidder was unlikely to bid
+	RecordAdapterShadow(comparison AdapterShadowComparison)  // Requests which were mirrored to the bidder's shadow endpoint
 }
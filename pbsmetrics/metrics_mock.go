@@ -101,3 +101,33 @@ func (me *MetricsEngineMock) RecordStoredImpCacheResult(cacheResult CacheResult,
 	me.Called(cacheResult, inc)
 	return
 }
+
+// RecordGeoLocationLookup mock
+func (me *MetricsEngineMock) RecordGeoLocationLookup(result GeoLocationResult) {
+	me.Called(result)
+	return
+}
+
+// RecordCookieEvictions mock
+func (me *MetricsEngineMock) RecordCookieEvictions(count int) {
+	me.Called(count)
+	return
+}
+
+// RecordAdapterCallSkipped mock
+func (me *MetricsEngineMock) RecordAdapterCallSkipped(adapter openrtb_ext.BidderName) {
+	me.Called(adapter)
+	return
+}
+
+// RecordAdapterShadow mock
+func (me *MetricsEngineMock) RecordAdapterShadow(comparison AdapterShadowComparison) {
+	me.Called(comparison)
+	return
+}
+
+// RecordAdapterCircuitBreakerState mock
+func (me *MetricsEngineMock) RecordAdapterCircuitBreakerState(adapter openrtb_ext.BidderName, state CircuitBreakerState) {
+	me.Called(adapter, state)
+	return
+}
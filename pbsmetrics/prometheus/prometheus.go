@@ -29,6 +29,14 @@ type Metrics struct {
 	userID               *prometheus.CounterVec
 	storedReqCacheResult *prometheus.CounterVec
 	storedImpCacheResult *prometheus.CounterVec
+	geoLocation          *prometheus.CounterVec
+	cookieEvictions      prometheus.Counter
+	adaptCircuitBreaker  *prometheus.GaugeVec
+	adaptSkipped         *prometheus.CounterVec
+	adaptShadowRequests  *prometheus.CounterVec
+	adaptShadowTime      *prometheus.HistogramVec
+	adaptShadowTimeDelta *prometheus.HistogramVec
+	adaptShadowPrice     *prometheus.HistogramVec
 }
 
 // NewMetrics constructs the appropriate options for the Prometheus metrics. Needs to be fed the promethus config
@@ -98,6 +106,11 @@ func NewMetrics(cfg config.PrometheusMetrics) *Metrics {
 		standardLabelNames,
 	)
 	metrics.Registry.MustRegister(metrics.storedImpCacheResult)
+	metrics.geoLocation = newCounter(cfg, "geolocation_lookups",
+		"Number of IP geolocation lookups by result",
+		[]string{"geolocation_result"},
+	)
+	metrics.Registry.MustRegister(metrics.geoLocation)
 	metrics.adaptPrices = newHistogram(cfg, "adapter_prices",
 		"Values of the bids from each bidder.",
 		adapterLabelNames, prometheus.LinearBuckets(0.1, 0.1, 200),
@@ -120,6 +133,45 @@ func NewMetrics(cfg config.PrometheusMetrics) *Metrics {
 		[]string{"action", "bidder"},
 	)
 	metrics.Registry.MustRegister(metrics.userID)
+	metrics.cookieEvictions = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: cfg.Namespace,
+		Subsystem: cfg.Subsystem,
+		Name:      "cookie_evictions_total",
+		Help:      "Number of UIDs evicted from the uids cookie to keep it under the max size.",
+	})
+	metrics.Registry.MustRegister(metrics.cookieEvictions)
+	metrics.adaptCircuitBreaker = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: cfg.Namespace,
+		Subsystem: cfg.Subsystem,
+		Name:      "adapter_circuit_breaker",
+		Help:      "1 for the state which each bidder's circuit breaker is in, and 0 for the others.",
+	}, []string{"adapter", "state"})
+	metrics.Registry.MustRegister(metrics.adaptCircuitBreaker)
+	metrics.adaptSkipped = newCounter(cfg, "adapter_skipped_requests_total",
+		"Number of requests to each bidder which traffic shaping skipped, because the bidder was unlikely to bid.",
+		[]string{"adapter"},
+	)
+	metrics.Registry.MustRegister(metrics.adaptSkipped)
+	metrics.adaptShadowRequests = newCounter(cfg, "adapter_shadow_requests_total",
+		"Number of requests mirrored to each bidder's shadow endpoint, by its HTTP status class and whether it returned more, the same number of, or fewer bids than production.",
+		[]string{"adapter", "status", "fill"},
+	)
+	metrics.Registry.MustRegister(metrics.adaptShadowRequests)
+	metrics.adaptShadowTime = newHistogram(cfg, "adapter_shadow_time_seconds",
+		"Seconds which each bidder's shadow endpoint took to respond.",
+		[]string{"adapter"}, timerBuckets,
+	)
+	metrics.Registry.MustRegister(metrics.adaptShadowTime)
+	metrics.adaptShadowTimeDelta = newHistogram(cfg, "adapter_shadow_time_delta_seconds",
+		"Seconds which each bidder's shadow endpoint took to respond, minus the seconds which production took.",
+		[]string{"adapter"}, prometheus.LinearBuckets(-1, 0.05, 41),
+	)
+	metrics.Registry.MustRegister(metrics.adaptShadowTimeDelta)
+	metrics.adaptShadowPrice = newHistogram(cfg, "adapter_shadow_price_delta",
+		"Total CPM of the bids from each bidder's shadow endpoint, minus the total CPM of the bids from production.",
+		[]string{"adapter"}, prometheus.LinearBuckets(-5, 0.25, 41),
+	)
+	metrics.Registry.MustRegister(metrics.adaptShadowPrice)
 
 	initializeTimeSeries(&metrics)
 
@@ -255,6 +307,57 @@ func (me *Metrics) RecordStoredImpCacheResult(cacheResult pbsmetrics.CacheResult
 	me.storedImpCacheResult.With(labels).Add(float64(inc))
 }
 
+// RecordGeoLocationLookup records the result of an IP geolocation lookup
+func (me *Metrics) RecordGeoLocationLookup(result pbsmetrics.GeoLocationResult) {
+	me.geoLocation.With(prometheus.Labels{
+		"geolocation_result": string(result),
+	}).Inc()
+}
+
+// RecordCookieEvictions records the UIDs which were evicted from the uids cookie
+func (me *Metrics) RecordCookieEvictions(count int) {
+	me.cookieEvictions.Add(float64(count))
+}
+
+// RecordAdapterCallSkipped records the calls to the bidder which traffic shaping skipped
+func (me *Metrics) RecordAdapterCallSkipped(adapter openrtb_ext.BidderName) {
+	me.adaptSkipped.With(prometheus.Labels{
+		"adapter": string(adapter),
+	}).Inc()
+}
+
+// RecordAdapterShadow records how a request which was mirrored to the bidder's shadow endpoint compared with production
+func (me *Metrics) RecordAdapterShadow(comparison pbsmetrics.AdapterShadowComparison) {
+	adapter := prometheus.Labels{
+		"adapter": string(comparison.Adapter),
+	}
+	me.adaptShadowRequests.With(prometheus.Labels{
+		"adapter": string(comparison.Adapter),
+		"status":  string(comparison.Status),
+		"fill":    string(comparison.Fill),
+	}).Inc()
+	if comparison.Status == pbsmetrics.ShadowStatusError {
+		return
+	}
+	me.adaptShadowTime.With(adapter).Observe(comparison.Time.Seconds())
+	me.adaptShadowTimeDelta.With(adapter).Observe(comparison.TimeDelta.Seconds())
+	me.adaptShadowPrice.With(adapter).Observe(comparison.PriceDelta)
+}
+
+// RecordAdapterCircuitBreakerState records the state which the bidder's circuit breaker is in
+func (me *Metrics) RecordAdapterCircuitBreakerState(adapter openrtb_ext.BidderName, state pbsmetrics.CircuitBreakerState) {
+	for _, s := range pbsmetrics.CircuitBreakerStates() {
+		value := 0.0
+		if s == state {
+			value = 1
+		}
+		me.adaptCircuitBreaker.With(prometheus.Labels{
+			"adapter": string(adapter),
+			"state":   string(s),
+		}).Set(value)
+	}
+}
+
 func (me *Metrics) RecordUserIDSet(userLabels pbsmetrics.UserLabels) {
 	me.userID.With(resolveUserSyncLabels(userLabels)).Inc()
 }
@@ -360,11 +463,17 @@ func initializeTimeSeries(m *Metrics) {
 	for _, l := range labels {
 		_ = m.adaptErrors.With(l)
 	}
+	for _, l := range addDimension([]prometheus.Labels{}, "adapter", adaptersAsString()) {
+		_ = m.adaptSkipped.With(l)
+	}
 	cookieLabels := addDimension([]prometheus.Labels{}, "adapter", adaptersAsString())
 	cookieLabels = addDimension(cookieLabels, "gdpr_blocked", []string{"true", "false"})
 	for _, l := range cookieLabels {
 		_ = m.adaptCookieSync.With(l)
 	}
+	for _, l := range addDimension([]prometheus.Labels{}, "geolocation_result", geoLocationResultsAsString()) {
+		_ = m.geoLocation.With(l)
+	}
 }
 
 // addDimesion will expand a slice of labels to add the dimension of a new set of values for a new label name
@@ -409,6 +518,15 @@ func demandTypesAsString() []string {
 	return output
 }
 
+func geoLocationResultsAsString() []string {
+	list := pbsmetrics.GeoLocationResults()
+	output := make([]string, len(list))
+	for i, s := range list {
+		output[i] = string(s)
+	}
+	return output
+}
+
 func requestTypesAsString() []string {
 	list := pbsmetrics.RequestTypes()
 	output := make([]string, len(list))
@@ -323,6 +323,95 @@ func TestUserMetrics(t *testing.T) {
 	assertCounterValue(t, "usersync[3]", &metrics3, 0)
 }
 
+func TestGeoLocationMetrics(t *testing.T) {
+	proMetrics := newTestMetricsEngine()
+
+	found := dto.Metric{}
+	notFound := dto.Metric{}
+
+	proMetrics.RecordGeoLocationLookup(pbsmetrics.GeoLocationFound)
+	proMetrics.RecordGeoLocationLookup(pbsmetrics.GeoLocationFound)
+	proMetrics.RecordGeoLocationLookup(pbsmetrics.GeoLocationCacheHit)
+
+	proMetrics.geoLocation.With(prometheus.Labels{"geolocation_result": string(pbsmetrics.GeoLocationFound)}).Write(&found)
+	proMetrics.geoLocation.With(prometheus.Labels{"geolocation_result": string(pbsmetrics.GeoLocationNotFound)}).Write(&notFound)
+
+	assertCounterValue(t, "geolocation_found", &found, 2)
+	assertCounterValue(t, "geolocation_not_found", &notFound, 0)
+}
+
+func TestCookieEvictionMetrics(t *testing.T) {
+	proMetrics := newTestMetricsEngine()
+
+	evictions := dto.Metric{}
+
+	proMetrics.RecordCookieEvictions(2)
+	proMetrics.RecordCookieEvictions(3)
+
+	proMetrics.cookieEvictions.Write(&evictions)
+
+	assertCounterValue(t, "cookie_evictions", &evictions, 5)
+}
+
+func TestAdapterSkippedMetrics(t *testing.T) {
+	proMetrics := newTestMetricsEngine()
+
+	skipped := dto.Metric{}
+
+	proMetrics.RecordAdapterCallSkipped(openrtb_ext.BidderAppnexus)
+	proMetrics.RecordAdapterCallSkipped(openrtb_ext.BidderAppnexus)
+
+	proMetrics.adaptSkipped.With(prometheus.Labels{"adapter": "appnexus"}).Write(&skipped)
+
+	assertCounterValue(t, "adapter_skipped_requests", &skipped, 2)
+}
+
+func TestAdapterShadowMetrics(t *testing.T) {
+	proMetrics := newTestMetricsEngine()
+
+	requests := dto.Metric{}
+	failed := dto.Metric{}
+	timeDelta := dto.Metric{}
+
+	proMetrics.RecordAdapterShadow(pbsmetrics.AdapterShadowComparison{
+		Adapter:    openrtb_ext.BidderAppnexus,
+		Status:     pbsmetrics.ShadowStatus2xx,
+		Fill:       pbsmetrics.ShadowFillMore,
+		Time:       100 * time.Millisecond,
+		TimeDelta:  -20 * time.Millisecond,
+		PriceDelta: 0.5,
+	})
+	proMetrics.RecordAdapterShadow(pbsmetrics.AdapterShadowComparison{
+		Adapter: openrtb_ext.BidderAppnexus,
+		Status:  pbsmetrics.ShadowStatusError,
+		Fill:    pbsmetrics.ShadowFillFewer,
+	})
+
+	proMetrics.adaptShadowRequests.With(prometheus.Labels{"adapter": "appnexus", "status": "2xx", "fill": "more"}).Write(&requests)
+	proMetrics.adaptShadowRequests.With(prometheus.Labels{"adapter": "appnexus", "status": "error", "fill": "fewer"}).Write(&failed)
+	proMetrics.adaptShadowTimeDelta.With(prometheus.Labels{"adapter": "appnexus"}).(prometheus.Histogram).Write(&timeDelta)
+
+	assertCounterValue(t, "adapter_shadow_requests", &requests, 1)
+	assertCounterValue(t, "adapter_shadow_requests", &failed, 1)
+	assertHistogramValue(t, "adapter_shadow_time_delta", &timeDelta, 1)
+}
+
+func TestCircuitBreakerMetrics(t *testing.T) {
+	proMetrics := newTestMetricsEngine()
+
+	open := dto.Metric{}
+	closed := dto.Metric{}
+
+	proMetrics.RecordAdapterCircuitBreakerState(openrtb_ext.BidderAppnexus, pbsmetrics.CircuitBreakerClosed)
+	proMetrics.RecordAdapterCircuitBreakerState(openrtb_ext.BidderAppnexus, pbsmetrics.CircuitBreakerOpen)
+
+	proMetrics.adaptCircuitBreaker.With(prometheus.Labels{"adapter": "appnexus", "state": "open"}).Write(&open)
+	proMetrics.adaptCircuitBreaker.With(prometheus.Labels{"adapter": "appnexus", "state": "closed"}).Write(&closed)
+
+	assertGaugeValue(t, "circuit_breaker_open", &open, 1)
+	assertGaugeValue(t, "circuit_breaker_closed", &closed, 0)
+}
+
 func TestMetricsExist(t *testing.T) {
 	// Initialize the metrics engine -> register the metrics to prometheus
 	metrics := newTestMetricsEngine()
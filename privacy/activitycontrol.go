@@ -0,0 +1,231 @@
+// Package privacy decides whether PBS may perform privacy-sensitive activities for a request.
+//
+// The decisions are driven by the host's "privacy" config, so that new privacy laws can be supported
+// with config changes instead of new code paths.
+package privacy
+
+import (
+	"strings"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/gpp"
+	"github.com/prebid/prebid-server/openrtb_ext"
+)
+
+// Activity is something PBS does which may be restricted by privacy laws.
+type Activity int
+
+const (
+	// ActivitySyncUser covers user syncs from /cookie_sync and /setuid.
+	ActivitySyncUser Activity = iota
+	// ActivityFetchBids covers sending a bid request to a bidder.
+	ActivityFetchBids
+	// ActivityTransmitUserFPD covers sending user first party data and device IDs to a bidder.
+	ActivityTransmitUserFPD
+	// ActivityTransmitPreciseGeo covers sending full IP addresses and lat/lon to a bidder.
+	ActivityTransmitPreciseGeo
+	// ActivityTransmitEIDs covers sending extended IDs to a bidder.
+	ActivityTransmitEIDs
+	// ActivityReportAnalytics covers handing request data to an analytics module.
+	ActivityReportAnalytics
+)
+
+func (a Activity) String() string {
+	switch a {
+	case ActivitySyncUser:
+		return "syncUser"
+	case ActivityFetchBids:
+		return "fetchBids"
+	case ActivityTransmitUserFPD:
+		return "transmitUfpd"
+	case ActivityTransmitPreciseGeo:
+		return "transmitPreciseGeo"
+	case ActivityTransmitEIDs:
+		return "transmitEids"
+	case ActivityReportAnalytics:
+		return "reportAnalytics"
+	}
+	return "unknown"
+}
+
+// ComponentType identifies the kind of thing which wants to perform an activity.
+type ComponentType string
+
+const (
+	ComponentTypeBidder    ComponentType = "bidder"
+	ComponentTypeAnalytics ComponentType = "analytics"
+	ComponentTypeGeneral   ComponentType = "general"
+)
+
+// Component is the thing which wants to perform an activity.
+type Component struct {
+	Type ComponentType
+	Name string
+}
+
+// ActivityRequest holds the signals from a request which activity rules can match on.
+type ActivityRequest struct {
+	// Country is the ISO-3166-1-alpha-3 country code, as used by OpenRTB.
+	Country string
+	// Region is the ISO-3166-2 region code, without the country prefix.
+	Region string
+	// GPPSIDs lists the GPP sections which apply to the request.
+	GPPSIDs []gpp.SectionID
+}
+
+// NewActivityRequest pulls the activity signals out of an OpenRTB request.
+func NewActivityRequest(req *openrtb.BidRequest) ActivityRequest {
+	var actReq ActivityRequest
+	if req == nil {
+		return actReq
+	}
+	if req.Device != nil && req.Device.Geo != nil {
+		actReq.Country = req.Device.Geo.Country
+		actReq.Region = req.Device.Geo.Region
+	} else if req.User != nil && req.User.Geo != nil {
+		actReq.Country = req.User.Geo.Country
+		actReq.Region = req.User.Geo.Region
+	}
+	if req.Regs != nil && len(req.Regs.Ext) > 0 {
+		var regsExt openrtb_ext.ExtRegs
+		if err := jsoniter.Unmarshal(req.Regs.Ext, &regsExt); err == nil {
+			for _, sid := range regsExt.GPPSID {
+				actReq.GPPSIDs = append(actReq.GPPSIDs, gpp.SectionID(sid))
+			}
+		}
+	}
+	return actReq
+}
+
+// DefaultRule decides an activity for a component when the config doesn't. Built-in privacy checks, like GDPR's,
+// are added to an ActivityControl as DefaultRules, so that hosts and accounts can override them with config.
+type DefaultRule func(component Component) bool
+
+// ActivityControl decides which activities are allowed.
+//
+// The zero value allows everything.
+type ActivityControl struct {
+	activities map[Activity]config.Activity
+	defaults   map[Activity]DefaultRule
+}
+
+// NewActivityControl builds the activity rules which apply to the given account (publisher ID).
+// Activities which the account doesn't define use the host's rules.
+func NewActivityControl(cfg *config.Privacy, account string) ActivityControl {
+	if cfg == nil {
+		return ActivityControl{}
+	}
+	host := activityMap(&cfg.Activities)
+	// Viper lower-cases all map keys, so the account lookup has to be case-insensitive.
+	accountActivities, ok := cfg.Accounts[strings.ToLower(account)]
+	if !ok || account == "" {
+		return ActivityControl{activities: host}
+	}
+	merged := activityMap(&accountActivities)
+	for activity, activityCfg := range merged {
+		if !activityCfg.IsDefined() {
+			merged[activity] = host[activity]
+		}
+	}
+	return ActivityControl{activities: merged}
+}
+
+func activityMap(cfg *config.Activities) map[Activity]config.Activity {
+	return map[Activity]config.Activity{
+		ActivitySyncUser:           cfg.SyncUser,
+		ActivityFetchBids:          cfg.FetchBids,
+		ActivityTransmitUserFPD:    cfg.TransmitUserFPD,
+		ActivityTransmitPreciseGeo: cfg.TransmitPreciseGeo,
+		ActivityTransmitEIDs:       cfg.TransmitEIDs,
+		ActivityReportAnalytics:    cfg.ReportAnalytics,
+	}
+}
+
+// WithDefault returns a copy of the ActivityControl which decides the activity with the rule
+// whenever none of the configured rules match, and the config doesn't define a default.
+func (ac ActivityControl) WithDefault(activity Activity, rule DefaultRule) ActivityControl {
+	defaults := make(map[Activity]DefaultRule, len(ac.defaults)+1)
+	for a, r := range ac.defaults {
+		defaults[a] = r
+	}
+	defaults[activity] = rule
+	ac.defaults = defaults
+	return ac
+}
+
+// Allow returns true if the component may perform the activity for this request.
+func (ac ActivityControl) Allow(activity Activity, component Component, req ActivityRequest) bool {
+	allowed, _ := ac.Decide(activity, component, req)
+	return allowed
+}
+
+// Decide is like Allow, but also returns true if the activity's DefaultRule made the decision.
+// Callers use it to explain the built-in checks' decisions the way they always have.
+func (ac ActivityControl) Decide(activity Activity, component Component, req ActivityRequest) (allowed bool, byDefaultRule bool) {
+	activityCfg := ac.activities[activity]
+	for _, rule := range activityCfg.Rules {
+		if conditionMatches(&rule.Condition, component, req) {
+			return rule.Allow, false
+		}
+	}
+	if activityCfg.Default != nil {
+		return *activityCfg.Default, false
+	}
+	if rule, ok := ac.defaults[activity]; ok {
+		return rule(component), true
+	}
+	return true, false
+}
+
+func conditionMatches(condition *config.ActivityCondition, component Component, req ActivityRequest) bool {
+	if len(condition.ComponentType) > 0 && !containsFold(condition.ComponentType, string(component.Type)) {
+		return false
+	}
+	if len(condition.ComponentName) > 0 && !containsFold(condition.ComponentName, component.Name) {
+		return false
+	}
+	if len(condition.GPPSID) > 0 && !sidsMatch(condition.GPPSID, req.GPPSIDs) {
+		return false
+	}
+	if len(condition.Geo) > 0 && !GeoMatches(condition.Geo, req.Country, req.Region) {
+		return false
+	}
+	return true
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func sidsMatch(ruleSIDs []int, requestSIDs []gpp.SectionID) bool {
+	for _, sid := range ruleSIDs {
+		if gpp.ContainsSID(requestSIDs, gpp.SectionID(sid)) {
+			return true
+		}
+	}
+	return false
+}
+
+// GeoMatches is true if one of the rule's geos is "COUNTRY" or "COUNTRY.REGION" for the request's location.
+func GeoMatches(ruleGeos []string, country string, region string) bool {
+	if country == "" {
+		return false
+	}
+	for _, geo := range ruleGeos {
+		parts := strings.SplitN(geo, ".", 2)
+		if !strings.EqualFold(parts[0], country) {
+			continue
+		}
+		if len(parts) == 1 || strings.EqualFold(parts[1], region) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,141 @@
+package privacy
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/gpp"
+	"github.com/stretchr/testify/assert"
+)
+
+var appnexus = Component{Type: ComponentTypeBidder, Name: "appnexus"}
+var rubicon = Component{Type: ComponentTypeBidder, Name: "rubicon"}
+
+func TestZeroValueAllowsEverything(t *testing.T) {
+	var ac ActivityControl
+	assert.True(t, ac.Allow(ActivitySyncUser, appnexus, ActivityRequest{}))
+	assert.True(t, ac.Allow(ActivityReportAnalytics, Component{Type: ComponentTypeAnalytics, Name: "filelogger"}, ActivityRequest{}))
+}
+
+func TestRulesApplyInOrder(t *testing.T) {
+	ac := NewActivityControl(&config.Privacy{
+		Activities: config.Activities{
+			SyncUser: config.Activity{
+				Rules: []config.ActivityRule{
+					{Allow: true, Condition: config.ActivityCondition{ComponentName: []string{"appnexus"}}},
+					{Allow: false, Condition: config.ActivityCondition{ComponentType: []string{"bidder"}}},
+				},
+			},
+		},
+	}, "")
+	assert.True(t, ac.Allow(ActivitySyncUser, appnexus, ActivityRequest{}))
+	assert.False(t, ac.Allow(ActivitySyncUser, rubicon, ActivityRequest{}))
+	assert.True(t, ac.Allow(ActivityFetchBids, rubicon, ActivityRequest{}))
+}
+
+func TestDefault(t *testing.T) {
+	deny := false
+	ac := NewActivityControl(&config.Privacy{
+		Activities: config.Activities{
+			FetchBids: config.Activity{
+				Default: &deny,
+				Rules: []config.ActivityRule{
+					{Allow: true, Condition: config.ActivityCondition{ComponentName: []string{"AppNexus"}}},
+				},
+			},
+		},
+	}, "")
+	assert.True(t, ac.Allow(ActivityFetchBids, appnexus, ActivityRequest{}))
+	assert.False(t, ac.Allow(ActivityFetchBids, rubicon, ActivityRequest{}))
+}
+
+func TestDefaultRule(t *testing.T) {
+	onlyAppnexus := func(component Component) bool {
+		return component.Name == "appnexus"
+	}
+	ac := ActivityControl{}.WithDefault(ActivityTransmitUserFPD, onlyAppnexus)
+	allowed, byDefaultRule := ac.Decide(ActivityTransmitUserFPD, rubicon, ActivityRequest{})
+	assert.False(t, allowed)
+	assert.True(t, byDefaultRule)
+	assert.True(t, ac.Allow(ActivityTransmitUserFPD, appnexus, ActivityRequest{}))
+	assert.True(t, ac.Allow(ActivitySyncUser, rubicon, ActivityRequest{}), "Default rules should only decide their own activity")
+
+	allow := true
+	ac = NewActivityControl(&config.Privacy{
+		Activities: config.Activities{
+			TransmitUserFPD: config.Activity{
+				Rules: []config.ActivityRule{
+					{Allow: false, Condition: config.ActivityCondition{ComponentName: []string{"appnexus"}}},
+				},
+			},
+		},
+		Accounts: map[string]config.Activities{
+			"some-account": {TransmitUserFPD: config.Activity{Default: &allow}},
+		},
+	}, "").WithDefault(ActivityTransmitUserFPD, onlyAppnexus)
+	allowed, byDefaultRule = ac.Decide(ActivityTransmitUserFPD, appnexus, ActivityRequest{})
+	assert.False(t, allowed, "Configured rules should win over the default rule")
+	assert.False(t, byDefaultRule)
+	assert.False(t, ac.Allow(ActivityTransmitUserFPD, rubicon, ActivityRequest{}), "The default rule should decide what the config doesn't")
+
+	ac = NewActivityControl(&config.Privacy{
+		Accounts: map[string]config.Activities{
+			"some-account": {TransmitUserFPD: config.Activity{Default: &allow}},
+		},
+	}, "some-account").WithDefault(ActivityTransmitUserFPD, onlyAppnexus)
+	assert.True(t, ac.Allow(ActivityTransmitUserFPD, rubicon, ActivityRequest{}), "A configured default should win over the default rule")
+}
+
+func TestGeoAndGPPConditions(t *testing.T) {
+	ac := NewActivityControl(&config.Privacy{
+		Activities: config.Activities{
+			TransmitPreciseGeo: config.Activity{
+				Rules: []config.ActivityRule{
+					{Allow: false, Condition: config.ActivityCondition{Geo: []string{"USA.CA"}}},
+					{Allow: false, Condition: config.ActivityCondition{GPPSID: []int{7}}},
+				},
+			},
+		},
+	}, "")
+	assert.False(t, ac.Allow(ActivityTransmitPreciseGeo, appnexus, ActivityRequest{Country: "USA", Region: "CA"}))
+	assert.True(t, ac.Allow(ActivityTransmitPreciseGeo, appnexus, ActivityRequest{Country: "USA", Region: "NY"}))
+	assert.False(t, ac.Allow(ActivityTransmitPreciseGeo, appnexus, ActivityRequest{Country: "USA", GPPSIDs: []gpp.SectionID{gpp.SectionUSNat}}))
+	assert.True(t, ac.Allow(ActivityTransmitPreciseGeo, appnexus, ActivityRequest{}))
+}
+
+func TestAccountOverrides(t *testing.T) {
+	deny := false
+	allow := true
+	cfg := &config.Privacy{
+		Activities: config.Activities{
+			SyncUser:  config.Activity{Default: &deny},
+			FetchBids: config.Activity{Default: &deny},
+		},
+		Accounts: map[string]config.Activities{
+			"some-account": {
+				SyncUser: config.Activity{Default: &allow},
+			},
+		},
+	}
+
+	account := NewActivityControl(cfg, "Some-Account")
+	assert.True(t, account.Allow(ActivitySyncUser, appnexus, ActivityRequest{}))
+	assert.False(t, account.Allow(ActivityFetchBids, appnexus, ActivityRequest{}))
+
+	host := NewActivityControl(cfg, "other-account")
+	assert.False(t, host.Allow(ActivitySyncUser, appnexus, ActivityRequest{}))
+}
+
+func TestNewActivityRequest(t *testing.T) {
+	actReq := NewActivityRequest(&openrtb.BidRequest{
+		Device: &openrtb.Device{Geo: &openrtb.Geo{Country: "USA", Region: "CA"}},
+		Regs:   &openrtb.Regs{Ext: json.RawMessage(`{"gpp":"DBABTA~1YNN","gpp_sid":[6]}`)},
+	})
+	assert.Equal(t, "USA", actReq.Country)
+	assert.Equal(t, "CA", actReq.Region)
+	assert.Equal(t, []gpp.SectionID{gpp.SectionUSPV1}, actReq.GPPSIDs)
+
+	assert.Equal(t, ActivityRequest{}, NewActivityRequest(nil))
+}
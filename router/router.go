@@ -36,12 +36,16 @@ import (
 	"github.com/prebid/prebid-server/endpoints/openrtb2"
 	"github.com/prebid/prebid-server/exchange"
 	"github.com/prebid/prebid-server/gdpr"
+	"github.com/prebid/prebid-server/geolocation"
 	"github.com/prebid/prebid-server/openrtb_ext"
 	"github.com/prebid/prebid-server/pbs"
 	metricsConf "github.com/prebid/prebid-server/pbsmetrics/config"
 	pbc "github.com/prebid/prebid-server/prebid_cache_client"
+	"github.com/prebid/prebid-server/sharedid"
 	"github.com/prebid/prebid-server/ssl"
 	storedRequestsConf "github.com/prebid/prebid-server/stored_requests/config"
+	"github.com/prebid/prebid-server/usersync"
+	"github.com/prebid/prebid-server/usersync/uidstore"
 	"github.com/prebid/prebid-server/usersync/usersyncers"
 
 	"github.com/golang/glog"
@@ -63,23 +67,43 @@ var exchanges map[string]adapters.Adapter
 //
 // This function stores the file contents in memory, and should not be used on large directories.
 // If the root directory, or any of the files in it, cannot be read, then the program will exit.
-func NewJsonDirectoryServer(schemaDirectory string, validator openrtb_ext.BidderParamValidator, aliases map[string]string) httprouter.Handle {
+func NewJsonDirectoryServer(schemaDirectory string, validator openrtb_ext.BidderParamValidator, aliases map[string]string, genericBidders []openrtb_ext.BidderName) httprouter.Handle {
 	// Slurp the files into memory first, since they're small and it minimizes request latency.
 	files, err := ioutil.ReadDir(schemaDirectory)
 	if err != nil {
 		glog.Fatalf("Failed to read directory %s: %v", schemaDirectory, err)
 	}
 
+	knownBidders := make(map[string]openrtb_ext.BidderName, len(openrtb_ext.BidderMap)+len(genericBidders))
+	for bidder, bidderName := range openrtb_ext.BidderMap {
+		knownBidders[bidder] = bidderName
+	}
+	for _, bidderName := range genericBidders {
+		knownBidders[string(bidderName)] = bidderName
+	}
+
 	data := make(map[string]json.RawMessage, len(files))
 	for _, file := range files {
 		bidder := strings.TrimSuffix(file.Name(), ".json")
-		bidderName, isValid := openrtb_ext.BidderMap[bidder]
+		bidderName, isValid := knownBidders[bidder]
 		if !isValid {
 			glog.Fatalf("Schema exists for an unknown bidder: %s", bidder)
 		}
 		data[bidder] = json.RawMessage(validator.Schema(bidderName))
 	}
 
+	// Generic bidders usually don't have a file, and host aliases never do. The validator has a schema for them anyway.
+	for bidder, bidderName := range openrtb_ext.BidderMap {
+		if _, ok := data[bidder]; !ok && openrtb_ext.IsHostAlias(bidderName) {
+			data[bidder] = json.RawMessage(validator.Schema(bidderName))
+		}
+	}
+	for _, bidderName := range genericBidders {
+		if _, ok := data[string(bidderName)]; !ok {
+			data[string(bidderName)] = json.RawMessage(validator.Schema(bidderName))
+		}
+	}
+
 	// Add in any default aliases
 	for aliasName, bidderName := range aliases {
 		bidderData, ok := data[bidderName]
@@ -165,6 +189,7 @@ func newExchangeMap(cfg *config.Configuration) map[string]adapters.Adapter {
 type Router struct {
 	*httprouter.Router
 	MetricsEngine   *metricsConf.DetailedMetricsEngine
+	CircuitBreakers *exchange.CircuitBreakers
 	ParamsValidator openrtb_ext.BidderParamValidator
 	Shutdown        func()
 }
@@ -173,6 +198,15 @@ func New(cfg *config.Configuration, rateConvertor *currencies.RateConverter) (r
 	const schemaDirectory = "./static/bidder-params"
 	const infoDirectory = "./static/bidder-info"
 
+	// Host aliases need to be in the BidderMap before anything else reads it.
+	for name, adapter := range cfg.Adapters {
+		if aliasOf, ok := adapter.AliasedBidder(); ok {
+			if err := openrtb_ext.RegisterHostAlias(name, aliasOf); err != nil {
+				glog.Fatalf("Failed to add alias %s. %v", name, err)
+			}
+		}
+	}
+
 	r = &Router{
 		Router: httprouter.New(),
 	}
@@ -184,8 +218,11 @@ func New(cfg *config.Configuration, rateConvertor *currencies.RateConverter) (r
 			TLSClientConfig:     &tls.Config{RootCAs: ssl.GetRootCAPool()},
 		},
 	}
+	// Generic bidders aren't in the BidderMap, so everything which needs to know about them gets this list.
+	genericBidders := exchange.GenericBidders(cfg.Adapters)
+
 	// Hack because of how legacy handles districtm
-	legacyBidderList := openrtb_ext.BidderList()
+	legacyBidderList := append(openrtb_ext.BidderList(), genericBidders...)
 	legacyBidderList = append(legacyBidderList, openrtb_ext.BidderName("districtm"))
 
 	// Metrics engine
@@ -200,7 +237,7 @@ func New(cfg *config.Configuration, rateConvertor *currencies.RateConverter) (r
 
 	pbsAnalytics := analyticsConf.NewPBSAnalytics(&cfg.Analytics)
 
-	paramsValidator, err := openrtb_ext.NewBidderParamsValidator(schemaDirectory)
+	paramsValidator, err := openrtb_ext.NewBidderParamsValidator(schemaDirectory, genericBidders...)
 	if err != nil {
 		glog.Fatalf("Failed to create the bidder params validator. %v", err)
 	}
@@ -209,31 +246,70 @@ func New(cfg *config.Configuration, rateConvertor *currencies.RateConverter) (r
 		"indexExchange": "bidder \"indexExchange\" has been deprecated and is no longer available. Please use bidder \"ix\" and note that the bidder params have changed.",
 	}
 	bidderList, bidderMap := exchange.DisableBidders(cfg.Adapters, openrtb_ext.BidderList(), disabledBidders)
+	genericBidderList, genericBidderMap := exchange.DisableBidders(cfg.Adapters, genericBidders, disabledBidders)
+	for name, bidder := range genericBidderMap {
+		bidderMap[name] = bidder
+	}
 
 	p, _ := filepath.Abs(infoDirectory)
 	bidderInfos := adapters.ParseBidderInfos(p, bidderList)
+	for _, bidder := range genericBidderList {
+		bidderInfos[string(bidder)] = exchange.NewGenericBidderInfo(cfg.Adapters[string(bidder)].Generic)
+	}
+	for name, adapter := range cfg.Adapters {
+		if info, ok := bidderInfos[name]; ok && adapter.AliasOf != "" {
+			bidderInfos[name] = exchange.OverrideBidderInfo(info, adapter.BidderInfo)
+		}
+	}
 
 	defaultAliases, defReqJSON := readDefaultRequest(cfg.DefReqConfig)
 
 	syncers := usersyncers.NewSyncerMap(cfg)
-	gdprPerms := gdpr.NewPermissions(context.Background(), cfg.GDPR, adapters.GDPRAwareSyncerIDs(syncers), theClient)
+	gdprPerms := gdpr.NewPermissions(context.Background(), cfg.GDPR, gdprVendorIDs(cfg.Adapters, syncers), theClient)
+
+	var geoResolver *geolocation.Resolver
+	if cfg.GDPR.GeoLocation.Enabled {
+		geoDB, err := geolocation.OpenMaxMindDB(cfg.GDPR.GeoLocation.Database)
+		if err != nil {
+			glog.Fatalf("Failed to load the geolocation database. %v", err)
+		}
+		geoResolver = geolocation.NewResolver(geoDB, cfg.GDPR.GeoLocation.CacheSizeBytes, cfg.GDPR.GeoLocation.CacheTTLSeconds, r.MetricsEngine)
+	}
+
+	var uidStore *usersync.UIDStore
+	if cfg.UserIDStore.Enabled {
+		store, err := uidstore.NewStore(&cfg.UserIDStore)
+		if err != nil {
+			glog.Fatalf("Failed to open the user ID store. %v", err)
+		}
+		uidStore = usersync.NewUIDStore(store, &cfg.UserIDStore, &cfg.HostCookie.Security)
+		r.Shutdown = func() {
+			shutdown()
+			if err := store.Close(); err != nil {
+				glog.Errorf("Failed to close the user ID store. %v", err)
+			}
+		}
+	}
+
+	sharedIDGenerator := sharedid.NewGenerator(&cfg.SharedID, &cfg.HostCookie, &cfg.GDPR, gdprPerms)
 
 	exchanges = newExchangeMap(cfg)
-	theExchange := exchange.NewExchange(theClient, pbc.NewClient(&cfg.CacheURL), cfg, r.MetricsEngine, bidderInfos, gdprPerms, rateConvertor)
+	r.CircuitBreakers = exchange.NewCircuitBreakers(cfg, r.MetricsEngine)
+	theExchange := exchange.NewExchange(theClient, pbc.NewClient(&cfg.CacheURL), cfg, r.MetricsEngine, bidderInfos, gdprPerms, rateConvertor, geoResolver, r.CircuitBreakers, pbsAnalytics)
 
-	openrtbEndpoint, err := openrtb2.NewEndpoint(theExchange, paramsValidator, fetcher, categoriesFetcher, cfg, r.MetricsEngine, pbsAnalytics, disabledBidders, defReqJSON, bidderMap)
+	openrtbEndpoint, err := openrtb2.NewEndpoint(theExchange, paramsValidator, fetcher, categoriesFetcher, cfg, r.MetricsEngine, pbsAnalytics, disabledBidders, defReqJSON, bidderMap, uidStore, sharedIDGenerator)
 
 	if err != nil {
 		glog.Fatalf("Failed to create the openrtb endpoint handler. %v", err)
 	}
 
-	ampEndpoint, err := openrtb2.NewAmpEndpoint(theExchange, paramsValidator, ampFetcher, categoriesFetcher, cfg, r.MetricsEngine, pbsAnalytics, disabledBidders, defReqJSON, bidderMap)
+	ampEndpoint, err := openrtb2.NewAmpEndpoint(theExchange, paramsValidator, ampFetcher, categoriesFetcher, cfg, r.MetricsEngine, pbsAnalytics, disabledBidders, defReqJSON, bidderMap, uidStore, sharedIDGenerator)
 
 	if err != nil {
 		glog.Fatalf("Failed to create the amp endpoint handler. %v", err)
 	}
 
-	videoEndpoint, err := openrtb2.NewVideoEndpoint(theExchange, paramsValidator, fetcher, videoFetcher, categoriesFetcher, cfg, r.MetricsEngine, pbsAnalytics, disabledBidders, defReqJSON, bidderMap)
+	videoEndpoint, err := openrtb2.NewVideoEndpoint(theExchange, paramsValidator, fetcher, videoFetcher, categoriesFetcher, cfg, r.MetricsEngine, pbsAnalytics, disabledBidders, defReqJSON, bidderMap, uidStore)
 	if err != nil {
 		glog.Fatalf("Failed to create the video endpoint handler. %v", err)
 	}
@@ -242,10 +318,10 @@ func New(cfg *config.Configuration, rateConvertor *currencies.RateConverter) (r
 	r.POST("/openrtb2/auction", openrtbEndpoint)
 	r.POST("/openrtb2/video", videoEndpoint)
 	r.GET("/openrtb2/amp", ampEndpoint)
-	r.GET("/info/bidders", infoEndpoints.NewBiddersEndpoint(defaultAliases))
+	r.GET("/info/bidders", infoEndpoints.NewBiddersEndpoint(defaultAliases, genericBidders))
 	r.GET("/info/bidders/:bidderName", infoEndpoints.NewBidderDetailsEndpoint(bidderInfos, defaultAliases))
-	r.GET("/bidders/params", NewJsonDirectoryServer(schemaDirectory, paramsValidator, defaultAliases))
-	r.POST("/cookie_sync", endpoints.NewCookieSyncEndpoint(syncers, cfg, gdprPerms, r.MetricsEngine, pbsAnalytics))
+	r.GET("/bidders/params", NewJsonDirectoryServer(schemaDirectory, paramsValidator, defaultAliases, genericBidders))
+	r.POST("/cookie_sync", endpoints.NewCookieSyncEndpoint(syncers, cfg, gdprPerms, r.MetricsEngine, pbsAnalytics, uidStore))
 	r.GET("/status", endpoints.NewStatusEndpoint(cfg.StatusResponse))
 	r.GET("/", serveIndex)
 	r.ServeFiles("/static/*filepath", http.Dir("static"))
@@ -258,7 +334,7 @@ func New(cfg *config.Configuration, rateConvertor *currencies.RateConverter) (r
 		PBSAnalytics:     pbsAnalytics,
 	}
 
-	r.GET("/setuid", endpoints.NewSetUIDEndpoint(cfg.HostCookie, gdprPerms, pbsAnalytics, r.MetricsEngine))
+	r.GET("/setuid", endpoints.NewSetUIDEndpoint(cfg.HostCookie, gdprPerms, &cfg.Privacy, pbsAnalytics, r.MetricsEngine, uidStore))
 	r.GET("/getuids", endpoints.NewGetUIDsEndpoint(cfg.HostCookie))
 	r.POST("/optout", userSyncDeps.OptOut)
 	r.GET("/optout", userSyncDeps.OptOut)
@@ -266,6 +342,25 @@ func New(cfg *config.Configuration, rateConvertor *currencies.RateConverter) (r
 	return r, nil
 }
 
+// gdprVendorIDs returns the GDPR vendor IDs of the bidders which have them. Host aliases without a usersyncer
+// of their own still bid, so they get the vendor ID from their config, or else that of the bidder they're an alias of.
+func gdprVendorIDs(adapterCfgs map[string]config.Adapter, syncers map[openrtb_ext.BidderName]usersync.Usersyncer) map[openrtb_ext.BidderName]uint16 {
+	vendorIDs := adapters.GDPRAwareSyncerIDs(syncers)
+	for name, adapterCfg := range adapterCfgs {
+		alias := openrtb_ext.BidderName(name)
+		aliasOf, ok := adapterCfg.AliasedBidder()
+		if _, hasVendorID := vendorIDs[alias]; !ok || hasVendorID {
+			continue
+		}
+		if adapterCfg.GDPRVendorID != 0 {
+			vendorIDs[alias] = adapterCfg.GDPRVendorID
+		} else if vendorID, ok := vendorIDs[aliasOf]; ok {
+			vendorIDs[alias] = vendorID
+		}
+	}
+	return vendorIDs
+}
+
 // Fixes #648
 //
 // These CORS options pose a security risk... but it's a calculated one.
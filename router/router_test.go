@@ -9,8 +9,10 @@ import (
 	"testing"
 
 	jsoniter "github.com/json-iterator/go"
+	"github.com/prebid/prebid-server/adapters"
 	"github.com/prebid/prebid-server/config"
 	"github.com/prebid/prebid-server/openrtb_ext"
+	"github.com/prebid/prebid-server/usersync"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -39,7 +41,7 @@ func ensureHasKey(t *testing.T, data map[string]json.RawMessage, key string) {
 }
 
 func TestNewJsonDirectoryServer(t *testing.T) {
-	handler := NewJsonDirectoryServer("../static/bidder-params", &testValidator{}, nil)
+	handler := NewJsonDirectoryServer("../static/bidder-params", &testValidator{}, nil, nil)
 	recorder := httptest.NewRecorder()
 	request, _ := http.NewRequest("GET", "/whatever", nil)
 	handler(recorder, request, nil)
@@ -54,7 +56,7 @@ func TestNewJsonDirectoryServer(t *testing.T) {
 	}
 
 	for _, adapterFile := range adapterFiles {
-		if adapterFile.IsDir() && adapterFile.Name() != "adapterstest" {
+		if adapterFile.IsDir() && adapterFile.Name() != "adapterstest" && adapterFile.Name() != "generic" {
 			ensureHasKey(t, data, adapterFile.Name())
 		}
 	}
@@ -179,3 +181,20 @@ func TestLoadDefaultAliasesNoInfo(t *testing.T) {
 	assert.Equal(t, expectedAliases, defAliases)
 
 }
+
+func TestGDPRVendorIDs(t *testing.T) {
+	adapterCfgs := map[string]config.Adapter{
+		"appnexus":   {},
+		"somealias":  {AliasOf: "appnexus", GDPRVendorID: 12},
+		"otheralias": {AliasOf: "appnexus"},
+		"rubicon":    {GDPRVendorID: 99},
+	}
+	syncers := map[openrtb_ext.BidderName]usersync.Usersyncer{
+		openrtb_ext.BidderAppnexus: adapters.NewSyncer("adnxs", 32, nil, adapters.SyncTypeRedirect),
+	}
+	assert.Equal(t, map[openrtb_ext.BidderName]uint16{
+		"appnexus":   32,
+		"somealias":  12,
+		"otheralias": 32,
+	}, gdprVendorIDs(adapterCfgs, syncers), "Aliases without syncers should get the vendor ID from their config, or else the aliased bidder's")
+}
@@ -0,0 +1,188 @@
+package sharedid
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/buger/jsonparser"
+	"github.com/gofrs/uuid"
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/gdpr"
+	"github.com/prebid/prebid-server/gpp"
+	"github.com/prebid/prebid-server/openrtb_ext"
+)
+
+// atypeDevice is the eids[].uids[].atype for IDs which come from a cookie or device.
+const atypeDevice = 1
+
+// Generator gives web users a random first-party ID if they don't have one yet. The ID is sent to bidders
+// in request.user.ext.eids, and saved in a cookie on the host's domain so that it's the same on the next auction.
+//
+// A nil *Generator is valid. It means the host didn't enable shared IDs, so requests are left alone.
+type Generator struct {
+	cfg                 *config.SharedID
+	domain              string
+	usersyncIfAmbiguous bool
+	perms               gdpr.Permissions
+}
+
+// NewGenerator returns a Generator, or nil if the host didn't enable shared IDs.
+func NewGenerator(cfg *config.SharedID, hostCookie *config.HostCookie, gdprCfg *config.GDPR, perms gdpr.Permissions) *Generator {
+	if !cfg.Enabled {
+		return nil
+	}
+	return &Generator{
+		cfg:                 cfg,
+		domain:              hostCookie.Domain,
+		usersyncIfAmbiguous: gdprCfg.UsersyncIfAmbiguous,
+		perms:               perms,
+	}
+}
+
+// Apply makes sure that the request carries the user's shared ID, and writes the shared ID cookie on the response.
+//
+// The ID comes from request.user.ext.eids if the page already sent one, then from the cookie, and is generated
+// as a last resort. App requests are left alone, since apps have device IDs instead. So are users who
+// haven't allowed the host to set cookies under GDPR, or who have opted out of sales under CCPA.
+func (g *Generator) Apply(ctx context.Context, r *http.Request, w http.ResponseWriter, bidReq *openrtb.BidRequest) error {
+	if g == nil || bidReq.App != nil || !g.allowed(ctx, bidReq) {
+		return nil
+	}
+
+	id, inRequest := g.idFromRequest(bidReq)
+	if id == "" {
+		if cookie, err := r.Cookie(g.cfg.CookieName); err == nil {
+			id = cookie.Value
+		}
+	}
+	if id == "" {
+		generated, err := uuid.NewV4()
+		if err != nil {
+			return err
+		}
+		id = generated.String()
+	}
+
+	if !inRequest {
+		if err := g.addToRequest(bidReq, id); err != nil {
+			return err
+		}
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:    g.cfg.CookieName,
+		Value:   id,
+		Domain:  g.domain,
+		Path:    "/",
+		Expires: time.Now().Add(g.cfg.TTL()),
+	})
+	return nil
+}
+
+// allowed is true if the privacy signals on the request let the host keep an ID for this user.
+func (g *Generator) allowed(ctx context.Context, bidReq *openrtb.BidRequest) bool {
+	var regs openrtb_ext.ExtRegs
+	if bidReq.Regs != nil && len(bidReq.Regs.Ext) > 0 {
+		if err := json.Unmarshal(bidReq.Regs.Ext, &regs); err != nil {
+			return false
+		}
+	}
+	if len(regs.USPrivacy) == 4 && regs.USPrivacy[2] == 'Y' {
+		return false
+	}
+
+	gdprApplies := !g.usersyncIfAmbiguous
+	if regs.GDPR != nil {
+		gdprApplies = *regs.GDPR == 1
+	} else if applies := gpp.GDPRApplies(gppSIDs(regs.GPPSID)); applies != nil {
+		gdprApplies = *applies == 1
+	}
+	if !gdprApplies {
+		return true
+	}
+	allowed, err := g.perms.HostCookiesAllowed(ctx, consent(bidReq, regs.GPP))
+	return allowed && err == nil
+}
+
+// idFromRequest returns the ID which the page sent in request.user.ext.eids for the configured source, if any.
+func (g *Generator) idFromRequest(bidReq *openrtb.BidRequest) (string, bool) {
+	for _, eid := range eids(bidReq) {
+		if eid.Source == g.cfg.Source && len(eid.UIDs) > 0 && eid.UIDs[0].ID != "" {
+			return eid.UIDs[0].ID, true
+		}
+	}
+	return "", false
+}
+
+// addToRequest adds the ID to request.user.ext.eids. The rest of request.user.ext is left untouched.
+func (g *Generator) addToRequest(bidReq *openrtb.BidRequest, id string) error {
+	newEid, err := json.Marshal(openrtb_ext.ExtUserEid{
+		Source: g.cfg.Source,
+		UIDs:   []openrtb_ext.ExtUserEidUID{{ID: id, Atype: atypeDevice}},
+	})
+	if err != nil {
+		return err
+	}
+
+	var user openrtb.User
+	if bidReq.User != nil {
+		user = *bidReq.User
+	}
+	ext := user.Ext
+	if len(ext) == 0 {
+		ext = []byte("{}")
+	}
+	allEids := "[" + string(newEid) + "]"
+	if oldEids, dataType, _, err := jsonparser.Get(ext, "eids"); err == nil && dataType == jsonparser.Array {
+		if oldEids = bytes.TrimSpace(oldEids[1 : len(oldEids)-1]); len(oldEids) > 0 {
+			allEids = "[" + string(oldEids) + "," + string(newEid) + "]"
+		}
+	}
+	if user.Ext, err = jsonparser.Set(append([]byte(nil), ext...), []byte(allEids), "eids"); err != nil {
+		return err
+	}
+	bidReq.User = &user
+	return nil
+}
+
+func eids(bidReq *openrtb.BidRequest) []openrtb_ext.ExtUserEid {
+	if bidReq.User == nil {
+		return nil
+	}
+	eidsJSON, dataType, _, err := jsonparser.Get(bidReq.User.Ext, "eids")
+	if err != nil || dataType != jsonparser.Array {
+		return nil
+	}
+	var parsed []openrtb_ext.ExtUserEid
+	if err := json.Unmarshal(eidsJSON, &parsed); err != nil {
+		return nil
+	}
+	return parsed
+}
+
+func consent(bidReq *openrtb.BidRequest, gppString string) string {
+	if bidReq.User != nil {
+		if consent, err := jsonparser.GetString(bidReq.User.Ext, "consent"); err == nil && consent != "" {
+			return consent
+		}
+	}
+	if gppString != "" {
+		if parsed, err := gpp.Parse(gppString); err == nil {
+			if tcf, ok := parsed.Section(gpp.SectionTCFEU2); ok {
+				return tcf
+			}
+		}
+	}
+	return ""
+}
+
+func gppSIDs(sids []int) []gpp.SectionID {
+	converted := make([]gpp.SectionID, len(sids))
+	for i, sid := range sids {
+		converted[i] = gpp.SectionID(sid)
+	}
+	return converted
+}
@@ -0,0 +1,156 @@
+package sharedid
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/gdpr"
+	"github.com/prebid/prebid-server/openrtb_ext"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockPermissions struct {
+	allowHostCookies bool
+}
+
+func (m *mockPermissions) HostCookiesAllowed(ctx context.Context, consent string) (bool, error) {
+	if consent == "malformed" {
+		return false, errors.New("malformed consent")
+	}
+	return m.allowHostCookies, nil
+}
+
+func (m *mockPermissions) BidderSyncAllowed(ctx context.Context, bidder openrtb_ext.BidderName, consent string) (bool, error) {
+	return true, nil
+}
+
+func (m *mockPermissions) PersonalInfoAllowed(ctx context.Context, bidder openrtb_ext.BidderName, consent string) (bool, error) {
+	return true, nil
+}
+
+func newTestGenerator(perms gdpr.Permissions) *Generator {
+	return NewGenerator(
+		&config.SharedID{Enabled: true, CookieName: "_pubcid", Source: "pubcid.org", TTLDays: 365},
+		&config.HostCookie{Domain: "prebid.example.com"},
+		&config.GDPR{UsersyncIfAmbiguous: true},
+		perms,
+	)
+}
+
+func TestDisabled(t *testing.T) {
+	generator := NewGenerator(&config.SharedID{}, &config.HostCookie{}, &config.GDPR{}, gdpr.AlwaysAllow{})
+	assert.Nil(t, generator)
+
+	bidReq := &openrtb.BidRequest{Site: &openrtb.Site{}}
+	w := httptest.NewRecorder()
+	assert.NoError(t, generator.Apply(context.Background(), httptest.NewRequest("POST", "/openrtb2/auction", nil), w, bidReq))
+	assert.Nil(t, bidReq.User)
+	assert.Empty(t, w.Header().Get("Set-Cookie"))
+}
+
+func TestGeneratesID(t *testing.T) {
+	bidReq := &openrtb.BidRequest{Site: &openrtb.Site{}, User: &openrtb.User{Ext: json.RawMessage(`{"consent":"abc"}`)}}
+	w := httptest.NewRecorder()
+	assert.NoError(t, newTestGenerator(gdpr.AlwaysAllow{}).Apply(context.Background(), httptest.NewRequest("POST", "/openrtb2/auction", nil), w, bidReq))
+
+	id := assertEid(t, bidReq, 0, "pubcid.org")
+	assert.Len(t, id, 36, "generated IDs should be UUIDs")
+	assert.Contains(t, string(bidReq.User.Ext), `"consent":"abc"`, "other user.ext fields should be kept")
+
+	cookie := parseSetCookie(w)
+	assert.Equal(t, "_pubcid", cookie.Name)
+	assert.Equal(t, id, cookie.Value)
+	assert.Equal(t, "prebid.example.com", cookie.Domain)
+}
+
+func TestReusesCookie(t *testing.T) {
+	bidReq := &openrtb.BidRequest{Site: &openrtb.Site{}, User: &openrtb.User{Ext: json.RawMessage(`{"eids":[{"source":"other.com","uids":[{"id":"x"}]}]}`)}}
+	httpReq := httptest.NewRequest("POST", "/openrtb2/auction", nil)
+	httpReq.AddCookie(&http.Cookie{Name: "_pubcid", Value: "existing-id"})
+	w := httptest.NewRecorder()
+	assert.NoError(t, newTestGenerator(gdpr.AlwaysAllow{}).Apply(context.Background(), httpReq, w, bidReq))
+
+	assertEid(t, bidReq, 0, "other.com")
+	assert.Equal(t, "existing-id", assertEid(t, bidReq, 1, "pubcid.org"))
+	assert.Equal(t, "existing-id", parseSetCookie(w).Value, "the cookie should be refreshed")
+}
+
+func TestReusesRequestID(t *testing.T) {
+	ext := `{"eids":[{"source":"pubcid.org","uids":[{"id":"page-id","atype":1}]}]}`
+	bidReq := &openrtb.BidRequest{Site: &openrtb.Site{}, User: &openrtb.User{Ext: json.RawMessage(ext)}}
+	httpReq := httptest.NewRequest("POST", "/openrtb2/auction", nil)
+	httpReq.AddCookie(&http.Cookie{Name: "_pubcid", Value: "cookie-id"})
+	w := httptest.NewRecorder()
+	assert.NoError(t, newTestGenerator(gdpr.AlwaysAllow{}).Apply(context.Background(), httpReq, w, bidReq))
+
+	assert.JSONEq(t, ext, string(bidReq.User.Ext), "the request shouldn't change if it already has the ID")
+	assert.Equal(t, "page-id", parseSetCookie(w).Value)
+}
+
+func TestPrivacy(t *testing.T) {
+	testCases := []struct {
+		description string
+		regsExt     string
+		userExt     string
+		perms       gdpr.Permissions
+		expectID    bool
+	}{
+		{"no signals", ``, ``, &mockPermissions{}, true},
+		{"GDPR doesn't apply", `{"gdpr":0}`, ``, &mockPermissions{}, true},
+		{"GDPR consent given", `{"gdpr":1}`, `{"consent":"ok"}`, &mockPermissions{allowHostCookies: true}, true},
+		{"GDPR consent refused", `{"gdpr":1}`, `{"consent":"ok"}`, &mockPermissions{}, false},
+		{"GDPR consent malformed", `{"gdpr":1}`, `{"consent":"malformed"}`, &mockPermissions{allowHostCookies: true}, false},
+		{"GDPR from GPP", `{"gpp_sid":[2]}`, ``, &mockPermissions{}, false},
+		{"CCPA opt out", `{"us_privacy":"1YYN"}`, ``, &mockPermissions{}, false},
+		{"CCPA no opt out", `{"us_privacy":"1YNN"}`, ``, &mockPermissions{}, true},
+	}
+
+	for _, test := range testCases {
+		bidReq := &openrtb.BidRequest{Site: &openrtb.Site{}}
+		if test.regsExt != "" {
+			bidReq.Regs = &openrtb.Regs{Ext: json.RawMessage(test.regsExt)}
+		}
+		if test.userExt != "" {
+			bidReq.User = &openrtb.User{Ext: json.RawMessage(test.userExt)}
+		}
+		w := httptest.NewRecorder()
+		assert.NoError(t, newTestGenerator(test.perms).Apply(context.Background(), httptest.NewRequest("POST", "/openrtb2/auction", nil), w, bidReq), test.description)
+		assert.Equal(t, test.expectID, len(eids(bidReq)) == 1, test.description)
+		assert.Equal(t, test.expectID, w.Header().Get("Set-Cookie") != "", test.description)
+	}
+}
+
+func TestIgnoresApps(t *testing.T) {
+	bidReq := &openrtb.BidRequest{App: &openrtb.App{}}
+	w := httptest.NewRecorder()
+	assert.NoError(t, newTestGenerator(gdpr.AlwaysAllow{}).Apply(context.Background(), httptest.NewRequest("POST", "/openrtb2/auction", nil), w, bidReq))
+	assert.Nil(t, bidReq.User)
+	assert.Empty(t, w.Header().Get("Set-Cookie"))
+}
+
+func assertEid(t *testing.T, bidReq *openrtb.BidRequest, index int, source string) string {
+	t.Helper()
+	allEids := eids(bidReq)
+	if !assert.True(t, len(allEids) > index, "missing eid %d", index) {
+		return ""
+	}
+	assert.Equal(t, source, allEids[index].Source)
+	if !assert.Len(t, allEids[index].UIDs, 1) {
+		return ""
+	}
+	return allEids[index].UIDs[0].ID
+}
+
+func parseSetCookie(w *httptest.ResponseRecorder) *http.Cookie {
+	cookies := (&http.Response{Header: w.Header()}).Cookies()
+	if len(cookies) == 0 {
+		return &http.Cookie{}
+	}
+	return cookies[0]
+}
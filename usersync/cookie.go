@@ -1,9 +1,9 @@
 package usersync
 
 import (
-	"encoding/base64"
 	"errors"
 	"net/http"
+	"sort"
 	"time"
 
 	jsoniter "github.com/json-iterator/go"
@@ -57,7 +57,7 @@ func ParsePBSCookieFromRequest(r *http.Request, cookie *config.HostCookie) *PBSC
 	var parsed *PBSCookie
 	uidCookie, err2 := r.Cookie(UID_COOKIE_NAME)
 	if err2 == nil {
-		parsed = ParsePBSCookie(uidCookie)
+		parsed = parseSecurePBSCookie(uidCookie, &cookie.Security)
 	} else {
 		parsed = NewPBSCookie()
 	}
@@ -71,10 +71,18 @@ func ParsePBSCookieFromRequest(r *http.Request, cookie *config.HostCookie) *PBSC
 }
 
 // ParsePBSCookie parses the UserSync cookie from a raw HTTP cookie.
+//
+// This only understands unsigned cookies. Use ParsePBSCookieFromRequest to honor the host's cookie security config.
 func ParsePBSCookie(uidCookie *http.Cookie) *PBSCookie {
+	return parseSecurePBSCookie(uidCookie, &config.CookieSecurity{})
+}
+
+// parseSecurePBSCookie parses the UserSync cookie from a raw HTTP cookie.
+// Cookies which weren't signed by one of the host's keys are treated as empty.
+func parseSecurePBSCookie(uidCookie *http.Cookie, security *config.CookieSecurity) *PBSCookie {
 	pc := NewPBSCookie()
 
-	j, err := base64.URLEncoding.DecodeString(uidCookie.Value)
+	j, err := decodeCookieValue(uidCookie.Value, security, security.LegacyCookiesAllowed(time.Now()))
 	if err != nil {
 		// corrupted cookie; we should reset
 		return pc
@@ -110,16 +118,23 @@ func (cookie *PBSCookie) SetPreference(allow bool) {
 }
 
 // Gets an HTTP cookie containing all the data from this UserSyncMap. This is a snapshot--not a live view.
+//
+// The cookie is unsigned. SetCookieOnResponse signs it if the host has configured keys.
 func (cookie *PBSCookie) ToHTTPCookie(ttl time.Duration) *http.Cookie {
+	httpCookie, _ := cookie.toSecureHTTPCookie(ttl, &config.CookieSecurity{})
+	return httpCookie
+}
+
+func (cookie *PBSCookie) toSecureHTTPCookie(ttl time.Duration, security *config.CookieSecurity) (*http.Cookie, error) {
 	j, _ := jsoniter.Marshal(cookie)
-	b64 := base64.URLEncoding.EncodeToString(j)
+	value, err := encodeCookieValue(j, security)
 
 	return &http.Cookie{
 		Name:    UID_COOKIE_NAME,
-		Value:   b64,
+		Value:   value,
 		Expires: time.Now().Add(ttl),
 		Path:    "/",
-	}
+	}, err
 }
 
 // GetUID Gets this user's ID for the given family.
@@ -159,13 +174,80 @@ func (cookie *PBSCookie) GetId(bidderName openrtb_ext.BidderName) (id string, ex
 	return
 }
 
-// SetCookieOnResponse is a shortcut for "ToHTTPCookie(); cookie.setDomain(domain); setCookie(w, cookie)"
-func (cookie *PBSCookie) SetCookieOnResponse(w http.ResponseWriter, domain string, ttl time.Duration) {
-	httpCookie := cookie.ToHTTPCookie(ttl)
-	if domain != "" {
-		httpCookie.Domain = domain
+// SetCookieOnResponse is a shortcut for "ToHTTPCookie(); cookie.setDomain(domain); setCookie(w, cookie)".
+// The cookie is signed, and maybe encrypted, according to the host's cookie security config.
+//
+// Browsers silently drop cookies which are too big. If cfg.MaxCookieSizeBytes is positive, UIDs are evicted from this
+// cookie in the order of cfg.EvictionPriority until its Set-Cookie header value is no bigger than that.
+// It returns the family names which were evicted.
+func (cookie *PBSCookie) SetCookieOnResponse(w http.ResponseWriter, cfg *config.HostCookie, ttl time.Duration) ([]string, error) {
+	var evicted []string
+	httpCookie, err := cookie.toHTTPCookieWithDomain(ttl, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if maxSize := cfg.MaxCookieSizeBytes; maxSize > 0 && len(httpCookie.String()) > maxSize {
+		for _, familyName := range cookie.evictionOrder(cfg.EvictionPriority) {
+			cookie.Unsync(familyName)
+			evicted = append(evicted, familyName)
+			if httpCookie, err = cookie.toHTTPCookieWithDomain(ttl, cfg); err != nil {
+				return nil, err
+			}
+			if len(httpCookie.String()) <= maxSize {
+				break
+			}
+		}
 	}
 	http.SetCookie(w, httpCookie)
+	return evicted, nil
+}
+
+func (cookie *PBSCookie) toHTTPCookieWithDomain(ttl time.Duration, cfg *config.HostCookie) (*http.Cookie, error) {
+	httpCookie, err := cookie.toSecureHTTPCookie(ttl, &cfg.Security)
+	if cfg.Domain != "" {
+		httpCookie.Domain = cfg.Domain
+	}
+	return httpCookie, err
+}
+
+// evictionOrder returns the families in the order which their UIDs should be evicted if the cookie is too big.
+// Expired UIDs go first. The live ones follow from the lowest priority to the highest, where families which
+// aren't in the priority list come before any which are. UIDs with the same priority go in the order which
+// they expire, so the ones which were synced longest ago go first.
+func (cookie *PBSCookie) evictionOrder(priority []string) []string {
+	ranks := make(map[string]int, len(priority))
+	for i, familyName := range priority {
+		if _, ok := ranks[familyName]; !ok {
+			ranks[familyName] = i
+		}
+	}
+	rank := func(familyName string) int {
+		if rank, ok := ranks[familyName]; ok {
+			return rank
+		}
+		return len(priority)
+	}
+
+	now := time.Now()
+	families := make([]string, 0, len(cookie.uids))
+	for familyName := range cookie.uids {
+		families = append(families, familyName)
+	}
+	sort.Slice(families, func(i, j int) bool {
+		left, right := cookie.uids[families[i]].Expires, cookie.uids[families[j]].Expires
+		if leftExpired, rightExpired := !now.Before(left), !now.Before(right); leftExpired != rightExpired {
+			return leftExpired
+		} else if !leftExpired {
+			if leftRank, rightRank := rank(families[i]), rank(families[j]); leftRank != rightRank {
+				return leftRank > rightRank
+			}
+		}
+		if left.Equal(right) {
+			return families[i] < families[j]
+		}
+		return left.Before(right)
+	})
+	return families
 }
 
 // Unsync removes the user's ID for the given family from this cookie.
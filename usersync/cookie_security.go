@@ -0,0 +1,145 @@
+package usersync
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/prebid/prebid-server/config"
+)
+
+// Signed cookies look like "{prefix}{payload}.{signature}", where both parts are unpadded base64-URL.
+// The signature is an HMAC-SHA256 of everything before the last ".". Legacy cookies are padded base64-URL,
+// which never contains a ".", so they can't be confused with these.
+const (
+	signedCookiePrefix    = "s1."
+	encryptedCookiePrefix = "e1."
+)
+
+var cookieEncoding = base64.RawURLEncoding
+
+// cookieKey holds the keys derived from one of the host's configured keys.
+// Separate keys are used for signing and encryption, so that neither use can weaken the other.
+type cookieKey struct {
+	signing    []byte
+	encryption []byte
+}
+
+func newCookieKey(secret string) cookieKey {
+	return cookieKey{
+		signing:    deriveCookieKey(secret, "uids-cookie-signing"),
+		encryption: deriveCookieKey(secret, "uids-cookie-encryption"),
+	}
+}
+
+func deriveCookieKey(secret string, purpose string) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(purpose))
+	return mac.Sum(nil)
+}
+
+func (key cookieKey) sign(value string) string {
+	mac := hmac.New(sha256.New, key.signing)
+	mac.Write([]byte(value))
+	return cookieEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func (key cookieKey) verify(value string, signature string) bool {
+	expected, err := cookieEncoding.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, key.signing)
+	mac.Write([]byte(value))
+	return hmac.Equal(mac.Sum(nil), expected)
+}
+
+func (key cookieKey) gcm() (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key.encryption)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func (key cookieKey) encrypt(plaintext []byte) ([]byte, error) {
+	aead, err := key.gcm()
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func (key cookieKey) decrypt(ciphertext []byte) ([]byte, error) {
+	aead, err := key.gcm()
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, errors.New("encrypted cookie is too short")
+	}
+	nonce, sealed := ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():]
+	return aead.Open(nil, nonce, sealed, nil)
+}
+
+// encodeCookieValue turns the cookie's JSON into the value of the uids cookie.
+// If the host hasn't configured any keys, this is the legacy base64 encoding.
+func encodeCookieValue(j []byte, security *config.CookieSecurity) (string, error) {
+	if len(security.Keys) == 0 {
+		return base64.URLEncoding.EncodeToString(j), nil
+	}
+	key := newCookieKey(security.Keys[0])
+	prefix := signedCookiePrefix
+	payload := j
+	if security.Encrypt {
+		var err error
+		if payload, err = key.encrypt(j); err != nil {
+			return "", err
+		}
+		prefix = encryptedCookiePrefix
+	}
+	signed := prefix + cookieEncoding.EncodeToString(payload)
+	return signed + "." + key.sign(signed), nil
+}
+
+// decodeCookieValue returns the cookie's JSON from the value of the uids cookie.
+// It returns an error if the value wasn't signed by one of the host's keys, unless it's a legacy cookie
+// and the host still accepts those.
+func decodeCookieValue(value string, security *config.CookieSecurity, legacyAllowed bool) ([]byte, error) {
+	isSigned := strings.HasPrefix(value, signedCookiePrefix)
+	isEncrypted := strings.HasPrefix(value, encryptedCookiePrefix)
+	if !isSigned && !isEncrypted {
+		if !legacyAllowed {
+			return nil, errors.New("unsigned uids cookies are not accepted")
+		}
+		return base64.URLEncoding.DecodeString(value)
+	}
+
+	separator := strings.LastIndex(value, ".")
+	signed, signature := value[:separator], value[separator+1:]
+	for _, secret := range security.Keys {
+		key := newCookieKey(secret)
+		if !key.verify(signed, signature) {
+			continue
+		}
+		payload, err := cookieEncoding.DecodeString(signed[len(signedCookiePrefix):])
+		if err != nil {
+			return nil, err
+		}
+		if isEncrypted {
+			return key.decrypt(payload)
+		}
+		return payload, nil
+	}
+	return nil, errors.New("the uids cookie signature is invalid")
+}
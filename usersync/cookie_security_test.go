@@ -0,0 +1,105 @@
+package usersync
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prebid/prebid-server/config"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testCookieKey    = "0123456789abcdef0123456789abcdef"
+	testCookieOldKey = "fedcba9876543210fedcba9876543210"
+)
+
+var testCookieJSON = []byte(`{"tempUIDs":{"adnxs":{"uid":"123"}}}`)
+
+func TestSignedCookieRoundTrip(t *testing.T) {
+	security := &config.CookieSecurity{Keys: []string{testCookieKey}}
+	value, err := encodeCookieValue(testCookieJSON, security)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(value, signedCookiePrefix))
+
+	decoded, err := decodeCookieValue(value, security, false)
+	assert.NoError(t, err)
+	assert.Equal(t, testCookieJSON, decoded)
+}
+
+func TestEncryptedCookieRoundTrip(t *testing.T) {
+	security := &config.CookieSecurity{Keys: []string{testCookieKey}, Encrypt: true}
+	value, err := encodeCookieValue(testCookieJSON, security)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(value, encryptedCookiePrefix))
+	assert.NotContains(t, value, cookieEncoding.EncodeToString(testCookieJSON), "the payload should not be readable")
+
+	decoded, err := decodeCookieValue(value, security, false)
+	assert.NoError(t, err)
+	assert.Equal(t, testCookieJSON, decoded)
+}
+
+func TestCookieKeyRotation(t *testing.T) {
+	for _, encrypt := range []bool{false, true} {
+		old := &config.CookieSecurity{Keys: []string{testCookieOldKey}, Encrypt: encrypt}
+		value, err := encodeCookieValue(testCookieJSON, old)
+		assert.NoError(t, err)
+
+		rotated := &config.CookieSecurity{Keys: []string{testCookieKey, testCookieOldKey}, Encrypt: encrypt}
+		decoded, err := decodeCookieValue(value, rotated, false)
+		assert.NoError(t, err, "cookies signed with an older key should still be read")
+		assert.Equal(t, testCookieJSON, decoded)
+
+		retired := &config.CookieSecurity{Keys: []string{testCookieKey}, Encrypt: encrypt}
+		_, err = decodeCookieValue(value, retired, false)
+		assert.Error(t, err, "cookies signed with a removed key should be rejected")
+	}
+}
+
+func TestForgedCookies(t *testing.T) {
+	security := &config.CookieSecurity{Keys: []string{testCookieKey}}
+	value, err := encodeCookieValue(testCookieJSON, security)
+	assert.NoError(t, err)
+	separator := strings.LastIndex(value, ".")
+
+	forgedPayload := signedCookiePrefix + cookieEncoding.EncodeToString([]byte(`{"tempUIDs":{"adnxs":{"uid":"evil"}}}`)) + value[separator:]
+	_, err = decodeCookieValue(forgedPayload, security, false)
+	assert.Error(t, err, "a changed payload should fail the signature check")
+
+	_, err = decodeCookieValue(value[:separator]+".bm90LWEtc2lnbmF0dXJl", security, false)
+	assert.Error(t, err, "a changed signature should fail the signature check")
+
+	_, err = decodeCookieValue(value[:separator]+".!!!", security, false)
+	assert.Error(t, err, "a malformed signature should fail the signature check")
+}
+
+func TestLegacyCookies(t *testing.T) {
+	legacy := base64.URLEncoding.EncodeToString(testCookieJSON)
+	security := &config.CookieSecurity{Keys: []string{testCookieKey}}
+
+	_, err := decodeCookieValue(legacy, security, false)
+	assert.Error(t, err, "unsigned cookies should be rejected outside the migration window")
+
+	decoded, err := decodeCookieValue(legacy, security, true)
+	assert.NoError(t, err, "unsigned cookies should be accepted during the migration window")
+	assert.Equal(t, testCookieJSON, decoded)
+
+	unsecured := &config.CookieSecurity{}
+	value, err := encodeCookieValue(testCookieJSON, unsecured)
+	assert.NoError(t, err)
+	assert.Equal(t, legacy, value, "hosts without keys should write legacy cookies")
+}
+
+func TestParseUnsignedCookieFromRequest(t *testing.T) {
+	legacy := base64.URLEncoding.EncodeToString([]byte(`{"tempUIDs":{"adnxs":{"uid":"123","expires":"2100-01-01T00:00:00Z"}}}`))
+	req := httptest.NewRequest("GET", "http://www.prebid.com", nil)
+	req.AddCookie(&http.Cookie{Name: UID_COOKIE_NAME, Value: legacy})
+
+	signed := &config.HostCookie{Security: config.CookieSecurity{Keys: []string{testCookieKey}}}
+	assert.Equal(t, 0, ParsePBSCookieFromRequest(req, signed).LiveSyncCount(), "unsigned cookies should be treated as empty")
+
+	signed.Security.AcceptLegacyUntil = "2100-01-01T00:00:00Z"
+	assert.Equal(t, 1, ParsePBSCookieFromRequest(req, signed).LiveSyncCount(), "unsigned cookies should be read during the migration window")
+}
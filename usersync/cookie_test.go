@@ -353,7 +353,7 @@ func newSampleCookie() *PBSCookie {
 
 func writeThenRead(cookie *PBSCookie) *PBSCookie {
 	w := httptest.NewRecorder()
-	cookie.SetCookieOnResponse(w, "mock-domain", 90*24*time.Hour)
+	cookie.SetCookieOnResponse(w, &config.HostCookie{Domain: "mock-domain"}, 90*24*time.Hour)
 	writtenCookie := w.HeaderMap.Get("Set-Cookie")
 
 	header := http.Header{}
@@ -361,3 +361,71 @@ func writeThenRead(cookie *PBSCookie) *PBSCookie {
 	request := http.Request{Header: header}
 	return ParsePBSCookieFromRequest(&request, &config.HostCookie{})
 }
+
+func TestEvictionOrder(t *testing.T) {
+	now := time.Now()
+	cookie := &PBSCookie{
+		uids: map[string]uidWithExpiry{
+			"newest":  {UID: "1", Expires: now.Add(time.Hour)},
+			"expired": {UID: "2", Expires: now.Add(-time.Hour)},
+			"b-old":   {UID: "3", Expires: now},
+			"a-old":   {UID: "4", Expires: now},
+		},
+		birthday: timestamp(),
+	}
+	assert.Equal(t, []string{"expired", "a-old", "b-old", "newest"}, cookie.evictionOrder(nil))
+}
+
+func TestEvictionOrderPriority(t *testing.T) {
+	now := time.Now()
+	cookie := &PBSCookie{
+		uids: map[string]uidWithExpiry{
+			"expired":        {UID: "1", Expires: now.Add(-time.Hour)},
+			"unlisted-late":  {UID: "2", Expires: now.Add(3 * time.Hour)},
+			"unlisted-early": {UID: "3", Expires: now.Add(time.Hour)},
+			"old":            {UID: "4", Expires: now.Add(time.Hour)},
+			"newest":         {UID: "5", Expires: now.Add(2 * time.Hour)},
+			"important":      {UID: "6", Expires: now.Add(time.Hour)},
+		},
+		birthday: timestamp(),
+	}
+	priority := []string{"important", "newest", "old", "expired"}
+	assert.Equal(t, []string{"expired", "unlisted-early", "unlisted-late", "old", "newest", "important"}, cookie.evictionOrder(priority),
+		"Expired UIDs should go first, then unlisted families by expiry, then the listed ones from the end of the list")
+}
+
+func TestSetCookieOnResponseMaxSize(t *testing.T) {
+	cookie := newSampleCookie()
+	cookie.TrySync("pulsepoint", "a-much-longer-user-id-than-the-others")
+
+	unlimited := httptest.NewRecorder()
+	evicted, err := cookie.SetCookieOnResponse(unlimited, &config.HostCookie{}, 90*24*time.Hour)
+	assert.NoError(t, err)
+	assert.Empty(t, evicted)
+	fullSize := len(unlimited.Header().Get("Set-Cookie"))
+
+	capped := httptest.NewRecorder()
+	evicted, err = cookie.SetCookieOnResponse(capped, &config.HostCookie{MaxCookieSizeBytes: fullSize - 1}, 90*24*time.Hour)
+	assert.NoError(t, err)
+	assert.Len(t, evicted, 1, "Only one UID should be evicted to get under the cap")
+	assert.NotContains(t, evicted, "pulsepoint", "The most recent sync should be evicted last")
+	assert.True(t, len(capped.Header().Get("Set-Cookie")) <= fullSize-1)
+	assert.Equal(t, 2, cookie.LiveSyncCount())
+}
+
+func TestSetCookieOnResponseEvictionPriority(t *testing.T) {
+	cookie := newSampleCookie()
+	cookie.TrySync("pulsepoint", "a-much-longer-user-id-than-the-others")
+
+	unlimited := httptest.NewRecorder()
+	cookie.SetCookieOnResponse(unlimited, &config.HostCookie{}, 90*24*time.Hour)
+	fullSize := len(unlimited.Header().Get("Set-Cookie"))
+
+	capped := httptest.NewRecorder()
+	evicted, err := cookie.SetCookieOnResponse(capped, &config.HostCookie{
+		MaxCookieSizeBytes: fullSize - 1,
+		EvictionPriority:   []string{"pulsepoint", "adnxs"},
+	}, 90*24*time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"rubicon"}, evicted, "Families outside the priority list should be evicted first")
+}
@@ -0,0 +1,176 @@
+package usersync
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/buger/jsonparser"
+	"github.com/golang/glog"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/usersync/uidstore"
+)
+
+// maxFirstPartyIDLength caps the IDs which are used as store keys, so that callers can't fill the store with huge keys.
+const maxFirstPartyIDLength = 256
+
+// firstPartyIDPrefix marks the IDs which PBS issues. They look like "{prefix}{random}.{signature}",
+// where both parts are unpadded base64-URL and the signature is an HMAC-SHA256 of everything before the last ".".
+const firstPartyIDPrefix = "f1."
+
+// UIDStore keeps users' UIDs on the server instead of in the uids cookie. Each user is keyed by a first-party ID
+// which PBS issues them. The IDs are signed with the host's cookie keys, so callers can't pick someone else's ID
+// and read or overwrite their UIDs. If the host trusts publisher IDs, the publisher's own unsigned IDs are used
+// as keys too. The PBSCookie which it loads is used as the IdFetcher for auctions.
+//
+// A nil *UIDStore is valid. It means the host didn't enable the store, so only the uids cookie is used.
+type UIDStore struct {
+	store uidstore.Store
+	cfg   *config.UserIDStore
+	// keys sign the first-party IDs. New IDs are signed with the first one, and any of them is accepted.
+	keys [][]byte
+}
+
+// NewUIDStore returns a UIDStore which saves UIDs in the given store. The first-party IDs are signed with
+// the keys in security.
+func NewUIDStore(store uidstore.Store, cfg *config.UserIDStore, security *config.CookieSecurity) *UIDStore {
+	keys := make([][]byte, 0, len(security.Keys))
+	for _, secret := range security.Keys {
+		keys = append(keys, deriveCookieKey(secret, "uid-store-id-signing"))
+	}
+	return &UIDStore{
+		store: store,
+		cfg:   cfg,
+		keys:  keys,
+	}
+}
+
+// FirstPartyIDFromUser returns the first-party ID from request.user.ext, or "" if there isn't one.
+func (s *UIDStore) FirstPartyIDFromUser(user *openrtb.User) string {
+	if s == nil || user == nil || s.cfg.ExtField == "" {
+		return ""
+	}
+	fpid, _ := jsonparser.GetString(user.Ext, s.cfg.ExtField)
+	return fpid
+}
+
+// FirstPartyIDFromRequest returns the user's first-party ID, or "" if they don't have a valid one.
+// fpid is used if it isn't empty, and the store's cookie otherwise. IDs which PBS didn't issue are ignored,
+// unless the host trusts publisher IDs.
+func (s *UIDStore) FirstPartyIDFromRequest(r *http.Request, fpid string) string {
+	if s == nil {
+		return ""
+	}
+	if fpid == "" {
+		if fpidCookie, err := r.Cookie(s.cfg.CookieName); err == nil {
+			fpid = fpidCookie.Value
+		}
+	}
+	if !s.verify(fpid) {
+		return ""
+	}
+	return fpid
+}
+
+// ParsePBSCookieFromRequest returns the user's UIDs and the first-party ID which they should be saved under.
+//
+// The first-party ID comes from FirstPartyIDFromRequest. The UIDs from the store are merged into the ones from
+// the uids cookie. If both have a UID for the same family, the one which expires last is used. This way, UIDs
+// which were only in the cookie carry over into the store on the next Save.
+//
+// If the user has no valid first-party ID, or the store can't be used, this returns the uids cookie and
+// an empty first-party ID.
+func (s *UIDStore) ParsePBSCookieFromRequest(ctx context.Context, r *http.Request, hostCookie *config.HostCookie, fpid string) (*PBSCookie, string) {
+	cookie := ParsePBSCookieFromRequest(r, hostCookie)
+	if s == nil || !cookie.AllowSyncs() {
+		return cookie, ""
+	}
+	fpid = s.FirstPartyIDFromRequest(r, fpid)
+	if fpid == "" {
+		return cookie, ""
+	}
+
+	j, err := s.store.Get(ctx, fpid)
+	if err == uidstore.ErrNotFound {
+		return cookie, fpid
+	}
+	if err != nil {
+		glog.Errorf("Failed to read from the user ID store: %v", err)
+		return cookie, ""
+	}
+	stored := NewPBSCookie()
+	if err := jsoniter.Unmarshal(j, stored); err != nil {
+		glog.Errorf("Corrupt data in the user ID store: %v", err)
+		return cookie, fpid
+	}
+	for familyName, uid := range stored.uids {
+		if existing, ok := cookie.uids[familyName]; !ok || uid.Expires.After(existing.Expires) {
+			cookie.uids[familyName] = uid
+		}
+	}
+	return cookie, fpid
+}
+
+// IssueFirstPartyID makes a new first-party ID, and saves it in the store's cookie on the response.
+// The cookie lasts as long as the ttl.
+func (s *UIDStore) IssueFirstPartyID(w http.ResponseWriter, hostCookie *config.HostCookie, ttl time.Duration) (string, error) {
+	if len(s.keys) == 0 {
+		return "", errors.New("the user ID store has no keys to sign first-party IDs with")
+	}
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	unsigned := firstPartyIDPrefix + cookieEncoding.EncodeToString(random)
+	fpid := unsigned + "." + s.sign(s.keys[0], unsigned)
+	http.SetCookie(w, &http.Cookie{
+		Name:    s.cfg.CookieName,
+		Value:   fpid,
+		Domain:  hostCookie.Domain,
+		Path:    "/",
+		Expires: time.Now().Add(ttl),
+	})
+	return fpid, nil
+}
+
+func (s *UIDStore) sign(key []byte, value string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(value))
+	return cookieEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// verify is true if fpid is an ID which PBS issued and signed with one of the host's keys.
+// If the host trusts publisher IDs, any other non-empty ID is valid too. IDs with PBS's prefix must still be signed,
+// so that publisher IDs can't be used to reach the UIDs of users who PBS issued IDs to.
+func (s *UIDStore) verify(fpid string) bool {
+	if fpid == "" || len(fpid) > maxFirstPartyIDLength {
+		return false
+	}
+	if !strings.HasPrefix(fpid, firstPartyIDPrefix) {
+		return s.cfg.TrustPublisherIDs
+	}
+	separator := strings.LastIndex(fpid, ".")
+	unsigned, signature := fpid[:separator], fpid[separator+1:]
+	for _, key := range s.keys {
+		if hmac.Equal([]byte(s.sign(key, unsigned)), []byte(signature)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Save stores the cookie's UIDs under the first-party ID. They expire after the ttl.
+func (s *UIDStore) Save(ctx context.Context, fpid string, cookie *PBSCookie, ttl time.Duration) error {
+	j, err := jsoniter.Marshal(cookie)
+	if err != nil {
+		return err
+	}
+	return s.store.Put(ctx, fpid, j, ttl)
+}
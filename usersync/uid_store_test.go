@@ -0,0 +1,196 @@
+package usersync
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mxmCherry/openrtb"
+	"github.com/prebid/prebid-server/config"
+	"github.com/prebid/prebid-server/usersync/uidstore"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testStoreKey    = "0123456789abcdef0123456789abcdef"
+	testOldStoreKey = "fedcba9876543210fedcba9876543210"
+)
+
+// newTestUIDStore returns a UIDStore, and a first-party ID which it issued with UIDs for adnxs in the store.
+func newTestUIDStore(t *testing.T) (*UIDStore, string) {
+	uidStore := NewUIDStore(uidstore.NewMemoryStore(512*1024), &config.UserIDStore{CookieName: "fpid", ExtField: "fpid"}, &config.CookieSecurity{
+		Keys: []string{testStoreKey},
+	})
+	fpid := issueTestID(t, uidStore)
+	stored := `{"tempUIDs":{"adnxs":{"uid":"stored-id","expires":"2100-01-01T00:00:00Z"}}}`
+	assert.NoError(t, uidStore.store.Put(context.Background(), fpid, []byte(stored), time.Hour))
+	return uidStore, fpid
+}
+
+func issueTestID(t *testing.T, uidStore *UIDStore) string {
+	fpid, err := uidStore.IssueFirstPartyID(httptest.NewRecorder(), &config.HostCookie{}, time.Hour)
+	assert.NoError(t, err)
+	return fpid
+}
+
+func TestNilUIDStore(t *testing.T) {
+	var uidStore *UIDStore
+	req := httptest.NewRequest("GET", "http://www.prebid.com", nil)
+	req.AddCookie(&http.Cookie{Name: "fpid", Value: "user-1"})
+
+	cookie, fpid := uidStore.ParsePBSCookieFromRequest(context.Background(), req, &config.HostCookie{}, "")
+	assert.Equal(t, "", fpid)
+	assert.Equal(t, 0, cookie.LiveSyncCount())
+	assert.Equal(t, "", uidStore.FirstPartyIDFromUser(&openrtb.User{Ext: []byte(`{"fpid":"user-1"}`)}))
+	assert.Equal(t, "", uidStore.FirstPartyIDFromRequest(req, ""))
+}
+
+func TestUIDStoreReadsByCookie(t *testing.T) {
+	uidStore, storedID := newTestUIDStore(t)
+	req := httptest.NewRequest("GET", "http://www.prebid.com", nil)
+	req.AddCookie(&http.Cookie{Name: "fpid", Value: storedID})
+
+	cookie, fpid := uidStore.ParsePBSCookieFromRequest(context.Background(), req, &config.HostCookie{}, "")
+	assert.Equal(t, storedID, fpid)
+	uid, _, _ := cookie.GetUID("adnxs")
+	assert.Equal(t, "stored-id", uid)
+}
+
+func TestUIDStoreReadsByUserExt(t *testing.T) {
+	uidStore, storedID := newTestUIDStore(t)
+	req := httptest.NewRequest("POST", "http://www.prebid.com", nil)
+	req.AddCookie(&http.Cookie{Name: "fpid", Value: issueTestID(t, uidStore)})
+
+	fpid := uidStore.FirstPartyIDFromUser(&openrtb.User{Ext: []byte(`{"fpid":"` + storedID + `"}`)})
+	cookie, fpid := uidStore.ParsePBSCookieFromRequest(context.Background(), req, &config.HostCookie{}, fpid)
+	assert.Equal(t, storedID, fpid, "the ID from user.ext should win over the cookie")
+	uid, _, _ := cookie.GetUID("adnxs")
+	assert.Equal(t, "stored-id", uid)
+}
+
+func TestUIDStoreRejectsUnsignedIDs(t *testing.T) {
+	uidStore, storedID := newTestUIDStore(t)
+	otherStore := NewUIDStore(uidStore.store, uidStore.cfg, &config.CookieSecurity{Keys: []string{testOldStoreKey}})
+	stored := `{"tempUIDs":{"adnxs":{"uid":"stored-id","expires":"2100-01-01T00:00:00Z"}}}`
+	assert.NoError(t, uidStore.store.Put(context.Background(), "user-1", []byte(stored), time.Hour))
+
+	badIDs := map[string]string{
+		"unsigned":            "user-1",
+		"forged signature":    storedID[:strings.LastIndex(storedID, ".")+1] + "AAAA",
+		"signed by other key": issueTestID(t, otherStore),
+		"no signature":        firstPartyIDPrefix + "abc",
+	}
+	for description, badID := range badIDs {
+		req := httptest.NewRequest("GET", "http://www.prebid.com", nil)
+		cookie, fpid := uidStore.ParsePBSCookieFromRequest(context.Background(), req, &config.HostCookie{}, badID)
+		assert.Equal(t, "", fpid, description)
+		assert.Equal(t, 0, cookie.LiveSyncCount(), description)
+	}
+}
+
+func TestUIDStoreTrustsPublisherIDs(t *testing.T) {
+	uidStore, storedID := newTestUIDStore(t)
+	trusting := NewUIDStore(uidStore.store, &config.UserIDStore{CookieName: "fpid", ExtField: "fpid", TrustPublisherIDs: true}, &config.CookieSecurity{Keys: []string{testStoreKey}})
+	stored := `{"tempUIDs":{"adnxs":{"uid":"publisher-user-id","expires":"2100-01-01T00:00:00Z"}}}`
+	assert.NoError(t, uidStore.store.Put(context.Background(), "user-1", []byte(stored), time.Hour))
+
+	fpid := trusting.FirstPartyIDFromUser(&openrtb.User{Ext: []byte(`{"fpid":"user-1"}`)})
+	cookie, fpid := trusting.ParsePBSCookieFromRequest(context.Background(), httptest.NewRequest("POST", "http://www.prebid.com", nil), &config.HostCookie{}, fpid)
+	assert.Equal(t, "user-1", fpid, "publisher IDs from user.ext should be used as-is")
+	uid, _, _ := cookie.GetUID("adnxs")
+	assert.Equal(t, "publisher-user-id", uid)
+
+	req := httptest.NewRequest("GET", "http://www.prebid.com", nil)
+	req.AddCookie(&http.Cookie{Name: "fpid", Value: "user-1"})
+	assert.Equal(t, "user-1", trusting.FirstPartyIDFromRequest(req, ""), "publisher IDs from the cookie should be used as-is")
+
+	assert.Equal(t, storedID, trusting.FirstPartyIDFromRequest(req, storedID), "IDs which PBS issued should still be accepted")
+	forged := storedID[:strings.LastIndex(storedID, ".")+1] + "AAAA"
+	assert.Equal(t, "", trusting.FirstPartyIDFromRequest(req, forged), "IDs with PBS's prefix should still need a valid signature")
+}
+
+func TestUIDStoreKeyRotation(t *testing.T) {
+	oldStore := NewUIDStore(uidstore.NewMemoryStore(512*1024), &config.UserIDStore{CookieName: "fpid"}, &config.CookieSecurity{
+		Keys: []string{testOldStoreKey},
+	})
+	oldID := issueTestID(t, oldStore)
+
+	rotated := NewUIDStore(oldStore.store, oldStore.cfg, &config.CookieSecurity{Keys: []string{testStoreKey, testOldStoreKey}})
+	req := httptest.NewRequest("GET", "http://www.prebid.com", nil)
+	assert.Equal(t, oldID, rotated.FirstPartyIDFromRequest(req, oldID), "IDs signed with older keys should still be accepted")
+}
+
+func TestUIDStoreMergesCookie(t *testing.T) {
+	uidStore, storedID := newTestUIDStore(t)
+	req := httptest.NewRequest("GET", "http://www.prebid.com", nil)
+	req.AddCookie(&http.Cookie{Name: "fpid", Value: storedID})
+	uids := base64.URLEncoding.EncodeToString([]byte(`{"tempUIDs":{` +
+		`"adnxs":{"uid":"older-cookie-id","expires":"2099-01-01T00:00:00Z"},` +
+		`"rubicon":{"uid":"cookie-id","expires":"2100-01-01T00:00:00Z"}}}`))
+	req.AddCookie(&http.Cookie{Name: UID_COOKIE_NAME, Value: uids})
+
+	cookie, _ := uidStore.ParsePBSCookieFromRequest(context.Background(), req, &config.HostCookie{}, "")
+	uid, _, _ := cookie.GetUID("adnxs")
+	assert.Equal(t, "stored-id", uid, "the UID which expires last should win")
+	uid, _, _ = cookie.GetUID("rubicon")
+	assert.Equal(t, "cookie-id", uid, "UIDs which are only in the cookie should be kept")
+}
+
+func TestUIDStoreNewUser(t *testing.T) {
+	uidStore, _ := newTestUIDStore(t)
+	newID := issueTestID(t, uidStore)
+	req := httptest.NewRequest("GET", "http://www.prebid.com", nil)
+	req.AddCookie(&http.Cookie{Name: "fpid", Value: newID})
+	uids := base64.URLEncoding.EncodeToString([]byte(`{"tempUIDs":{"rubicon":{"uid":"cookie-id","expires":"2100-01-01T00:00:00Z"}}}`))
+	req.AddCookie(&http.Cookie{Name: UID_COOKIE_NAME, Value: uids})
+
+	cookie, fpid := uidStore.ParsePBSCookieFromRequest(context.Background(), req, &config.HostCookie{}, "")
+	assert.Equal(t, newID, fpid)
+	uid, _, _ := cookie.GetUID("rubicon")
+	assert.Equal(t, "cookie-id", uid, "UIDs from the cookie should carry over for users who aren't in the store yet")
+}
+
+func TestUIDStoreIgnoresLongIDs(t *testing.T) {
+	uidStore, _ := newTestUIDStore(t)
+	req := httptest.NewRequest("GET", "http://www.prebid.com", nil)
+
+	_, fpid := uidStore.ParsePBSCookieFromRequest(context.Background(), req, &config.HostCookie{}, strings.Repeat("a", maxFirstPartyIDLength+1))
+	assert.Equal(t, "", fpid)
+}
+
+func TestUIDStoreOptOut(t *testing.T) {
+	uidStore, storedID := newTestUIDStore(t)
+	req := httptest.NewRequest("GET", "http://www.prebid.com", nil)
+	req.AddCookie(&http.Cookie{Name: "fpid", Value: storedID})
+	req.AddCookie(&http.Cookie{Name: "optout", Value: "true"})
+	hostCookie := &config.HostCookie{OptOutCookie: config.Cookie{Name: "optout", Value: "true"}}
+
+	cookie, fpid := uidStore.ParsePBSCookieFromRequest(context.Background(), req, hostCookie, "")
+	assert.Equal(t, "", fpid)
+	assert.False(t, cookie.AllowSyncs(), "users who opted out should not be read from the store")
+}
+
+func TestIssueFirstPartyID(t *testing.T) {
+	uidStore, _ := newTestUIDStore(t)
+	w := httptest.NewRecorder()
+	fpid, err := uidStore.IssueFirstPartyID(w, &config.HostCookie{Domain: "prebid.com"}, time.Hour)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(fpid, firstPartyIDPrefix))
+	assert.NotEqual(t, fpid, issueTestID(t, uidStore), "every user should get their own ID")
+
+	setCookie := w.Header().Get("Set-Cookie")
+	assert.Contains(t, setCookie, "fpid="+fpid)
+	assert.Contains(t, setCookie, "Domain=prebid.com")
+
+	req := httptest.NewRequest("GET", "http://www.prebid.com", nil)
+	req.AddCookie(&http.Cookie{Name: "fpid", Value: fpid})
+	assert.Equal(t, fpid, uidStore.FirstPartyIDFromRequest(req, ""))
+
+	noKeys := NewUIDStore(uidStore.store, uidStore.cfg, &config.CookieSecurity{})
+	_, err = noKeys.IssueFirstPartyID(httptest.NewRecorder(), &config.HostCookie{}, time.Hour)
+	assert.Error(t, err)
+}
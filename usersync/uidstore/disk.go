@@ -0,0 +1,323 @@
+package uidstore
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+// The disk store is an append-only log of records. Each record looks like:
+//
+//	crc32 (4 bytes) | key length (4 bytes) | value length (4 bytes) | expiry unix seconds (8 bytes) | key | value
+//
+// The checksum covers everything after itself. Newer records for a key replace older ones.
+// An index of where each key's latest value lives is kept in memory, so each Get is a single read.
+const recordHeaderSize = 20
+
+// compactionThreshold is the least number of bytes of replaced records which will trigger a compaction.
+// Compactions also wait until at least half of the file is garbage. Expired records are dropped by compactions too.
+//
+// Compactions triggered by Put run in the background, so that they don't hold up the request which wrote the record.
+const compactionThreshold = 1024 * 1024
+
+// OpenDiskStore opens the Store saved at path, or creates it if the file doesn't exist.
+//
+// If the last record in the file was only partly written (e.g. because the process crashed), it's discarded.
+func OpenDiskStore(path string) (Store, error) {
+	s := &diskStore{path: path}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	if s.shouldCompact() {
+		if err := s.compact(); err != nil {
+			glog.Errorf("Failed to compact the user ID store. It will be retried after the next write: %v", err)
+		}
+	}
+	return s, nil
+}
+
+type diskStore struct {
+	path string
+
+	lock  sync.RWMutex
+	file  *os.File
+	index map[string]diskEntry
+	// size is the length of the file. New records are written here.
+	size int64
+	// garbage counts the bytes of records which have been replaced.
+	garbage int64
+	// compacting is true while a background compaction is running.
+	compacting bool
+	closed     bool
+}
+
+// diskEntry tells where the latest value for a key lives in the file.
+type diskEntry struct {
+	recordOffset int64
+	recordSize   int64
+	valueOffset  int64
+	valueSize    int
+	expires      int64
+}
+
+func (entry diskEntry) expired(now time.Time) bool {
+	return entry.expires != 0 && now.Unix() >= entry.expires
+}
+
+func (s *diskStore) Get(ctx context.Context, key string) ([]byte, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	entry, ok := s.index[key]
+	if !ok || entry.expired(time.Now()) {
+		return nil, ErrNotFound
+	}
+	value := make([]byte, entry.valueSize)
+	if _, err := s.file.ReadAt(value, entry.valueOffset); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+func (s *diskStore) Put(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if len(key)+len(value) > maxRecordBodySize {
+		return errors.New("the value is too big for the user ID store")
+	}
+	var expires int64
+	if ttl > 0 {
+		expires = time.Now().Add(ttl).Unix()
+	}
+	record := encodeRecord(key, value, expires)
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if _, err := s.file.WriteAt(record, s.size); err != nil {
+		return err
+	}
+	s.addToIndex(key, s.size, len(value), expires)
+	if s.shouldCompact() && !s.compacting {
+		s.compacting = true
+		go s.compactInBackground()
+	}
+	return nil
+}
+
+func (s *diskStore) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.closed = true
+	return s.file.Close()
+}
+
+// open reads the file at s.path and builds the index. The caller must hold the write lock, or own s exclusively.
+func (s *diskStore) open() error {
+	file, err := os.OpenFile(s.path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	s.file = file
+	s.index = make(map[string]diskEntry)
+	s.size = 0
+	s.garbage = 0
+
+	reader := bufio.NewReader(file)
+	for {
+		key, valueSize, expires, err := readRecord(reader)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			// The rest of the file can't be trusted, so it gets overwritten by the next Put.
+			return file.Truncate(s.size)
+		}
+		s.addToIndex(key, s.size, valueSize, expires)
+	}
+}
+
+// addToIndex records a record which was written at offset, and moves s.size past it.
+func (s *diskStore) addToIndex(key string, offset int64, valueSize int, expires int64) {
+	if old, ok := s.index[key]; ok {
+		s.garbage += old.recordSize
+	}
+	recordSize := int64(recordHeaderSize + len(key) + valueSize)
+	s.index[key] = diskEntry{
+		recordOffset: offset,
+		recordSize:   recordSize,
+		valueOffset:  offset + int64(recordHeaderSize+len(key)),
+		valueSize:    valueSize,
+		expires:      expires,
+	}
+	s.size = offset + recordSize
+}
+
+func (s *diskStore) shouldCompact() bool {
+	return s.garbage >= compactionThreshold && s.garbage*2 >= s.size
+}
+
+func (s *diskStore) compactInBackground() {
+	if err := s.compact(); err != nil {
+		glog.Errorf("Failed to compact the user ID store. It will be retried after the next write: %v", err)
+	}
+	s.lock.Lock()
+	s.compacting = false
+	s.lock.Unlock()
+}
+
+// compact rewrites the file with only the latest, unexpired record for each key.
+//
+// Most of the file is copied without holding the write lock, so Gets and Puts carry on while it runs. The records
+// which were written in the meantime are copied over at the end, under the write lock, and the new file replaces
+// the old one. If anything fails, the store keeps using the old file.
+func (s *diskStore) compact() error {
+	s.lock.RLock()
+	snapshot := make(map[string]diskEntry, len(s.index))
+	for key, entry := range s.index {
+		snapshot[key] = entry
+	}
+	snapshotSize := s.size
+	file := s.file
+	s.lock.RUnlock()
+
+	tempPath := s.path + ".tmp"
+	temp, err := os.OpenFile(tempPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	swapped := false
+	defer func() {
+		if !swapped {
+			temp.Close()
+			os.Remove(tempPath)
+		}
+	}()
+
+	now := time.Now()
+	moved := make(map[string]diskEntry, len(snapshot))
+	writer := bufio.NewWriter(temp)
+	var size int64
+	for key, entry := range snapshot {
+		if entry.expired(now) {
+			continue
+		}
+		record := make([]byte, entry.recordSize)
+		if _, err := file.ReadAt(record, entry.recordOffset); err != nil {
+			return err
+		}
+		if _, err := writer.Write(record); err != nil {
+			return err
+		}
+		moved[key] = entry.moveTo(size)
+		size += entry.recordSize
+	}
+	if err := writer.Flush(); err != nil {
+		return err
+	}
+	if err := temp.Sync(); err != nil {
+		return err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.closed {
+		return errors.New("the user ID store was closed")
+	}
+
+	tail := make([]byte, s.size-snapshotSize)
+	if _, err := s.file.ReadAt(tail, snapshotSize); err != nil {
+		return err
+	}
+	if _, err := temp.WriteAt(tail, size); err != nil {
+		return err
+	}
+	if err := temp.Sync(); err != nil {
+		return err
+	}
+
+	index := make(map[string]diskEntry, len(s.index))
+	var live int64
+	for key, entry := range s.index {
+		if entry.recordOffset >= snapshotSize {
+			// This was written after the snapshot, so it's in the tail.
+			entry = entry.moveTo(entry.recordOffset - snapshotSize + size)
+		} else if movedEntry, ok := moved[key]; ok {
+			entry = movedEntry
+		} else {
+			// This had expired, so it wasn't copied.
+			continue
+		}
+		index[key] = entry
+		live += entry.recordSize
+	}
+	if err := os.Rename(tempPath, s.path); err != nil {
+		return err
+	}
+
+	swapped = true
+	s.file.Close()
+	s.file = temp
+	s.index = index
+	s.size = size + int64(len(tail))
+	s.garbage = s.size - live
+	return nil
+}
+
+// moveTo returns the entry for the same record, if it were written at offset.
+func (entry diskEntry) moveTo(offset int64) diskEntry {
+	entry.valueOffset += offset - entry.recordOffset
+	entry.recordOffset = offset
+	return entry
+}
+
+func encodeRecord(key string, value []byte, expires int64) []byte {
+	record := make([]byte, recordHeaderSize+len(key)+len(value))
+	binary.BigEndian.PutUint32(record[4:8], uint32(len(key)))
+	binary.BigEndian.PutUint32(record[8:12], uint32(len(value)))
+	binary.BigEndian.PutUint64(record[12:20], uint64(expires))
+	copy(record[recordHeaderSize:], key)
+	copy(record[recordHeaderSize+len(key):], value)
+	binary.BigEndian.PutUint32(record[0:4], crc32.ChecksumIEEE(record[4:]))
+	return record
+}
+
+var errCorruptRecord = errors.New("corrupt record in the user ID store")
+
+// maxRecordBodySize guards against huge allocations if a corrupt header claims an enormous key or value.
+const maxRecordBodySize = 16 * 1024 * 1024
+
+// readRecord reads the next record, and returns its key, the size of its value and its expiry.
+// It returns io.EOF if there are no more records.
+func readRecord(reader *bufio.Reader) (string, int, int64, error) {
+	header := make([]byte, recordHeaderSize)
+	if n, err := io.ReadFull(reader, header); err != nil {
+		if err == io.EOF && n == 0 {
+			return "", 0, 0, io.EOF
+		}
+		return "", 0, 0, errCorruptRecord
+	}
+	keySize := int64(binary.BigEndian.Uint32(header[4:8]))
+	valueSize := int64(binary.BigEndian.Uint32(header[8:12]))
+	expires := int64(binary.BigEndian.Uint64(header[12:20]))
+	if keySize+valueSize > maxRecordBodySize {
+		return "", 0, 0, errCorruptRecord
+	}
+	body := make([]byte, keySize+valueSize)
+	if _, err := io.ReadFull(reader, body); err != nil {
+		return "", 0, 0, errCorruptRecord
+	}
+	checksum := crc32.NewIEEE()
+	checksum.Write(header[4:])
+	checksum.Write(body)
+	if checksum.Sum32() != binary.BigEndian.Uint32(header[0:4]) {
+		return "", 0, 0, errCorruptRecord
+	}
+	return string(body[:keySize]), int(valueSize), expires, nil
+}
@@ -0,0 +1,206 @@
+package uidstore
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiskStore(t *testing.T) {
+	path, cleanup := tempStorePath(t)
+	defer cleanup()
+
+	store, err := OpenDiskStore(path)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assertStoreWorks(t, store)
+	assert.NoError(t, store.Close())
+
+	reopened, err := OpenDiskStore(path)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer reopened.Close()
+	value, err := reopened.Get(context.Background(), "user-1")
+	assert.NoError(t, err, "values should survive a restart")
+	assert.Equal(t, "replaced", string(value))
+}
+
+func TestDiskStoreExpiry(t *testing.T) {
+	path, cleanup := tempStorePath(t)
+	defer cleanup()
+
+	expired := encodeRecord("expired", []byte("old"), time.Now().Add(-time.Second).Unix())
+	assert.NoError(t, ioutil.WriteFile(path, expired, 0600))
+
+	store, err := OpenDiskStore(path)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer store.Close()
+	_, err = store.Get(context.Background(), "expired")
+	assert.Equal(t, ErrNotFound, err)
+}
+
+func TestDiskStoreTruncatedRecord(t *testing.T) {
+	path, cleanup := tempStorePath(t)
+	defer cleanup()
+
+	store, err := OpenDiskStore(path)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NoError(t, store.Put(context.Background(), "user-1", []byte("saved"), time.Hour))
+	assert.NoError(t, store.Close())
+
+	// Simulate a crash partway through the next write.
+	partial := encodeRecord("user-2", []byte("lost"), 0)
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0600)
+	assert.NoError(t, err)
+	file.Write(partial[:len(partial)-2])
+	file.Close()
+
+	reopened, err := OpenDiskStore(path)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer reopened.Close()
+	value, err := reopened.Get(context.Background(), "user-1")
+	assert.NoError(t, err)
+	assert.Equal(t, "saved", string(value))
+	_, err = reopened.Get(context.Background(), "user-2")
+	assert.Equal(t, ErrNotFound, err)
+
+	assert.NoError(t, reopened.Put(context.Background(), "user-3", []byte("after"), time.Hour))
+	value, err = reopened.Get(context.Background(), "user-3")
+	assert.NoError(t, err, "new records should overwrite the partial one")
+	assert.Equal(t, "after", string(value))
+}
+
+func TestDiskStoreCompaction(t *testing.T) {
+	path, cleanup := tempStorePath(t)
+	defer cleanup()
+
+	opened, err := OpenDiskStore(path)
+	if !assert.NoError(t, err) {
+		return
+	}
+	store := opened.(*diskStore)
+	defer store.Close()
+
+	value := make([]byte, 1024)
+	puts := 2 * compactionThreshold / len(value)
+	for i := 0; i < puts; i++ {
+		value[0] = byte(i)
+		assert.NoError(t, store.Put(context.Background(), fmt.Sprintf("user-%d", i%10), value, time.Hour))
+	}
+	waitForCompaction(t, store)
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.True(t, info.Size() < compactionThreshold, "replaced records should be compacted away. File size: %d", info.Size())
+
+	for i := puts - 10; i < puts; i++ {
+		saved, err := store.Get(context.Background(), fmt.Sprintf("user-%d", i%10))
+		assert.NoError(t, err)
+		if assert.Len(t, saved, len(value)) {
+			assert.Equal(t, byte(i), saved[0], "the latest value should survive the compaction")
+		}
+	}
+}
+
+func TestDiskStoreCompactionKeepsRecentWrites(t *testing.T) {
+	path, cleanup := tempStorePath(t)
+	defer cleanup()
+
+	opened, err := OpenDiskStore(path)
+	if !assert.NoError(t, err) {
+		return
+	}
+	store := opened.(*diskStore)
+	defer store.Close()
+
+	assert.NoError(t, store.Put(context.Background(), "user-1", []byte("old"), time.Hour))
+	assert.NoError(t, store.Put(context.Background(), "user-2", []byte("kept"), time.Hour))
+	assert.NoError(t, store.Put(context.Background(), "expired", []byte("gone"), time.Nanosecond))
+	assert.NoError(t, store.Put(context.Background(), "user-1", []byte("new"), time.Hour))
+
+	// Writes which land while the bulk of the file is being copied go in the tail.
+	store.lock.Lock()
+	store.file.WriteAt(encodeRecord("user-3", []byte("late"), 0), store.size)
+	store.addToIndex("user-3", store.size, len("late"), 0)
+	store.lock.Unlock()
+
+	assert.NoError(t, store.compact())
+	for key, expected := range map[string]string{"user-1": "new", "user-2": "kept", "user-3": "late"} {
+		value, err := store.Get(context.Background(), key)
+		assert.NoError(t, err, key)
+		assert.Equal(t, expected, string(value), key)
+	}
+	_, err = store.Get(context.Background(), "expired")
+	assert.Equal(t, ErrNotFound, err)
+	assert.Equal(t, int64(0), store.garbage)
+
+	reopened, err := OpenDiskStore(path)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer reopened.Close()
+	value, err := reopened.Get(context.Background(), "user-3")
+	assert.NoError(t, err, "the compacted file should be the one on disk")
+	assert.Equal(t, "late", string(value))
+}
+
+func TestDiskStoreCompactionFailure(t *testing.T) {
+	path, cleanup := tempStorePath(t)
+	defer cleanup()
+
+	opened, err := OpenDiskStore(path)
+	if !assert.NoError(t, err) {
+		return
+	}
+	store := opened.(*diskStore)
+	defer store.Close()
+	assert.NoError(t, store.Put(context.Background(), "user-1", []byte("saved"), time.Hour))
+
+	// A directory in the way of the temp file makes the compaction fail.
+	assert.NoError(t, os.Mkdir(path+".tmp", 0700))
+	assert.Error(t, store.compact())
+
+	value, err := store.Get(context.Background(), "user-1")
+	assert.NoError(t, err, "the store should keep using the old file")
+	assert.Equal(t, "saved", string(value))
+	assert.NoError(t, store.Put(context.Background(), "user-2", []byte("after"), time.Hour))
+	value, err = store.Get(context.Background(), "user-2")
+	assert.NoError(t, err)
+	assert.Equal(t, "after", string(value))
+}
+
+func waitForCompaction(t *testing.T, store *diskStore) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		store.lock.RLock()
+		compacting := store.compacting
+		store.lock.RUnlock()
+		if !compacting {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("The compaction didn't finish")
+}
+
+func tempStorePath(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "uidstore")
+	if err != nil {
+		t.Fatalf("Failed to create a temp dir: %v", err)
+	}
+	return filepath.Join(dir, "uids.db"), func() { os.RemoveAll(dir) }
+}
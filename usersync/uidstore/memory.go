@@ -0,0 +1,37 @@
+package uidstore
+
+import (
+	"context"
+	"time"
+
+	"github.com/coocood/freecache"
+)
+
+// NewMemoryStore returns a Store which keeps everything in memory. Nothing survives a restart.
+//
+// Once the store uses sizeBytes, the least recently written users are evicted to make room.
+func NewMemoryStore(sizeBytes int) Store {
+	return &memoryStore{
+		cache: freecache.NewCache(sizeBytes),
+	}
+}
+
+type memoryStore struct {
+	cache *freecache.Cache
+}
+
+func (s *memoryStore) Get(ctx context.Context, key string) ([]byte, error) {
+	value, err := s.cache.Get([]byte(key))
+	if err == freecache.ErrNotFound {
+		return nil, ErrNotFound
+	}
+	return value, err
+}
+
+func (s *memoryStore) Put(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return s.cache.Set([]byte(key), value, int(ttl/time.Second))
+}
+
+func (s *memoryStore) Close() error {
+	return nil
+}
@@ -0,0 +1,36 @@
+package uidstore
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryStore(t *testing.T) {
+	store := NewMemoryStore(512 * 1024)
+	defer store.Close()
+	assertStoreWorks(t, store)
+}
+
+// assertStoreWorks runs the checks which every Store implementation should pass.
+func assertStoreWorks(t *testing.T, store Store) {
+	t.Helper()
+	ctx := context.Background()
+
+	_, err := store.Get(ctx, "missing")
+	assert.Equal(t, ErrNotFound, err)
+
+	assert.NoError(t, store.Put(ctx, "user-1", []byte("first"), time.Hour))
+	assert.NoError(t, store.Put(ctx, "user-2", []byte("second"), time.Hour))
+	assert.NoError(t, store.Put(ctx, "user-1", []byte("replaced"), time.Hour))
+
+	value, err := store.Get(ctx, "user-1")
+	assert.NoError(t, err)
+	assert.Equal(t, "replaced", string(value))
+
+	value, err = store.Get(ctx, "user-2")
+	assert.NoError(t, err)
+	assert.Equal(t, "second", string(value))
+}
@@ -0,0 +1,37 @@
+package uidstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/prebid/prebid-server/config"
+)
+
+// ErrNotFound is returned by Store.Get if nothing is stored under the key, or if it has expired.
+var ErrNotFound = errors.New("no data is stored for this key")
+
+// Store saves each user's UIDs on the server, keyed by a first-party ID which PBS issues them.
+//
+// The values are opaque to the Store. Implementations must be safe for concurrent use.
+type Store interface {
+	// Get returns the value stored under the key, or ErrNotFound.
+	Get(ctx context.Context, key string) ([]byte, error)
+	// Put stores the value under the key, replacing any older value. It expires after the ttl.
+	Put(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Close releases any resources used by the Store.
+	Close() error
+}
+
+// NewStore returns the Store configured by the host.
+func NewStore(cfg *config.UserIDStore) (Store, error) {
+	switch cfg.Backend {
+	case "memory":
+		return NewMemoryStore(cfg.Memory.SizeBytes), nil
+	case "disk":
+		return OpenDiskStore(cfg.Disk.Path)
+	default:
+		return nil, fmt.Errorf("unknown user ID store backend: %s", cfg.Backend)
+	}
+}
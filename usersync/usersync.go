@@ -9,6 +9,12 @@ type Usersyncer interface {
 	//
 	// For more information about user syncs, see http://clearcode.cc/2015/12/cookie-syncing/
 	GetUsersyncInfo(gdpr string, consent string) (*UsersyncInfo, error)
+	// GetUsersyncInfoForType is like GetUsersyncInfo, but for a specific type of sync from SyncTypes.
+	// It returns an error if the type isn't supported.
+	GetUsersyncInfoForType(syncType SyncType, gdpr string, consent string) (*UsersyncInfo, error)
+	// SyncTypes returns the types of syncs which this Usersyncer supports. The first one is preferred,
+	// and is the type which GetUsersyncInfo returns.
+	SyncTypes() []SyncType
 	// FamilyName should be the same as the `BidderName` for this Usersyncer.
 	// This function only exists for legacy reasons.
 	// TODO #362: when the appnexus usersyncer is consistent, delete this and use the key
@@ -26,6 +32,14 @@ type Usersyncer interface {
 	GDPRVendorID() uint16
 }
 
+// SyncType is the way which the browser should run a user sync.
+type SyncType string
+
+const (
+	SyncTypeRedirect SyncType = "redirect"
+	SyncTypeIframe   SyncType = "iframe"
+)
+
 type UsersyncInfo struct {
 	URL         string `json:"url,omitempty"`
 	Type        string `json:"type,omitempty"`
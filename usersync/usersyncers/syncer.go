@@ -7,6 +7,7 @@ import (
 	"github.com/prebid/prebid-server/adapters/gamoshi"
 
 	"github.com/golang/glog"
+	"github.com/prebid/prebid-server/adapters"
 	ttx "github.com/prebid/prebid-server/adapters/33across"
 	"github.com/prebid/prebid-server/adapters/adform"
 	"github.com/prebid/prebid-server/adapters/adkernelAdn"
@@ -43,42 +44,96 @@ import (
 func NewSyncerMap(cfg *config.Configuration) map[openrtb_ext.BidderName]usersync.Usersyncer {
 	syncers := make(map[openrtb_ext.BidderName]usersync.Usersyncer, len(cfg.Adapters))
 
-	insertIntoMap(cfg, syncers, openrtb_ext.Bidder33Across, ttx.New33AcrossSyncer)
-	insertIntoMap(cfg, syncers, openrtb_ext.BidderAdform, adform.NewAdformSyncer)
-	insertIntoMap(cfg, syncers, openrtb_ext.BidderAdkernelAdn, adkernelAdn.NewAdkernelAdnSyncer)
-	insertIntoMap(cfg, syncers, openrtb_ext.BidderAdtelligent, adtelligent.NewAdtelligentSyncer)
-	insertIntoMap(cfg, syncers, openrtb_ext.BidderAppnexus, appnexus.NewAppnexusSyncer)
-	insertIntoMap(cfg, syncers, openrtb_ext.BidderBeachfront, beachfront.NewBeachfrontSyncer)
-	insertIntoMap(cfg, syncers, openrtb_ext.BidderBrightroll, brightroll.NewBrightrollSyncer)
-	insertIntoMap(cfg, syncers, openrtb_ext.BidderConsumable, consumable.NewConsumableSyncer)
-	insertIntoMap(cfg, syncers, openrtb_ext.BidderConversant, conversant.NewConversantSyncer)
-	insertIntoMap(cfg, syncers, openrtb_ext.BidderEPlanning, eplanning.NewEPlanningSyncer)
-	insertIntoMap(cfg, syncers, openrtb_ext.BidderFacebook, audienceNetwork.NewFacebookSyncer)
-	insertIntoMap(cfg, syncers, openrtb_ext.BidderGrid, grid.NewGridSyncer)
-	insertIntoMap(cfg, syncers, openrtb_ext.BidderGumGum, gumgum.NewGumGumSyncer)
-	insertIntoMap(cfg, syncers, openrtb_ext.BidderImprovedigital, improvedigital.NewImprovedigitalSyncer)
-	insertIntoMap(cfg, syncers, openrtb_ext.BidderIx, ix.NewIxSyncer)
-	insertIntoMap(cfg, syncers, openrtb_ext.BidderLifestreet, lifestreet.NewLifestreetSyncer)
-	insertIntoMap(cfg, syncers, openrtb_ext.BidderOpenx, openx.NewOpenxSyncer)
-	insertIntoMap(cfg, syncers, openrtb_ext.BidderPubmatic, pubmatic.NewPubmaticSyncer)
-	insertIntoMap(cfg, syncers, openrtb_ext.BidderPulsepoint, pulsepoint.NewPulsepointSyncer)
-	insertIntoMap(cfg, syncers, openrtb_ext.BidderRhythmone, rhythmone.NewRhythmoneSyncer)
-	insertIntoMap(cfg, syncers, openrtb_ext.BidderRubicon, rubicon.NewRubiconSyncer)
-	insertIntoMap(cfg, syncers, openrtb_ext.BidderSomoaudience, somoaudience.NewSomoaudienceSyncer)
-	insertIntoMap(cfg, syncers, openrtb_ext.BidderSovrn, sovrn.NewSovrnSyncer)
-	insertIntoMap(cfg, syncers, openrtb_ext.BidderSonobi, sonobi.NewSonobiSyncer)
-	insertIntoMap(cfg, syncers, openrtb_ext.BidderYieldmo, yieldmo.NewYieldmoSyncer)
-	insertIntoMap(cfg, syncers, openrtb_ext.BidderGamoshi, gamoshi.NewGamoshiSyncer)
+	syncerFactories := map[openrtb_ext.BidderName]func(temp *template.Template) usersync.Usersyncer{
+		openrtb_ext.Bidder33Across:       ttx.New33AcrossSyncer,
+		openrtb_ext.BidderAdform:         adform.NewAdformSyncer,
+		openrtb_ext.BidderAdkernelAdn:    adkernelAdn.NewAdkernelAdnSyncer,
+		openrtb_ext.BidderAdtelligent:    adtelligent.NewAdtelligentSyncer,
+		openrtb_ext.BidderAppnexus:       appnexus.NewAppnexusSyncer,
+		openrtb_ext.BidderBeachfront:     beachfront.NewBeachfrontSyncer,
+		openrtb_ext.BidderBrightroll:     brightroll.NewBrightrollSyncer,
+		openrtb_ext.BidderConsumable:     consumable.NewConsumableSyncer,
+		openrtb_ext.BidderConversant:     conversant.NewConversantSyncer,
+		openrtb_ext.BidderEPlanning:      eplanning.NewEPlanningSyncer,
+		openrtb_ext.BidderFacebook:       audienceNetwork.NewFacebookSyncer,
+		openrtb_ext.BidderGrid:           grid.NewGridSyncer,
+		openrtb_ext.BidderGumGum:         gumgum.NewGumGumSyncer,
+		openrtb_ext.BidderImprovedigital: improvedigital.NewImprovedigitalSyncer,
+		openrtb_ext.BidderIx:             ix.NewIxSyncer,
+		openrtb_ext.BidderLifestreet:     lifestreet.NewLifestreetSyncer,
+		openrtb_ext.BidderOpenx:          openx.NewOpenxSyncer,
+		openrtb_ext.BidderPubmatic:       pubmatic.NewPubmaticSyncer,
+		openrtb_ext.BidderPulsepoint:     pulsepoint.NewPulsepointSyncer,
+		openrtb_ext.BidderRhythmone:      rhythmone.NewRhythmoneSyncer,
+		openrtb_ext.BidderRubicon:        rubicon.NewRubiconSyncer,
+		openrtb_ext.BidderSomoaudience:   somoaudience.NewSomoaudienceSyncer,
+		openrtb_ext.BidderSovrn:          sovrn.NewSovrnSyncer,
+		openrtb_ext.BidderSonobi:         sonobi.NewSonobiSyncer,
+		openrtb_ext.BidderYieldmo:        yieldmo.NewYieldmoSyncer,
+		openrtb_ext.BidderGamoshi:        gamoshi.NewGamoshiSyncer,
+	}
+	for bidder, syncerFactory := range syncerFactories {
+		insertIntoMap(cfg, syncers, bidder, syncerFactory)
+	}
+
+	// Host aliases sync with the usersync URLs in their own config, and keep their IDs in their own cookie family.
+	for name, adapterCfg := range cfg.Adapters {
+		aliasOf, ok := adapterCfg.AliasedBidder()
+		if !ok || syncerFactories[aliasOf] == nil {
+			continue
+		}
+		alias := openrtb_ext.BidderName(name)
+		insertIntoMap(cfg, syncers, alias, syncerFactories[aliasOf])
+		if syncer, ok := syncers[alias]; ok {
+			syncers[alias] = &aliasSyncer{
+				Usersyncer:   syncer,
+				familyName:   name,
+				gdprVendorID: adapterCfg.GDPRVendorID,
+			}
+		}
+	}
 
 	return syncers
 }
 
+// aliasSyncer is the Usersyncer of a host alias. It syncs like the Usersyncer of the bidder it's an alias of,
+// but it has its own family name and, if the host configured one, its own GDPR vendor ID.
+type aliasSyncer struct {
+	usersync.Usersyncer
+	familyName   string
+	gdprVendorID uint16
+}
+
+func (s *aliasSyncer) FamilyName() string {
+	return s.familyName
+}
+
+func (s *aliasSyncer) GDPRVendorID() uint16 {
+	if s.gdprVendorID != 0 {
+		return s.gdprVendorID
+	}
+	return s.Usersyncer.GDPRVendorID()
+}
+
 func insertIntoMap(cfg *config.Configuration, syncers map[openrtb_ext.BidderName]usersync.Usersyncer, bidder openrtb_ext.BidderName, syncerFactory func(temp *template.Template) usersync.Usersyncer) {
 	lowercased := strings.ToLower(string(bidder))
-	urlString := cfg.Adapters[lowercased].UserSyncURL
+	adapterCfg := cfg.Adapters[lowercased]
+	urlString := adapterCfg.UserSyncURL
 	if urlString == "" {
 		glog.Warningf("adapters." + string(bidder) + ".usersync_url was not defined, and their usersync API isn't flexible enough for Prebid Server to choose a good default. No usersyncs will be performed with " + string(bidder))
 		return
 	}
-	syncers[bidder] = syncerFactory(template.Must(template.New(lowercased + "_usersync_url").Parse(urlString)))
+	syncer := syncerFactory(template.Must(template.New(lowercased + "_usersync_url").Parse(urlString)))
+	if multiSyncer, ok := syncer.(*adapters.Syncer); ok {
+		addSyncType(multiSyncer, adapters.SyncTypeIframe, adapterCfg.UserSyncURLIframe, lowercased+"_usersync_url_iframe")
+		addSyncType(multiSyncer, adapters.SyncTypeRedirect, adapterCfg.UserSyncURLRedirect, lowercased+"_usersync_url_redirect")
+	}
+	syncers[bidder] = syncer
+}
+
+// addSyncType adds a type of sync to the syncer, if the host configured a URL for it.
+func addSyncType(syncer *adapters.Syncer, syncType adapters.SyncType, urlString string, templateName string) {
+	if urlString != "" {
+		syncer.AddSyncType(syncType, template.Must(template.New(templateName).Parse(urlString)))
+	}
 }
@@ -6,6 +6,7 @@ import (
 
 	"github.com/prebid/prebid-server/config"
 	"github.com/prebid/prebid-server/openrtb_ext"
+	"github.com/prebid/prebid-server/usersync"
 )
 
 func TestNewSyncerMap(t *testing.T) {
@@ -55,6 +56,32 @@ func TestNewSyncerMap(t *testing.T) {
 	}
 }
 
+func TestExtraSyncTypes(t *testing.T) {
+	cfg := &config.Configuration{
+		Adapters: map[string]config.Adapter{
+			strings.ToLower(string(openrtb_ext.BidderAppnexus)): {
+				UserSyncURL:       "https://redirect.com",
+				UserSyncURLIframe: "https://iframe.com",
+			},
+			strings.ToLower(string(openrtb_ext.BidderRubicon)): {
+				UserSyncURL: "https://rubicon.com",
+			},
+		},
+	}
+	syncers := NewSyncerMap(cfg)
+
+	appnexusTypes := syncers[openrtb_ext.BidderAppnexus].SyncTypes()
+	if len(appnexusTypes) != 2 || appnexusTypes[0] != usersync.SyncTypeRedirect || appnexusTypes[1] != usersync.SyncTypeIframe {
+		t.Errorf("appnexus should support redirect and then iframe syncs. Got %v", appnexusTypes)
+	}
+	if syncInfo, err := syncers[openrtb_ext.BidderAppnexus].GetUsersyncInfoForType(usersync.SyncTypeIframe, "", ""); err != nil || syncInfo.URL != "https://iframe.com" {
+		t.Errorf("appnexus iframe sync was wrong. Got %v, %v", syncInfo, err)
+	}
+	if rubiconTypes := syncers[openrtb_ext.BidderRubicon].SyncTypes(); len(rubiconTypes) != 1 {
+		t.Errorf("rubicon should only support one type of sync. Got %v", rubiconTypes)
+	}
+}
+
 // Bidders may have an ID on the IAB-maintained global vendor list.
 // This makes sure that we don't have conflicting IDs among Bidders in our project,
 // since that's almost certainly a bug.
@@ -82,3 +109,34 @@ func assertStringsMatch(t *testing.T, expected string, actual string) {
 		t.Errorf("Expected %s, got %s", expected, actual)
 	}
 }
+
+func TestAliasSyncer(t *testing.T) {
+	cfg := &config.Configuration{
+		Adapters: map[string]config.Adapter{
+			"somealias":  {AliasOf: "appnexus", UserSyncURL: "https://some-alias.com/sync", GDPRVendorID: 12},
+			"otheralias": {AliasOf: "appnexus", UserSyncURL: "https://other-alias.com/sync"},
+			"nosync":     {AliasOf: "appnexus"},
+		},
+	}
+	syncers := NewSyncerMap(cfg)
+
+	alias := syncers["somealias"]
+	if alias == nil {
+		t.Fatal("The alias should have a syncer.")
+	}
+	if alias.FamilyName() != "somealias" {
+		t.Errorf("The alias should have its own family name. Got %s", alias.FamilyName())
+	}
+	if alias.GDPRVendorID() != 12 {
+		t.Errorf("The alias should have the vendor ID from its config. Got %d", alias.GDPRVendorID())
+	}
+	if syncInfo, err := alias.GetUsersyncInfo("", ""); err != nil || syncInfo.URL != "https://some-alias.com/sync" {
+		t.Errorf("The alias should sync with its own URL. Got %v, %v", syncInfo, err)
+	}
+	if vendorID := syncers["otheralias"].GDPRVendorID(); vendorID != 32 {
+		t.Errorf("The alias should have the aliased bidder's vendor ID by default. Got %d", vendorID)
+	}
+	if _, ok := syncers["nosync"]; ok {
+		t.Error("Aliases without a usersync URL should not have a syncer.")
+	}
+}